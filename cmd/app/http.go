@@ -14,13 +14,18 @@ import (
 	"github.com/sappy5678/DeeliAi/internal/router"
 )
 
-func runHTTPServer(rootCtx context.Context, wg *sync.WaitGroup, port int, app *app.Application) {
+func runHTTPServer(rootCtx context.Context, wg *sync.WaitGroup, port int, trustedProxies []string, app *app.Application) {
 	// Set to release mode to disable Gin logger
 	gin.SetMode(gin.ReleaseMode)
 
 	// Create gin router
 	ginRouter := gin.New()
 
+	// Only take the client IP from X-Forwarded-For of known proxies, login throttling counts failures by it
+	if err := router.SetTrustedProxies(ginRouter, trustedProxies); err != nil {
+		zerolog.Ctx(rootCtx).Panic().Err(err).Strs("proxies", trustedProxies).Msg("invalid trusted proxies")
+	}
+
 	// Set general middleware
 	router.SetGeneralMiddlewares(rootCtx, ginRouter)
 
@@ -22,12 +22,32 @@ var (
 )
 
 const (
-	defaultEnv                     = "staging"
-	defaultLogLevel                = "info"
-	defaultPort                    = "9000"
-	defaultTokenSigningKey         = "cb-signing-key" // nolint
-	defaultTokenExpiryDurationHour = "8"
-	defaultTokenTokenIssuer        = "app"
+	defaultEnv                          = "staging"
+	defaultLogLevel                     = "info"
+	defaultPort                         = "9000"
+	defaultTokenSigningAlgorithm        = "RS256"
+	defaultTokenKeyRotationHour         = "168"
+	defaultTokenExpiryDurationMin       = "15"
+	defaultRefreshTokenExpiryHour       = "720"
+	defaultTokenTokenIssuer             = "app"
+	defaultAppBaseURL                   = "http://localhost:9000"
+	defaultPasswordResetExpiryMin       = "30"
+	defaultEmailVerificationExpiryHour  = "24"
+	defaultPasswordHashAlgorithm        = "argon2id"
+	defaultPasswordMinLength            = "8"
+	defaultLoginAttemptStore            = "postgres"
+	defaultLoginMaxAccountFailures      = "5"
+	defaultLoginMaxIPFailures           = "50"
+	defaultLoginLockoutMin              = "15"
+	defaultLoginBaseDelaySec            = "1"
+	defaultTwoFactorIssuer              = "DeeliAi"
+	defaultLoginChallengeExpiryMin      = "5"
+	defaultOIDCStateExpiryMin           = "10"
+	defaultWorkspaceInvitationExpiryDay = "7"
+	defaultMailDriver                   = "file"
+	defaultMailFrom                     = "DeeliAi <no-reply@deeliai.local>"
+	defaultMailFileDir                  = "./mail"
+	defaultSMTPPort                     = "587"
 )
 
 type AppConfig struct {
@@ -39,12 +59,52 @@ type AppConfig struct {
 	DatabaseDSN *string
 
 	// HTTP configuration
-	Port *int
+	Port           *int
+	TrustedProxies *[]string
 
 	// Token configuration
-	TokenSigningKey         *string
-	TokenExpiryDurationHour *int
-	TokenIssuer             *string
+	TokenSigningAlgorithm          *string
+	TokenKeyRotationIntervalHour   *int
+	TokenExpiryDurationMinute      *int
+	RefreshTokenExpiryDurationHour *int
+	TokenIssuer                    *string
+
+	// User account configuration
+	AppBaseURL                       *string
+	PasswordResetTokenExpiryMinute   *int
+	EmailVerificationTokenExpiryHour *int
+	RequireVerifiedEmail             *bool
+
+	// Password configuration
+	PasswordHashAlgorithm *string
+	PasswordMinLength     *int
+
+	// Login throttling configuration
+	LoginAttemptStore       *string
+	LoginMaxAccountFailures *int
+	LoginMaxIPFailures      *int
+	LoginLockoutMinute      *int
+	LoginBaseDelaySecond    *int
+
+	// Two-factor authentication configuration
+	TwoFactorIssuer            *string
+	LoginChallengeExpiryMinute *int
+
+	// OpenID Connect configuration
+	OIDCProviders         *[]string
+	OIDCStateExpiryMinute *int
+
+	// Workspace configuration
+	WorkspaceInvitationExpiryDay *int
+
+	// Mail configuration
+	MailDriver   *string
+	MailFrom     *string
+	MailFileDir  *string
+	SMTPHost     *string
+	SMTPPort     *int
+	SMTPUsername *string
+	SMTPPassword *string
 }
 
 func initAppConfig() AppConfig {
@@ -65,21 +125,106 @@ func initAppConfig() AppConfig {
 	config.Port = app.
 		Flag("port", "The HTTP server port").
 		Envar("CB_PORT").Default(defaultPort).Int()
+	config.TrustedProxies = app.
+		Flag("trusted_proxy", "An IP or CIDR of a reverse proxy whose X-Forwarded-For header gives the client IP, none by default. Repeat for more proxies").
+		Envar("CB_TRUSTED_PROXIES").Strings()
 
 	config.DatabaseDSN = app.
 		Flag("database_dsn", "The database DSN").
 		Envar("CB_DATABASE_DSN").Required().String()
 
-	config.TokenSigningKey = app.
-		Flag("token_signing_key", "Token signing key").
-		Envar("CB_TOKEN_SIGNING_KEY").Default(defaultTokenSigningKey).String()
-	config.TokenExpiryDurationHour = app.
-		Flag("token_expiry_duration_hour", "Token expiry time").
-		Envar("CB_TOKEN_EXPIRY_DURATION_HOUR").Default(defaultTokenExpiryDurationHour).Int()
+	config.TokenSigningAlgorithm = app.
+		Flag("token_signing_algorithm", "Token signing algorithm of newly rotated keys").
+		Envar("CB_TOKEN_SIGNING_ALGORITHM").Default(defaultTokenSigningAlgorithm).Enum("RS256", "EdDSA")
+	config.TokenKeyRotationIntervalHour = app.
+		Flag("token_key_rotation_interval_hour", "How long a token signing key is used before it is rotated").
+		Envar("CB_TOKEN_KEY_ROTATION_INTERVAL_HOUR").Default(defaultTokenKeyRotationHour).Int()
+	config.TokenExpiryDurationMinute = app.
+		Flag("token_expiry_duration_minute", "Access token expiry time").
+		Envar("CB_TOKEN_EXPIRY_DURATION_MINUTE").Default(defaultTokenExpiryDurationMin).Int()
+	config.RefreshTokenExpiryDurationHour = app.
+		Flag("refresh_token_expiry_duration_hour", "Refresh token expiry time").
+		Envar("CB_REFRESH_TOKEN_EXPIRY_DURATION_HOUR").Default(defaultRefreshTokenExpiryHour).Int()
 	config.TokenIssuer = app.
 		Flag("token_issuer", "Token issuer").
 		Envar("CB_TOKEN_ISSUER").Default(defaultTokenTokenIssuer).String()
 
+	config.AppBaseURL = app.
+		Flag("app_base_url", "The public base URL used in links sent by mail").
+		Envar("CB_APP_BASE_URL").Default(defaultAppBaseURL).String()
+	config.PasswordResetTokenExpiryMinute = app.
+		Flag("password_reset_token_expiry_minute", "Password reset link expiry time").
+		Envar("CB_PASSWORD_RESET_TOKEN_EXPIRY_MINUTE").Default(defaultPasswordResetExpiryMin).Int()
+	config.EmailVerificationTokenExpiryHour = app.
+		Flag("email_verification_token_expiry_hour", "Email verification link expiry time").
+		Envar("CB_EMAIL_VERIFICATION_TOKEN_EXPIRY_HOUR").Default(defaultEmailVerificationExpiryHour).Int()
+	config.RequireVerifiedEmail = app.
+		Flag("require_verified_email", "Only allow users with a verified email to modify their articles").
+		Envar("CB_REQUIRE_VERIFIED_EMAIL").Default("false").Bool()
+
+	config.PasswordHashAlgorithm = app.
+		Flag("password_hash_algorithm", "How new passwords are hashed, hashes of the other algorithm are replaced on the next login").
+		Envar("CB_PASSWORD_HASH_ALGORITHM").Default(defaultPasswordHashAlgorithm).Enum("argon2id", "bcrypt")
+	config.PasswordMinLength = app.
+		Flag("password_min_length", "The minimum number of characters of a new password").
+		Envar("CB_PASSWORD_MIN_LENGTH").Default(defaultPasswordMinLength).Int()
+
+	config.LoginAttemptStore = app.
+		Flag("login_attempt_store", "Where failed login counters are kept, memory only suits a single instance").
+		Envar("CB_LOGIN_ATTEMPT_STORE").Default(defaultLoginAttemptStore).Enum("postgres", "memory")
+	config.LoginMaxAccountFailures = app.
+		Flag("login_max_account_failures", "Failed logins in a row that lock an account out").
+		Envar("CB_LOGIN_MAX_ACCOUNT_FAILURES").Default(defaultLoginMaxAccountFailures).Int()
+	config.LoginMaxIPFailures = app.
+		Flag("login_max_ip_failures", "Failed logins in a row that lock a client IP out").
+		Envar("CB_LOGIN_MAX_IP_FAILURES").Default(defaultLoginMaxIPFailures).Int()
+	config.LoginLockoutMinute = app.
+		Flag("login_lockout_minute", "How long a lockout lasts and a failed login is remembered").
+		Envar("CB_LOGIN_LOCKOUT_MINUTE").Default(defaultLoginLockoutMin).Int()
+	config.LoginBaseDelaySecond = app.
+		Flag("login_base_delay_second", "Wait after the second failed login of an account, doubled after each further failure").
+		Envar("CB_LOGIN_BASE_DELAY_SECOND").Default(defaultLoginBaseDelaySec).Int()
+
+	config.TwoFactorIssuer = app.
+		Flag("two_factor_issuer", "The name authenticator apps show for TOTP secrets").
+		Envar("CB_TWO_FACTOR_ISSUER").Default(defaultTwoFactorIssuer).String()
+	config.LoginChallengeExpiryMinute = app.
+		Flag("login_challenge_expiry_minute", "How long a login may wait for its second factor").
+		Envar("CB_LOGIN_CHALLENGE_EXPIRY_MINUTE").Default(defaultLoginChallengeExpiryMin).Int()
+
+	config.OIDCProviders = app.
+		Flag("oidc_provider", "An OpenID Connect provider users can log in with, e.g. name=company,issuer=https://idp.example.com,client_id=deeliai,client_secret=s3cret. Repeat for more providers").
+		Envar("CB_OIDC_PROVIDERS").Strings()
+	config.OIDCStateExpiryMinute = app.
+		Flag("oidc_state_expiry_minute", "How long a login at an OpenID Connect provider may take").
+		Envar("CB_OIDC_STATE_EXPIRY_MINUTE").Default(defaultOIDCStateExpiryMin).Int()
+
+	config.WorkspaceInvitationExpiryDay = app.
+		Flag("workspace_invitation_expiry_day", "How long an invitation to a workspace can be accepted").
+		Envar("CB_WORKSPACE_INVITATION_EXPIRY_DAY").Default(defaultWorkspaceInvitationExpiryDay).Int()
+
+	config.MailDriver = app.
+		Flag("mail_driver", "How mail is delivered").
+		Envar("CB_MAIL_DRIVER").Default(defaultMailDriver).Enum("smtp", "file")
+	config.MailFrom = app.
+		Flag("mail_from", "The sender address of outgoing mail").
+		Envar("CB_MAIL_FROM").Default(defaultMailFrom).String()
+	config.MailFileDir = app.
+		Flag("mail_file_dir", "The directory mail is written to by the file driver").
+		Envar("CB_MAIL_FILE_DIR").Default(defaultMailFileDir).String()
+	config.SMTPHost = app.
+		Flag("smtp_host", "The SMTP relay host").
+		Envar("CB_SMTP_HOST").String()
+	config.SMTPPort = app.
+		Flag("smtp_port", "The SMTP relay port").
+		Envar("CB_SMTP_PORT").Default(defaultSMTPPort).Int()
+	config.SMTPUsername = app.
+		Flag("smtp_username", "The SMTP username").
+		Envar("CB_SMTP_USERNAME").String()
+	config.SMTPPassword = app.
+		Flag("smtp_password", "The SMTP password").
+		Envar("CB_SMTP_PASSWORD").String()
+
 	kingpin.MustParse(app.Parse(os.Args[1:]))
 
 	return config
@@ -126,16 +271,41 @@ func main() {
 	wg := sync.WaitGroup{}
 	// Create application
 	app := app.MustNewApplication(rootCtx, &wg, app.ApplicationParams{
-		Env:                 *cfg.Env,
-		DatabaseDSN:         *cfg.DatabaseDSN,
-		TokenSigningKey:     []byte(*cfg.TokenSigningKey),
-		TokenExpiryDuration: time.Duration(*cfg.TokenExpiryDurationHour) * time.Hour,
-		TokenIssuer:         *cfg.TokenIssuer,
+		Env:                          *cfg.Env,
+		DatabaseDSN:                  *cfg.DatabaseDSN,
+		TokenSigningAlgorithm:        *cfg.TokenSigningAlgorithm,
+		TokenKeyRotationInterval:     time.Duration(*cfg.TokenKeyRotationIntervalHour) * time.Hour,
+		TokenExpiryDuration:          time.Duration(*cfg.TokenExpiryDurationMinute) * time.Minute,
+		RefreshTokenExpiryDuration:   time.Duration(*cfg.RefreshTokenExpiryDurationHour) * time.Hour,
+		TokenIssuer:                  *cfg.TokenIssuer,
+		AppBaseURL:                   *cfg.AppBaseURL,
+		PasswordResetTokenExpiry:     time.Duration(*cfg.PasswordResetTokenExpiryMinute) * time.Minute,
+		EmailVerificationTokenExpiry: time.Duration(*cfg.EmailVerificationTokenExpiryHour) * time.Hour,
+		RequireVerifiedEmail:         *cfg.RequireVerifiedEmail,
+		PasswordHashAlgorithm:        *cfg.PasswordHashAlgorithm,
+		PasswordMinLength:            *cfg.PasswordMinLength,
+		LoginAttemptStore:            *cfg.LoginAttemptStore,
+		LoginMaxAccountFailures:      *cfg.LoginMaxAccountFailures,
+		LoginMaxIPFailures:           *cfg.LoginMaxIPFailures,
+		LoginLockoutDuration:         time.Duration(*cfg.LoginLockoutMinute) * time.Minute,
+		LoginBaseDelay:               time.Duration(*cfg.LoginBaseDelaySecond) * time.Second,
+		TwoFactorIssuer:              *cfg.TwoFactorIssuer,
+		LoginChallengeExpiry:         time.Duration(*cfg.LoginChallengeExpiryMinute) * time.Minute,
+		OIDCProviders:                *cfg.OIDCProviders,
+		OIDCStateExpiry:              time.Duration(*cfg.OIDCStateExpiryMinute) * time.Minute,
+		WorkspaceInvitationExpiry:    time.Duration(*cfg.WorkspaceInvitationExpiryDay) * 24 * time.Hour,
+		MailDriver:                   *cfg.MailDriver,
+		MailFrom:                     *cfg.MailFrom,
+		MailFileDir:                  *cfg.MailFileDir,
+		SMTPHost:                     *cfg.SMTPHost,
+		SMTPPort:                     *cfg.SMTPPort,
+		SMTPUsername:                 *cfg.SMTPUsername,
+		SMTPPassword:                 *cfg.SMTPPassword,
 	})
 
 	// Run server
 	wg.Add(1)
-	runHTTPServer(rootCtx, &wg, *cfg.Port, app)
+	runHTTPServer(rootCtx, &wg, *cfg.Port, *cfg.TrustedProxies, app)
 
 	// Listen to SIGTERM/SIGINT to close
 	gracefulStop := make(chan os.Signal, 1)
@@ -0,0 +1,46 @@
+// Command mock-oidc runs a local OpenID Connect provider that signs in a fixed user without asking,
+// for trying out the OIDC login of the app without a real identity provider.
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/sappy5678/DeeliAi/internal/adapter/oidc"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+func main() {
+	app := kingpin.New("mock-oidc", "A mock OpenID Connect provider for local development")
+	port := app.Flag("port", "The port to listen on, the issuer is http://localhost:<port>").Default("9100").Int()
+	clientID := app.Flag("client_id", "The client ID the app is registered with").Default("deeliai").String()
+	clientSecret := app.Flag("client_secret", "The client secret the app is registered with").Default("s3cret").String()
+	subject := app.Flag("subject", "The subject of the signed in user").Default("mock-user").String()
+	email := app.Flag("email", "The email of the signed in user").Default("mock-user@example.com").String()
+	emailVerified := app.Flag("email_verified", "Whether the email of the signed in user is verified").Default("true").Bool()
+	name := app.Flag("name", "The name of the signed in user").Default("Mock User").String()
+	kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	provider, err := oidc.NewMockProvider(*clientID, *clientSecret, user.ExternalIdentity{
+		Subject:       *subject,
+		Email:         *email,
+		EmailVerified: *emailVerified,
+		Name:          *name,
+	})
+	if err != nil {
+		log.Fatalf("fail to create mock provider, err: %s", err.Error())
+	}
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", *port),
+		Handler:           provider,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	log.Printf("mock OIDC provider listening on %s", server.Addr)
+	log.Fatal(server.ListenAndServe())
+}
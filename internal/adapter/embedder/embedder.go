@@ -0,0 +1,15 @@
+package embedder
+
+import (
+	"context"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// Embedder turns text into a vector of unit length. The more similar two texts are,
+// the larger the dot product of their vectors is.
+type Embedder interface {
+	// Model names the vectors the embedder returns, vectors of different models can't be compared
+	Model() string
+	Embed(ctx context.Context, text string) ([]float32, common.Error)
+}
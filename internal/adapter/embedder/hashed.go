@@ -0,0 +1,136 @@
+package embedder
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// DefaultHashedDimensions is the number of dimensions of the vectors the hashed embedder returns by default
+const DefaultHashedDimensions = 512
+
+// Weights of the features of text
+const (
+	hashedWordWeight    = 1.0 // words, and pairs of CJK characters
+	hashedTrigramWeight = 0.3 // trigrams of the letters of words, which match the forms of a word
+	hashedCharWeight    = 0.5 // single CJK characters
+)
+
+// hashedStopWords are frequent English words, which say nothing about what text is about
+var hashedStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "how": true, "in": true, "is": true, "it": true, "of": true, "on": true,
+	"or": true, "that": true, "the": true, "this": true, "to": true, "was": true, "what": true,
+	"with": true, "you": true, "your": true,
+}
+
+// hashedEmbedder embeds text offline with the hashing trick. Words, trigrams of their letters and CJK characters
+// and character pairs are hashed to the dimensions of the vector, weighted by how often they occur.
+type hashedEmbedder struct {
+	dimensions int
+}
+
+// NewHashedEmbedder creates an embedder which needs no model service, with vectors of the dimensions.
+func NewHashedEmbedder(dimensions int) Embedder {
+	return &hashedEmbedder{dimensions: dimensions}
+}
+
+func (e *hashedEmbedder) Model() string {
+	return fmt.Sprintf("hashed-ngram-%d", e.dimensions)
+}
+
+func (e *hashedEmbedder) Embed(_ context.Context, text string) ([]float32, common.Error) {
+	vector := make([]float64, e.dimensions)
+	for feature, count := range hashedFeatures(text) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+
+		// The sign keeps features which collide from adding up
+		sign := 1.0
+		if sum>>63 == 1 {
+			sign = -1.0
+		}
+		vector[sum%uint64(e.dimensions)] += sign * featureWeight(feature) * (1 + math.Log(float64(count)))
+	}
+
+	norm := 0.0
+	for _, v := range vector {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+
+	embedding := make([]float32, e.dimensions)
+	if norm == 0 {
+		return embedding, nil
+	}
+	for i, v := range vector {
+		embedding[i] = float32(v / norm)
+	}
+	return embedding, nil
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// hashedFeatures returns the features of text with how often they occur.
+// Features are prefixed with their kind: w: for words, t: for trigrams and c: for CJK characters.
+func hashedFeatures(text string) map[string]int {
+	counts := make(map[string]int)
+
+	var word, cjk []rune
+	flushWord := func() {
+		if len(word) > 0 && !hashedStopWords[string(word)] {
+			counts["w:"+string(word)]++
+			padded := []rune("^" + string(word) + "$")
+			for i := 0; i+3 <= len(padded); i++ {
+				counts["t:"+string(padded[i:i+3])]++
+			}
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		for i, r := range cjk {
+			counts["c:"+string(r)]++
+			if i+1 < len(cjk) {
+				counts["w:"+string(cjk[i:i+2])]++
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return counts
+}
+
+// featureWeight returns the weight of the kind of the feature
+func featureWeight(feature string) float64 {
+	switch feature[:2] {
+	case "t:":
+		return hashedTrigramWeight
+	case "c:":
+		return hashedCharWeight
+	default:
+		return hashedWordWeight
+	}
+}
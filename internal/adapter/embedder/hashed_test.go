@@ -0,0 +1,63 @@
+package embedder
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func similarity(t *testing.T, e Embedder, a string, b string) float64 {
+	t.Helper()
+
+	va, err := e.Embed(context.Background(), a)
+	require.NoError(t, err)
+	vb, err := e.Embed(context.Background(), b)
+	require.NoError(t, err)
+
+	dot := 0.0
+	for i := range va {
+		dot += float64(va[i]) * float64(vb[i])
+	}
+	return dot
+}
+
+func TestHashedEmbedder_Embed(t *testing.T) {
+	t.Parallel()
+
+	e := NewHashedEmbedder(DefaultHashedDimensions)
+	assert.Equal(t, "hashed-ngram-512", e.Model())
+
+	vector, err := e.Embed(context.Background(), "Tuning Postgres indexes")
+	require.NoError(t, err)
+	require.Len(t, vector, DefaultHashedDimensions)
+	norm := 0.0
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	assert.InDelta(t, 1, math.Sqrt(norm), 1e-6)
+
+	// Text without features is the zero vector
+	vector, err = e.Embed(context.Background(), " the, and ")
+	require.NoError(t, err)
+	assert.Equal(t, make([]float32, DefaultHashedDimensions), vector)
+}
+
+func TestHashedEmbedder_Similarity(t *testing.T) {
+	t.Parallel()
+
+	e := NewHashedEmbedder(DefaultHashedDimensions)
+
+	assert.InDelta(t, 1, similarity(t, e, "Index tuning", "index TUNING"), 1e-6)
+
+	// Other forms of the words are closer than other topics
+	related := similarity(t, e, "tuning postgres indexes", "how to tune an index in PostgreSQL")
+	unrelated := similarity(t, e, "tuning postgres indexes", "a week of hiking in the mountains")
+	assert.Greater(t, related, unrelated+0.2)
+
+	related = similarity(t, e, "資料庫效能調校", "調校資料庫的效能")
+	unrelated = similarity(t, e, "資料庫效能調校", "台灣登山健行路線")
+	assert.Greater(t, related, unrelated+0.2)
+}
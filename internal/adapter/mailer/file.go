@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// FileMailer writes every mail as an .eml file into a directory. It is meant for local development.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(_ context.Context, dir string, from string) *FileMailer {
+	return &FileMailer{
+		dir:  dir,
+		from: from,
+	}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) common.Error {
+	now := time.Now()
+	data, err := msg.build(m.from, now)
+	if err != nil {
+		return common.NewError(common.ErrorCodeParameterInvalid, err)
+	}
+
+	if err := os.MkdirAll(m.dir, 0o750); err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to create mail directory"))
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405"), uuid.NewString())
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o600); err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to write mail"))
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"context"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage_Build(t *testing.T) {
+	t.Parallel()
+
+	msg := Message{
+		To:      "alice@example.com",
+		Subject: "重設密碼",
+		Body:    "Hi Alice,\nopen https://example.com/reset-password?token=abc\n",
+	}
+	data, err := msg.build("DeeliAi <no-reply@deeliai.test>", time.Now())
+	require.NoError(t, err)
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", parsed.Header.Get("To"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "重設密碼", subject)
+	assert.Contains(t, parsed.Header.Get("Message-ID"), "@deeliai.test>")
+}
+
+func TestMessage_BuildInvalidRecipient(t *testing.T) {
+	t.Parallel()
+
+	_, err := Message{To: "not an address"}.build("no-reply@deeliai.test", time.Now())
+	assert.Error(t, err)
+}
+
+func TestFileMailer_Send(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	m := NewFileMailer(context.Background(), dir, "no-reply@deeliai.test")
+	require.Nil(t, m.Send(context.Background(), Message{To: "alice@example.com", Subject: "hello", Body: "world"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Subject: hello")
+}
@@ -0,0 +1,56 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"net/smtp"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// SMTPMailer delivers mail through an SMTP relay
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer creates a mailer for the given relay. Authentication is skipped when username is empty.
+func NewSMTPMailer(_ context.Context, host string, port int, username string, password string, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPMailer{
+		addr: fmt.Sprintf("%s:%d", host, port),
+		auth: auth,
+		from: from,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) common.Error {
+	data, err := msg.build(m.from, time.Now())
+	if err != nil {
+		return common.NewError(common.ErrorCodeParameterInvalid, err)
+	}
+
+	from, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "invalid sender address"))
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return common.NewError(common.ErrorCodeParameterInvalid, err)
+	}
+
+	if err := smtp.SendMail(m.addr, m.auth, from.Address, []string{to.Address}, data); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to send mail"))
+	}
+
+	return nil
+}
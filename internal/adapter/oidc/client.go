@@ -0,0 +1,314 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+const (
+	httpTimeout = 10 * time.Second
+	// minKeyReloadInterval throttles refetching the key set when an ID token carries an unknown kid
+	minKeyReloadInterval = 5 * time.Second
+	// maxResponseBytes caps what we read from a provider
+	maxResponseBytes = 1 << 20
+)
+
+// validSigningMethods are the ID token algorithms we accept, never "none" or a shared secret
+var validSigningMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// discoveryDocument holds the fields of the provider metadata we use
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type idTokenClaims struct {
+	Nonce             string       `json:"nonce"`
+	Email             string       `json:"email"`
+	EmailVerified     flexibleBool `json:"email_verified"`
+	Name              string       `json:"name"`
+	PreferredUsername string       `json:"preferred_username"`
+	jwt.RegisteredClaims
+}
+
+// flexibleBool accepts both true and "true", as some providers send claims as strings
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	*b = flexibleBool(strings.Trim(string(data), `"`) == "true")
+	return nil
+}
+
+// Client is a Provider talking to an OpenID Connect provider over HTTP.
+// The provider metadata is discovered on first use and the signing keys are cached.
+type Client struct {
+	config     Config
+	httpClient *http.Client
+
+	mu           sync.Mutex
+	discovery    *discoveryDocument
+	keys         map[string]crypto.PublicKey
+	keysLoadedAt time.Time
+}
+
+func NewClient(_ context.Context, config Config) *Client {
+	return &Client{
+		config:     config,
+		httpClient: &http.Client{Timeout: httpTimeout},
+	}
+}
+
+func (c *Client) AuthCodeURL(ctx context.Context, state string, nonce string, codeVerifier string) (string, common.Error) {
+	discovery, cerr := c.discover(ctx)
+	if cerr != nil {
+		return "", cerr
+	}
+
+	authURL, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "invalid authorization endpoint"))
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", c.config.ClientID)
+	query.Set("redirect_uri", c.config.RedirectURL)
+	query.Set("scope", strings.Join(c.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+
+	return authURL.String(), nil
+}
+
+func (c *Client) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*user.ExternalIdentity, common.Error) {
+	discovery, cerr := c.discover(ctx)
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", c.config.ClientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.config.ClientID), url.QueryEscape(c.config.ClientSecret))
+	}
+
+	var token tokenResponse
+	status, cerr := c.doJSON(req, &token)
+	if cerr != nil {
+		return nil, cerr
+	}
+	if status == http.StatusBadRequest && token.Error == "invalid_grant" {
+		msg := "the sign in has expired, please try again"
+		return nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(token.ErrorDescription), common.WithMsg(msg))
+	}
+	if status != http.StatusOK || token.IDToken == "" {
+		err := fmt.Errorf("token request failed: %s %s", token.Error, token.ErrorDescription)
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, err, common.WithStatus(status))
+	}
+
+	claims, cerr := c.verifyIDToken(ctx, token.IDToken, nonce)
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	return &user.ExternalIdentity{
+		Provider:          c.config.Name,
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     bool(claims.EmailVerified),
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token
+func (c *Client) verifyIDToken(ctx context.Context, idToken string, nonce string) (*idTokenClaims, common.Error) {
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return c.verificationKey(ctx, kid)
+	},
+		jwt.WithValidMethods(validSigningMethods),
+		jwt.WithIssuer(c.config.Issuer),
+		jwt.WithAudience(c.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.Wrap(err, "invalid ID token"), common.WithMsg("invalid ID token"))
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		msg := "invalid ID token nonce"
+		return nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg("invalid ID token"))
+	}
+	if claims.Subject == "" {
+		msg := "ID token has no subject"
+		return nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg("invalid ID token"))
+	}
+
+	return claims, nil
+}
+
+// verificationKey returns the provider key identified by kid, refetching the key set once if it is unknown.
+// A token without kid is accepted when the provider publishes a single key.
+func (c *Client) verificationKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	key, ok := c.findKey(kid)
+	canReload := time.Since(c.keysLoadedAt) >= minKeyReloadInterval
+	c.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !canReload {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if cerr := c.loadKeys(ctx); cerr != nil {
+		return nil, cerr
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key, ok := c.findKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// findKey must be called with c.mu held
+func (c *Client) findKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, true
+		}
+	}
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+func (c *Client) loadKeys(ctx context.Context) common.Error {
+	discovery, cerr := c.discover(ctx)
+	if cerr != nil {
+		return cerr
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.JWKSURI, nil)
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	var keySet jsonWebKeySet
+	status, cerr := c.doJSON(req, &keySet)
+	if cerr != nil {
+		return cerr
+	}
+	if status != http.StatusOK {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.New("failed to fetch provider keys"), common.WithStatus(status))
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(keySet.Keys))
+	for _, jwk := range keySet.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			c.logger(ctx).Warn().Err(err).Msg("skip unsupported provider key")
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys = keys
+	c.keysLoadedAt = time.Now()
+	return nil
+}
+
+// discover fetches the provider metadata once and caches it
+func (c *Client) discover(ctx context.Context) (*discoveryDocument, common.Error) {
+	c.mu.Lock()
+	discovery := c.discovery
+	c.mu.Unlock()
+	if discovery != nil {
+		return discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.config.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	var doc discoveryDocument
+	status, cerr := c.doJSON(req, &doc)
+	if cerr != nil {
+		return nil, cerr
+	}
+	if status != http.StatusOK {
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.New("failed to discover provider"), common.WithStatus(status))
+	}
+	// The issuer must match exactly, otherwise a provider could vouch for tokens of another one
+	if doc.Issuer != c.config.Issuer {
+		err := fmt.Errorf("provider reports issuer %q, expected %q", doc.Issuer, c.config.Issuer)
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.discovery = &doc
+	return c.discovery, nil
+}
+
+// doJSON sends the request and decodes a JSON response of any status
+func (c *Client) doJSON(req *http.Request, v interface{}) (int, common.Error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to reach provider"))
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(v); err != nil {
+		return resp.StatusCode, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "invalid provider response"), common.WithStatus(resp.StatusCode))
+	}
+	return resp.StatusCode, nil
+}
+
+// logger wrap the execution context with component info
+func (c *Client) logger(ctx context.Context) *zerolog.Logger {
+	l := zerolog.Ctx(ctx).With().Str("component", "oidc-client").Str("provider", c.config.Name).Logger()
+	return &l
+}
@@ -0,0 +1,196 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+const mockKeyID = "mock"
+
+// MockProvider is a minimal OpenID Connect provider for tests and local development.
+// It approves every authorization request right away, signing in the configured identity.
+// The issuer is the scheme and host the provider is reached at.
+type MockProvider struct {
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+
+	mu       sync.Mutex
+	identity user.ExternalIdentity
+	codes    map[string]mockAuthorization
+}
+
+type mockAuthorization struct {
+	issuer        string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	identity      user.ExternalIdentity
+}
+
+func NewMockProvider(clientID string, clientSecret string, identity user.ExternalIdentity) (*MockProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	return &MockProvider{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		key:          key,
+		identity:     identity,
+		codes:        map[string]mockAuthorization{},
+	}, nil
+}
+
+// SetIdentity changes who the following sign ins are for
+func (m *MockProvider) SetIdentity(identity user.ExternalIdentity) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.identity = identity
+}
+
+func (m *MockProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		m.serveDiscovery(w, r)
+	case "/authorize":
+		m.serveAuthorize(w, r)
+	case "/token":
+		m.serveToken(w, r)
+	case "/jwks":
+		m.serveJWKS(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *MockProvider) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	issuer := mockIssuer(r)
+	writeJSON(w, http.StatusOK, discoveryDocument{
+		Issuer:                issuer,
+		AuthorizationEndpoint: issuer + "/authorize",
+		TokenEndpoint:         issuer + "/token",
+		JWKSURI:               issuer + "/jwks",
+	})
+}
+
+func (m *MockProvider) serveAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("response_type") != "code" || query.Get("client_id") != m.clientID || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	redirectURL, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirectURL.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	code := uuid.NewString()
+	m.mu.Lock()
+	m.codes[code] = mockAuthorization{
+		issuer:        mockIssuer(r),
+		redirectURI:   redirectURL.String(),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		identity:      m.identity,
+	}
+	m.mu.Unlock()
+
+	callback := redirectURL.Query()
+	callback.Set("code", code)
+	callback.Set("state", query.Get("state"))
+	redirectURL.RawQuery = callback.Encode()
+	http.Redirect(w, r, redirectURL.String(), http.StatusFound)
+}
+
+func (m *MockProvider) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, tokenResponse{Error: "invalid_request"})
+		return
+	}
+	if m.clientSecret != "" {
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || clientID != m.clientID || clientSecret != m.clientSecret {
+			writeJSON(w, http.StatusUnauthorized, tokenResponse{Error: "invalid_client"})
+			return
+		}
+	}
+
+	// Codes are single-use, whether the exchange succeeds or not
+	m.mu.Lock()
+	authorization, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != authorization.redirectURI ||
+		codeChallenge(r.PostForm.Get("code_verifier")) != authorization.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, tokenResponse{Error: "invalid_grant", ErrorDescription: "invalid code or code verifier"})
+		return
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, idTokenClaims{
+		Nonce:             authorization.nonce,
+		Email:             authorization.identity.Email,
+		EmailVerified:     flexibleBool(authorization.identity.EmailVerified),
+		Name:              authorization.identity.Name,
+		PreferredUsername: authorization.identity.PreferredUsername,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    authorization.issuer,
+			Subject:   authorization.identity.Subject,
+			Audience:  jwt.ClaimStrings{m.clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	})
+	token.Header["kid"] = mockKeyID
+	idToken, err := token.SignedString(m.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, tokenResponse{Error: "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": uuid.NewString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (m *MockProvider) serveJWKS(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, jsonWebKeySet{Keys: []jsonWebKey{{
+		KeyType:  "RSA",
+		KeyID:    mockKeyID,
+		Use:      "sig",
+		Modulus:  base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+		Exponent: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+	}}})
+}
+
+func mockIssuer(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,80 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// Provider signs users in through the OpenID Connect authorization code flow with PKCE
+type Provider interface {
+	// AuthCodeURL returns the URL that sends the user to the provider to sign in
+	AuthCodeURL(ctx context.Context, state string, nonce string, codeVerifier string) (string, common.Error)
+	// Exchange redeems an authorization code and returns the identity asserted by its verified ID token
+	Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*user.ExternalIdentity, common.Error)
+}
+
+// Config is the registration of DeeliAi as a client of one provider
+type Config struct {
+	// Name identifies the provider in URLs and linked identities, e.g. "company"
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+var defaultScopes = []string{"openid", "email", "profile"}
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9-]{1,50}$`)
+
+// ParseConfig parses a provider given as comma separated key=value pairs, e.g.
+// "name=company,issuer=https://idp.example.com,client_id=deeliai,client_secret=s3cret,scopes=openid email".
+// The redirect URL is left for the caller to fill in.
+func ParseConfig(spec string) (Config, error) {
+	var config Config
+	for _, pair := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return Config{}, fmt.Errorf("invalid provider option %q, expected key=value", pair)
+		}
+		switch key {
+		case "name":
+			config.Name = value
+		case "issuer":
+			config.Issuer = strings.TrimSuffix(value, "/")
+		case "client_id":
+			config.ClientID = value
+		case "client_secret":
+			config.ClientSecret = value
+		case "scopes":
+			config.Scopes = strings.Fields(value)
+		default:
+			return Config{}, fmt.Errorf("unknown provider option %q", key)
+		}
+	}
+
+	if !providerNamePattern.MatchString(config.Name) {
+		return Config{}, fmt.Errorf("invalid provider name %q, use lowercase letters, digits and dashes", config.Name)
+	}
+	if config.Issuer == "" || config.ClientID == "" {
+		return Config{}, fmt.Errorf("provider %q needs an issuer and a client_id", config.Name)
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = defaultScopes
+	}
+	return config, nil
+}
+
+// codeChallenge derives the S256 PKCE challenge of a code verifier
+func codeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,119 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+func TestParseConfig(t *testing.T) {
+	t.Parallel()
+
+	config, err := ParseConfig("name=company, issuer=https://idp.example.com/,client_id=deeliai,client_secret=a=b,scopes=openid email")
+	require.NoError(t, err)
+	assert.Equal(t, Config{
+		Name:         "company",
+		Issuer:       "https://idp.example.com",
+		ClientID:     "deeliai",
+		ClientSecret: "a=b",
+		Scopes:       []string{"openid", "email"},
+	}, config)
+
+	config, err = ParseConfig("name=company,issuer=https://idp.example.com,client_id=deeliai")
+	require.NoError(t, err)
+	assert.Equal(t, defaultScopes, config.Scopes)
+
+	for _, spec := range []string{
+		"issuer=https://idp.example.com,client_id=deeliai",
+		"name=Company,issuer=https://idp.example.com,client_id=deeliai",
+		"name=company,client_id=deeliai",
+		"name=company,issuer=https://idp.example.com,client_id=deeliai,color=blue",
+	} {
+		_, err := ParseConfig(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// newTestClient starts a mock provider and returns a client registered with it
+func newTestClient(t *testing.T, identity user.ExternalIdentity) *Client {
+	mock, err := NewMockProvider("deeliai", "s3cret", identity)
+	require.NoError(t, err)
+	server := httptest.NewServer(mock)
+	t.Cleanup(server.Close)
+
+	return NewClient(context.Background(), Config{
+		Name:         "mock",
+		Issuer:       server.URL,
+		ClientID:     "deeliai",
+		ClientSecret: "s3cret",
+		RedirectURL:  "https://deeliai.test/api/v1/user/oidc/mock/callback",
+		Scopes:       defaultScopes,
+	})
+}
+
+// authorize follows the authorization URL like a browser and returns the code and state of the callback
+func authorize(t *testing.T, authURL string) (string, string) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/user/oidc/mock/callback", callback.Path)
+	return callback.Query().Get("code"), callback.Query().Get("state")
+}
+
+func TestClient_Exchange(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := newTestClient(t, user.ExternalIdentity{Subject: "42", Email: "alice@example.com", EmailVerified: true, Name: "Alice"})
+
+	authURL, cerr := client.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-0123456789-0123456789-0123456789")
+	require.Nil(t, cerr)
+	code, state := authorize(t, authURL)
+	assert.Equal(t, "state-1", state)
+
+	identity, cerr := client.Exchange(ctx, code, "verifier-0123456789-0123456789-0123456789", "nonce-1")
+	require.Nil(t, cerr)
+	assert.Equal(t, &user.ExternalIdentity{
+		Provider:      "mock",
+		Subject:       "42",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Name:          "Alice",
+	}, identity)
+
+	// A code is single-use
+	_, cerr = client.Exchange(ctx, code, "verifier-0123456789-0123456789-0123456789", "nonce-1")
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeAuthNotAuthenticated))
+}
+
+func TestClient_ExchangeRejectsWrongVerifierAndNonce(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := newTestClient(t, user.ExternalIdentity{Subject: "42", Email: "alice@example.com"})
+
+	authURL, cerr := client.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-0123456789-0123456789-0123456789")
+	require.Nil(t, cerr)
+	code, _ := authorize(t, authURL)
+	_, cerr = client.Exchange(ctx, code, "another-verifier-0123456789-0123456789", "nonce-1")
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeAuthNotAuthenticated))
+
+	authURL, cerr = client.AuthCodeURL(ctx, "state-2", "nonce-2", "verifier-0123456789-0123456789-0123456789")
+	require.Nil(t, cerr)
+	code, _ = authorize(t, authURL)
+	_, cerr = client.Exchange(ctx, code, "verifier-0123456789-0123456789-0123456789", "nonce-1")
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeAuthNotAuthenticated))
+}
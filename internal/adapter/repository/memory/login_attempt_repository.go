@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// LoginAttemptRepository keeps failed login counters in memory.
+// Counters are not shared between instances, so it only suits a single instance deployment or tests.
+type LoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]*user.LoginAttempt
+}
+
+func NewLoginAttemptRepository() *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		attempts: map[string]*user.LoginAttempt{},
+	}
+}
+
+func (r *LoginAttemptRepository) GetLoginAttempt(_ context.Context, key string) (*user.LoginAttempt, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt, ok := r.attempts[key]
+	if !ok {
+		return &user.LoginAttempt{Key: key}, nil
+	}
+	return copyLoginAttempt(attempt), nil
+}
+
+func (r *LoginAttemptRepository) RecordLoginFailure(_ context.Context, key string, failedAt time.Time, window time.Duration) (*user.LoginAttempt, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt, ok := r.attempts[key]
+	if !ok {
+		attempt = &user.LoginAttempt{Key: key}
+		r.attempts[key] = attempt
+	}
+	if attempt.LastFailedAt.Before(failedAt.Add(-window)) {
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailedAt = failedAt
+	return copyLoginAttempt(attempt), nil
+}
+
+func (r *LoginAttemptRepository) LockLoginAttempt(_ context.Context, key string, until time.Time) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt, ok := r.attempts[key]
+	if !ok {
+		attempt = &user.LoginAttempt{Key: key, LastFailedAt: time.Now()}
+		r.attempts[key] = attempt
+	}
+	attempt.LockedUntil = &until
+	return nil
+}
+
+func (r *LoginAttemptRepository) DeleteLoginAttempt(_ context.Context, key string) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.attempts, key)
+	return nil
+}
+
+func (r *LoginAttemptRepository) DeleteStaleLoginAttempts(_ context.Context, before time.Time) common.Error {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, attempt := range r.attempts {
+		if attempt.LastFailedAt.Before(before) && !attempt.IsLocked(now) {
+			delete(r.attempts, key)
+		}
+	}
+	return nil
+}
+
+func copyLoginAttempt(attempt *user.LoginAttempt) *user.LoginAttempt {
+	copied := *attempt
+	if attempt.LockedUntil != nil {
+		lockedUntil := *attempt.LockedUntil
+		copied.LockedUntil = &lockedUntil
+	}
+	return &copied
+}
@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginAttemptRepository_RecordLoginFailure(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := NewLoginAttemptRepository()
+	now := time.Now()
+
+	attempt, err := repo.RecordLoginFailure(ctx, "account:a@example.com", now, time.Minute)
+	require.Nil(t, err)
+	assert.Equal(t, 1, attempt.Failures)
+	attempt, err = repo.RecordLoginFailure(ctx, "account:a@example.com", now.Add(30*time.Second), time.Minute)
+	require.Nil(t, err)
+	assert.Equal(t, 2, attempt.Failures)
+
+	// A failure after the window starts a new count
+	attempt, err = repo.RecordLoginFailure(ctx, "account:a@example.com", now.Add(2*time.Minute), time.Minute)
+	require.Nil(t, err)
+	assert.Equal(t, 1, attempt.Failures)
+}
+
+func TestLoginAttemptRepository_DeleteStaleLoginAttempts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := NewLoginAttemptRepository()
+	past := time.Now().Add(-time.Hour)
+
+	_, err := repo.RecordLoginFailure(ctx, "ip:192.0.2.1", past, time.Minute)
+	require.Nil(t, err)
+	_, err = repo.RecordLoginFailure(ctx, "ip:192.0.2.2", past, time.Minute)
+	require.Nil(t, err)
+	require.Nil(t, repo.LockLoginAttempt(ctx, "ip:192.0.2.2", time.Now().Add(time.Hour)))
+
+	require.Nil(t, repo.DeleteStaleLoginAttempts(ctx, time.Now().Add(-time.Minute)))
+
+	// Locked counters outlive their failures
+	attempt, err := repo.GetLoginAttempt(ctx, "ip:192.0.2.1")
+	require.Nil(t, err)
+	assert.Equal(t, 0, attempt.Failures)
+	attempt, err = repo.GetLoginAttempt(ctx, "ip:192.0.2.2")
+	require.Nil(t, err)
+	assert.Equal(t, 1, attempt.Failures)
+}
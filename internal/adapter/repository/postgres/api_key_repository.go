@@ -0,0 +1,203 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// apiKeyTouchInterval limits how often the last used time of a key is written
+const apiKeyTouchInterval = time.Minute
+
+type repoAPIKey struct {
+	ID         uuid.UUID      `db:"id"`
+	UserID     uuid.UUID      `db:"user_id"`
+	Name       string         `db:"name"`
+	Prefix     string         `db:"prefix"`
+	KeyHash    string         `db:"key_hash"`
+	Scopes     pq.StringArray `db:"scopes"`
+	LastUsedAt sql.NullTime   `db:"last_used_at"`
+	CreatedAt  time.Time      `db:"created_at"`
+}
+
+func (k *repoAPIKey) toDomain() *user.APIKey {
+	var lastUsedAt *time.Time
+	if k.LastUsedAt.Valid {
+		lastUsedAt = &k.LastUsedAt.Time
+	}
+
+	return &user.APIKey{
+		ID:         k.ID,
+		UserID:     k.UserID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		KeyHash:    k.KeyHash,
+		Scopes:     []string(k.Scopes),
+		LastUsedAt: lastUsedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+const repoTableAPIKey = "api_keys"
+
+type repoColumnPatternAPIKey struct {
+	ID         string
+	UserID     string
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     string
+	LastUsedAt string
+	CreatedAt  string
+}
+
+var repoColumnAPIKey = repoColumnPatternAPIKey{
+	ID:         "id",
+	UserID:     "user_id",
+	Name:       "name",
+	Prefix:     "prefix",
+	KeyHash:    "key_hash",
+	Scopes:     "scopes",
+	LastUsedAt: "last_used_at",
+	CreatedAt:  "created_at",
+}
+
+func (c repoColumnPatternAPIKey) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.UserID,
+		c.Name,
+		c.Prefix,
+		c.KeyHash,
+		c.Scopes,
+		c.LastUsedAt,
+		c.CreatedAt,
+	}, ", ")
+}
+
+func (r *PostgresRepository) CreateAPIKey(ctx context.Context, key *user.APIKey) (*user.APIKey, common.Error) {
+	query, args, err := r.pgsq.Insert(repoTableAPIKey).
+		SetMap(map[string]interface{}{
+			repoColumnAPIKey.UserID:  key.UserID,
+			repoColumnAPIKey.Name:    key.Name,
+			repoColumnAPIKey.Prefix:  key.Prefix,
+			repoColumnAPIKey.KeyHash: key.KeyHash,
+			repoColumnAPIKey.Scopes:  pq.StringArray(key.Scopes),
+		}).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnAPIKey.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for api key"))
+	}
+
+	var row repoAPIKey
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert api key")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert api key"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// ListUserAPIKeys returns the API keys of the user, newest first
+func (r *PostgresRepository) ListUserAPIKeys(ctx context.Context, userID uuid.UUID) ([]*user.APIKey, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnAPIKey.columns()).
+		From(repoTableAPIKey).
+		Where(sq.Eq{repoColumnAPIKey.UserID: userID}).
+		OrderBy(fmt.Sprintf("%s DESC", repoColumnAPIKey.CreatedAt)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for api keys"))
+	}
+
+	var rows []repoAPIKey
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select api keys"))
+	}
+
+	keys := make([]*user.APIKey, 0, len(rows))
+	for i := range rows {
+		keys = append(keys, rows[i].toDomain())
+	}
+	return keys, nil
+}
+
+func (r *PostgresRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*user.APIKey, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnAPIKey.columns()).
+		From(repoTableAPIKey).
+		Where(sq.Eq{repoColumnAPIKey.KeyHash: keyHash}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for api key"))
+	}
+
+	var row repoAPIKey
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("api key is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select api key"))
+	}
+
+	return row.toDomain(), nil
+}
+
+func (r *PostgresRepository) DeleteAPIKey(ctx context.Context, userID uuid.UUID, keyID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableAPIKey).
+		Where(sq.And{
+			sq.Eq{repoColumnAPIKey.ID: keyID},
+			sq.Eq{repoColumnAPIKey.UserID: userID},
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for api key"))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to delete api key"))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+	if rowsAffected == 0 {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("api key is not found"), common.WithMsg("api key is not found"))
+	}
+
+	return nil
+}
+
+// TouchAPIKey records that the key was used. Writes are skipped while the recorded time is recent enough.
+func (r *PostgresRepository) TouchAPIKey(ctx context.Context, keyID uuid.UUID, usedAt time.Time) common.Error {
+	query, args, err := r.pgsq.Update(repoTableAPIKey).
+		Set(repoColumnAPIKey.LastUsedAt, usedAt).
+		Where(sq.And{
+			sq.Eq{repoColumnAPIKey.ID: keyID},
+			sq.Or{
+				sq.Eq{repoColumnAPIKey.LastUsedAt: nil},
+				sq.Lt{repoColumnAPIKey.LastUsedAt: usedAt.Add(-apiKeyTouchInterval)},
+			},
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for api key"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to update api key"))
+	}
+
+	return nil
+}
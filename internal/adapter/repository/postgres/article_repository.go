@@ -25,6 +25,7 @@ type repoArticle struct {
 	Description sql.NullString  `db:"description"`
 	ImageURL    sql.NullString  `db:"image_url"`
 	Metadata    json.RawMessage `db:"metadata"`
+	Domain      sql.NullString  `db:"domain"`
 }
 
 func (a *repoArticle) toDomain() *article.Article {
@@ -35,6 +36,7 @@ func (a *repoArticle) toDomain() *article.Article {
 		Description: a.Description.String,
 		ImageURL:    a.ImageURL.String,
 		Metadata:    a.Metadata,
+		Domain:      a.Domain.String,
 	}
 }
 
@@ -52,6 +54,8 @@ type repoColumnPatternArticle struct {
 	Description   string
 	ImageURL      string
 	Metadata      string
+	Domain        string
+	SearchVector  string
 	AverageRating string
 }
 
@@ -62,6 +66,8 @@ var repoColumnArticle = repoColumnPatternArticle{
 	Description:   "description",
 	ImageURL:      "image_url",
 	Metadata:      "metadata",
+	Domain:        "domain",
+	SearchVector:  "search_vector",
 	AverageRating: "average_rating",
 }
 
@@ -73,6 +79,7 @@ func (c repoColumnPatternArticle) columns() string {
 		c.Description,
 		c.ImageURL,
 		c.Metadata,
+		c.Domain,
 	}
 	for i, v := range col {
 		col[i] = fmt.Sprintf("%s.%s", repoTableArticle, v)
@@ -83,11 +90,14 @@ func (c repoColumnPatternArticle) columns() string {
 // --- user_articles table ---
 
 type repoUserArticle struct {
-	ID          int64     `db:"id"`
-	UserID      uuid.UUID `db:"user_id"`
-	ArticleID   uuid.UUID `db:"article_id"`
-	Rate        int16     `db:"rate"`
-	CollectedAt time.Time `db:"collected_at"`
+	ID          int64        `db:"id"`
+	UserID      uuid.UUID    `db:"user_id"`
+	ArticleID   uuid.UUID    `db:"article_id"`
+	Rate        int16        `db:"rate"`
+	CollectedAt time.Time    `db:"collected_at"`
+	State       string       `db:"state"`
+	ReadAt      sql.NullTime `db:"read_at"`
+	ArchivedAt  sql.NullTime `db:"archived_at"`
 }
 
 func (ua *repoUserArticle) toDomain() *article.UserArticle {
@@ -97,9 +107,20 @@ func (ua *repoUserArticle) toDomain() *article.UserArticle {
 		ArticleID:   ua.ArticleID,
 		Rate:        ua.Rate,
 		CollectedAt: ua.CollectedAt,
+		State:       ua.State,
+		ReadAt:      nullTimeToPtr(ua.ReadAt),
+		ArchivedAt:  nullTimeToPtr(ua.ArchivedAt),
 	}
 }
 
+// nullTimeToPtr returns the time of t, nil if it is NULL
+func nullTimeToPtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
 const repoTableUserArticle = "user_articles"
 
 type repoColumnPatternUserArticle struct {
@@ -108,6 +129,9 @@ type repoColumnPatternUserArticle struct {
 	ArticleID   string
 	Rate        string
 	CollectedAt string
+	State       string
+	ReadAt      string
+	ArchivedAt  string
 }
 
 var repoColumnUserArticle = repoColumnPatternUserArticle{
@@ -116,6 +140,9 @@ var repoColumnUserArticle = repoColumnPatternUserArticle{
 	ArticleID:   "article_id",
 	Rate:        "rate",
 	CollectedAt: "collected_at",
+	State:       "state",
+	ReadAt:      "read_at",
+	ArchivedAt:  "archived_at",
 }
 
 func (c repoColumnPatternUserArticle) columns() string {
@@ -125,9 +152,27 @@ func (c repoColumnPatternUserArticle) columns() string {
 		c.ArticleID,
 		c.Rate,
 		c.CollectedAt,
+		c.State,
+		c.ReadAt,
+		c.ArchivedAt,
 	}, ", ")
 }
 
+// savedColumns are the columns selected with the articles columns for a repoSavedArticle
+func (c repoColumnPatternUserArticle) savedColumns() string {
+	col := []string{
+		c.Rate,
+		c.CollectedAt,
+		c.State,
+		c.ReadAt,
+		c.ArchivedAt,
+	}
+	for i, v := range col {
+		col[i] = fmt.Sprintf("%s.%s", repoTableUserArticle, v)
+	}
+	return strings.Join(col, ", ")
+}
+
 // --- repository methods ---
 
 func (r *PostgresRepository) CreateArticle(ctx context.Context, url string) (*article.Article, common.Error) {
@@ -188,6 +233,36 @@ func (r *PostgresRepository) CreateUserArticle(ctx context.Context, userID uuid.
 	return row.toDomain(), nil
 }
 
+// ImportUserArticle saves the article for the user as saved at collectedAt, or now when it is zero.
+// It returns false when the user had already saved the article, which is left as it is.
+func (r *PostgresRepository) ImportUserArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, collectedAt time.Time) (bool, common.Error) {
+	insert := map[string]interface{}{
+		repoColumnUserArticle.UserID:    userID,
+		repoColumnUserArticle.ArticleID: articleID,
+	}
+	if !collectedAt.IsZero() {
+		insert[repoColumnUserArticle.CollectedAt] = collectedAt
+	}
+
+	query, args, err := r.pgsq.Insert(repoTableUserArticle).
+		SetMap(insert).
+		Suffix(fmt.Sprintf("ON CONFLICT (%s, %s) DO NOTHING", repoColumnUserArticle.UserID, repoColumnUserArticle.ArticleID)).
+		ToSql()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for user_article"))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert user_article"))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+	return rowsAffected > 0, nil
+}
+
 func (r *PostgresRepository) GetUserArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) (*article.UserArticle, common.Error) {
 	where := sq.And{
 		sq.Eq{repoColumnUserArticle.UserID: userID},
@@ -214,34 +289,111 @@ func (r *PostgresRepository) GetUserArticle(ctx context.Context, userID uuid.UUI
 	return row.toDomain(), nil
 }
 
-func (r *PostgresRepository) ListArticles(ctx context.Context, userID uuid.UUID, afterID uuid.UUID, limit int) ([]*article.Article, common.Error) {
+// savedArticleFilter returns the condition selecting the saved articles in user_articles which match the filter
+func savedArticleFilter(filter article.ArticleFilter) sq.And {
+	column := func(table string, column string) string {
+		return fmt.Sprintf("%s.%s", table, column)
+	}
+	collectedAt := column(repoTableUserArticle, repoColumnUserArticle.CollectedAt)
+	rate := column(repoTableUserArticle, repoColumnUserArticle.Rate)
+
+	where := sq.And{tagFilter(filter)}
+	if len(filter.States) > 0 {
+		where = append(where, sq.Eq{column(repoTableUserArticle, repoColumnUserArticle.State): filter.States})
+	}
+	if !filter.CollectedAfter.IsZero() {
+		where = append(where, sq.GtOrEq{collectedAt: filter.CollectedAfter})
+	}
+	if !filter.CollectedBefore.IsZero() {
+		where = append(where, sq.Lt{collectedAt: filter.CollectedBefore})
+	}
+	if filter.MinRating > 0 {
+		where = append(where, sq.GtOrEq{rate: filter.MinRating})
+	}
+	if filter.MaxRating > 0 {
+		where = append(where, sq.LtOrEq{rate: filter.MaxRating})
+	}
+	if filter.Unrated {
+		where = append(where, sq.Eq{rate: 0})
+	}
+	if filter.Domain != "" {
+		domain := column(repoTableArticle, repoColumnArticle.Domain)
+		where = append(where, sq.Or{
+			sq.Eq{domain: filter.Domain},
+			sq.Like{domain: "%." + likeEscaper.Replace(filter.Domain)},
+		})
+	}
+	if filter.FetchStatus != "" {
+		status, _ := article.ParseRetryStatus(filter.FetchStatus)
+		where = append(where, sq.Expr(fmt.Sprintf("EXISTS (SELECT 1 FROM %s WHERE %s.%s = %s.%s AND %s.%s = ?)",
+			repoTableMetadataFetchRetries,
+			repoTableMetadataFetchRetries, repoColumnMetadataFetchRetries.ArticleID, repoTableArticle, repoColumnArticle.ID,
+			repoTableMetadataFetchRetries, repoColumnMetadataFetchRetries.Status,
+		), status))
+	}
+	return where
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// articleSortKey returns the expression saved articles are sorted by, and the type cursor keys are cast to
+func articleSortKey(sort article.ArticleSort) (string, string) {
+	switch sort.By {
+	case article.SortByRating:
+		return fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.Rate), "SMALLINT"
+	case article.SortByTitle:
+		return fmt.Sprintf("COALESCE(%s.%s, '')", repoTableArticle, repoColumnArticle.Title), "TEXT"
+	case article.SortByDomain:
+		return fmt.Sprintf("COALESCE(%s.%s, '')", repoTableArticle, repoColumnArticle.Domain), "TEXT"
+	default:
+		return fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.CollectedAt), "TIMESTAMPTZ"
+	}
+}
+
+// ListArticles returns the articles the user saved which match the filter in the order of the sort,
+// starting after the cursor, or with the first article if it is nil.
+func (r *PostgresRepository) ListArticles(ctx context.Context, userID uuid.UUID, filter article.ArticleFilter, sort article.ArticleSort, after *article.ArticleCursor, limit int) ([]*article.SavedArticle, common.Error) {
+	key, keyType := articleSortKey(sort)
+	id := fmt.Sprintf("%s.%s", repoTableArticle, repoColumnArticle.ID)
+	direction, comparison := "ASC", ">"
+	if sort.Order == article.SortOrderDesc {
+		direction, comparison = "DESC", "<"
+	}
+
 	where := sq.And{
 		sq.Eq{fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.UserID): userID},
+		savedArticleFilter(filter),
 	}
-	if afterID != uuid.Nil {
-		where = append(where, sq.Gt{fmt.Sprintf("%s.%s", repoTableArticle, repoColumnArticle.ID): afterID.String()})
+	if after != nil {
+		where = append(where, sq.Expr(fmt.Sprintf("(%s, %s) %s (?::%s, ?::UUID)", key, id, comparison, keyType), after.Key, after.ID))
 	}
 
-	query, args, err := r.pgsq.Select(repoColumnArticle.columns()).
+	query, args, err := r.pgsq.Select(
+		repoColumnArticle.columns(),
+		repoColumnUserArticle.savedColumns(),
+		repoColumnNote.latestNote(),
+	).
 		From(repoTableArticle).
 		Join(fmt.Sprintf("%s ON %s.%s = %s.%s",
 			repoTableUserArticle,
 			repoTableUserArticle, repoColumnUserArticle.ArticleID,
 			repoTableArticle, repoColumnArticle.ID)).
 		Where(where).
+		OrderBy(key+" "+direction, id+" "+direction).
 		Limit(uint64(limit)).
 		ToSql()
 	if err != nil {
 		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to build select query for articles")
 		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for articles"))
 	}
-	var rows []repoArticle
+	var rows []repoSavedArticle
 	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
 		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to select articles")
 		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select articles"))
 	}
 
-	articles := make([]*article.Article, 0, len(rows))
+	articles := make([]*article.SavedArticle, 0, len(rows))
 	for _, row := range rows {
 		articles = append(articles, row.toDomain())
 	}
@@ -249,6 +401,104 @@ func (r *PostgresRepository) ListArticles(ctx context.Context, userID uuid.UUID,
 	return articles, nil
 }
 
+// GetSavedArticle returns the article the user saved, with the data of the user.
+func (r *PostgresRepository) GetSavedArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) (*article.SavedArticle, common.Error) {
+	query, args, err := r.pgsq.Select(
+		repoColumnArticle.columns(),
+		repoColumnUserArticle.savedColumns(),
+		repoColumnNote.latestNote(),
+	).
+		From(repoTableArticle).
+		Join(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableUserArticle,
+			repoTableUserArticle, repoColumnUserArticle.ArticleID,
+			repoTableArticle, repoColumnArticle.ID)).
+		Where(sq.Eq{
+			fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.UserID): userID,
+			fmt.Sprintf("%s.%s", repoTableArticle, repoColumnArticle.ID):             articleID,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for saved article"))
+	}
+
+	var row repoSavedArticle
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err)
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select saved article"))
+	}
+	return row.toDomain(), nil
+}
+
+type repoSavedArticle struct {
+	repoArticle
+	Rate        int16          `db:"rate"`
+	CollectedAt time.Time      `db:"collected_at"`
+	State       string         `db:"state"`
+	ReadAt      sql.NullTime   `db:"read_at"`
+	ArchivedAt  sql.NullTime   `db:"archived_at"`
+	Note        sql.NullString `db:"note"` // only selected when listing articles
+}
+
+func (a *repoSavedArticle) toDomain() *article.SavedArticle {
+	return &article.SavedArticle{
+		Article:     *a.repoArticle.toDomain(),
+		Rate:        a.Rate,
+		CollectedAt: a.CollectedAt,
+		State:       a.State,
+		ReadAt:      nullTimeToPtr(a.ReadAt),
+		ArchivedAt:  nullTimeToPtr(a.ArchivedAt),
+		NoteSnippet: article.NoteSnippet(a.Note.String),
+	}
+}
+
+// IterateUserArticles calls fn with every article the user saved which matches the filter, oldest first.
+// Rows are streamed from the database, so the whole collection never has to fit in memory.
+func (r *PostgresRepository) IterateUserArticles(ctx context.Context, userID uuid.UUID, filter article.ArticleFilter, fn func(*article.SavedArticle) common.Error) common.Error {
+	query, args, err := r.pgsq.Select(
+		repoColumnArticle.columns(),
+		repoColumnUserArticle.savedColumns(),
+	).
+		From(repoTableArticle).
+		Join(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableUserArticle,
+			repoTableUserArticle, repoColumnUserArticle.ArticleID,
+			repoTableArticle, repoColumnArticle.ID)).
+		Where(sq.And{
+			sq.Eq{fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.UserID): userID},
+			savedArticleFilter(filter),
+		}).
+		OrderBy(fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.CollectedAt)).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for user articles"))
+	}
+
+	rows, err := r.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to select user articles")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select user articles"))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row repoSavedArticle
+		if err := rows.StructScan(&row); err != nil {
+			return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to scan user article"))
+		}
+		if cerr := fn(row.toDomain()); cerr != nil {
+			return cerr
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to iterate user articles"))
+	}
+
+	return nil
+}
+
 func (r *PostgresRepository) DeleteUserArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) common.Error {
 	where := sq.And{
 		sq.Eq{repoColumnUserArticle.UserID: userID},
@@ -304,6 +554,70 @@ func (r *PostgresRepository) UpdateUserArticleRate(ctx context.Context, userID u
 	return nil
 }
 
+// UpdateUserArticleStates moves the saved articles of the IDs to the read state and returns how many it moved.
+// Articles the user did not save are skipped. Moving to read or archived sets read_at unless it is set already,
+// so it tells when the article was first read, and moving to unread clears it. archived_at is set when an article
+// is archived and kept while it stays archived, moving to any other state clears it.
+func (r *PostgresRepository) UpdateUserArticleStates(ctx context.Context, userID uuid.UUID, articleIDs []uuid.UUID, state string) (int64, common.Error) {
+	readAt := sq.Expr(fmt.Sprintf("CASE WHEN ? = '%s' THEN NULL ELSE COALESCE(%s, NOW()) END",
+		article.ReadStateUnread, repoColumnUserArticle.ReadAt), state)
+	archivedAt := sq.Expr(fmt.Sprintf("CASE WHEN ? = '%s' THEN COALESCE(%s, NOW()) END",
+		article.ReadStateArchived, repoColumnUserArticle.ArchivedAt), state)
+
+	query, args, err := r.pgsq.Update(repoTableUserArticle).
+		Set(repoColumnUserArticle.State, state).
+		Set(repoColumnUserArticle.ReadAt, readAt).
+		Set(repoColumnUserArticle.ArchivedAt, archivedAt).
+		Where(sq.Eq{
+			repoColumnUserArticle.UserID:    userID,
+			repoColumnUserArticle.ArticleID: articleIDs,
+		}).
+		ToSql()
+	if err != nil {
+		return 0, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for user_article state"))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to update user_article state")
+		return 0, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to update user_article state"))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+
+	return rowsAffected, nil
+}
+
+// CountUserArticlesByState returns the number of articles the user saved in each read state.
+// States without articles are left out.
+func (r *PostgresRepository) CountUserArticlesByState(ctx context.Context, userID uuid.UUID) (map[string]int, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnUserArticle.State, "COUNT(*) AS count").
+		From(repoTableUserArticle).
+		Where(sq.Eq{repoColumnUserArticle.UserID: userID}).
+		GroupBy(repoColumnUserArticle.State).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build count query for user_articles"))
+	}
+
+	var rows []struct {
+		State string `db:"state"`
+		Count int    `db:"count"`
+	}
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to count user_articles")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to count user_articles"))
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.State] = row.Count
+	}
+	return counts, nil
+}
+
 func (r *PostgresRepository) RefreshMaterializedView(ctx context.Context) common.Error {
 	_, err := r.db.ExecContext(ctx, "REFRESH MATERIALIZED VIEW CONCURRENTLY materialized_articles_average_rate;")
 	if err != nil {
@@ -409,6 +723,26 @@ func (r *PostgresRepository) CreateMetadataFetchRetry(ctx context.Context, artic
 	return nil
 }
 
+// GetMetadataFetchRetry returns how fetching the metadata of the article went.
+func (r *PostgresRepository) GetMetadataFetchRetry(ctx context.Context, articleID uuid.UUID) (*article.MetadataFetchRetry, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnMetadataFetchRetries.columns()).
+		From(repoTableMetadataFetchRetries).
+		Where(sq.Eq{repoColumnMetadataFetchRetries.ArticleID: articleID}).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for metadata fetch retry"))
+	}
+
+	var row repoMetadataFetchRetry
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err)
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select metadata fetch retry"))
+	}
+	return row.toDomain(), nil
+}
+
 func (r *PostgresRepository) GetPendingMetadataFetchRetries(ctx context.Context) ([]*article.MetadataFetchRetry, common.Error) {
 	query, args, err := r.pgsq.Select(repoColumnMetadataFetchRetries.columns()).
 		From(repoTableMetadataFetchRetries).
@@ -508,6 +842,8 @@ func (r *PostgresRepository) UpdateArticle(ctx context.Context, art *article.Art
 		repoColumnArticle.Description: art.Description,
 		repoColumnArticle.ImageURL:    art.ImageURL,
 		repoColumnArticle.Metadata:    art.Metadata,
+		// Index the article for search again with what it now says
+		repoColumnArticle.SearchVector: sq.Expr("?::TSVECTOR", article.SearchVector(art)),
 	}
 
 	query, args, err := r.pgsq.Update(repoTableArticle).
@@ -525,6 +861,98 @@ func (r *PostgresRepository) UpdateArticle(ctx context.Context, art *article.Art
 	return nil
 }
 
+// UpdateArticleSearchVector indexes an article which has not been indexed for search yet with the tsvector literal.
+// The article is left alone if it was indexed meanwhile, UpdateArticle indexes it with its newer metadata.
+func (r *PostgresRepository) UpdateArticleSearchVector(ctx context.Context, articleID uuid.UUID, vector string) common.Error {
+	query, args, err := r.pgsq.Update(repoTableArticle).
+		Set(repoColumnArticle.SearchVector, sq.Expr("?::TSVECTOR", vector)).
+		Where(sq.Eq{
+			repoColumnArticle.ID:           articleID,
+			repoColumnArticle.SearchVector: nil,
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for article search vector"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to update article search vector"))
+	}
+
+	return nil
+}
+
+// ListUnindexedArticles returns up to limit articles which have not been indexed for search yet.
+func (r *PostgresRepository) ListUnindexedArticles(ctx context.Context, limit int) ([]*article.Article, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnArticle.columns()).
+		From(repoTableArticle).
+		Where(sq.Eq{repoColumnArticle.SearchVector: nil}).
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for unindexed articles"))
+	}
+
+	var rows []repoArticle
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to select unindexed articles")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select unindexed articles"))
+	}
+
+	articles := make([]*article.Article, 0, len(rows))
+	for i := range rows {
+		articles = append(articles, rows[i].toDomain())
+	}
+	return articles, nil
+}
+
+// SearchArticles returns the articles the user saved which match the tsquery, best matches first.
+func (r *PostgresRepository) SearchArticles(ctx context.Context, userID uuid.UUID, tsquery string, limit int) ([]*article.SearchResult, common.Error) {
+	searchVector := fmt.Sprintf("%s.%s", repoTableArticle, repoColumnArticle.SearchVector)
+	query, args, err := r.pgsq.Select(
+		repoColumnArticle.columns(),
+		repoColumnUserArticle.savedColumns(),
+		repoColumnNote.latestNote(),
+	).
+		Column(sq.Expr(fmt.Sprintf("ts_rank(%s, ?::TSQUERY) AS rank", searchVector), tsquery)).
+		From(repoTableArticle).
+		Join(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableUserArticle,
+			repoTableUserArticle, repoColumnUserArticle.ArticleID,
+			repoTableArticle, repoColumnArticle.ID)).
+		Where(sq.Eq{fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.UserID): userID}).
+		Where(sq.Expr(fmt.Sprintf("%s @@ ?::TSQUERY", searchVector), tsquery)).
+		OrderBy("rank DESC", fmt.Sprintf("%s.%s", repoTableArticle, repoColumnArticle.ID)).
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build search query for articles"))
+	}
+
+	var rows []repoSearchResult
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to search articles")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to search articles"))
+	}
+	return toSearchResults(rows), nil
+}
+
+type repoSearchResult struct {
+	repoSavedArticle
+	Rank float64 `db:"rank"`
+}
+
+func toSearchResults(rows []repoSearchResult) []*article.SearchResult {
+	results := make([]*article.SearchResult, 0, len(rows))
+	for i := range rows {
+		results = append(results, &article.SearchResult{
+			SavedArticle: *rows[i].toDomain(),
+			Rank:         rows[i].Rank,
+		})
+	}
+	return results
+}
+
 func (r *PostgresRepository) GetTopRatedArticlesExcludingUser(ctx context.Context, excludedUserID uuid.UUID, limit int) (article.RecommendationArticles, common.Error) {
 	selectColumns := []string{
 		fmt.Sprintf("%s.%s", repoTableArticle, repoColumnArticle.ID),
@@ -8,6 +8,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
 	"github.com/sappy5678/DeeliAi/testdata"
 )
 
@@ -52,15 +54,54 @@ func TestPostgresRepository_GetUserArticle(t *testing.T) {
 	assert.Equal(t, int16(5), userArticle.Rate)
 }
 
+func TestPostgresRepository_GetSavedArticle(t *testing.T) {
+	db := getTestPostgresDB()
+	repo := initRepository(t, db, testdata.Path(testdata.TestDataUser), testdata.Path(testdata.TestDataArticle), testdata.Path(testdata.TestDataUserArticle))
+
+	userID := uuid.MustParse("8a6d6322-a428-441a-8433-9551b1048a8c")
+	articleID := uuid.MustParse("1a7a3f16-6e3b-4758-9882-03d4a7d0251b")
+
+	saved, err := repo.GetSavedArticle(context.Background(), userID, articleID)
+	require.NoError(t, err)
+	assert.Equal(t, articleID, saved.ID)
+	assert.Equal(t, int16(5), saved.Rate)
+
+	_, err = repo.GetSavedArticle(context.Background(), uuid.New(), articleID)
+	assert.True(t, common.IsErrorCode(err, common.ErrorCodeResourceNotFound))
+}
+
 func TestPostgresRepository_ListArticles(t *testing.T) {
 	db := getTestPostgresDB()
 	repo := initRepository(t, db, testdata.Path(testdata.TestDataUser), testdata.Path(testdata.TestDataArticle), testdata.Path(testdata.TestDataUserArticle))
 
 	userID := uuid.MustParse("8a6d6322-a428-441a-8433-9551b1048a8c")
 
-	articles, err := repo.ListArticles(context.Background(), userID, uuid.Nil, 10)
+	sort := article.ArticleSort{By: article.SortByCollectedAt, Order: article.SortOrderDesc}
+	articles, err := repo.ListArticles(context.Background(), userID, article.ArticleFilter{}, sort, nil, 10)
+	require.NoError(t, err)
+	require.Len(t, articles, 2)
+	assert.False(t, articles[0].CollectedAt.Before(articles[1].CollectedAt))
+
+	// The next page starts after the cursor
+	articles, err = repo.ListArticles(context.Background(), userID, article.ArticleFilter{}, sort, article.NewArticleCursor(sort, articles[0]), 10)
 	require.NoError(t, err)
-	assert.Len(t, articles, 2)
+	assert.Len(t, articles, 1)
+}
+
+func TestPostgresRepository_IterateUserArticles(t *testing.T) {
+	db := getTestPostgresDB()
+	repo := initRepository(t, db, testdata.Path(testdata.TestDataUser), testdata.Path(testdata.TestDataArticle), testdata.Path(testdata.TestDataUserArticle))
+
+	userID := uuid.MustParse("8a6d6322-a428-441a-8433-9551b1048a8c")
+
+	var saved []*article.SavedArticle
+	err := repo.IterateUserArticles(context.Background(), userID, article.ArticleFilter{}, func(a *article.SavedArticle) common.Error {
+		saved = append(saved, a)
+		return nil
+	})
+	require.Nil(t, err)
+	require.Len(t, saved, 2)
+	assert.False(t, saved[0].CollectedAt.After(saved[1].CollectedAt))
 }
 
 func TestPostgresRepository_DeleteUserArticle(t *testing.T) {
@@ -107,3 +148,121 @@ func TestPostgresRepository_DeleteUserArticleRate(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, int16(0), userArticle.Rate)
 }
+
+func TestPostgresRepository_UpdateUserArticleStates(t *testing.T) {
+	db := getTestPostgresDB()
+	repo := initRepository(t, db, testdata.Path(testdata.TestDataUser), testdata.Path(testdata.TestDataArticle), testdata.Path(testdata.TestDataUserArticle))
+	ctx := context.Background()
+
+	userID := uuid.MustParse("8a6d6322-a428-441a-8433-9551b1048a8c")
+	articleID := uuid.MustParse("1a7a3f16-6e3b-4758-9882-03d4a7d0251b")
+	moveTo := func(state string) *article.UserArticle {
+		t.Helper()
+		updated, err := repo.UpdateUserArticleStates(ctx, userID, []uuid.UUID{articleID}, state)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), updated)
+		userArticle, err := repo.GetUserArticle(ctx, userID, articleID)
+		require.NoError(t, err)
+		assert.Equal(t, state, userArticle.State)
+		return userArticle
+	}
+
+	// unread -> read stamps the read time
+	read := moveTo(article.ReadStateRead)
+	require.NotNil(t, read.ReadAt)
+	assert.Nil(t, read.ArchivedAt)
+
+	// read -> archived keeps the read time and stamps the archive time
+	archived := moveTo(article.ReadStateArchived)
+	require.NotNil(t, archived.ReadAt)
+	assert.True(t, read.ReadAt.Equal(*archived.ReadAt))
+	require.NotNil(t, archived.ArchivedAt)
+
+	// archived -> archived keeps the archive time
+	again := moveTo(article.ReadStateArchived)
+	require.NotNil(t, again.ArchivedAt)
+	assert.True(t, archived.ArchivedAt.Equal(*again.ArchivedAt))
+
+	// archived -> read keeps the read time and clears the archive time
+	reread := moveTo(article.ReadStateRead)
+	require.NotNil(t, reread.ReadAt)
+	assert.True(t, read.ReadAt.Equal(*reread.ReadAt))
+	assert.Nil(t, reread.ArchivedAt)
+
+	// archived -> unread clears both
+	moveTo(article.ReadStateArchived)
+	unread := moveTo(article.ReadStateUnread)
+	assert.Nil(t, unread.ReadAt)
+	assert.Nil(t, unread.ArchivedAt)
+
+	// Articles the user did not save are skipped
+	updated, err := repo.UpdateUserArticleStates(ctx, uuid.New(), []uuid.UUID{articleID}, article.ReadStateRead)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), updated)
+}
+
+func TestPostgresRepository_UpdateArticleSearchVector(t *testing.T) {
+	db := getTestPostgresDB()
+	repo := initRepository(t, db, testdata.Path(testdata.TestDataUser))
+	ctx := context.Background()
+
+	art, err := repo.CreateArticle(ctx, "https://example.com/unindexed")
+	require.NoError(t, err)
+	searchVector := func() string {
+		var vector string
+		require.NoError(t, db.GetContext(ctx, &vector, "SELECT search_vector::TEXT FROM articles WHERE id = $1", art.ID))
+		return vector
+	}
+
+	require.NoError(t, repo.UpdateArticleSearchVector(ctx, art.ID, "'backfill':1A"))
+	assert.Equal(t, "'backfill':1A", searchVector())
+	unindexed, err := repo.ListUnindexedArticles(ctx, 100)
+	require.NoError(t, err)
+	for _, a := range unindexed {
+		assert.NotEqual(t, art.ID, a.ID)
+	}
+
+	// Articles indexed meanwhile with their fetched metadata keep that index
+	art.Title = "Fetched title"
+	require.NoError(t, repo.UpdateArticle(ctx, art))
+	indexed := searchVector()
+	require.NoError(t, repo.UpdateArticleSearchVector(ctx, art.ID, "'stale':1A"))
+	assert.Equal(t, indexed, searchVector())
+}
+
+func TestPostgresRepository_ListUserArticleNotes(t *testing.T) {
+	db := getTestPostgresDB()
+	repo := initRepository(t, db, testdata.Path(testdata.TestDataUser), testdata.Path(testdata.TestDataArticle), testdata.Path(testdata.TestDataUserArticle))
+	ctx := context.Background()
+
+	userID := uuid.MustParse("8a6d6322-a428-441a-8433-9551b1048a8c")
+	articleID := uuid.MustParse("1a7a3f16-6e3b-4758-9882-03d4a7d0251b")
+	otherID := createTestUser(t, repo, "other-reader")
+	_, err := repo.CreateUserArticle(ctx, otherID, articleID)
+	require.NoError(t, err)
+
+	note, err := repo.CreateNote(ctx, userID, articleID, "Worth a second read")
+	require.NoError(t, err)
+	highlight, err := repo.CreateHighlight(ctx, userID, &article.Highlight{ArticleID: articleID, Text: "quoted", Position: &article.HighlightPosition{Start: 3, End: 9}})
+	require.NoError(t, err)
+	_, err = repo.CreateNote(ctx, otherID, articleID, "Someone else's")
+	require.NoError(t, err)
+
+	notes, err := repo.ListUserArticleNotes(ctx, userID, []uuid.UUID{articleID, uuid.New()})
+	require.NoError(t, err)
+	require.Len(t, notes, 1)
+	require.Len(t, notes[articleID], 1)
+	assert.Equal(t, note.ID, notes[articleID][0].ID)
+	assert.Equal(t, articleID, notes[articleID][0].ArticleID)
+
+	highlights, err := repo.ListUserArticleHighlights(ctx, userID, []uuid.UUID{articleID})
+	require.NoError(t, err)
+	require.Len(t, highlights[articleID], 1)
+	assert.Equal(t, highlight.ID, highlights[articleID][0].ID)
+	assert.Equal(t, highlight.Position, highlights[articleID][0].Position)
+
+	// Articles the user did not save have nothing
+	highlights, err = repo.ListUserArticleHighlights(ctx, uuid.New(), []uuid.UUID{articleID})
+	require.NoError(t, err)
+	assert.Empty(t, highlights)
+}
@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+type repoAuditEvent struct {
+	ID         int64         `db:"id"`
+	ActorID    uuid.NullUUID `db:"actor_id"`
+	Action     string        `db:"action"`
+	TargetType string        `db:"target_type"`
+	TargetID   string        `db:"target_id"`
+	IP         string        `db:"ip"`
+	UserAgent  string        `db:"user_agent"`
+	RequestID  string        `db:"request_id"`
+	Outcome    string        `db:"outcome"`
+	Reason     string        `db:"reason"`
+	Count      int           `db:"count"`
+	CreatedAt  time.Time     `db:"created_at"`
+}
+
+func (e *repoAuditEvent) toDomain() *audit.Event {
+	return &audit.Event{
+		ID:         e.ID,
+		ActorID:    e.ActorID.UUID,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IP:         e.IP,
+		UserAgent:  e.UserAgent,
+		RequestID:  e.RequestID,
+		Outcome:    e.Outcome,
+		Reason:     e.Reason,
+		Count:      e.Count,
+		CreatedAt:  e.CreatedAt,
+	}
+}
+
+const repoTableAuditEvent = "audit_events"
+
+type repoColumnPatternAuditEvent struct {
+	ID         string
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	UserAgent  string
+	RequestID  string
+	Outcome    string
+	Reason     string
+	Count      string
+	CreatedAt  string
+}
+
+var repoColumnAuditEvent = repoColumnPatternAuditEvent{
+	ID:         "id",
+	ActorID:    "actor_id",
+	Action:     "action",
+	TargetType: "target_type",
+	TargetID:   "target_id",
+	IP:         "ip",
+	UserAgent:  "user_agent",
+	RequestID:  "request_id",
+	Outcome:    "outcome",
+	Reason:     "reason",
+	Count:      "count",
+	CreatedAt:  "created_at",
+}
+
+func (c repoColumnPatternAuditEvent) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.ActorID,
+		c.Action,
+		c.TargetType,
+		c.TargetID,
+		c.IP,
+		c.UserAgent,
+		c.RequestID,
+		c.Outcome,
+		c.Reason,
+		c.Count,
+		c.CreatedAt,
+	}, ", ")
+}
+
+func (r *PostgresRepository) CreateAuditEvent(ctx context.Context, event *audit.Event) common.Error {
+	actorID := uuid.NullUUID{UUID: event.ActorID, Valid: event.ActorID != uuid.Nil}
+	query, args, err := r.pgsq.Insert(repoTableAuditEvent).
+		SetMap(map[string]interface{}{
+			repoColumnAuditEvent.ActorID:    actorID,
+			repoColumnAuditEvent.Action:     event.Action,
+			repoColumnAuditEvent.TargetType: event.TargetType,
+			repoColumnAuditEvent.TargetID:   event.TargetID,
+			repoColumnAuditEvent.IP:         event.IP,
+			repoColumnAuditEvent.UserAgent:  event.UserAgent,
+			repoColumnAuditEvent.RequestID:  event.RequestID,
+			repoColumnAuditEvent.Outcome:    event.Outcome,
+			repoColumnAuditEvent.Reason:     event.Reason,
+			repoColumnAuditEvent.Count:      event.Count,
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for audit event"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert audit event"))
+	}
+
+	return nil
+}
+
+// ListAuditEvents returns the events matching the filter, newest first, starting before the event beforeID.
+// A beforeID of 0 starts with the newest event.
+func (r *PostgresRepository) ListAuditEvents(ctx context.Context, filter audit.Filter, beforeID int64, limit int) ([]*audit.Event, common.Error) {
+	where := sq.And{}
+	if filter.UserID != uuid.Nil {
+		where = append(where, sq.Or{
+			sq.Eq{repoColumnAuditEvent.ActorID: filter.UserID},
+			sq.Eq{
+				repoColumnAuditEvent.TargetType: audit.TargetUser,
+				repoColumnAuditEvent.TargetID:   filter.UserID.String(),
+			},
+		})
+	}
+	if filter.Action != "" {
+		where = append(where, sq.Eq{repoColumnAuditEvent.Action: filter.Action})
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, sq.GtOrEq{repoColumnAuditEvent.CreatedAt: filter.Since})
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, sq.Lt{repoColumnAuditEvent.CreatedAt: filter.Until})
+	}
+	if beforeID > 0 {
+		where = append(where, sq.Lt{repoColumnAuditEvent.ID: beforeID})
+	}
+
+	query, args, err := r.pgsq.Select(repoColumnAuditEvent.columns()).
+		From(repoTableAuditEvent).
+		Where(where).
+		OrderBy(fmt.Sprintf("%s DESC", repoColumnAuditEvent.ID)).
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for audit events"))
+	}
+
+	var rows []repoAuditEvent
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to list audit events")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select audit events"))
+	}
+
+	events := make([]*audit.Event, 0, len(rows))
+	for i := range rows {
+		events = append(events, rows[i].toDomain())
+	}
+	return events, nil
+}
@@ -0,0 +1,476 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// --- collections table ---
+
+type repoCollection struct {
+	ID           uuid.UUID `db:"id"`
+	UserID       uuid.UUID `db:"user_id"`
+	Name         string    `db:"name"`
+	ArticleCount int       `db:"article_count"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+func (c *repoCollection) toDomain() *article.Collection {
+	return &article.Collection{
+		ID:           c.ID,
+		UserID:       c.UserID,
+		Name:         c.Name,
+		ArticleCount: c.ArticleCount,
+		CreatedAt:    c.CreatedAt,
+	}
+}
+
+const repoTableCollection = "collections"
+
+type repoColumnPatternCollection struct {
+	ID        string
+	UserID    string
+	Name      string
+	CreatedAt string
+}
+
+var repoColumnCollection = repoColumnPatternCollection{
+	ID:        "id",
+	UserID:    "user_id",
+	Name:      "name",
+	CreatedAt: "created_at",
+}
+
+func (c repoColumnPatternCollection) columns() string {
+	col := []string{
+		c.ID,
+		c.UserID,
+		c.Name,
+		c.CreatedAt,
+	}
+	for i, v := range col {
+		col[i] = fmt.Sprintf("%s.%s", repoTableCollection, v)
+	}
+	return strings.Join(col, ", ")
+}
+
+// articleCount selects the number of articles in the collection as article_count
+func (c repoColumnPatternCollection) articleCount() string {
+	return fmt.Sprintf("(SELECT COUNT(*) FROM %s WHERE %s.%s = %s.%s) AS article_count",
+		repoTableCollectionArticle,
+		repoTableCollectionArticle, repoColumnCollectionArticle.CollectionID,
+		repoTableCollection, c.ID)
+}
+
+// --- collection_articles table ---
+
+const repoTableCollectionArticle = "collection_articles"
+
+type repoColumnPatternCollectionArticle struct {
+	CollectionID  string
+	UserArticleID string
+	Position      string
+	AddedAt       string
+}
+
+var repoColumnCollectionArticle = repoColumnPatternCollectionArticle{
+	CollectionID:  "collection_id",
+	UserArticleID: "user_article_id",
+	Position:      "position",
+	AddedAt:       "added_at",
+}
+
+// --- repository methods ---
+
+func (r *PostgresRepository) CreateCollection(ctx context.Context, userID uuid.UUID, name string) (*article.Collection, common.Error) {
+	query, args, err := r.pgsq.Insert(repoTableCollection).
+		SetMap(map[string]interface{}{
+			repoColumnCollection.UserID: userID,
+			repoColumnCollection.Name:   name,
+		}).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnCollection.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for collection"))
+	}
+
+	var row repoCollection
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if _, ok := uniqueViolation(err); ok {
+			return nil, common.NewError(common.ErrorCodeResourceConflict, err, common.WithMsg("collection already exists"))
+		}
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert collection")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert collection"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// GetCollection returns a collection of the user with the number of articles in it.
+func (r *PostgresRepository) GetCollection(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID) (*article.Collection, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnCollection.columns(), repoColumnCollection.articleCount()).
+		From(repoTableCollection).
+		Where(sq.Eq{
+			repoColumnCollection.ID:     collectionID,
+			repoColumnCollection.UserID: userID,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for collection"))
+	}
+
+	var row repoCollection
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("collection is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select collection"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// ListCollections returns the collections of the user by name, with the number of articles in them.
+func (r *PostgresRepository) ListCollections(ctx context.Context, userID uuid.UUID) ([]*article.Collection, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnCollection.columns(), repoColumnCollection.articleCount()).
+		From(repoTableCollection).
+		Where(sq.Eq{repoColumnCollection.UserID: userID}).
+		OrderBy(fmt.Sprintf("LOWER(%s.%s)", repoTableCollection, repoColumnCollection.Name)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for collections"))
+	}
+
+	var rows []repoCollection
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to list collections")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select collections"))
+	}
+
+	collections := make([]*article.Collection, 0, len(rows))
+	for i := range rows {
+		collections = append(collections, rows[i].toDomain())
+	}
+	return collections, nil
+}
+
+func (r *PostgresRepository) UpdateCollectionName(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, name string) (*article.Collection, common.Error) {
+	query, args, err := r.pgsq.Update(repoTableCollection).
+		Set(repoColumnCollection.Name, name).
+		Where(sq.Eq{
+			repoColumnCollection.ID:     collectionID,
+			repoColumnCollection.UserID: userID,
+		}).
+		Suffix(fmt.Sprintf("RETURNING %s, %s", repoColumnCollection.columns(), repoColumnCollection.articleCount())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for collection"))
+	}
+
+	var row repoCollection
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("collection is not found"))
+		}
+		if _, ok := uniqueViolation(err); ok {
+			return nil, common.NewError(common.ErrorCodeResourceConflict, err, common.WithMsg("collection already exists"))
+		}
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to update collection")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to update collection"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// DeleteCollection deletes the collection, the saved articles in it are kept.
+func (r *PostgresRepository) DeleteCollection(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableCollection).
+		Where(sq.Eq{
+			repoColumnCollection.ID:     collectionID,
+			repoColumnCollection.UserID: userID,
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for collection"))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to delete collection"))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+	if rowsAffected == 0 {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("collection not found"), common.WithMsg("collection is not found"))
+	}
+
+	return nil
+}
+
+// AddCollectionArticle appends a saved article of the user to the end of the collection.
+func (r *PostgresRepository) AddCollectionArticle(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID) common.Error {
+	tx, cerr := r.beginTx()
+	if cerr != nil {
+		return cerr
+	}
+
+	cerr = r.lockCollection(ctx, tx, userID, collectionID)
+	if cerr == nil {
+		cerr = r.appendCollectionArticle(ctx, tx, userID, collectionID, articleID)
+	}
+	return r.finishTx(cerr, tx)
+}
+
+func (r *PostgresRepository) appendCollectionArticle(ctx context.Context, db sqlContextGetter, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID) common.Error {
+	nextPosition := fmt.Sprintf("(SELECT COALESCE(MAX(%s) + 1, 0) FROM %s WHERE %s = ?)",
+		repoColumnCollectionArticle.Position, repoTableCollectionArticle, repoColumnCollectionArticle.CollectionID)
+	userArticle := sq.Select().
+		Column("?::UUID", collectionID).
+		Column(repoColumnUserArticle.ID).
+		Column(nextPosition, collectionID).
+		From(repoTableUserArticle).
+		Where(sq.Eq{
+			repoColumnUserArticle.UserID:    userID,
+			repoColumnUserArticle.ArticleID: articleID,
+		})
+
+	query, args, err := r.pgsq.Insert(repoTableCollectionArticle).
+		Columns(
+			repoColumnCollectionArticle.CollectionID,
+			repoColumnCollectionArticle.UserArticleID,
+			repoColumnCollectionArticle.Position,
+		).
+		Select(userArticle).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for collection article"))
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		if _, ok := uniqueViolation(err); ok {
+			return common.NewError(common.ErrorCodeResourceConflict, err, common.WithMsg("the article is already in the collection"))
+		}
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert collection article")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert collection article"))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+	if rowsAffected == 0 {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("user article not found"), common.WithMsg("article is not found"))
+	}
+
+	return nil
+}
+
+// RemoveCollectionArticle takes the article out of the collection, the user keeps it saved.
+func (r *PostgresRepository) RemoveCollectionArticle(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableCollectionArticle).
+		Where(sq.Eq{repoColumnCollectionArticle.CollectionID: collectionID}).
+		Where(sq.Expr(fmt.Sprintf("%s IN (?)", repoColumnCollectionArticle.UserArticleID), r.userArticleIDQuery(userID, articleID))).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for collection article"))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to delete collection article"))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+	if rowsAffected == 0 {
+		return common.NewError(common.ErrorCodeResourceNotFound, article.ErrNotInCollection, common.WithMsg(article.ErrNotInCollection.Error()))
+	}
+
+	return nil
+}
+
+// MoveCollectionArticle moves an article of the collection to the 0-based position, shifting the articles after it.
+func (r *PostgresRepository) MoveCollectionArticle(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID, position int) common.Error {
+	tx, cerr := r.beginTx()
+	if cerr != nil {
+		return cerr
+	}
+
+	cerr = r.lockCollection(ctx, tx, userID, collectionID)
+	if cerr == nil {
+		cerr = r.moveCollectionArticle(ctx, tx, collectionID, articleID, position)
+	}
+	return r.finishTx(cerr, tx)
+}
+
+// moveCollectionArticle renumbers the articles of the collection in their new order, the collection must be locked
+func (r *PostgresRepository) moveCollectionArticle(ctx context.Context, db sqlContextGetter, collectionID uuid.UUID, articleID uuid.UUID, position int) common.Error {
+	query, args, err := r.pgsq.Select(
+		fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.ID),
+		fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.ArticleID),
+	).
+		From(repoTableCollectionArticle).
+		Join(r.joinCollectionUserArticle()).
+		Where(sq.Eq{repoColumnCollectionArticle.CollectionID: collectionID}).
+		OrderBy(repoColumnCollectionArticle.Position, repoColumnCollectionArticle.UserArticleID).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for collection articles"))
+	}
+
+	var rows []repoUserArticle
+	if err = db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to select collection articles")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select collection articles"))
+	}
+
+	articleIDs := make([]uuid.UUID, 0, len(rows))
+	userArticleIDs := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		articleIDs = append(articleIDs, row.ArticleID)
+		userArticleIDs[row.ArticleID] = row.ID
+	}
+	moved, err := article.MoveArticle(articleIDs, articleID, position)
+	if err != nil {
+		if errors.Is(err, article.ErrNotInCollection) {
+			return common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg(err.Error()))
+		}
+		return common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+
+	ordered := make([]int64, 0, len(moved))
+	positions := make([]int64, 0, len(moved))
+	for i, id := range moved {
+		ordered = append(ordered, userArticleIDs[id])
+		positions = append(positions, int64(i))
+	}
+
+	query, args, err = r.pgsq.Update(repoTableCollectionArticle).
+		Set(repoColumnCollectionArticle.Position, sq.Expr(fmt.Sprintf(
+			"(SELECT ordered.position FROM unnest(?::BIGINT[], ?::INTEGER[]) AS ordered (user_article_id, position) WHERE ordered.user_article_id = %s.%s)",
+			repoTableCollectionArticle, repoColumnCollectionArticle.UserArticleID,
+		), pq.Array(ordered), pq.Array(positions))).
+		Where(sq.Eq{repoColumnCollectionArticle.CollectionID: collectionID}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for collection articles"))
+	}
+
+	if _, err = db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to update collection articles")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to update collection article positions"))
+	}
+
+	return nil
+}
+
+// ListCollectionArticles returns the saved articles in the collection in its order, starting after the article afterID.
+func (r *PostgresRepository) ListCollectionArticles(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, afterID uuid.UUID, limit int) ([]*article.SavedArticle, common.Error) {
+	where := sq.And{
+		sq.Eq{
+			fmt.Sprintf("%s.%s", repoTableCollectionArticle, repoColumnCollectionArticle.CollectionID): collectionID,
+			fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.UserID):                   userID,
+		},
+	}
+	if afterID != uuid.Nil {
+		// Continue after the position of the article, user_article_id breaks ties
+		after := sq.Select(repoColumnCollectionArticle.Position, repoColumnCollectionArticle.UserArticleID).
+			From(repoTableCollectionArticle).
+			Where(sq.Eq{repoColumnCollectionArticle.CollectionID: collectionID}).
+			Where(sq.Expr(fmt.Sprintf("%s IN (?)", repoColumnCollectionArticle.UserArticleID), r.userArticleIDQuery(userID, afterID)))
+		where = append(where, sq.Expr(fmt.Sprintf("(%s.%s, %s.%s) > (?)",
+			repoTableCollectionArticle, repoColumnCollectionArticle.Position,
+			repoTableCollectionArticle, repoColumnCollectionArticle.UserArticleID,
+		), after))
+	}
+
+	query, args, err := r.pgsq.Select(
+		repoColumnArticle.columns(),
+		repoColumnUserArticle.savedColumns(),
+		repoColumnNote.latestNote(),
+	).
+		From(repoTableCollectionArticle).
+		Join(r.joinCollectionUserArticle()).
+		Join(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableArticle,
+			repoTableArticle, repoColumnArticle.ID,
+			repoTableUserArticle, repoColumnUserArticle.ArticleID)).
+		Where(where).
+		OrderBy(
+			fmt.Sprintf("%s.%s", repoTableCollectionArticle, repoColumnCollectionArticle.Position),
+			fmt.Sprintf("%s.%s", repoTableCollectionArticle, repoColumnCollectionArticle.UserArticleID),
+		).
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for collection articles"))
+	}
+
+	var rows []repoSavedArticle
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to select collection articles")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select collection articles"))
+	}
+
+	articles := make([]*article.SavedArticle, 0, len(rows))
+	for _, row := range rows {
+		articles = append(articles, row.toDomain())
+	}
+	return articles, nil
+}
+
+func (r *PostgresRepository) joinCollectionUserArticle() string {
+	return fmt.Sprintf("%s ON %s.%s = %s.%s",
+		repoTableUserArticle,
+		repoTableUserArticle, repoColumnUserArticle.ID,
+		repoTableCollectionArticle, repoColumnCollectionArticle.UserArticleID)
+}
+
+// userArticleIDQuery selects the ID of the user_articles row of the saved article
+func (r *PostgresRepository) userArticleIDQuery(userID uuid.UUID, articleID uuid.UUID) sq.SelectBuilder {
+	return sq.Select(repoColumnUserArticle.ID).
+		From(repoTableUserArticle).
+		Where(sq.Eq{
+			repoColumnUserArticle.UserID:    userID,
+			repoColumnUserArticle.ArticleID: articleID,
+		})
+}
+
+// lockCollection locks a collection of the user until the transaction ends, so its articles can be renumbered
+func (r *PostgresRepository) lockCollection(ctx context.Context, db sqlContextGetter, userID uuid.UUID, collectionID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Select(repoColumnCollection.ID).
+		From(repoTableCollection).
+		Where(sq.Eq{
+			repoColumnCollection.ID:     collectionID,
+			repoColumnCollection.UserID: userID,
+		}).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build lock query for collection"))
+	}
+
+	var id uuid.UUID
+	if err = db.GetContext(ctx, &id, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("collection is not found"))
+		}
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to lock collection"))
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+type repoEmailVerificationToken struct {
+	ID        int64        `db:"id"`
+	UserID    uuid.UUID    `db:"user_id"`
+	Email     string       `db:"email"`
+	TokenHash string       `db:"token_hash"`
+	ExpiresAt time.Time    `db:"expires_at"`
+	UsedAt    sql.NullTime `db:"used_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+func (t *repoEmailVerificationToken) toDomain() *user.EmailVerificationToken {
+	var usedAt *time.Time
+	if t.UsedAt.Valid {
+		usedAt = &t.UsedAt.Time
+	}
+
+	return &user.EmailVerificationToken{
+		ID:        t.ID,
+		UserID:    t.UserID,
+		Email:     t.Email,
+		TokenHash: t.TokenHash,
+		ExpiresAt: t.ExpiresAt,
+		UsedAt:    usedAt,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+const repoTableEmailVerificationToken = "email_verification_tokens"
+
+type repoColumnPatternEmailVerificationToken struct {
+	ID        string
+	UserID    string
+	Email     string
+	TokenHash string
+	ExpiresAt string
+	UsedAt    string
+	CreatedAt string
+}
+
+var repoColumnEmailVerificationToken = repoColumnPatternEmailVerificationToken{
+	ID:        "id",
+	UserID:    "user_id",
+	Email:     "email",
+	TokenHash: "token_hash",
+	ExpiresAt: "expires_at",
+	UsedAt:    "used_at",
+	CreatedAt: "created_at",
+}
+
+func (c repoColumnPatternEmailVerificationToken) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.UserID,
+		c.Email,
+		c.TokenHash,
+		c.ExpiresAt,
+		c.UsedAt,
+		c.CreatedAt,
+	}, ", ")
+}
+
+func (r *PostgresRepository) CreateEmailVerificationToken(ctx context.Context, token *user.EmailVerificationToken) common.Error {
+	query, args, err := r.pgsq.Insert(repoTableEmailVerificationToken).
+		SetMap(map[string]interface{}{
+			repoColumnEmailVerificationToken.UserID:    token.UserID,
+			repoColumnEmailVerificationToken.Email:     token.Email,
+			repoColumnEmailVerificationToken.TokenHash: token.TokenHash,
+			repoColumnEmailVerificationToken.ExpiresAt: token.ExpiresAt,
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for email verification token"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert email verification token")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert email verification token"))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) GetEmailVerificationTokenByHash(ctx context.Context, tokenHash string) (*user.EmailVerificationToken, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnEmailVerificationToken.columns()).
+		From(repoTableEmailVerificationToken).
+		Where(sq.Eq{repoColumnEmailVerificationToken.TokenHash: tokenHash}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for email verification token"))
+	}
+
+	var row repoEmailVerificationToken
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("email verification token is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select email verification token"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// UseEmailVerificationToken marks a verification token as consumed.
+// It returns false if the token was already used by a concurrent or earlier request.
+func (r *PostgresRepository) UseEmailVerificationToken(ctx context.Context, tokenID int64) (bool, common.Error) {
+	query, args, err := r.pgsq.Update(repoTableEmailVerificationToken).
+		Set(repoColumnEmailVerificationToken.UsedAt, time.Now()).
+		Where(sq.And{
+			sq.Eq{repoColumnEmailVerificationToken.ID: tokenID},
+			sq.Eq{repoColumnEmailVerificationToken.UsedAt: nil},
+		}).
+		ToSql()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for email verification token"))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to use email verification token"))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// InvalidateUserEmailVerificationTokens consumes every outstanding verification token of the user
+func (r *PostgresRepository) InvalidateUserEmailVerificationTokens(ctx context.Context, userID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Update(repoTableEmailVerificationToken).
+		Set(repoColumnEmailVerificationToken.UsedAt, time.Now()).
+		Where(sq.And{
+			sq.Eq{repoColumnEmailVerificationToken.UserID: userID},
+			sq.Eq{repoColumnEmailVerificationToken.UsedAt: nil},
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for email verification tokens"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to invalidate email verification tokens"))
+	}
+
+	return nil
+}
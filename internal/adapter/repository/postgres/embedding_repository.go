@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// --- article_embeddings table ---
+
+const repoTableArticleEmbedding = "article_embeddings"
+
+type repoColumnPatternArticleEmbedding struct {
+	ArticleID string
+	Model     string
+	Embedding string
+	UpdatedAt string
+}
+
+var repoColumnArticleEmbedding = repoColumnPatternArticleEmbedding{
+	ArticleID: "article_id",
+	Model:     "model",
+	Embedding: "embedding",
+	UpdatedAt: "updated_at",
+}
+
+// similarity selects the dot product of the embedding of the article and the embedding bound to it as rank
+func (c repoColumnPatternArticleEmbedding) similarity() string {
+	return fmt.Sprintf("(SELECT SUM(a * b) FROM unnest(%s.%s, ?::REAL[]) AS v(a, b)) AS rank",
+		repoTableArticleEmbedding, c.Embedding)
+}
+
+// UpsertArticleEmbedding stores the embedding of the article by the model, replacing the one it had.
+func (r *PostgresRepository) UpsertArticleEmbedding(ctx context.Context, articleID uuid.UUID, model string, embedding []float32) common.Error {
+	query, args, err := r.pgsq.Insert(repoTableArticleEmbedding).
+		Columns(
+			repoColumnArticleEmbedding.ArticleID,
+			repoColumnArticleEmbedding.Model,
+			repoColumnArticleEmbedding.Embedding,
+		).
+		Values(articleID, model, pq.Array(embedding)).
+		Suffix(fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s = EXCLUDED.%s, %s = EXCLUDED.%s, %s = NOW()",
+			repoColumnArticleEmbedding.ArticleID,
+			repoColumnArticleEmbedding.Model, repoColumnArticleEmbedding.Model,
+			repoColumnArticleEmbedding.Embedding, repoColumnArticleEmbedding.Embedding,
+			repoColumnArticleEmbedding.UpdatedAt)).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build upsert query for article embedding"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to upsert article embedding"))
+	}
+	return nil
+}
+
+// ListUnembeddedArticles returns up to limit articles which have no embedding by the model.
+func (r *PostgresRepository) ListUnembeddedArticles(ctx context.Context, model string, limit int) ([]*article.Article, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnArticle.columns()).
+		From(repoTableArticle).
+		LeftJoin(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableArticleEmbedding,
+			repoTableArticleEmbedding, repoColumnArticleEmbedding.ArticleID,
+			repoTableArticle, repoColumnArticle.ID)).
+		Where(sq.Or{
+			sq.Eq{fmt.Sprintf("%s.%s", repoTableArticleEmbedding, repoColumnArticleEmbedding.ArticleID): nil},
+			sq.NotEq{fmt.Sprintf("%s.%s", repoTableArticleEmbedding, repoColumnArticleEmbedding.Model): model},
+		}).
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for unembedded articles"))
+	}
+
+	var rows []repoArticle
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to select unembedded articles")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select unembedded articles"))
+	}
+
+	articles := make([]*article.Article, 0, len(rows))
+	for i := range rows {
+		articles = append(articles, rows[i].toDomain())
+	}
+	return articles, nil
+}
+
+// SearchSimilarArticles returns the articles the user saved whose embedding by the model is at least minSimilarity
+// similar to the embedding, most similar first.
+func (r *PostgresRepository) SearchSimilarArticles(ctx context.Context, userID uuid.UUID, model string, embedding []float32, minSimilarity float64, limit int) ([]*article.SearchResult, common.Error) {
+	similar := sq.Select(
+		repoColumnArticle.columns(),
+		repoColumnUserArticle.savedColumns(),
+		repoColumnNote.latestNote(),
+	).
+		Column(repoColumnArticleEmbedding.similarity(), pq.Array(embedding)).
+		From(repoTableArticle).
+		Join(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableUserArticle,
+			repoTableUserArticle, repoColumnUserArticle.ArticleID,
+			repoTableArticle, repoColumnArticle.ID)).
+		Join(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableArticleEmbedding,
+			repoTableArticleEmbedding, repoColumnArticleEmbedding.ArticleID,
+			repoTableArticle, repoColumnArticle.ID)).
+		Where(sq.Eq{
+			fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.UserID):          userID,
+			fmt.Sprintf("%s.%s", repoTableArticleEmbedding, repoColumnArticleEmbedding.Model): model,
+		})
+
+	query, args, err := r.pgsq.Select("*").
+		FromSelect(similar, "similar_articles").
+		Where(sq.GtOrEq{"rank": minSimilarity}).
+		OrderBy("rank DESC", repoColumnArticle.ID).
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for similar articles"))
+	}
+
+	var rows []repoSearchResult
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to select similar articles")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select similar articles"))
+	}
+	return toSearchResults(rows), nil
+}
@@ -0,0 +1,239 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// --- user_identities table ---
+
+type repoIdentity struct {
+	ID        int64     `db:"id"`
+	UserID    uuid.UUID `db:"user_id"`
+	Provider  string    `db:"provider"`
+	Subject   string    `db:"subject"`
+	Email     string    `db:"email"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (i *repoIdentity) toDomain() *user.Identity {
+	return &user.Identity{
+		ID:        i.ID,
+		UserID:    i.UserID,
+		Provider:  i.Provider,
+		Subject:   i.Subject,
+		Email:     i.Email,
+		CreatedAt: i.CreatedAt,
+	}
+}
+
+const repoTableIdentity = "user_identities"
+
+type repoColumnPatternIdentity struct {
+	ID        string
+	UserID    string
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt string
+}
+
+var repoColumnIdentity = repoColumnPatternIdentity{
+	ID:        "id",
+	UserID:    "user_id",
+	Provider:  "provider",
+	Subject:   "subject",
+	Email:     "email",
+	CreatedAt: "created_at",
+}
+
+func (c repoColumnPatternIdentity) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.UserID,
+		c.Provider,
+		c.Subject,
+		c.Email,
+		c.CreatedAt,
+	}, ", ")
+}
+
+// --- oidc_states table ---
+
+type repoOIDCState struct {
+	ID           int64        `db:"id"`
+	StateHash    string       `db:"state_hash"`
+	Provider     string       `db:"provider"`
+	Nonce        string       `db:"nonce"`
+	CodeVerifier string       `db:"code_verifier"`
+	ExpiresAt    time.Time    `db:"expires_at"`
+	UsedAt       sql.NullTime `db:"used_at"`
+	CreatedAt    time.Time    `db:"created_at"`
+}
+
+func (s *repoOIDCState) toDomain() *user.OIDCState {
+	var usedAt *time.Time
+	if s.UsedAt.Valid {
+		usedAt = &s.UsedAt.Time
+	}
+
+	return &user.OIDCState{
+		ID:           s.ID,
+		StateHash:    s.StateHash,
+		Provider:     s.Provider,
+		Nonce:        s.Nonce,
+		CodeVerifier: s.CodeVerifier,
+		ExpiresAt:    s.ExpiresAt,
+		UsedAt:       usedAt,
+		CreatedAt:    s.CreatedAt,
+	}
+}
+
+const repoTableOIDCState = "oidc_states"
+
+type repoColumnPatternOIDCState struct {
+	ID           string
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    string
+	UsedAt       string
+	CreatedAt    string
+}
+
+var repoColumnOIDCState = repoColumnPatternOIDCState{
+	ID:           "id",
+	StateHash:    "state_hash",
+	Provider:     "provider",
+	Nonce:        "nonce",
+	CodeVerifier: "code_verifier",
+	ExpiresAt:    "expires_at",
+	UsedAt:       "used_at",
+	CreatedAt:    "created_at",
+}
+
+func (c repoColumnPatternOIDCState) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.StateHash,
+		c.Provider,
+		c.Nonce,
+		c.CodeVerifier,
+		c.ExpiresAt,
+		c.UsedAt,
+		c.CreatedAt,
+	}, ", ")
+}
+
+// --- repository methods ---
+
+// CreateIdentity links an external identity to a user.
+// It fails with RESOURCE_CONFLICT if the identity is already linked.
+func (r *PostgresRepository) CreateIdentity(ctx context.Context, identity *user.Identity) (*user.Identity, common.Error) {
+	query, args, err := r.pgsq.Insert(repoTableIdentity).
+		SetMap(map[string]interface{}{
+			repoColumnIdentity.UserID:   identity.UserID,
+			repoColumnIdentity.Provider: identity.Provider,
+			repoColumnIdentity.Subject:  identity.Subject,
+			repoColumnIdentity.Email:    identity.Email,
+		}).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnIdentity.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for identity"))
+	}
+
+	var row repoIdentity
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if _, ok := uniqueViolation(err); ok {
+			return nil, common.NewError(common.ErrorCodeResourceConflict, err, common.WithMsg("the identity is already linked to an account"))
+		}
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert identity")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert identity"))
+	}
+
+	return row.toDomain(), nil
+}
+
+func (r *PostgresRepository) GetIdentity(ctx context.Context, provider string, subject string) (*user.Identity, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnIdentity.columns()).
+		From(repoTableIdentity).
+		Where(sq.Eq{
+			repoColumnIdentity.Provider: provider,
+			repoColumnIdentity.Subject:  subject,
+		}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for identity"))
+	}
+
+	var row repoIdentity
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("identity is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select identity"))
+	}
+
+	return row.toDomain(), nil
+}
+
+func (r *PostgresRepository) CreateOIDCState(ctx context.Context, state *user.OIDCState) common.Error {
+	query, args, err := r.pgsq.Insert(repoTableOIDCState).
+		SetMap(map[string]interface{}{
+			repoColumnOIDCState.StateHash:    state.StateHash,
+			repoColumnOIDCState.Provider:     state.Provider,
+			repoColumnOIDCState.Nonce:        state.Nonce,
+			repoColumnOIDCState.CodeVerifier: state.CodeVerifier,
+			repoColumnOIDCState.ExpiresAt:    state.ExpiresAt,
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for oidc state"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert oidc state")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert oidc state"))
+	}
+
+	return nil
+}
+
+// ConsumeOIDCState marks a state as used and returns it, in one statement so that a state is only ever consumed once.
+// It fails with RESOURCE_NOT_FOUND if the state does not exist or was already used.
+func (r *PostgresRepository) ConsumeOIDCState(ctx context.Context, stateHash string) (*user.OIDCState, common.Error) {
+	query, args, err := r.pgsq.Update(repoTableOIDCState).
+		Set(repoColumnOIDCState.UsedAt, time.Now()).
+		Where(sq.And{
+			sq.Eq{repoColumnOIDCState.StateHash: stateHash},
+			sq.Eq{repoColumnOIDCState.UsedAt: nil},
+		}).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnOIDCState.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for oidc state"))
+	}
+
+	var row repoOIDCState
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("oidc state is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to consume oidc state"))
+	}
+
+	return row.toDomain(), nil
+}
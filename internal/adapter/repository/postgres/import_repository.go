@@ -0,0 +1,371 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// --- article_imports table ---
+
+type repoImportItem struct {
+	Line        int       `json:"line"`
+	URL         string    `json:"url"`
+	CollectedAt time.Time `json:"collected_at"`
+	Tags        []string  `json:"tags,omitempty"`
+	State       string    `json:"state,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type repoImportError struct {
+	Line    int    `json:"line"`
+	URL     string `json:"url"`
+	Message string `json:"message"`
+}
+
+type repoImportJob struct {
+	ID         uuid.UUID       `db:"id"`
+	UserID     uuid.UUID       `db:"user_id"`
+	Format     string          `db:"format"`
+	Status     string          `db:"status"`
+	Items      json.RawMessage `db:"items"` // only selected to run the job
+	Total      int             `db:"total"`
+	Processed  int             `db:"processed"`
+	Imported   int             `db:"imported"`
+	Duplicates int             `db:"duplicates"`
+	Failed     int             `db:"failed"`
+	Errors     json.RawMessage `db:"errors"`
+	Attempts   int             `db:"attempts"`
+	CreatedAt  time.Time       `db:"created_at"`
+	StartedAt  sql.NullTime    `db:"started_at"`
+	FinishedAt sql.NullTime    `db:"finished_at"`
+}
+
+func (j *repoImportJob) toDomain() (*article.ImportJob, error) {
+	job := &article.ImportJob{
+		ID:         j.ID,
+		UserID:     j.UserID,
+		Format:     j.Format,
+		Status:     j.Status,
+		Total:      j.Total,
+		Processed:  j.Processed,
+		Imported:   j.Imported,
+		Duplicates: j.Duplicates,
+		Failed:     j.Failed,
+		Attempts:   j.Attempts,
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  nullTimeToPtr(j.StartedAt),
+		FinishedAt: nullTimeToPtr(j.FinishedAt),
+	}
+
+	var importErrors []repoImportError
+	if err := json.Unmarshal(j.Errors, &importErrors); err != nil {
+		return nil, errors.Wrap(err, "failed to decode import errors")
+	}
+	for _, e := range importErrors {
+		job.Errors = append(job.Errors, article.ImportError{Line: e.Line, URL: e.URL, Message: e.Message})
+	}
+
+	if len(j.Items) == 0 {
+		return job, nil
+	}
+	var items []repoImportItem
+	if err := json.Unmarshal(j.Items, &items); err != nil {
+		return nil, errors.Wrap(err, "failed to decode import items")
+	}
+	for _, item := range items {
+		job.Items = append(job.Items, &article.ImportItem{
+			Line:        item.Line,
+			URL:         item.URL,
+			CollectedAt: item.CollectedAt,
+			Tags:        item.Tags,
+			State:       item.State,
+			Error:       item.Error,
+		})
+	}
+	return job, nil
+}
+
+// encodeImportErrors returns the errors of the job as stored in the errors column
+func encodeImportErrors(job *article.ImportJob) ([]byte, error) {
+	importErrors := make([]repoImportError, 0, len(job.Errors))
+	for _, e := range job.Errors {
+		importErrors = append(importErrors, repoImportError{Line: e.Line, URL: e.URL, Message: e.Message})
+	}
+	return json.Marshal(importErrors)
+}
+
+const repoTableImportJob = "article_imports"
+
+type repoColumnPatternImportJob struct {
+	ID         string
+	UserID     string
+	Format     string
+	Status     string
+	Items      string
+	Total      string
+	Processed  string
+	Imported   string
+	Duplicates string
+	Failed     string
+	Errors     string
+	Attempts   string
+	CreatedAt  string
+	UpdatedAt  string
+	StartedAt  string
+	FinishedAt string
+}
+
+var repoColumnImportJob = repoColumnPatternImportJob{
+	ID:         "id",
+	UserID:     "user_id",
+	Format:     "format",
+	Status:     "status",
+	Items:      "items",
+	Total:      "total",
+	Processed:  "processed",
+	Imported:   "imported",
+	Duplicates: "duplicates",
+	Failed:     "failed",
+	Errors:     "errors",
+	Attempts:   "attempts",
+	CreatedAt:  "created_at",
+	UpdatedAt:  "updated_at",
+	StartedAt:  "started_at",
+	FinishedAt: "finished_at",
+}
+
+// columns are the columns of a job without its items
+func (c repoColumnPatternImportJob) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.UserID,
+		c.Format,
+		c.Status,
+		c.Total,
+		c.Processed,
+		c.Imported,
+		c.Duplicates,
+		c.Failed,
+		c.Errors,
+		c.Attempts,
+		c.CreatedAt,
+		c.StartedAt,
+		c.FinishedAt,
+	}, ", ")
+}
+
+// --- repository methods ---
+
+// CreateImportJob stores a new import job with its items.
+// It fails with RESOURCE_CONFLICT while the user has maxUnfinished jobs which are pending or running.
+func (r *PostgresRepository) CreateImportJob(ctx context.Context, job *article.ImportJob, maxUnfinished int) (*article.ImportJob, common.Error) {
+	items := make([]repoImportItem, 0, len(job.Items))
+	for _, item := range job.Items {
+		items = append(items, repoImportItem{
+			Line:        item.Line,
+			URL:         item.URL,
+			CollectedAt: item.CollectedAt,
+			Tags:        item.Tags,
+			State:       item.State,
+			Error:       item.Error,
+		})
+	}
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to encode import items"))
+	}
+
+	tx, cerr := r.beginTx()
+	if cerr != nil {
+		return nil, cerr
+	}
+	created, cerr := r.createImportJob(ctx, tx, job, itemsJSON, maxUnfinished)
+	if cerr = r.finishTx(cerr, tx); cerr != nil {
+		return nil, cerr
+	}
+	return created, nil
+}
+
+func (r *PostgresRepository) createImportJob(ctx context.Context, db sqlContextGetter, job *article.ImportJob, itemsJSON []byte, maxUnfinished int) (*article.ImportJob, common.Error) {
+	// Lock the user, so that concurrent uploads of the user are counted one after another.
+	// FOR NO KEY UPDATE still lets other rows referencing the user be written meanwhile.
+	query, args, err := r.pgsq.Select(repoColumnUser.ID).
+		From(repoTableUser).
+		Where(sq.Eq{repoColumnUser.ID: job.UserID}).
+		Suffix("FOR NO KEY UPDATE").
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build lock query for user"))
+	}
+	var userID uuid.UUID
+	if err = db.GetContext(ctx, &userID, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("user is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to lock user"))
+	}
+
+	query, args, err = r.pgsq.Select("COUNT(*)").
+		From(repoTableImportJob).
+		Where(sq.Eq{
+			repoColumnImportJob.UserID: job.UserID,
+			repoColumnImportJob.Status: []string{article.ImportStatusPending, article.ImportStatusRunning},
+		}).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build count query for import jobs"))
+	}
+	var unfinished int
+	if err = db.GetContext(ctx, &unfinished, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to count import jobs")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to count import jobs"))
+	}
+	if unfinished >= maxUnfinished {
+		msg := fmt.Sprintf("at most %d imports can be in progress, wait for one to finish", maxUnfinished)
+		return nil, common.NewError(common.ErrorCodeResourceConflict, errors.New(msg), common.WithMsg(msg))
+	}
+
+	query, args, err = r.pgsq.Insert(repoTableImportJob).
+		SetMap(map[string]interface{}{
+			repoColumnImportJob.UserID: job.UserID,
+			repoColumnImportJob.Format: job.Format,
+			repoColumnImportJob.Status: job.Status,
+			repoColumnImportJob.Items:  string(itemsJSON),
+			repoColumnImportJob.Total:  job.Total,
+		}).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnImportJob.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for import job"))
+	}
+
+	var row repoImportJob
+	if err = db.GetContext(ctx, &row, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert import job")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert import job"))
+	}
+	created, err := row.toDomain()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	return created, nil
+}
+
+// GetImportJob returns an import job of the user, without its items.
+func (r *PostgresRepository) GetImportJob(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*article.ImportJob, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnImportJob.columns()).
+		From(repoTableImportJob).
+		Where(sq.Eq{
+			repoColumnImportJob.ID:     jobID,
+			repoColumnImportJob.UserID: userID,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for import job"))
+	}
+
+	var row repoImportJob
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err)
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select import job"))
+	}
+	job, err := row.toDomain()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	return job, nil
+}
+
+// ClaimImportJob marks the oldest pending import job as running, counts the attempt and returns the job with its items.
+// Running jobs which made no progress since staleBefore are claimed again, their worker is taken to be gone.
+// It returns a ResourceNotFound error when there is no job to run.
+func (r *PostgresRepository) ClaimImportJob(ctx context.Context, staleBefore time.Time) (*article.ImportJob, common.Error) {
+	next := sq.Select(repoColumnImportJob.ID).
+		From(repoTableImportJob).
+		Where(sq.Or{
+			sq.Eq{repoColumnImportJob.Status: article.ImportStatusPending},
+			sq.And{
+				sq.Eq{repoColumnImportJob.Status: article.ImportStatusRunning},
+				sq.Lt{repoColumnImportJob.UpdatedAt: staleBefore},
+			},
+		}).
+		OrderBy(repoColumnImportJob.CreatedAt).
+		Limit(1).
+		Suffix("FOR UPDATE SKIP LOCKED")
+
+	query, args, err := r.pgsq.Update(repoTableImportJob).
+		Set(repoColumnImportJob.Status, article.ImportStatusRunning).
+		Set(repoColumnImportJob.Attempts, sq.Expr(fmt.Sprintf("%s + 1", repoColumnImportJob.Attempts))).
+		Set(repoColumnImportJob.StartedAt, sq.Expr(fmt.Sprintf("COALESCE(%s, NOW())", repoColumnImportJob.StartedAt))).
+		Set(repoColumnImportJob.UpdatedAt, sq.Expr("NOW()")).
+		Where(sq.Expr(fmt.Sprintf("%s = (?)", repoColumnImportJob.ID), next)).
+		Suffix(fmt.Sprintf("RETURNING %s, %s", repoColumnImportJob.columns(), repoColumnImportJob.Items)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for claiming import job"))
+	}
+
+	var row repoImportJob
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err)
+		}
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to claim import job")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to claim import job"))
+	}
+	job, err := row.toDomain()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	return job, nil
+}
+
+// UpdateImportJobProgress stores the counts and errors of a running job.
+// Jobs which are finished drop their items.
+func (r *PostgresRepository) UpdateImportJobProgress(ctx context.Context, job *article.ImportJob) common.Error {
+	errorsJSON, err := encodeImportErrors(job)
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to encode import errors"))
+	}
+
+	update := r.pgsq.Update(repoTableImportJob).
+		SetMap(map[string]interface{}{
+			repoColumnImportJob.Status:     job.Status,
+			repoColumnImportJob.Processed:  job.Processed,
+			repoColumnImportJob.Imported:   job.Imported,
+			repoColumnImportJob.Duplicates: job.Duplicates,
+			repoColumnImportJob.Failed:     job.Failed,
+			repoColumnImportJob.Errors:     string(errorsJSON),
+			repoColumnImportJob.UpdatedAt:  sq.Expr("NOW()"),
+		}).
+		Where(sq.Eq{repoColumnImportJob.ID: job.ID})
+	if job.IsFinished() {
+		update = update.
+			Set(repoColumnImportJob.Items, sq.Expr("'[]'")).
+			Set(repoColumnImportJob.FinishedAt, sq.Expr("NOW()"))
+	}
+
+	query, args, err := update.ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for import job"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to update import job")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to update import job"))
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/testdata"
+)
+
+func createTestImportJob(t *testing.T, repo *PostgresRepository, userID uuid.UUID, url string) *article.ImportJob {
+	t.Helper()
+	job, err := repo.CreateImportJob(context.Background(), article.NewImportJob(userID, article.ImportFormatText, []*article.ImportItem{
+		{Line: 1, URL: url},
+		{Line: 2, URL: url + "/next"},
+	}), article.ImportMaxUnfinished)
+	require.NoError(t, err)
+	return job
+}
+
+func TestPostgresRepository_ClaimImportJob(t *testing.T) {
+	db := getTestPostgresDB()
+	repo := initRepository(t, db, testdata.Path(testdata.TestDataUser))
+	ctx := context.Background()
+
+	userID := createTestUser(t, repo, "importer")
+	first := createTestImportJob(t, repo, userID, "https://example.com/first")
+	second := createTestImportJob(t, repo, userID, "https://example.com/second")
+	staleBefore := time.Now().Add(-time.Minute)
+
+	// The oldest pending job is claimed first, with its items
+	claimed, err := repo.ClaimImportJob(ctx, staleBefore)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, claimed.ID)
+	assert.Equal(t, article.ImportStatusRunning, claimed.Status)
+	assert.Equal(t, 1, claimed.Attempts)
+	require.Len(t, claimed.Items, 2)
+	assert.Equal(t, "https://example.com/first", claimed.Items[0].URL)
+	require.NotNil(t, claimed.StartedAt)
+	startedAt := *claimed.StartedAt
+
+	claimed.Processed = 1
+	claimed.Imported = 1
+	require.NoError(t, repo.UpdateImportJobProgress(ctx, claimed))
+
+	next, err := repo.ClaimImportJob(ctx, staleBefore)
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, next.ID)
+
+	// Running jobs which made progress lately belong to their worker
+	_, err = repo.ClaimImportJob(ctx, staleBefore)
+	assert.True(t, common.IsErrorCode(err, common.ErrorCodeResourceNotFound))
+
+	// Stale jobs are taken over where they stopped, counting another attempt
+	takenOver, err := repo.ClaimImportJob(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, takenOver.ID)
+	assert.Equal(t, 2, takenOver.Attempts)
+	assert.Equal(t, 1, takenOver.Processed)
+	assert.Equal(t, 1, takenOver.Imported)
+	require.NotNil(t, takenOver.StartedAt)
+	assert.True(t, startedAt.Equal(*takenOver.StartedAt), "the job keeps when it first started")
+
+	// Finished jobs are never claimed again, and drop their items
+	takenOver.Status = article.ImportStatusFailed
+	require.NoError(t, repo.UpdateImportJobProgress(ctx, takenOver))
+	next.Status = article.ImportStatusDone
+	require.NoError(t, repo.UpdateImportJobProgress(ctx, next))
+	_, err = repo.ClaimImportJob(ctx, time.Now().Add(time.Minute))
+	assert.True(t, common.IsErrorCode(err, common.ErrorCodeResourceNotFound))
+
+	failed, err := repo.GetImportJob(ctx, userID, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, article.ImportStatusFailed, failed.Status)
+	assert.NotNil(t, failed.FinishedAt)
+	var items string
+	require.NoError(t, db.GetContext(ctx, &items, "SELECT items::TEXT FROM article_imports WHERE id = $1", first.ID))
+	assert.Equal(t, "[]", items)
+}
+
+func TestPostgresRepository_ClaimImportJobSkipsLocked(t *testing.T) {
+	db := getTestPostgresDB()
+	repo := initRepository(t, db, testdata.Path(testdata.TestDataUser))
+	ctx := context.Background()
+
+	userID := createTestUser(t, repo, "importer")
+	first := createTestImportJob(t, repo, userID, "https://example.com/first")
+	second := createTestImportJob(t, repo, userID, "https://example.com/second")
+
+	// Another worker is claiming the oldest job meanwhile
+	tx, err := db.Beginx()
+	require.NoError(t, err)
+	defer func() { _ = tx.Rollback() }()
+	_, err = tx.ExecContext(ctx, "SELECT id FROM article_imports WHERE id = $1 FOR UPDATE", first.ID)
+	require.NoError(t, err)
+
+	claimCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	claimed, cerr := repo.ClaimImportJob(claimCtx, time.Now().Add(-time.Minute))
+	require.NoError(t, cerr, "claiming does not wait for the locked job")
+	assert.Equal(t, second.ID, claimed.ID)
+
+	// Once the other worker is gone the job is free again
+	require.NoError(t, tx.Rollback())
+	claimed, cerr = repo.ClaimImportJob(ctx, time.Now().Add(-time.Minute))
+	require.NoError(t, cerr)
+	assert.Equal(t, first.ID, claimed.ID)
+}
+
+func TestPostgresRepository_CreateImportJobLimit(t *testing.T) {
+	db := getTestPostgresDB()
+	repo := initRepository(t, db, testdata.Path(testdata.TestDataUser))
+	ctx := context.Background()
+
+	userID, otherID := createTestUser(t, repo, "importer"), createTestUser(t, repo, "other-importer")
+	for i := 0; i < article.ImportMaxUnfinished; i++ {
+		createTestImportJob(t, repo, userID, fmt.Sprintf("https://example.com/%d", i))
+	}
+
+	_, err := repo.CreateImportJob(ctx, article.NewImportJob(userID, article.ImportFormatText, []*article.ImportItem{{Line: 1, URL: "https://example.com/over"}}), article.ImportMaxUnfinished)
+	assert.True(t, common.IsErrorCode(err, common.ErrorCodeResourceConflict))
+
+	// The limit is per user
+	createTestImportJob(t, repo, otherID, "https://example.com/other")
+
+	// Finished jobs no longer count
+	claimed, err := repo.ClaimImportJob(ctx, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	claimed.Status = article.ImportStatusDone
+	require.NoError(t, repo.UpdateImportJobProgress(ctx, claimed))
+	createTestImportJob(t, repo, userID, "https://example.com/after")
+}
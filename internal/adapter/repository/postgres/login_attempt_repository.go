@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+type repoLoginAttempt struct {
+	Key          string       `db:"key"`
+	Failures     int          `db:"failures"`
+	LastFailedAt time.Time    `db:"last_failed_at"`
+	LockedUntil  sql.NullTime `db:"locked_until"`
+}
+
+func (a *repoLoginAttempt) toDomain() *user.LoginAttempt {
+	var lockedUntil *time.Time
+	if a.LockedUntil.Valid {
+		lockedUntil = &a.LockedUntil.Time
+	}
+
+	return &user.LoginAttempt{
+		Key:          a.Key,
+		Failures:     a.Failures,
+		LastFailedAt: a.LastFailedAt,
+		LockedUntil:  lockedUntil,
+	}
+}
+
+const repoTableLoginAttempt = "login_attempts"
+
+type repoColumnPatternLoginAttempt struct {
+	Key          string
+	Failures     string
+	LastFailedAt string
+	LockedUntil  string
+}
+
+var repoColumnLoginAttempt = repoColumnPatternLoginAttempt{
+	Key:          "key",
+	Failures:     "failures",
+	LastFailedAt: "last_failed_at",
+	LockedUntil:  "locked_until",
+}
+
+func (c repoColumnPatternLoginAttempt) columns() string {
+	return strings.Join([]string{
+		c.Key,
+		c.Failures,
+		c.LastFailedAt,
+		c.LockedUntil,
+	}, ", ")
+}
+
+// GetLoginAttempt returns the failure counter of the key, which is empty if the key has no recorded failure
+func (r *PostgresRepository) GetLoginAttempt(ctx context.Context, key string) (*user.LoginAttempt, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnLoginAttempt.columns()).
+		From(repoTableLoginAttempt).
+		Where(sq.Eq{repoColumnLoginAttempt.Key: key}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for login attempt"))
+	}
+
+	var row repoLoginAttempt
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &user.LoginAttempt{Key: key}, nil
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select login attempt"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// RecordLoginFailure increments the failure counter of the key in a single upsert, so that concurrent
+// failures on different instances are all counted. The count restarts when the last failure is older than window.
+func (r *PostgresRepository) RecordLoginFailure(ctx context.Context, key string, failedAt time.Time, window time.Duration) (*user.LoginAttempt, common.Error) {
+	c := repoColumnLoginAttempt
+	query, args, err := r.pgsq.Insert(repoTableLoginAttempt).
+		SetMap(map[string]interface{}{
+			c.Key:          key,
+			c.Failures:     1,
+			c.LastFailedAt: failedAt,
+		}).
+		Suffix(fmt.Sprintf(
+			"ON CONFLICT (%[1]s) DO UPDATE SET %[2]s = CASE WHEN %[4]s.%[3]s < ? THEN 1 ELSE %[4]s.%[2]s + 1 END, %[3]s = EXCLUDED.%[3]s RETURNING %[5]s",
+			c.Key, c.Failures, c.LastFailedAt, repoTableLoginAttempt, c.columns(),
+		), failedAt.Add(-window)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build upsert query for login attempt"))
+	}
+
+	var row repoLoginAttempt
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to record login failure")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to record login failure"))
+	}
+
+	return row.toDomain(), nil
+}
+
+func (r *PostgresRepository) LockLoginAttempt(ctx context.Context, key string, until time.Time) common.Error {
+	query, args, err := r.pgsq.Update(repoTableLoginAttempt).
+		Set(repoColumnLoginAttempt.LockedUntil, until).
+		Where(sq.Eq{repoColumnLoginAttempt.Key: key}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for login attempt"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to lock login attempt"))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) DeleteLoginAttempt(ctx context.Context, key string) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableLoginAttempt).
+		Where(sq.Eq{repoColumnLoginAttempt.Key: key}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for login attempt"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to delete login attempt"))
+	}
+
+	return nil
+}
+
+// DeleteStaleLoginAttempts removes the counters whose last failure is before the given time and that are not locked
+func (r *PostgresRepository) DeleteStaleLoginAttempts(ctx context.Context, before time.Time) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableLoginAttempt).
+		Where(sq.And{
+			sq.Lt{repoColumnLoginAttempt.LastFailedAt: before},
+			sq.Or{
+				sq.Eq{repoColumnLoginAttempt.LockedUntil: nil},
+				sq.Lt{repoColumnLoginAttempt.LockedUntil: time.Now()},
+			},
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for login attempts"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to delete stale login attempts"))
+	}
+
+	return nil
+}
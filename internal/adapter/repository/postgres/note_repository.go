@@ -0,0 +1,451 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// --- article_notes table ---
+
+type repoNote struct {
+	ID        uuid.UUID `db:"id"`
+	Body      string    `db:"body"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+func (n *repoNote) toDomain(articleID uuid.UUID) *article.Note {
+	return &article.Note{
+		ID:        n.ID,
+		ArticleID: articleID,
+		Body:      n.Body,
+		CreatedAt: n.CreatedAt,
+		UpdatedAt: n.UpdatedAt,
+	}
+}
+
+const repoTableNote = "article_notes"
+
+type repoColumnPatternNote struct {
+	ID            string
+	UserArticleID string
+	Body          string
+	CreatedAt     string
+	UpdatedAt     string
+}
+
+var repoColumnNote = repoColumnPatternNote{
+	ID:            "id",
+	UserArticleID: "user_article_id",
+	Body:          "body",
+	CreatedAt:     "created_at",
+	UpdatedAt:     "updated_at",
+}
+
+func (c repoColumnPatternNote) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.Body,
+		c.CreatedAt,
+		c.UpdatedAt,
+	}, ", ")
+}
+
+// qualifiedColumns are the columns prefixed with the table, for joins
+func (c repoColumnPatternNote) qualifiedColumns() string {
+	col := strings.Split(c.columns(), ", ")
+	for i, v := range col {
+		col[i] = fmt.Sprintf("%s.%s", repoTableNote, v)
+	}
+	return strings.Join(col, ", ")
+}
+
+// latestNote selects the start of the latest note on the saved article in user_articles as note
+func (c repoColumnPatternNote) latestNote() string {
+	return fmt.Sprintf("(SELECT LEFT(%s, %d) FROM %s WHERE %s = %s.%s ORDER BY %s DESC LIMIT 1) AS note",
+		c.Body, article.NoteSnippetLength*2, repoTableNote,
+		c.UserArticleID, repoTableUserArticle, repoColumnUserArticle.ID,
+		c.UpdatedAt)
+}
+
+// --- article_highlights table ---
+
+type repoHighlight struct {
+	ID          uuid.UUID     `db:"id"`
+	Text        string        `db:"text"`
+	Comment     string        `db:"comment"`
+	StartOffset sql.NullInt32 `db:"start_offset"`
+	EndOffset   sql.NullInt32 `db:"end_offset"`
+	CreatedAt   time.Time     `db:"created_at"`
+	UpdatedAt   time.Time     `db:"updated_at"`
+}
+
+func (h *repoHighlight) toDomain(articleID uuid.UUID) *article.Highlight {
+	var position *article.HighlightPosition
+	if h.StartOffset.Valid && h.EndOffset.Valid {
+		position = &article.HighlightPosition{
+			Start: int(h.StartOffset.Int32),
+			End:   int(h.EndOffset.Int32),
+		}
+	}
+
+	return &article.Highlight{
+		ID:        h.ID,
+		ArticleID: articleID,
+		Text:      h.Text,
+		Comment:   h.Comment,
+		Position:  position,
+		CreatedAt: h.CreatedAt,
+		UpdatedAt: h.UpdatedAt,
+	}
+}
+
+const repoTableHighlight = "article_highlights"
+
+type repoColumnPatternHighlight struct {
+	ID            string
+	UserArticleID string
+	Text          string
+	Comment       string
+	StartOffset   string
+	EndOffset     string
+	CreatedAt     string
+	UpdatedAt     string
+}
+
+var repoColumnHighlight = repoColumnPatternHighlight{
+	ID:            "id",
+	UserArticleID: "user_article_id",
+	Text:          "text",
+	Comment:       "comment",
+	StartOffset:   "start_offset",
+	EndOffset:     "end_offset",
+	CreatedAt:     "created_at",
+	UpdatedAt:     "updated_at",
+}
+
+func (c repoColumnPatternHighlight) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.Text,
+		c.Comment,
+		c.StartOffset,
+		c.EndOffset,
+		c.CreatedAt,
+		c.UpdatedAt,
+	}, ", ")
+}
+
+// qualifiedColumns are the columns prefixed with the table, for joins
+func (c repoColumnPatternHighlight) qualifiedColumns() string {
+	col := strings.Split(c.columns(), ", ")
+	for i, v := range col {
+		col[i] = fmt.Sprintf("%s.%s", repoTableHighlight, v)
+	}
+	return strings.Join(col, ", ")
+}
+
+// joinUserArticle joins the saved article in user_articles which notes or highlights in table are on
+func joinUserArticle(table string, userArticleIDColumn string) string {
+	return fmt.Sprintf("%s ON %s.%s = %s.%s",
+		repoTableUserArticle,
+		repoTableUserArticle, repoColumnUserArticle.ID,
+		table, userArticleIDColumn)
+}
+
+// --- repository methods ---
+
+// CreateNote adds a note to the saved article, it fails with RESOURCE_NOT_FOUND if the user did not save the article.
+func (r *PostgresRepository) CreateNote(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, body string) (*article.Note, common.Error) {
+	userArticle := r.userArticleIDQuery(userID, articleID).
+		Column("?", body)
+
+	query, args, err := r.pgsq.Insert(repoTableNote).
+		Columns(repoColumnNote.UserArticleID, repoColumnNote.Body).
+		Select(userArticle).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnNote.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for note"))
+	}
+
+	var row repoNote
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err)
+		}
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert note")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert note"))
+	}
+
+	return row.toDomain(articleID), nil
+}
+
+// ListNotes returns the notes on the saved article, oldest first.
+func (r *PostgresRepository) ListNotes(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) ([]*article.Note, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnNote.columns()).
+		From(repoTableNote).
+		Where(sq.Expr(fmt.Sprintf("%s IN (?)", repoColumnNote.UserArticleID), r.userArticleIDQuery(userID, articleID))).
+		OrderBy(repoColumnNote.ID).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for notes"))
+	}
+
+	var rows []repoNote
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to list notes")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select notes"))
+	}
+
+	notes := make([]*article.Note, 0, len(rows))
+	for i := range rows {
+		notes = append(notes, rows[i].toDomain(articleID))
+	}
+	return notes, nil
+}
+
+func (r *PostgresRepository) UpdateNote(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, noteID uuid.UUID, body string) (*article.Note, common.Error) {
+	query, args, err := r.pgsq.Update(repoTableNote).
+		Set(repoColumnNote.Body, body).
+		Set(repoColumnNote.UpdatedAt, sq.Expr("NOW()")).
+		Where(sq.Eq{repoColumnNote.ID: noteID}).
+		Where(sq.Expr(fmt.Sprintf("%s IN (?)", repoColumnNote.UserArticleID), r.userArticleIDQuery(userID, articleID))).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnNote.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for note"))
+	}
+
+	var row repoNote
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("note is not found"))
+		}
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to update note")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to update note"))
+	}
+
+	return row.toDomain(articleID), nil
+}
+
+func (r *PostgresRepository) DeleteNote(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, noteID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableNote).
+		Where(sq.Eq{repoColumnNote.ID: noteID}).
+		Where(sq.Expr(fmt.Sprintf("%s IN (?)", repoColumnNote.UserArticleID), r.userArticleIDQuery(userID, articleID))).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for note"))
+	}
+
+	return r.execDeleteOne(ctx, query, args, "note")
+}
+
+// CreateHighlight adds a highlight to the saved article of highlight.ArticleID.
+// It fails with RESOURCE_NOT_FOUND if the user did not save the article.
+func (r *PostgresRepository) CreateHighlight(ctx context.Context, userID uuid.UUID, highlight *article.Highlight) (*article.Highlight, common.Error) {
+	var startOffset, endOffset sql.NullInt32
+	if highlight.Position != nil {
+		startOffset = sql.NullInt32{Int32: int32(highlight.Position.Start), Valid: true}
+		endOffset = sql.NullInt32{Int32: int32(highlight.Position.End), Valid: true}
+	}
+	userArticle := r.userArticleIDQuery(userID, highlight.ArticleID).
+		Column("?", highlight.Text).
+		Column("?", highlight.Comment).
+		Column("?::INTEGER", startOffset).
+		Column("?::INTEGER", endOffset)
+
+	query, args, err := r.pgsq.Insert(repoTableHighlight).
+		Columns(
+			repoColumnHighlight.UserArticleID,
+			repoColumnHighlight.Text,
+			repoColumnHighlight.Comment,
+			repoColumnHighlight.StartOffset,
+			repoColumnHighlight.EndOffset,
+		).
+		Select(userArticle).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnHighlight.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for highlight"))
+	}
+
+	var row repoHighlight
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err)
+		}
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert highlight")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert highlight"))
+	}
+
+	return row.toDomain(highlight.ArticleID), nil
+}
+
+// ListHighlights returns the highlights on the saved article in the order of their positions,
+// highlights without a position come last.
+func (r *PostgresRepository) ListHighlights(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) ([]*article.Highlight, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnHighlight.columns()).
+		From(repoTableHighlight).
+		Where(sq.Expr(fmt.Sprintf("%s IN (?)", repoColumnHighlight.UserArticleID), r.userArticleIDQuery(userID, articleID))).
+		OrderBy(fmt.Sprintf("%s NULLS LAST", repoColumnHighlight.StartOffset), repoColumnHighlight.ID).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for highlights"))
+	}
+
+	var rows []repoHighlight
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to list highlights")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select highlights"))
+	}
+
+	highlights := make([]*article.Highlight, 0, len(rows))
+	for i := range rows {
+		highlights = append(highlights, rows[i].toDomain(articleID))
+	}
+	return highlights, nil
+}
+
+func (r *PostgresRepository) UpdateHighlightComment(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, highlightID uuid.UUID, comment string) (*article.Highlight, common.Error) {
+	query, args, err := r.pgsq.Update(repoTableHighlight).
+		Set(repoColumnHighlight.Comment, comment).
+		Set(repoColumnHighlight.UpdatedAt, sq.Expr("NOW()")).
+		Where(sq.Eq{repoColumnHighlight.ID: highlightID}).
+		Where(sq.Expr(fmt.Sprintf("%s IN (?)", repoColumnHighlight.UserArticleID), r.userArticleIDQuery(userID, articleID))).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnHighlight.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for highlight"))
+	}
+
+	var row repoHighlight
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("highlight is not found"))
+		}
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to update highlight")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to update highlight"))
+	}
+
+	return row.toDomain(articleID), nil
+}
+
+func (r *PostgresRepository) DeleteHighlight(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, highlightID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableHighlight).
+		Where(sq.Eq{repoColumnHighlight.ID: highlightID}).
+		Where(sq.Expr(fmt.Sprintf("%s IN (?)", repoColumnHighlight.UserArticleID), r.userArticleIDQuery(userID, articleID))).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for highlight"))
+	}
+
+	return r.execDeleteOne(ctx, query, args, "highlight")
+}
+
+// execDeleteOne runs a statement deleting one row of what, which fails with RESOURCE_NOT_FOUND if there is none
+func (r *PostgresRepository) execDeleteOne(ctx context.Context, query string, args []interface{}, what string) common.Error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrapf(err, "failed to delete %s", what))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+	if rowsAffected == 0 {
+		msg := fmt.Sprintf("%s is not found", what)
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New(msg), common.WithMsg(msg))
+	}
+
+	return nil
+}
+
+// ListUserArticleNotes returns the notes on the saved articles of the user by article, oldest first.
+func (r *PostgresRepository) ListUserArticleNotes(ctx context.Context, userID uuid.UUID, articleIDs []uuid.UUID) (map[uuid.UUID][]*article.Note, common.Error) {
+	notes := make(map[uuid.UUID][]*article.Note)
+	if len(articleIDs) == 0 {
+		return notes, nil
+	}
+
+	query, args, err := r.pgsq.Select(
+		repoColumnNote.qualifiedColumns(),
+		fmt.Sprintf("%s.%s AS article_id", repoTableUserArticle, repoColumnUserArticle.ArticleID),
+	).
+		From(repoTableNote).
+		Join(joinUserArticle(repoTableNote, repoColumnNote.UserArticleID)).
+		Where(sq.Eq{
+			fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.UserID):    userID,
+			fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.ArticleID): articleIDs,
+		}).
+		OrderBy(fmt.Sprintf("%s.%s", repoTableNote, repoColumnNote.ID)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for user article notes"))
+	}
+
+	var rows []struct {
+		repoNote
+		ArticleID uuid.UUID `db:"article_id"`
+	}
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to list user article notes")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select user article notes"))
+	}
+
+	for i := range rows {
+		notes[rows[i].ArticleID] = append(notes[rows[i].ArticleID], rows[i].repoNote.toDomain(rows[i].ArticleID))
+	}
+	return notes, nil
+}
+
+// ListUserArticleHighlights returns the highlights on the saved articles of the user by article, in the order of ListHighlights.
+func (r *PostgresRepository) ListUserArticleHighlights(ctx context.Context, userID uuid.UUID, articleIDs []uuid.UUID) (map[uuid.UUID][]*article.Highlight, common.Error) {
+	highlights := make(map[uuid.UUID][]*article.Highlight)
+	if len(articleIDs) == 0 {
+		return highlights, nil
+	}
+
+	query, args, err := r.pgsq.Select(
+		repoColumnHighlight.qualifiedColumns(),
+		fmt.Sprintf("%s.%s AS article_id", repoTableUserArticle, repoColumnUserArticle.ArticleID),
+	).
+		From(repoTableHighlight).
+		Join(joinUserArticle(repoTableHighlight, repoColumnHighlight.UserArticleID)).
+		Where(sq.Eq{
+			fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.UserID):    userID,
+			fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.ArticleID): articleIDs,
+		}).
+		OrderBy(
+			fmt.Sprintf("%s.%s NULLS LAST", repoTableHighlight, repoColumnHighlight.StartOffset),
+			fmt.Sprintf("%s.%s", repoTableHighlight, repoColumnHighlight.ID),
+		).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for user article highlights"))
+	}
+
+	var rows []struct {
+		repoHighlight
+		ArticleID uuid.UUID `db:"article_id"`
+	}
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to list user article highlights")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select user article highlights"))
+	}
+
+	for i := range rows {
+		highlights[rows[i].ArticleID] = append(highlights[rows[i].ArticleID], rows[i].repoHighlight.toDomain(rows[i].ArticleID))
+	}
+	return highlights, nil
+}
@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+type repoPasswordResetToken struct {
+	ID        int64        `db:"id"`
+	UserID    uuid.UUID    `db:"user_id"`
+	TokenHash string       `db:"token_hash"`
+	ExpiresAt time.Time    `db:"expires_at"`
+	UsedAt    sql.NullTime `db:"used_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+func (t *repoPasswordResetToken) toDomain() *user.PasswordResetToken {
+	var usedAt *time.Time
+	if t.UsedAt.Valid {
+		usedAt = &t.UsedAt.Time
+	}
+
+	return &user.PasswordResetToken{
+		ID:        t.ID,
+		UserID:    t.UserID,
+		TokenHash: t.TokenHash,
+		ExpiresAt: t.ExpiresAt,
+		UsedAt:    usedAt,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+const repoTablePasswordResetToken = "password_reset_tokens"
+
+type repoColumnPatternPasswordResetToken struct {
+	ID        string
+	UserID    string
+	TokenHash string
+	ExpiresAt string
+	UsedAt    string
+	CreatedAt string
+}
+
+var repoColumnPasswordResetToken = repoColumnPatternPasswordResetToken{
+	ID:        "id",
+	UserID:    "user_id",
+	TokenHash: "token_hash",
+	ExpiresAt: "expires_at",
+	UsedAt:    "used_at",
+	CreatedAt: "created_at",
+}
+
+func (c repoColumnPatternPasswordResetToken) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.UserID,
+		c.TokenHash,
+		c.ExpiresAt,
+		c.UsedAt,
+		c.CreatedAt,
+	}, ", ")
+}
+
+func (r *PostgresRepository) CreatePasswordResetToken(ctx context.Context, token *user.PasswordResetToken) common.Error {
+	query, args, err := r.pgsq.Insert(repoTablePasswordResetToken).
+		SetMap(map[string]interface{}{
+			repoColumnPasswordResetToken.UserID:    token.UserID,
+			repoColumnPasswordResetToken.TokenHash: token.TokenHash,
+			repoColumnPasswordResetToken.ExpiresAt: token.ExpiresAt,
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for password reset token"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert password reset token")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert password reset token"))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) GetPasswordResetTokenByHash(ctx context.Context, tokenHash string) (*user.PasswordResetToken, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnPasswordResetToken.columns()).
+		From(repoTablePasswordResetToken).
+		Where(sq.Eq{repoColumnPasswordResetToken.TokenHash: tokenHash}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for password reset token"))
+	}
+
+	var row repoPasswordResetToken
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("password reset token is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select password reset token"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// UsePasswordResetToken marks a reset token as consumed.
+// It returns false if the token was already used by a concurrent or earlier request.
+func (r *PostgresRepository) UsePasswordResetToken(ctx context.Context, tokenID int64) (bool, common.Error) {
+	query, args, err := r.pgsq.Update(repoTablePasswordResetToken).
+		Set(repoColumnPasswordResetToken.UsedAt, time.Now()).
+		Where(sq.And{
+			sq.Eq{repoColumnPasswordResetToken.ID: tokenID},
+			sq.Eq{repoColumnPasswordResetToken.UsedAt: nil},
+		}).
+		ToSql()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for password reset token"))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to use password reset token"))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// InvalidateUserPasswordResetTokens consumes every outstanding reset token of the user
+func (r *PostgresRepository) InvalidateUserPasswordResetTokens(ctx context.Context, userID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Update(repoTablePasswordResetToken).
+		Set(repoColumnPasswordResetToken.UsedAt, time.Now()).
+		Where(sq.And{
+			sq.Eq{repoColumnPasswordResetToken.UserID: userID},
+			sq.Eq{repoColumnPasswordResetToken.UsedAt: nil},
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for password reset tokens"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to invalidate password reset tokens"))
+	}
+
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 	sq "github.com/Masterminds/squirrel"
 	"github.com/hashicorp/go-multierror"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 
 	"github.com/sappy5678/DeeliAi/internal/domain/common"
@@ -60,6 +62,18 @@ func (r *PostgresRepository) finishTx(err common.Error, tx *sqlx.Tx) common.Erro
 	}
 }
 
+// pgUniqueViolation is the SQLSTATE postgres reports when a unique constraint is violated
+const pgUniqueViolation = "23505"
+
+// uniqueViolation returns the name of the unique constraint err violates, if any
+func uniqueViolation(err error) (string, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+		return pqErr.Constraint, true
+	}
+	return "", false
+}
+
 // logger wrap the execution context with component info
 func (r *PostgresRepository) logger(ctx context.Context) *zerolog.Logger {
 	l := zerolog.Ctx(ctx).With().Str("component", "postgres-repository").Logger()
@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+const pemTypePrivateKey = "PRIVATE KEY"
+
+type repoSigningKey struct {
+	ID          string    `db:"id"`
+	Algorithm   string    `db:"algorithm"`
+	PrivateKey  string    `db:"private_key"`
+	CreatedAt   time.Time `db:"created_at"`
+	ActivatesAt time.Time `db:"activates_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+func (k *repoSigningKey) toDomain() (*user.SigningKey, error) {
+	block, _ := pem.Decode([]byte(k.PrivateKey))
+	if block == nil || block.Type != pemTypePrivateKey {
+		return nil, errors.Errorf("signing key %s is not a PEM encoded private key", k.ID)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse signing key %s", k.ID)
+	}
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("signing key %s is not a signer", k.ID)
+	}
+
+	return &user.SigningKey{
+		ID:          k.ID,
+		Algorithm:   k.Algorithm,
+		PrivateKey:  signer,
+		CreatedAt:   k.CreatedAt,
+		ActivatesAt: k.ActivatesAt,
+		ExpiresAt:   k.ExpiresAt,
+	}, nil
+}
+
+const repoTableSigningKey = "signing_keys"
+
+type repoColumnPatternSigningKey struct {
+	ID          string
+	Algorithm   string
+	PrivateKey  string
+	CreatedAt   string
+	ActivatesAt string
+	ExpiresAt   string
+}
+
+var repoColumnSigningKey = repoColumnPatternSigningKey{
+	ID:          "id",
+	Algorithm:   "algorithm",
+	PrivateKey:  "private_key",
+	CreatedAt:   "created_at",
+	ActivatesAt: "activates_at",
+	ExpiresAt:   "expires_at",
+}
+
+func (c repoColumnPatternSigningKey) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.Algorithm,
+		c.PrivateKey,
+		c.CreatedAt,
+		c.ActivatesAt,
+		c.ExpiresAt,
+	}, ", ")
+}
+
+func (r *PostgresRepository) CreateSigningKey(ctx context.Context, key *user.SigningKey) common.Error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to marshal signing key"))
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: pemTypePrivateKey, Bytes: der})
+
+	query, args, err := r.pgsq.Insert(repoTableSigningKey).
+		SetMap(map[string]interface{}{
+			repoColumnSigningKey.ID:          key.ID,
+			repoColumnSigningKey.Algorithm:   key.Algorithm,
+			repoColumnSigningKey.PrivateKey:  string(privateKey),
+			repoColumnSigningKey.CreatedAt:   key.CreatedAt,
+			repoColumnSigningKey.ActivatesAt: key.ActivatesAt,
+			repoColumnSigningKey.ExpiresAt:   key.ExpiresAt,
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for signing key"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert signing key"))
+	}
+
+	return nil
+}
+
+// ListSigningKeys returns every key not yet expired, newest first.
+func (r *PostgresRepository) ListSigningKeys(ctx context.Context) ([]*user.SigningKey, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnSigningKey.columns()).
+		From(repoTableSigningKey).
+		Where(sq.Gt{repoColumnSigningKey.ExpiresAt: time.Now()}).
+		OrderBy(repoColumnSigningKey.CreatedAt + " DESC").
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for signing keys"))
+	}
+
+	var rows []repoSigningKey
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select signing keys"))
+	}
+
+	keys := make([]*user.SigningKey, 0, len(rows))
+	for _, row := range rows {
+		key, err := row.toDomain()
+		if err != nil {
+			r.logger(ctx).Error().Err(err).Str("kid", row.ID).Msg("skip unreadable signing key")
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (r *PostgresRepository) DeleteExpiredSigningKeys(ctx context.Context) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableSigningKey).
+		Where(sq.LtOrEq{repoColumnSigningKey.ExpiresAt: time.Now()}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for signing keys"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to delete expired signing keys"))
+	}
+
+	return nil
+}
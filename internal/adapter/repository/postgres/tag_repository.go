@@ -0,0 +1,459 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// --- tags table ---
+
+type repoTag struct {
+	ID           uuid.UUID `db:"id"`
+	UserID       uuid.UUID `db:"user_id"`
+	Name         string    `db:"name"`
+	ArticleCount int       `db:"article_count"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+func (t *repoTag) toDomain() *article.Tag {
+	return &article.Tag{
+		ID:           t.ID,
+		UserID:       t.UserID,
+		Name:         t.Name,
+		ArticleCount: t.ArticleCount,
+		CreatedAt:    t.CreatedAt,
+	}
+}
+
+const repoTableTag = "tags"
+
+type repoColumnPatternTag struct {
+	ID        string
+	UserID    string
+	Name      string
+	CreatedAt string
+}
+
+var repoColumnTag = repoColumnPatternTag{
+	ID:        "id",
+	UserID:    "user_id",
+	Name:      "name",
+	CreatedAt: "created_at",
+}
+
+func (c repoColumnPatternTag) columns() string {
+	col := []string{
+		c.ID,
+		c.UserID,
+		c.Name,
+		c.CreatedAt,
+	}
+	for i, v := range col {
+		col[i] = fmt.Sprintf("%s.%s", repoTableTag, v)
+	}
+	return strings.Join(col, ", ")
+}
+
+// --- user_article_tags table ---
+
+const repoTableUserArticleTag = "user_article_tags"
+
+type repoColumnPatternUserArticleTag struct {
+	UserArticleID string
+	TagID         string
+}
+
+var repoColumnUserArticleTag = repoColumnPatternUserArticleTag{
+	UserArticleID: "user_article_id",
+	TagID:         "tag_id",
+}
+
+// repoUserArticleTag is a tag of the saved article of ArticleID
+type repoUserArticleTag struct {
+	repoTag
+	ArticleID uuid.UUID `db:"article_id"`
+}
+
+// --- repository methods ---
+
+func (r *PostgresRepository) CreateTag(ctx context.Context, userID uuid.UUID, name string) (*article.Tag, common.Error) {
+	query, args, err := r.pgsq.Insert(repoTableTag).
+		SetMap(map[string]interface{}{
+			repoColumnTag.UserID: userID,
+			repoColumnTag.Name:   name,
+		}).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnTag.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for tag"))
+	}
+
+	var row repoTag
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if _, ok := uniqueViolation(err); ok {
+			return nil, common.NewError(common.ErrorCodeResourceConflict, err, common.WithMsg("tag already exists"))
+		}
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert tag")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert tag"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// GetTag returns a tag of the user with the number of saved articles carrying it.
+func (r *PostgresRepository) GetTag(ctx context.Context, userID uuid.UUID, tagID uuid.UUID) (*article.Tag, common.Error) {
+	query, args, err := r.pgsq.Select(
+		repoColumnTag.columns(),
+		fmt.Sprintf("(SELECT COUNT(*) FROM %s WHERE %s.%s = %s.%s) AS article_count",
+			repoTableUserArticleTag,
+			repoTableUserArticleTag, repoColumnUserArticleTag.TagID,
+			repoTableTag, repoColumnTag.ID),
+	).
+		From(repoTableTag).
+		Where(sq.Eq{
+			repoColumnTag.ID:     tagID,
+			repoColumnTag.UserID: userID,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for tag"))
+	}
+
+	var row repoTag
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("tag is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select tag"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// ListTags returns the tags of the user by name, with the number of saved articles carrying them.
+func (r *PostgresRepository) ListTags(ctx context.Context, userID uuid.UUID) ([]*article.Tag, common.Error) {
+	query, args, err := r.pgsq.Select(
+		repoColumnTag.columns(),
+		fmt.Sprintf("COUNT(%s.%s) AS article_count", repoTableUserArticleTag, repoColumnUserArticleTag.TagID),
+	).
+		From(repoTableTag).
+		LeftJoin(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableUserArticleTag,
+			repoTableUserArticleTag, repoColumnUserArticleTag.TagID,
+			repoTableTag, repoColumnTag.ID)).
+		Where(sq.Eq{fmt.Sprintf("%s.%s", repoTableTag, repoColumnTag.UserID): userID}).
+		GroupBy(fmt.Sprintf("%s.%s", repoTableTag, repoColumnTag.ID)).
+		OrderBy(fmt.Sprintf("LOWER(%s.%s)", repoTableTag, repoColumnTag.Name)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for tags"))
+	}
+
+	var rows []repoTag
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to list tags")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select tags"))
+	}
+
+	tags := make([]*article.Tag, 0, len(rows))
+	for i := range rows {
+		tags = append(tags, rows[i].toDomain())
+	}
+	return tags, nil
+}
+
+// UpdateTagName renames a tag, the saved articles carrying it keep it.
+func (r *PostgresRepository) UpdateTagName(ctx context.Context, userID uuid.UUID, tagID uuid.UUID, name string) (*article.Tag, common.Error) {
+	query, args, err := r.pgsq.Update(repoTableTag).
+		Set(repoColumnTag.Name, name).
+		Where(sq.Eq{
+			repoColumnTag.ID:     tagID,
+			repoColumnTag.UserID: userID,
+		}).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnTag.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for tag"))
+	}
+
+	var row repoTag
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("tag is not found"))
+		}
+		if _, ok := uniqueViolation(err); ok {
+			msg := "another tag already has the name, merge the tags instead"
+			return nil, common.NewError(common.ErrorCodeResourceConflict, err, common.WithMsg(msg))
+		}
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to update tag")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to update tag"))
+	}
+
+	return row.toDomain(), nil
+}
+
+func (r *PostgresRepository) DeleteTag(ctx context.Context, userID uuid.UUID, tagID uuid.UUID) common.Error {
+	return r.deleteTag(ctx, r.db, userID, tagID)
+}
+
+func (r *PostgresRepository) deleteTag(ctx context.Context, db sqlContextGetter, userID uuid.UUID, tagID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableTag).
+		Where(sq.Eq{
+			repoColumnTag.ID:     tagID,
+			repoColumnTag.UserID: userID,
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for tag"))
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to delete tag"))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+	if rowsAffected == 0 {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("tag not found"), common.WithMsg("tag is not found"))
+	}
+
+	return nil
+}
+
+// MergeTags puts the target tag on every saved article carrying the source tag, then deletes the source tag.
+// Both tags must belong to the user.
+func (r *PostgresRepository) MergeTags(ctx context.Context, userID uuid.UUID, sourceID uuid.UUID, targetID uuid.UUID) common.Error {
+	tx, cerr := r.beginTx()
+	if cerr != nil {
+		return cerr
+	}
+
+	cerr = r.moveTagArticles(ctx, tx, userID, sourceID, targetID)
+	if cerr == nil {
+		cerr = r.deleteTag(ctx, tx, userID, sourceID)
+	}
+	return r.finishTx(cerr, tx)
+}
+
+// moveTagArticles links the target tag with the saved articles of the source tag, which both must belong to the user
+func (r *PostgresRepository) moveTagArticles(ctx context.Context, db sqlContextGetter, userID uuid.UUID, sourceID uuid.UUID, targetID uuid.UUID) common.Error {
+	sourceArticles := sq.Select(
+		repoColumnUserArticleTag.UserArticleID,
+		fmt.Sprintf("%s.%s", repoTableTag, repoColumnTag.ID),
+	).
+		From(repoTableUserArticleTag).
+		Join(fmt.Sprintf("%s ON %s.%s = ? AND %s.%s = ?",
+			repoTableTag,
+			repoTableTag, repoColumnTag.ID,
+			repoTableTag, repoColumnTag.UserID), targetID, userID).
+		Where(sq.Eq{repoColumnUserArticleTag.TagID: sourceID})
+
+	query, args, err := r.pgsq.Insert(repoTableUserArticleTag).
+		Columns(repoColumnUserArticleTag.UserArticleID, repoColumnUserArticleTag.TagID).
+		Select(sourceArticles).
+		Suffix("ON CONFLICT DO NOTHING").
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for user_article_tags"))
+	}
+
+	if _, err = db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to merge tags")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert user_article_tags"))
+	}
+
+	return nil
+}
+
+// AddUserArticleTags puts the tags of the names on the saved article, creating the tags the user does not have yet.
+// Names are matched with existing tags ignoring case, and tags the article already carries are left as they are.
+func (r *PostgresRepository) AddUserArticleTags(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, names []string) common.Error {
+	tx, cerr := r.beginTx()
+	if cerr != nil {
+		return cerr
+	}
+
+	cerr = r.createTags(ctx, tx, userID, names)
+	if cerr == nil {
+		cerr = r.linkUserArticleTags(ctx, tx, userID, articleID, names)
+	}
+	return r.finishTx(cerr, tx)
+}
+
+func (r *PostgresRepository) createTags(ctx context.Context, db sqlContextGetter, userID uuid.UUID, names []string) common.Error {
+	insert := r.pgsq.Insert(repoTableTag).
+		Columns(repoColumnTag.UserID, repoColumnTag.Name)
+	for _, name := range names {
+		insert = insert.Values(userID, name)
+	}
+
+	query, args, err := insert.
+		Suffix(fmt.Sprintf("ON CONFLICT (%s, LOWER(%s)) DO NOTHING", repoColumnTag.UserID, repoColumnTag.Name)).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for tags"))
+	}
+
+	if _, err = db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert tags")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert tags"))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) linkUserArticleTags(ctx context.Context, db sqlContextGetter, userID uuid.UUID, articleID uuid.UUID, names []string) common.Error {
+	lowerNames := make([]string, 0, len(names))
+	for _, name := range names {
+		lowerNames = append(lowerNames, strings.ToLower(name))
+	}
+
+	tags := sq.Select(
+		fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.ID),
+		fmt.Sprintf("%s.%s", repoTableTag, repoColumnTag.ID),
+	).
+		From(repoTableUserArticle).
+		Join(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableTag,
+			repoTableTag, repoColumnTag.UserID,
+			repoTableUserArticle, repoColumnUserArticle.UserID)).
+		Where(sq.Eq{
+			fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.UserID):    userID,
+			fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.ArticleID): articleID,
+			fmt.Sprintf("LOWER(%s.%s)", repoTableTag, repoColumnTag.Name):               lowerNames,
+		})
+
+	query, args, err := r.pgsq.Insert(repoTableUserArticleTag).
+		Columns(repoColumnUserArticleTag.UserArticleID, repoColumnUserArticleTag.TagID).
+		Select(tags).
+		Suffix("ON CONFLICT DO NOTHING").
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for user_article_tags"))
+	}
+
+	if _, err = db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert user_article_tags")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert user_article_tags"))
+	}
+
+	return nil
+}
+
+// RemoveUserArticleTag takes the tag off the saved article, the tag itself is kept.
+func (r *PostgresRepository) RemoveUserArticleTag(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, tagID uuid.UUID) common.Error {
+	userArticles := sq.Select(repoColumnUserArticle.ID).
+		From(repoTableUserArticle).
+		Where(sq.Eq{
+			repoColumnUserArticle.UserID:    userID,
+			repoColumnUserArticle.ArticleID: articleID,
+		})
+	userArticlesSQL, userArticlesArgs, err := userArticles.ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for user_article"))
+	}
+
+	query, args, err := r.pgsq.Delete(repoTableUserArticleTag).
+		Where(sq.Eq{repoColumnUserArticleTag.TagID: tagID}).
+		Where(fmt.Sprintf("%s IN (%s)", repoColumnUserArticleTag.UserArticleID, userArticlesSQL), userArticlesArgs...).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for user_article_tag"))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to delete user_article_tag"))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+	if rowsAffected == 0 {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("user article tag not found"), common.WithMsg("the article does not carry the tag"))
+	}
+
+	return nil
+}
+
+// ListUserArticleTags returns the tags on the saved articles of the IDs by article ID, each sorted by name.
+func (r *PostgresRepository) ListUserArticleTags(ctx context.Context, userID uuid.UUID, articleIDs []uuid.UUID) (map[uuid.UUID][]*article.Tag, common.Error) {
+	tags := make(map[uuid.UUID][]*article.Tag)
+	if len(articleIDs) == 0 {
+		return tags, nil
+	}
+
+	query, args, err := r.pgsq.Select(
+		repoColumnTag.columns(),
+		fmt.Sprintf("%s.%s AS article_id", repoTableUserArticle, repoColumnUserArticle.ArticleID),
+	).
+		From(repoTableUserArticleTag).
+		Join(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableUserArticle,
+			repoTableUserArticle, repoColumnUserArticle.ID,
+			repoTableUserArticleTag, repoColumnUserArticleTag.UserArticleID)).
+		Join(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableTag,
+			repoTableTag, repoColumnTag.ID,
+			repoTableUserArticleTag, repoColumnUserArticleTag.TagID)).
+		Where(sq.Eq{
+			fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.UserID):    userID,
+			fmt.Sprintf("%s.%s", repoTableUserArticle, repoColumnUserArticle.ArticleID): articleIDs,
+		}).
+		OrderBy(fmt.Sprintf("LOWER(%s.%s)", repoTableTag, repoColumnTag.Name)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for user article tags"))
+	}
+
+	var rows []repoUserArticleTag
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to list user article tags")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select user article tags"))
+	}
+
+	for i := range rows {
+		tags[rows[i].ArticleID] = append(tags[rows[i].ArticleID], rows[i].repoTag.toDomain())
+	}
+	return tags, nil
+}
+
+// tagFilter returns the condition selecting the saved articles in user_articles which carry the tags of the filter
+func tagFilter(filter article.ArticleFilter) sq.Sqlizer {
+	if len(filter.Tags) == 0 {
+		return sq.And{}
+	}
+
+	// exists matches the articles carrying a tag which name matches cond
+	exists := func(cond string, arg interface{}) sq.Sqlizer {
+		return sq.Expr(fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM %s JOIN %s ON %s.%s = %s.%s WHERE %s.%s = %s.%s AND LOWER(%s.%s) "+cond+")",
+			repoTableUserArticleTag, repoTableTag,
+			repoTableTag, repoColumnTag.ID, repoTableUserArticleTag, repoColumnUserArticleTag.TagID,
+			repoTableUserArticleTag, repoColumnUserArticleTag.UserArticleID, repoTableUserArticle, repoColumnUserArticle.ID,
+			repoTableTag, repoColumnTag.Name,
+		), arg)
+	}
+
+	if filter.TagMatch == article.TagMatchAny {
+		return exists("= ANY(?)", pq.Array(filter.Tags))
+	}
+	all := sq.And{}
+	for _, name := range filter.Tags {
+		all = append(all, exists("= ?", name))
+	}
+	return all
+}
@@ -0,0 +1,370 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// --- token_families table ---
+
+type repoTokenFamily struct {
+	ID         uuid.UUID    `db:"id"`
+	UserID     uuid.UUID    `db:"user_id"`
+	UserAgent  string       `db:"user_agent"`
+	IPAddress  string       `db:"ip_address"`
+	CreatedAt  time.Time    `db:"created_at"`
+	LastSeenAt time.Time    `db:"last_seen_at"`
+	RevokedAt  sql.NullTime `db:"revoked_at"`
+}
+
+func (f *repoTokenFamily) toDomain() *user.TokenFamily {
+	var revokedAt *time.Time
+	if f.RevokedAt.Valid {
+		revokedAt = &f.RevokedAt.Time
+	}
+
+	return &user.TokenFamily{
+		ID:         f.ID,
+		UserID:     f.UserID,
+		UserAgent:  f.UserAgent,
+		IPAddress:  f.IPAddress,
+		CreatedAt:  f.CreatedAt,
+		LastSeenAt: f.LastSeenAt,
+		RevokedAt:  revokedAt,
+	}
+}
+
+const repoTableTokenFamily = "token_families"
+
+type repoColumnPatternTokenFamily struct {
+	ID         string
+	UserID     string
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  string
+	LastSeenAt string
+	RevokedAt  string
+}
+
+var repoColumnTokenFamily = repoColumnPatternTokenFamily{
+	ID:         "id",
+	UserID:     "user_id",
+	UserAgent:  "user_agent",
+	IPAddress:  "ip_address",
+	CreatedAt:  "created_at",
+	LastSeenAt: "last_seen_at",
+	RevokedAt:  "revoked_at",
+}
+
+func (c repoColumnPatternTokenFamily) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.UserID,
+		c.UserAgent,
+		c.IPAddress,
+		c.CreatedAt,
+		c.LastSeenAt,
+		c.RevokedAt,
+	}, ", ")
+}
+
+// --- refresh_tokens table ---
+
+type repoRefreshToken struct {
+	ID        int64        `db:"id"`
+	FamilyID  uuid.UUID    `db:"family_id"`
+	TokenHash string       `db:"token_hash"`
+	ExpiresAt time.Time    `db:"expires_at"`
+	RotatedAt sql.NullTime `db:"rotated_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+func (t *repoRefreshToken) toDomain() *user.RefreshToken {
+	var rotatedAt *time.Time
+	if t.RotatedAt.Valid {
+		rotatedAt = &t.RotatedAt.Time
+	}
+
+	return &user.RefreshToken{
+		ID:        t.ID,
+		FamilyID:  t.FamilyID,
+		TokenHash: t.TokenHash,
+		ExpiresAt: t.ExpiresAt,
+		RotatedAt: rotatedAt,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+const repoTableRefreshToken = "refresh_tokens"
+
+type repoColumnPatternRefreshToken struct {
+	ID        string
+	FamilyID  string
+	TokenHash string
+	ExpiresAt string
+	RotatedAt string
+	CreatedAt string
+}
+
+var repoColumnRefreshToken = repoColumnPatternRefreshToken{
+	ID:        "id",
+	FamilyID:  "family_id",
+	TokenHash: "token_hash",
+	ExpiresAt: "expires_at",
+	RotatedAt: "rotated_at",
+	CreatedAt: "created_at",
+}
+
+func (c repoColumnPatternRefreshToken) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.FamilyID,
+		c.TokenHash,
+		c.ExpiresAt,
+		c.RotatedAt,
+		c.CreatedAt,
+	}, ", ")
+}
+
+// --- repository methods ---
+
+func (r *PostgresRepository) CreateTokenFamily(ctx context.Context, family *user.TokenFamily) (*user.TokenFamily, common.Error) {
+	query, args, err := r.pgsq.Insert(repoTableTokenFamily).
+		SetMap(map[string]interface{}{
+			repoColumnTokenFamily.UserID:    family.UserID,
+			repoColumnTokenFamily.UserAgent: family.UserAgent,
+			repoColumnTokenFamily.IPAddress: family.IPAddress,
+		}).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnTokenFamily.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for token family"))
+	}
+
+	var row repoTokenFamily
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert token family")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert token family"))
+	}
+
+	return row.toDomain(), nil
+}
+
+func (r *PostgresRepository) GetTokenFamily(ctx context.Context, familyID uuid.UUID) (*user.TokenFamily, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnTokenFamily.columns()).
+		From(repoTableTokenFamily).
+		Where(sq.Eq{repoColumnTokenFamily.ID: familyID}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for token family"))
+	}
+
+	var row repoTokenFamily
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("token family is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select token family"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// ListActiveTokenFamilies returns the families of the user that are neither revoked nor past their last refresh token,
+// the most recently seen first
+func (r *PostgresRepository) ListActiveTokenFamilies(ctx context.Context, userID uuid.UUID, now time.Time) ([]*user.TokenFamily, common.Error) {
+	activeToken := r.pgsq.Select("1").
+		From(repoTableRefreshToken).
+		Where(sq.And{
+			sq.Expr(fmt.Sprintf("%s.%s = %s.%s", repoTableRefreshToken, repoColumnRefreshToken.FamilyID, repoTableTokenFamily, repoColumnTokenFamily.ID)),
+			sq.Eq{repoColumnRefreshToken.RotatedAt: nil},
+			sq.Gt{repoColumnRefreshToken.ExpiresAt: now},
+		}).
+		Prefix("EXISTS (").
+		Suffix(")")
+
+	query, args, err := r.pgsq.Select(repoColumnTokenFamily.columns()).
+		From(repoTableTokenFamily).
+		Where(sq.And{
+			sq.Eq{repoColumnTokenFamily.UserID: userID},
+			sq.Eq{repoColumnTokenFamily.RevokedAt: nil},
+			activeToken,
+		}).
+		OrderBy(repoColumnTokenFamily.LastSeenAt + " DESC").
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for token families"))
+	}
+
+	var rows []repoTokenFamily
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select token families"))
+	}
+
+	families := make([]*user.TokenFamily, 0, len(rows))
+	for i := range rows {
+		families = append(families, rows[i].toDomain())
+	}
+	return families, nil
+}
+
+// TouchTokenFamily records that the family was used at seenAt, and from which device if one is given
+func (r *PostgresRepository) TouchTokenFamily(ctx context.Context, familyID uuid.UUID, seenAt time.Time, device *user.Device) common.Error {
+	update := r.pgsq.Update(repoTableTokenFamily).
+		Set(repoColumnTokenFamily.LastSeenAt, seenAt).
+		Where(sq.Eq{repoColumnTokenFamily.ID: familyID})
+	if device != nil {
+		update = update.
+			Set(repoColumnTokenFamily.UserAgent, device.UserAgent).
+			Set(repoColumnTokenFamily.IPAddress, device.IP)
+	}
+	query, args, err := update.ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for token family"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to touch token family"))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) RevokeTokenFamily(ctx context.Context, familyID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Update(repoTableTokenFamily).
+		Set(repoColumnTokenFamily.RevokedAt, time.Now()).
+		Where(sq.And{
+			sq.Eq{repoColumnTokenFamily.ID: familyID},
+			sq.Eq{repoColumnTokenFamily.RevokedAt: nil},
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for token family"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to revoke token family"))
+	}
+
+	return nil
+}
+
+// RevokeUserTokenFamilies revokes every token family of the user, logging them out everywhere
+func (r *PostgresRepository) RevokeUserTokenFamilies(ctx context.Context, userID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Update(repoTableTokenFamily).
+		Set(repoColumnTokenFamily.RevokedAt, time.Now()).
+		Where(sq.And{
+			sq.Eq{repoColumnTokenFamily.UserID: userID},
+			sq.Eq{repoColumnTokenFamily.RevokedAt: nil},
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for token families"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to revoke user token families"))
+	}
+
+	return nil
+}
+
+// RevokeOtherUserTokenFamilies revokes every token family of the user but keepFamilyID, logging them out everywhere else
+func (r *PostgresRepository) RevokeOtherUserTokenFamilies(ctx context.Context, userID uuid.UUID, keepFamilyID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Update(repoTableTokenFamily).
+		Set(repoColumnTokenFamily.RevokedAt, time.Now()).
+		Where(sq.And{
+			sq.Eq{repoColumnTokenFamily.UserID: userID},
+			sq.NotEq{repoColumnTokenFamily.ID: keepFamilyID},
+			sq.Eq{repoColumnTokenFamily.RevokedAt: nil},
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for token families"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to revoke other user token families"))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) CreateRefreshToken(ctx context.Context, token *user.RefreshToken) common.Error {
+	query, args, err := r.pgsq.Insert(repoTableRefreshToken).
+		SetMap(map[string]interface{}{
+			repoColumnRefreshToken.FamilyID:  token.FamilyID,
+			repoColumnRefreshToken.TokenHash: token.TokenHash,
+			repoColumnRefreshToken.ExpiresAt: token.ExpiresAt,
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for refresh token"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert refresh token")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert refresh token"))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*user.RefreshToken, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnRefreshToken.columns()).
+		From(repoTableRefreshToken).
+		Where(sq.Eq{repoColumnRefreshToken.TokenHash: tokenHash}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for refresh token"))
+	}
+
+	var row repoRefreshToken
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("refresh token is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select refresh token"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// RotateRefreshToken marks a refresh token as exchanged.
+// It returns false if the token was already rotated by a concurrent or earlier request.
+func (r *PostgresRepository) RotateRefreshToken(ctx context.Context, tokenID int64) (bool, common.Error) {
+	query, args, err := r.pgsq.Update(repoTableRefreshToken).
+		Set(repoColumnRefreshToken.RotatedAt, time.Now()).
+		Where(sq.And{
+			sq.Eq{repoColumnRefreshToken.ID: tokenID},
+			sq.Eq{repoColumnRefreshToken.RotatedAt: nil},
+		}).
+		ToSql()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for refresh token"))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to rotate refresh token"))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+
+	return rowsAffected == 1, nil
+}
@@ -0,0 +1,481 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// --- user_totp table ---
+
+type repoTOTP struct {
+	UserID       uuid.UUID    `db:"user_id"`
+	Secret       string       `db:"secret"`
+	ConfirmedAt  sql.NullTime `db:"confirmed_at"`
+	LastUsedStep int64        `db:"last_used_step"`
+	CreatedAt    time.Time    `db:"created_at"`
+}
+
+func (t *repoTOTP) toDomain() *user.TOTP {
+	var confirmedAt *time.Time
+	if t.ConfirmedAt.Valid {
+		confirmedAt = &t.ConfirmedAt.Time
+	}
+
+	return &user.TOTP{
+		UserID:       t.UserID,
+		Secret:       t.Secret,
+		ConfirmedAt:  confirmedAt,
+		LastUsedStep: t.LastUsedStep,
+		CreatedAt:    t.CreatedAt,
+	}
+}
+
+const repoTableTOTP = "user_totp"
+
+type repoColumnPatternTOTP struct {
+	UserID       string
+	Secret       string
+	ConfirmedAt  string
+	LastUsedStep string
+	CreatedAt    string
+}
+
+var repoColumnTOTP = repoColumnPatternTOTP{
+	UserID:       "user_id",
+	Secret:       "secret",
+	ConfirmedAt:  "confirmed_at",
+	LastUsedStep: "last_used_step",
+	CreatedAt:    "created_at",
+}
+
+func (c repoColumnPatternTOTP) columns() string {
+	return strings.Join([]string{
+		c.UserID,
+		c.Secret,
+		c.ConfirmedAt,
+		c.LastUsedStep,
+		c.CreatedAt,
+	}, ", ")
+}
+
+// --- recovery_codes table ---
+
+type repoRecoveryCode struct {
+	ID        int64        `db:"id"`
+	UserID    uuid.UUID    `db:"user_id"`
+	CodeHash  string       `db:"code_hash"`
+	UsedAt    sql.NullTime `db:"used_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+func (c *repoRecoveryCode) toDomain() *user.RecoveryCode {
+	var usedAt *time.Time
+	if c.UsedAt.Valid {
+		usedAt = &c.UsedAt.Time
+	}
+
+	return &user.RecoveryCode{
+		ID:        c.ID,
+		UserID:    c.UserID,
+		CodeHash:  c.CodeHash,
+		UsedAt:    usedAt,
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+const repoTableRecoveryCode = "recovery_codes"
+
+type repoColumnPatternRecoveryCode struct {
+	ID        string
+	UserID    string
+	CodeHash  string
+	UsedAt    string
+	CreatedAt string
+}
+
+var repoColumnRecoveryCode = repoColumnPatternRecoveryCode{
+	ID:        "id",
+	UserID:    "user_id",
+	CodeHash:  "code_hash",
+	UsedAt:    "used_at",
+	CreatedAt: "created_at",
+}
+
+func (c repoColumnPatternRecoveryCode) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.UserID,
+		c.CodeHash,
+		c.UsedAt,
+		c.CreatedAt,
+	}, ", ")
+}
+
+// --- login_challenges table ---
+
+type repoLoginChallenge struct {
+	ID        int64        `db:"id"`
+	UserID    uuid.UUID    `db:"user_id"`
+	TokenHash string       `db:"token_hash"`
+	ExpiresAt time.Time    `db:"expires_at"`
+	UsedAt    sql.NullTime `db:"used_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+func (c *repoLoginChallenge) toDomain() *user.LoginChallenge {
+	var usedAt *time.Time
+	if c.UsedAt.Valid {
+		usedAt = &c.UsedAt.Time
+	}
+
+	return &user.LoginChallenge{
+		ID:        c.ID,
+		UserID:    c.UserID,
+		TokenHash: c.TokenHash,
+		ExpiresAt: c.ExpiresAt,
+		UsedAt:    usedAt,
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+const repoTableLoginChallenge = "login_challenges"
+
+type repoColumnPatternLoginChallenge struct {
+	ID        string
+	UserID    string
+	TokenHash string
+	ExpiresAt string
+	UsedAt    string
+	CreatedAt string
+}
+
+var repoColumnLoginChallenge = repoColumnPatternLoginChallenge{
+	ID:        "id",
+	UserID:    "user_id",
+	TokenHash: "token_hash",
+	ExpiresAt: "expires_at",
+	UsedAt:    "used_at",
+	CreatedAt: "created_at",
+}
+
+func (c repoColumnPatternLoginChallenge) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.UserID,
+		c.TokenHash,
+		c.ExpiresAt,
+		c.UsedAt,
+		c.CreatedAt,
+	}, ", ")
+}
+
+// --- repository methods ---
+
+// SaveTOTP stores a new unconfirmed secret for the user, replacing a previous unconfirmed one.
+// A confirmed secret is left untouched.
+func (r *PostgresRepository) SaveTOTP(ctx context.Context, totp *user.TOTP) common.Error {
+	c := repoColumnTOTP
+	query, args, err := r.pgsq.Insert(repoTableTOTP).
+		SetMap(map[string]interface{}{
+			c.UserID: totp.UserID,
+			c.Secret: totp.Secret,
+		}).
+		Suffix(fmt.Sprintf(
+			"ON CONFLICT (%[1]s) DO UPDATE SET %[2]s = EXCLUDED.%[2]s, %[3]s = 0, %[4]s = CURRENT_TIMESTAMP WHERE %[5]s.%[6]s IS NULL",
+			c.UserID, c.Secret, c.LastUsedStep, c.CreatedAt, repoTableTOTP, c.ConfirmedAt,
+		)).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build upsert query for totp"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to save totp")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to save totp"))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) GetTOTP(ctx context.Context, userID uuid.UUID) (*user.TOTP, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnTOTP.columns()).
+		From(repoTableTOTP).
+		Where(sq.Eq{repoColumnTOTP.UserID: userID}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for totp"))
+	}
+
+	var row repoTOTP
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("two-factor authentication is not set up"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select totp"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// ConfirmTOTP enables two-factor authentication for the user and replaces the recovery codes in one transaction.
+// usedStep is the step of the code the secret was confirmed with, so that the code cannot log in afterwards.
+func (r *PostgresRepository) ConfirmTOTP(ctx context.Context, userID uuid.UUID, usedStep int64, recoveryCodeHashes []string) common.Error {
+	tx, cerr := r.beginTx()
+	if cerr != nil {
+		return cerr
+	}
+
+	cerr = r.confirmTOTP(ctx, tx, userID, usedStep)
+	if cerr == nil {
+		cerr = r.replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes)
+	}
+	return r.finishTx(cerr, tx)
+}
+
+func (r *PostgresRepository) confirmTOTP(ctx context.Context, db sqlContextGetter, userID uuid.UUID, usedStep int64) common.Error {
+	query, args, err := r.pgsq.Update(repoTableTOTP).
+		SetMap(map[string]interface{}{
+			repoColumnTOTP.ConfirmedAt:  time.Now(),
+			repoColumnTOTP.LastUsedStep: usedStep,
+		}).
+		Where(sq.And{
+			sq.Eq{repoColumnTOTP.UserID: userID},
+			sq.Eq{repoColumnTOTP.ConfirmedAt: nil},
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for totp"))
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to confirm totp"))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+	if rowsAffected == 0 {
+		return common.NewError(common.ErrorCodeResourceConflict, errors.New("totp is already confirmed"), common.WithMsg("two-factor authentication is already enabled"))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) replaceRecoveryCodes(ctx context.Context, db sqlContextGetter, userID uuid.UUID, codeHashes []string) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableRecoveryCode).
+		Where(sq.Eq{repoColumnRecoveryCode.UserID: userID}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for recovery codes"))
+	}
+	if _, err = db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to delete recovery codes"))
+	}
+
+	if len(codeHashes) == 0 {
+		return nil
+	}
+	insert := r.pgsq.Insert(repoTableRecoveryCode).
+		Columns(repoColumnRecoveryCode.UserID, repoColumnRecoveryCode.CodeHash)
+	for _, codeHash := range codeHashes {
+		insert = insert.Values(userID, codeHash)
+	}
+	query, args, err = insert.ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for recovery codes"))
+	}
+	if _, err = db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert recovery codes")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert recovery codes"))
+	}
+
+	return nil
+}
+
+// UseTOTPStep records the step of an accepted code.
+// It returns false if a code of the same or a later step was accepted concurrently or before.
+func (r *PostgresRepository) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, common.Error) {
+	query, args, err := r.pgsq.Update(repoTableTOTP).
+		Set(repoColumnTOTP.LastUsedStep, step).
+		Where(sq.And{
+			sq.Eq{repoColumnTOTP.UserID: userID},
+			sq.Lt{repoColumnTOTP.LastUsedStep: step},
+		}).
+		ToSql()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for totp"))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to use totp step"))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// DeleteTOTP disables two-factor authentication for the user, dropping the secret and the recovery codes.
+func (r *PostgresRepository) DeleteTOTP(ctx context.Context, userID uuid.UUID) common.Error {
+	tx, cerr := r.beginTx()
+	if cerr != nil {
+		return cerr
+	}
+
+	cerr = r.replaceRecoveryCodes(ctx, tx, userID, nil)
+	if cerr == nil {
+		cerr = r.deleteTOTP(ctx, tx, userID)
+	}
+	return r.finishTx(cerr, tx)
+}
+
+func (r *PostgresRepository) deleteTOTP(ctx context.Context, db sqlContextGetter, userID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableTOTP).
+		Where(sq.Eq{repoColumnTOTP.UserID: userID}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for totp"))
+	}
+
+	if _, err = db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to delete totp"))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) GetRecoveryCodeByHash(ctx context.Context, userID uuid.UUID, codeHash string) (*user.RecoveryCode, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnRecoveryCode.columns()).
+		From(repoTableRecoveryCode).
+		Where(sq.Eq{
+			repoColumnRecoveryCode.UserID:   userID,
+			repoColumnRecoveryCode.CodeHash: codeHash,
+		}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for recovery code"))
+	}
+
+	var row repoRecoveryCode
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("recovery code is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select recovery code"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// UseRecoveryCode marks a recovery code as consumed.
+// It returns false if the code was already used by a concurrent or earlier request.
+func (r *PostgresRepository) UseRecoveryCode(ctx context.Context, codeID int64) (bool, common.Error) {
+	query, args, err := r.pgsq.Update(repoTableRecoveryCode).
+		Set(repoColumnRecoveryCode.UsedAt, time.Now()).
+		Where(sq.And{
+			sq.Eq{repoColumnRecoveryCode.ID: codeID},
+			sq.Eq{repoColumnRecoveryCode.UsedAt: nil},
+		}).
+		ToSql()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for recovery code"))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to use recovery code"))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *PostgresRepository) CreateLoginChallenge(ctx context.Context, challenge *user.LoginChallenge) common.Error {
+	query, args, err := r.pgsq.Insert(repoTableLoginChallenge).
+		SetMap(map[string]interface{}{
+			repoColumnLoginChallenge.UserID:    challenge.UserID,
+			repoColumnLoginChallenge.TokenHash: challenge.TokenHash,
+			repoColumnLoginChallenge.ExpiresAt: challenge.ExpiresAt,
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for login challenge"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert login challenge")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert login challenge"))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) GetLoginChallengeByHash(ctx context.Context, tokenHash string) (*user.LoginChallenge, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnLoginChallenge.columns()).
+		From(repoTableLoginChallenge).
+		Where(sq.Eq{repoColumnLoginChallenge.TokenHash: tokenHash}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for login challenge"))
+	}
+
+	var row repoLoginChallenge
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("login challenge is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select login challenge"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// UseLoginChallenge marks a login challenge as passed.
+// It returns false if the challenge was already passed by a concurrent or earlier request.
+func (r *PostgresRepository) UseLoginChallenge(ctx context.Context, challengeID int64) (bool, common.Error) {
+	query, args, err := r.pgsq.Update(repoTableLoginChallenge).
+		Set(repoColumnLoginChallenge.UsedAt, time.Now()).
+		Where(sq.And{
+			sq.Eq{repoColumnLoginChallenge.ID: challengeID},
+			sq.Eq{repoColumnLoginChallenge.UsedAt: nil},
+		}).
+		ToSql()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for login challenge"))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to use login challenge"))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+
+	return rowsAffected == 1, nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
@@ -18,29 +19,66 @@ type UserRepository interface {
 	CreateUser(ctx context.Context, user *user.User) (*user.User, common.Error)
 	GetUserByEmail(ctx context.Context, email string) (*user.User, common.Error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*user.User, common.Error)
+	UpdateUserPassword(ctx context.Context, id uuid.UUID, passwordHash string) common.Error
+	MarkUserEmailVerified(ctx context.Context, id uuid.UUID, email string) common.Error
+	UpdateUserProfile(ctx context.Context, id uuid.UUID, username string, email string) (*user.User, common.Error)
+	DeleteUser(ctx context.Context, id uuid.UUID) common.Error
+	ListUsers(ctx context.Context, afterID uuid.UUID, limit int) ([]*user.User, common.Error)
+	UpdateUserRole(ctx context.Context, id uuid.UUID, role string) common.Error
+	UpdateUserDisabledAt(ctx context.Context, id uuid.UUID, disabledAt *time.Time) common.Error
 }
 
 type repoUser struct {
-	ID           uuid.UUID `db:"id"`
-	Email        string    `db:"email"`
-	Username     string    `db:"username"`
-	PasswordHash string    `db:"password_hash"`
+	ID              uuid.UUID    `db:"id"`
+	Email           string       `db:"email"`
+	Username        string       `db:"username"`
+	PasswordHash    string       `db:"password_hash"`
+	EmailVerifiedAt sql.NullTime `db:"email_verified_at"`
+	Role            string       `db:"role"`
+	DisabledAt      sql.NullTime `db:"disabled_at"`
+}
+
+func (u *repoUser) toDomain() *user.User {
+	var emailVerifiedAt *time.Time
+	if u.EmailVerifiedAt.Valid {
+		emailVerifiedAt = &u.EmailVerifiedAt.Time
+	}
+	var disabledAt *time.Time
+	if u.DisabledAt.Valid {
+		disabledAt = &u.DisabledAt.Time
+	}
+
+	return &user.User{
+		ID:              u.ID,
+		Email:           u.Email,
+		Username:        u.Username,
+		PasswordHash:    u.PasswordHash,
+		EmailVerifiedAt: emailVerifiedAt,
+		Role:            u.Role,
+		DisabledAt:      disabledAt,
+	}
 }
 
 const repoTableUser = "users"
 
 type repoColumnPatternUser struct {
-	ID           string
-	Email        string
-	Username     string
-	PasswordHash string
+	ID              string
+	Email           string
+	Username        string
+	PasswordHash    string
+	EmailVerifiedAt string
+	Role            string
+	DisabledAt      string
 }
 
 var repoColumnUser = repoColumnPatternUser{
-	ID:           "id",
-	Email:        "email",
-	Username:     "username",
-	PasswordHash: "password_hash",
+	ID:              "id",
+	Email:           "email",
+	Username:        "username",
+	PasswordHash:    "password_hash",
+	EmailVerifiedAt: "email_verified_at",
+	Role:            "role",
+	DisabledAt:      "disabled_at",
 }
 
 func (c *repoColumnPatternUser) columns() string {
@@ -49,6 +87,9 @@ func (c *repoColumnPatternUser) columns() string {
 		c.Email,
 		c.Username,
 		c.PasswordHash,
+		c.EmailVerifiedAt,
+		c.Role,
+		c.DisabledAt,
 	}, ", ")
 }
 
@@ -68,9 +109,8 @@ func (r *PostgresRepository) CreateUser(ctx context.Context, param *user.User) (
 
 	_, err = r.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		if _, cerr := r.GetUserByEmail(ctx, param.Email); cerr == nil {
-			e := fmt.Errorf("user with email %s already exists", param.Email)
-			return nil, common.NewError(common.ErrorCodeParameterInvalid, e, common.WithMsg(e.Error()))
+		if cerr := userConflictError(err); cerr != nil {
+			return nil, cerr
 		}
 		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to get user")
 		return nil, common.NewError(common.ErrorCodeRemoteProcess, err)
@@ -103,8 +143,11 @@ func (r *PostgresRepository) GetUserByEmail(ctx context.Context, email string) (
 		return nil, common.NewError(common.ErrorCodeRemoteProcess, err, common.WithMsg(query))
 	}
 
-	result := user.User(row)
-	return &result, nil
+	return row.toDomain(), nil
+}
+
+func (r *PostgresRepository) UpdateUserPassword(ctx context.Context, id uuid.UUID, passwordHash string) common.Error {
+	return r.updateUser(ctx, id, repoColumnUser.PasswordHash, passwordHash)
 }
 
 func (r *PostgresRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*user.User, common.Error) {
@@ -125,6 +168,185 @@ func (r *PostgresRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*us
 		return nil, common.NewError(common.ErrorCodeRemoteProcess, err)
 	}
 
-	result := user.User(row)
-	return &result, nil
+	return row.toDomain(), nil
+}
+
+// UpdateUserProfile sets the username and email of the user.
+// Changing the email clears its verification, the new address has to be verified again.
+func (r *PostgresRepository) UpdateUserProfile(ctx context.Context, id uuid.UUID, username string, email string) (*user.User, common.Error) {
+	emailVerifiedAt := sq.Expr(
+		fmt.Sprintf("CASE WHEN %s = ? THEN %s ELSE NULL END", repoColumnUser.Email, repoColumnUser.EmailVerifiedAt),
+		email,
+	)
+	query, args, err := r.pgsq.Update(repoTableUser).
+		Set(repoColumnUser.Username, username).
+		Set(repoColumnUser.Email, email).
+		Set(repoColumnUser.EmailVerifiedAt, emailVerifiedAt).
+		Where(sq.Eq{repoColumnUser.ID: id}).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnUser.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+
+	row := repoUser{}
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("user is not found"))
+		}
+		if cerr := userConflictError(err); cerr != nil {
+			return nil, cerr
+		}
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to update user profile")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, err)
+	}
+
+	return row.toDomain(), nil
+}
+
+// DeleteUser deletes the user. Saved articles, ratings and tokens of the user go with it through ON DELETE CASCADE.
+func (r *PostgresRepository) DeleteUser(ctx context.Context, id uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableUser).
+		Where(sq.Eq{repoColumnUser.ID: id}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to delete user")
+		return common.NewError(common.ErrorCodeRemoteProcess, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, err)
+	}
+	if rowsAffected == 0 {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("user is not found"), common.WithMsg("user is not found"))
+	}
+
+	// Drop the ratings of the user from the recommendation aggregates now instead of at the next scheduled refresh
+	if cerr := r.RefreshMaterializedView(ctx); cerr != nil {
+		r.logger(ctx).Error().Err(cerr).Msg("failed to refresh materialized view after deleting user")
+	}
+
+	return nil
+}
+
+// ListUsers returns users ordered by ID, starting after afterID
+func (r *PostgresRepository) ListUsers(ctx context.Context, afterID uuid.UUID, limit int) ([]*user.User, common.Error) {
+	builder := r.pgsq.Select(repoColumnUser.columns()).
+		From(repoTableUser).
+		OrderBy(repoColumnUser.ID).
+		Limit(uint64(limit))
+	if afterID != uuid.Nil {
+		builder = builder.Where(sq.Gt{repoColumnUser.ID: afterID})
+	}
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+
+	var rows []repoUser
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to list users")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, err)
+	}
+
+	users := make([]*user.User, 0, len(rows))
+	for i := range rows {
+		users = append(users, rows[i].toDomain())
+	}
+	return users, nil
+}
+
+func (r *PostgresRepository) UpdateUserRole(ctx context.Context, id uuid.UUID, role string) common.Error {
+	return r.updateUser(ctx, id, repoColumnUser.Role, role)
+}
+
+// UpdateUserDisabledAt disables the user at disabledAt, or enables the user if it is nil
+func (r *PostgresRepository) UpdateUserDisabledAt(ctx context.Context, id uuid.UUID, disabledAt *time.Time) common.Error {
+	return r.updateUser(ctx, id, repoColumnUser.DisabledAt, disabledAt)
+}
+
+// updateUser sets a single column of the user
+func (r *PostgresRepository) updateUser(ctx context.Context, id uuid.UUID, column string, value interface{}) common.Error {
+	query, args, err := r.pgsq.Update(repoTableUser).
+		Set(column, value).
+		Where(sq.Eq{repoColumnUser.ID: id}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msgf("failed to update user %s", column)
+		return common.NewError(common.ErrorCodeRemoteProcess, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, err)
+	}
+	if rowsAffected == 0 {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("user is not found"), common.WithMsg("user is not found"))
+	}
+
+	return nil
+}
+
+// userConflictError maps a violated unique constraint of the users table to a conflict error.
+// It returns nil if err is not a unique violation.
+func userConflictError(err error) common.Error {
+	constraint, ok := uniqueViolation(err)
+	if !ok {
+		return nil
+	}
+
+	var field, msg string
+	switch constraint {
+	case "users_username_key":
+		field, msg = "username", "username is already taken"
+	case "users_email_key":
+		field, msg = "email", "email is already registered"
+	default:
+		msg = "user already exists"
+	}
+
+	opts := []common.ErrorOption{common.WithMsg(msg)}
+	if field != "" {
+		opts = append(opts, common.WithDetail(map[string]interface{}{"field": field}))
+	}
+	return common.NewError(common.ErrorCodeResourceConflict, errors.Wrap(err, msg), opts...)
+}
+
+// MarkUserEmailVerified confirms the email of the user, as long as it has not changed in the meantime
+func (r *PostgresRepository) MarkUserEmailVerified(ctx context.Context, id uuid.UUID, email string) common.Error {
+	query, args, err := r.pgsq.Update(repoTableUser).
+		Set(repoColumnUser.EmailVerifiedAt, time.Now()).
+		Where(sq.And{
+			sq.Eq{repoColumnUser.ID: id},
+			sq.Eq{repoColumnUser.Email: email},
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to mark user email verified")
+		return common.NewError(common.ErrorCodeRemoteProcess, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, err)
+	}
+	if rowsAffected == 0 {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("user with this email is not found"), common.WithMsg("user is not found"))
+	}
+
+	return nil
 }
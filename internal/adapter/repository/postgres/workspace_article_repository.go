@@ -0,0 +1,223 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// --- workspace_articles table ---
+
+const repoTableWorkspaceArticle = "workspace_articles"
+
+type repoColumnPatternWorkspaceArticle struct {
+	ID          string
+	WorkspaceID string
+	ArticleID   string
+	AddedBy     string
+	CollectedAt string
+}
+
+var repoColumnWorkspaceArticle = repoColumnPatternWorkspaceArticle{
+	ID:          "id",
+	WorkspaceID: "workspace_id",
+	ArticleID:   "article_id",
+	AddedBy:     "added_by",
+	CollectedAt: "collected_at",
+}
+
+func (c repoColumnPatternWorkspaceArticle) columns() string {
+	col := []string{
+		c.WorkspaceID,
+		c.AddedBy,
+		c.CollectedAt,
+	}
+	for i, v := range col {
+		col[i] = fmt.Sprintf("%s.%s", repoTableWorkspaceArticle, v)
+	}
+	return strings.Join(col, ", ")
+}
+
+type repoWorkspaceArticle struct {
+	repoArticle
+	WorkspaceID   uuid.UUID     `db:"workspace_id"`
+	AddedBy       uuid.NullUUID `db:"added_by"`
+	CollectedAt   time.Time     `db:"collected_at"`
+	AverageRating float64       `db:"average_rating"`
+	RatingCount   int           `db:"rating_count"`
+	Rate          int16         `db:"rate"`
+}
+
+func (a *repoWorkspaceArticle) toDomain() *article.WorkspaceArticle {
+	return &article.WorkspaceArticle{
+		Article:       *a.repoArticle.toDomain(),
+		WorkspaceID:   a.WorkspaceID,
+		AddedBy:       a.AddedBy.UUID,
+		CollectedAt:   a.CollectedAt,
+		AverageRating: a.AverageRating,
+		RatingCount:   a.RatingCount,
+		Rate:          a.Rate,
+	}
+}
+
+// --- workspace_article_ratings table ---
+
+const repoTableWorkspaceArticleRating = "workspace_article_ratings"
+
+type repoColumnPatternWorkspaceArticleRating struct {
+	WorkspaceID string
+	ArticleID   string
+	UserID      string
+	Rate        string
+	RatedAt     string
+}
+
+var repoColumnWorkspaceArticleRating = repoColumnPatternWorkspaceArticleRating{
+	WorkspaceID: "workspace_id",
+	ArticleID:   "article_id",
+	UserID:      "user_id",
+	Rate:        "rate",
+	RatedAt:     "rated_at",
+}
+
+// --- repository methods ---
+
+// CreateWorkspaceArticle saves an article to the workspace, saving it again keeps who added it first.
+func (r *PostgresRepository) CreateWorkspaceArticle(ctx context.Context, workspaceID uuid.UUID, articleID uuid.UUID, addedBy uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Insert(repoTableWorkspaceArticle).
+		SetMap(map[string]interface{}{
+			repoColumnWorkspaceArticle.WorkspaceID: workspaceID,
+			repoColumnWorkspaceArticle.ArticleID:   articleID,
+			repoColumnWorkspaceArticle.AddedBy:     addedBy,
+		}).
+		Suffix(fmt.Sprintf("ON CONFLICT (%s, %s) DO NOTHING", repoColumnWorkspaceArticle.WorkspaceID, repoColumnWorkspaceArticle.ArticleID)).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for workspace article"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert workspace article")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert workspace article"))
+	}
+
+	return nil
+}
+
+// GetWorkspaceArticle returns an article of the workspace with the rating of the user.
+func (r *PostgresRepository) GetWorkspaceArticle(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, articleID uuid.UUID) (*article.WorkspaceArticle, common.Error) {
+	query, args, err := r.selectWorkspaceArticles(userID).
+		Where(sq.Eq{
+			fmt.Sprintf("%s.%s", repoTableWorkspaceArticle, repoColumnWorkspaceArticle.WorkspaceID): workspaceID,
+			fmt.Sprintf("%s.%s", repoTableWorkspaceArticle, repoColumnWorkspaceArticle.ArticleID):   articleID,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for workspace article"))
+	}
+
+	var row repoWorkspaceArticle
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("workspace article is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select workspace article"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// ListWorkspaceArticles returns the articles of the workspace ordered by article ID, with the ratings of its members
+// and the rating of the user.
+func (r *PostgresRepository) ListWorkspaceArticles(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, afterID uuid.UUID, limit int) ([]*article.WorkspaceArticle, common.Error) {
+	where := sq.And{
+		sq.Eq{fmt.Sprintf("%s.%s", repoTableWorkspaceArticle, repoColumnWorkspaceArticle.WorkspaceID): workspaceID},
+	}
+	if afterID != uuid.Nil {
+		where = append(where, sq.Gt{fmt.Sprintf("%s.%s", repoTableArticle, repoColumnArticle.ID): afterID.String()})
+	}
+
+	query, args, err := r.selectWorkspaceArticles(userID).
+		Where(where).
+		OrderBy(fmt.Sprintf("%s.%s", repoTableArticle, repoColumnArticle.ID)).
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for workspace articles"))
+	}
+
+	var rows []repoWorkspaceArticle
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to select workspace articles")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select workspace articles"))
+	}
+
+	articles := make([]*article.WorkspaceArticle, 0, len(rows))
+	for _, row := range rows {
+		articles = append(articles, row.toDomain())
+	}
+
+	return articles, nil
+}
+
+// selectWorkspaceArticles selects workspace articles with the average and count of their ratings,
+// and the rating of the user
+func (r *PostgresRepository) selectWorkspaceArticles(userID uuid.UUID) sq.SelectBuilder {
+	rating := func(column string) string {
+		return fmt.Sprintf("%s.%s", repoTableWorkspaceArticleRating, column)
+	}
+
+	return r.pgsq.Select(repoColumnArticle.columns(), repoColumnWorkspaceArticle.columns()).
+		Column(fmt.Sprintf("COALESCE(AVG(%s), 0) AS average_rating", rating(repoColumnWorkspaceArticleRating.Rate))).
+		Column(fmt.Sprintf("COUNT(%s) AS rating_count", rating(repoColumnWorkspaceArticleRating.Rate))).
+		Column(sq.Expr(fmt.Sprintf("COALESCE(MAX(CASE WHEN %s = ? THEN %s END), 0) AS rate",
+			rating(repoColumnWorkspaceArticleRating.UserID), rating(repoColumnWorkspaceArticleRating.Rate)), userID)).
+		From(repoTableArticle).
+		Join(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableWorkspaceArticle,
+			repoTableWorkspaceArticle, repoColumnWorkspaceArticle.ArticleID,
+			repoTableArticle, repoColumnArticle.ID)).
+		LeftJoin(fmt.Sprintf("%s ON %s = %s.%s AND %s = %s.%s",
+			repoTableWorkspaceArticleRating,
+			rating(repoColumnWorkspaceArticleRating.WorkspaceID), repoTableWorkspaceArticle, repoColumnWorkspaceArticle.WorkspaceID,
+			rating(repoColumnWorkspaceArticleRating.ArticleID), repoTableWorkspaceArticle, repoColumnWorkspaceArticle.ArticleID)).
+		GroupBy(
+			fmt.Sprintf("%s.%s", repoTableArticle, repoColumnArticle.ID),
+			fmt.Sprintf("%s.%s", repoTableWorkspaceArticle, repoColumnWorkspaceArticle.ID),
+		)
+}
+
+// UpsertWorkspaceArticleRating sets the rating a member gives an article of the workspace.
+func (r *PostgresRepository) UpsertWorkspaceArticleRating(ctx context.Context, workspaceID uuid.UUID, articleID uuid.UUID, userID uuid.UUID, rate int16) common.Error {
+	query, args, err := r.pgsq.Insert(repoTableWorkspaceArticleRating).
+		SetMap(map[string]interface{}{
+			repoColumnWorkspaceArticleRating.WorkspaceID: workspaceID,
+			repoColumnWorkspaceArticleRating.ArticleID:   articleID,
+			repoColumnWorkspaceArticleRating.UserID:      userID,
+			repoColumnWorkspaceArticleRating.Rate:        rate,
+		}).
+		Suffix(fmt.Sprintf("ON CONFLICT (%s, %s, %s) DO UPDATE SET %s = EXCLUDED.%s, %s = CURRENT_TIMESTAMP",
+			repoColumnWorkspaceArticleRating.WorkspaceID, repoColumnWorkspaceArticleRating.ArticleID, repoColumnWorkspaceArticleRating.UserID,
+			repoColumnWorkspaceArticleRating.Rate, repoColumnWorkspaceArticleRating.Rate,
+			repoColumnWorkspaceArticleRating.RatedAt)).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for workspace article rating"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to upsert workspace article rating")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to upsert workspace article rating"))
+	}
+
+	return nil
+}
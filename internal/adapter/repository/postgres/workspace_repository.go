@@ -0,0 +1,759 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/workspace"
+)
+
+// --- workspaces table ---
+
+type repoWorkspace struct {
+	ID        uuid.UUID `db:"id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (w *repoWorkspace) toDomain() *workspace.Workspace {
+	return &workspace.Workspace{
+		ID:        w.ID,
+		Name:      w.Name,
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+const repoTableWorkspace = "workspaces"
+
+type repoColumnPatternWorkspace struct {
+	ID        string
+	Name      string
+	CreatedAt string
+}
+
+var repoColumnWorkspace = repoColumnPatternWorkspace{
+	ID:        "id",
+	Name:      "name",
+	CreatedAt: "created_at",
+}
+
+func (c repoColumnPatternWorkspace) columns() string {
+	col := []string{
+		c.ID,
+		c.Name,
+		c.CreatedAt,
+	}
+	for i, v := range col {
+		col[i] = fmt.Sprintf("%s.%s", repoTableWorkspace, v)
+	}
+	return strings.Join(col, ", ")
+}
+
+// --- workspace_members table ---
+
+type repoWorkspaceMember struct {
+	WorkspaceID uuid.UUID `db:"workspace_id"`
+	UserID      uuid.UUID `db:"user_id"`
+	Username    string    `db:"username"`
+	Email       string    `db:"email"`
+	Role        string    `db:"role"`
+	JoinedAt    time.Time `db:"joined_at"`
+}
+
+func (m *repoWorkspaceMember) toDomain() *workspace.Member {
+	return &workspace.Member{
+		WorkspaceID: m.WorkspaceID,
+		UserID:      m.UserID,
+		Username:    m.Username,
+		Email:       m.Email,
+		Role:        m.Role,
+		JoinedAt:    m.JoinedAt,
+	}
+}
+
+const repoTableWorkspaceMember = "workspace_members"
+
+type repoColumnPatternWorkspaceMember struct {
+	WorkspaceID string
+	UserID      string
+	Role        string
+	JoinedAt    string
+}
+
+var repoColumnWorkspaceMember = repoColumnPatternWorkspaceMember{
+	WorkspaceID: "workspace_id",
+	UserID:      "user_id",
+	Role:        "role",
+	JoinedAt:    "joined_at",
+}
+
+// columns selects the members together with the username and email of their user
+func (c repoColumnPatternWorkspaceMember) columns() string {
+	col := []string{
+		c.WorkspaceID,
+		c.UserID,
+		c.Role,
+		c.JoinedAt,
+	}
+	for i, v := range col {
+		col[i] = fmt.Sprintf("%s.%s", repoTableWorkspaceMember, v)
+	}
+	col = append(col,
+		fmt.Sprintf("%s.%s", repoTableUser, repoColumnUser.Username),
+		fmt.Sprintf("%s.%s", repoTableUser, repoColumnUser.Email),
+	)
+	return strings.Join(col, ", ")
+}
+
+// --- workspace_invitations table ---
+
+type repoWorkspaceInvitation struct {
+	ID          int64        `db:"id"`
+	WorkspaceID uuid.UUID    `db:"workspace_id"`
+	Email       string       `db:"email"`
+	Role        string       `db:"role"`
+	TokenHash   string       `db:"token_hash"`
+	InvitedBy   uuid.UUID    `db:"invited_by"`
+	ExpiresAt   time.Time    `db:"expires_at"`
+	AcceptedAt  sql.NullTime `db:"accepted_at"`
+	CreatedAt   time.Time    `db:"created_at"`
+}
+
+func (i *repoWorkspaceInvitation) toDomain() *workspace.Invitation {
+	var acceptedAt *time.Time
+	if i.AcceptedAt.Valid {
+		acceptedAt = &i.AcceptedAt.Time
+	}
+
+	return &workspace.Invitation{
+		ID:          i.ID,
+		WorkspaceID: i.WorkspaceID,
+		Email:       i.Email,
+		Role:        i.Role,
+		TokenHash:   i.TokenHash,
+		InvitedBy:   i.InvitedBy,
+		ExpiresAt:   i.ExpiresAt,
+		AcceptedAt:  acceptedAt,
+		CreatedAt:   i.CreatedAt,
+	}
+}
+
+const repoTableWorkspaceInvitation = "workspace_invitations"
+
+type repoColumnPatternWorkspaceInvitation struct {
+	ID          string
+	WorkspaceID string
+	Email       string
+	Role        string
+	TokenHash   string
+	InvitedBy   string
+	ExpiresAt   string
+	AcceptedAt  string
+	CreatedAt   string
+}
+
+var repoColumnWorkspaceInvitation = repoColumnPatternWorkspaceInvitation{
+	ID:          "id",
+	WorkspaceID: "workspace_id",
+	Email:       "email",
+	Role:        "role",
+	TokenHash:   "token_hash",
+	InvitedBy:   "invited_by",
+	ExpiresAt:   "expires_at",
+	AcceptedAt:  "accepted_at",
+	CreatedAt:   "created_at",
+}
+
+func (c repoColumnPatternWorkspaceInvitation) columns() string {
+	return strings.Join([]string{
+		c.ID,
+		c.WorkspaceID,
+		c.Email,
+		c.Role,
+		c.TokenHash,
+		c.InvitedBy,
+		c.ExpiresAt,
+		c.AcceptedAt,
+		c.CreatedAt,
+	}, ", ")
+}
+
+// --- repository methods ---
+
+// CreateWorkspace creates a workspace with its creator as the owner, in one transaction.
+func (r *PostgresRepository) CreateWorkspace(ctx context.Context, ws *workspace.Workspace, ownerID uuid.UUID) (*workspace.Workspace, common.Error) {
+	tx, cerr := r.beginTx()
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	created, cerr := r.createWorkspace(ctx, tx, ws)
+	if cerr == nil {
+		cerr = r.addWorkspaceMember(ctx, tx, created.ID, ownerID, workspace.RoleOwner)
+	}
+	if cerr = r.finishTx(cerr, tx); cerr != nil {
+		return nil, cerr
+	}
+
+	return created, nil
+}
+
+func (r *PostgresRepository) createWorkspace(ctx context.Context, db sqlContextGetter, ws *workspace.Workspace) (*workspace.Workspace, common.Error) {
+	query, args, err := r.pgsq.Insert(repoTableWorkspace).
+		SetMap(map[string]interface{}{
+			repoColumnWorkspace.Name: ws.Name,
+		}).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnWorkspace.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for workspace"))
+	}
+
+	var row repoWorkspace
+	if err = db.GetContext(ctx, &row, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert workspace")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert workspace"))
+	}
+
+	return row.toDomain(), nil
+}
+
+func (r *PostgresRepository) GetWorkspace(ctx context.Context, workspaceID uuid.UUID) (*workspace.Workspace, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnWorkspace.columns()).
+		From(repoTableWorkspace).
+		Where(sq.Eq{repoColumnWorkspace.ID: workspaceID}).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for workspace"))
+	}
+
+	var row repoWorkspace
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("workspace is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select workspace"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// ListUserWorkspaces returns the workspaces the user is a member of, oldest first.
+func (r *PostgresRepository) ListUserWorkspaces(ctx context.Context, userID uuid.UUID) ([]*workspace.Membership, common.Error) {
+	query, args, err := r.pgsq.Select(
+		repoColumnWorkspace.columns(),
+		fmt.Sprintf("%s.%s", repoTableWorkspaceMember, repoColumnWorkspaceMember.Role),
+	).
+		From(repoTableWorkspace).
+		Join(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableWorkspaceMember,
+			repoTableWorkspaceMember, repoColumnWorkspaceMember.WorkspaceID,
+			repoTableWorkspace, repoColumnWorkspace.ID)).
+		Where(sq.Eq{fmt.Sprintf("%s.%s", repoTableWorkspaceMember, repoColumnWorkspaceMember.UserID): userID}).
+		OrderBy(fmt.Sprintf("%s.%s", repoTableWorkspace, repoColumnWorkspace.CreatedAt)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for user workspaces"))
+	}
+
+	type repoMembership struct {
+		repoWorkspace
+		Role string `db:"role"`
+	}
+
+	var rows []repoMembership
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to select user workspaces")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select user workspaces"))
+	}
+
+	memberships := make([]*workspace.Membership, 0, len(rows))
+	for _, row := range rows {
+		memberships = append(memberships, &workspace.Membership{
+			Workspace: *row.repoWorkspace.toDomain(),
+			Role:      row.Role,
+		})
+	}
+
+	return memberships, nil
+}
+
+func (r *PostgresRepository) UpdateWorkspaceName(ctx context.Context, workspaceID uuid.UUID, name string) common.Error {
+	query, args, err := r.pgsq.Update(repoTableWorkspace).
+		Set(repoColumnWorkspace.Name, name).
+		Where(sq.Eq{repoColumnWorkspace.ID: workspaceID}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for workspace"))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to update workspace"))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+	if rowsAffected == 0 {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("workspace is not found"), common.WithMsg("workspace is not found"))
+	}
+
+	return nil
+}
+
+// DeleteWorkspace deletes the workspace together with its members, invitations and articles.
+func (r *PostgresRepository) DeleteWorkspace(ctx context.Context, workspaceID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableWorkspace).
+		Where(sq.Eq{repoColumnWorkspace.ID: workspaceID}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for workspace"))
+	}
+
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to delete workspace"))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) GetWorkspaceMember(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID) (*workspace.Member, common.Error) {
+	query, args, err := r.selectWorkspaceMembers().
+		Where(sq.Eq{
+			fmt.Sprintf("%s.%s", repoTableWorkspaceMember, repoColumnWorkspaceMember.WorkspaceID): workspaceID,
+			fmt.Sprintf("%s.%s", repoTableWorkspaceMember, repoColumnWorkspaceMember.UserID):      userID,
+		}).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for workspace member"))
+	}
+
+	var row repoWorkspaceMember
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("workspace member is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select workspace member"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// ListWorkspaceMembers returns the members of the workspace in the order they joined.
+func (r *PostgresRepository) ListWorkspaceMembers(ctx context.Context, workspaceID uuid.UUID) ([]*workspace.Member, common.Error) {
+	query, args, err := r.selectWorkspaceMembers().
+		Where(sq.Eq{fmt.Sprintf("%s.%s", repoTableWorkspaceMember, repoColumnWorkspaceMember.WorkspaceID): workspaceID}).
+		OrderBy(fmt.Sprintf("%s.%s", repoTableWorkspaceMember, repoColumnWorkspaceMember.JoinedAt)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for workspace members"))
+	}
+
+	var rows []repoWorkspaceMember
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to select workspace members")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select workspace members"))
+	}
+
+	members := make([]*workspace.Member, 0, len(rows))
+	for _, row := range rows {
+		members = append(members, row.toDomain())
+	}
+
+	return members, nil
+}
+
+func (r *PostgresRepository) selectWorkspaceMembers() sq.SelectBuilder {
+	return r.pgsq.Select(repoColumnWorkspaceMember.columns()).
+		From(repoTableWorkspaceMember).
+		Join(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableUser,
+			repoTableUser, repoColumnUser.ID,
+			repoTableWorkspaceMember, repoColumnWorkspaceMember.UserID))
+}
+
+func (r *PostgresRepository) addWorkspaceMember(ctx context.Context, db sqlContextGetter, workspaceID uuid.UUID, userID uuid.UUID, role string) common.Error {
+	query, args, err := r.pgsq.Insert(repoTableWorkspaceMember).
+		SetMap(map[string]interface{}{
+			repoColumnWorkspaceMember.WorkspaceID: workspaceID,
+			repoColumnWorkspaceMember.UserID:      userID,
+			repoColumnWorkspaceMember.Role:        role,
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for workspace member"))
+	}
+
+	if _, err = db.ExecContext(ctx, query, args...); err != nil {
+		if _, ok := uniqueViolation(err); ok {
+			msg := "the user is already a member of the workspace"
+			return common.NewError(common.ErrorCodeResourceConflict, err, common.WithMsg(msg))
+		}
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert workspace member")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert workspace member"))
+	}
+
+	return nil
+}
+
+// UpdateWorkspaceMemberRole changes the role of a member.
+// It fails with RESOURCE_CONFLICT if that would leave the workspace without an owner.
+func (r *PostgresRepository) UpdateWorkspaceMemberRole(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, role string) common.Error {
+	update := r.pgsq.Update(repoTableWorkspaceMember).
+		Set(repoColumnWorkspaceMember.Role, role)
+	if role != workspace.RoleOwner {
+		update = update.Where(r.keepsWorkspaceOwner(workspaceID, userID))
+	}
+	query, args, err := update.
+		Where(sq.Eq{
+			repoColumnWorkspaceMember.WorkspaceID: workspaceID,
+			repoColumnWorkspaceMember.UserID:      userID,
+		}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for workspace member"))
+	}
+
+	return r.changeWorkspaceMembers(ctx, workspaceID, query, args, "failed to update workspace member")
+}
+
+// DeleteWorkspaceMember removes a member from the workspace.
+// It fails with RESOURCE_CONFLICT if the member is the last owner of the workspace.
+func (r *PostgresRepository) DeleteWorkspaceMember(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableWorkspaceMember).
+		Where(sq.Eq{
+			repoColumnWorkspaceMember.WorkspaceID: workspaceID,
+			repoColumnWorkspaceMember.UserID:      userID,
+		}).
+		Where(r.keepsWorkspaceOwner(workspaceID, userID)).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for workspace member"))
+	}
+
+	return r.changeWorkspaceMembers(ctx, workspaceID, query, args, "failed to delete workspace member")
+}
+
+// keepsWorkspaceOwner matches a member who is no owner, or whose workspace has another owner
+func (r *PostgresRepository) keepsWorkspaceOwner(workspaceID uuid.UUID, userID uuid.UUID) sq.Sqlizer {
+	otherOwners := r.pgsq.Select("1").
+		From(repoTableWorkspaceMember).
+		Where(sq.And{
+			sq.Eq{repoColumnWorkspaceMember.WorkspaceID: workspaceID},
+			sq.NotEq{repoColumnWorkspaceMember.UserID: userID},
+			sq.Eq{repoColumnWorkspaceMember.Role: workspace.RoleOwner},
+		}).
+		Prefix("EXISTS (").
+		Suffix(")")
+
+	return sq.Or{
+		sq.NotEq{repoColumnWorkspaceMember.Role: workspace.RoleOwner},
+		otherOwners,
+	}
+}
+
+// LeaveUserWorkspaces removes the user from every workspace, and deletes the workspaces nobody else is a member of.
+// It fails with RESOURCE_CONFLICT, changing nothing, while the user is the last owner of a workspace with other members.
+func (r *PostgresRepository) LeaveUserWorkspaces(ctx context.Context, userID uuid.UUID) common.Error {
+	tx, cerr := r.beginTx()
+	if cerr != nil {
+		return cerr
+	}
+
+	cerr = r.leaveUserWorkspaces(ctx, tx, userID)
+	return r.finishTx(cerr, tx)
+}
+
+func (r *PostgresRepository) leaveUserWorkspaces(ctx context.Context, db sqlContextGetter, userID uuid.UUID) common.Error {
+	// Lock the workspaces like changeWorkspaceMembers does, so that no other owner leaves meanwhile
+	query, args, err := r.pgsq.Select(fmt.Sprintf("%s.%s", repoTableWorkspace, repoColumnWorkspace.ID)).
+		From(repoTableWorkspace).
+		Join(fmt.Sprintf("%s ON %s.%s = %s.%s",
+			repoTableWorkspaceMember,
+			repoTableWorkspaceMember, repoColumnWorkspaceMember.WorkspaceID,
+			repoTableWorkspace, repoColumnWorkspace.ID)).
+		Where(sq.Eq{fmt.Sprintf("%s.%s", repoTableWorkspaceMember, repoColumnWorkspaceMember.UserID): userID}).
+		OrderBy(fmt.Sprintf("%s.%s", repoTableWorkspace, repoColumnWorkspace.ID)).
+		Suffix(fmt.Sprintf("FOR UPDATE OF %s", repoTableWorkspace)).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build lock query for user workspaces"))
+	}
+	var locked []uuid.UUID
+	if err = db.SelectContext(ctx, &locked, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to lock user workspaces")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to lock user workspaces"))
+	}
+	if len(locked) == 0 {
+		return nil
+	}
+
+	memberWorkspace := fmt.Sprintf("%s.%s", repoTableWorkspaceMember, repoColumnWorkspaceMember.WorkspaceID)
+	query, args, err = r.pgsq.Select(repoColumnWorkspaceMember.WorkspaceID).
+		From(repoTableWorkspaceMember).
+		Where(sq.Eq{
+			repoColumnWorkspaceMember.UserID: userID,
+			repoColumnWorkspaceMember.Role:   workspace.RoleOwner,
+		}).
+		Where(r.otherWorkspaceMembers("NOT EXISTS", memberWorkspace, userID, true)).
+		Where(r.otherWorkspaceMembers("EXISTS", memberWorkspace, userID, false)).
+		OrderBy(repoColumnWorkspaceMember.WorkspaceID).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for owned workspaces"))
+	}
+	var owned []uuid.UUID
+	if err = db.SelectContext(ctx, &owned, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to select owned workspaces")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select owned workspaces"))
+	}
+	if len(owned) > 0 {
+		msg := "the user is the last owner of workspaces with other members"
+		return common.NewError(common.ErrorCodeResourceConflict, errors.New(msg),
+			common.WithMsg(msg),
+			common.WithDetail(map[string]interface{}{"workspace_ids": owned}),
+		)
+	}
+
+	query, args, err = r.pgsq.Delete(repoTableWorkspace).
+		Where(sq.Eq{repoColumnWorkspace.ID: locked}).
+		Where(r.otherWorkspaceMembers("NOT EXISTS", fmt.Sprintf("%s.%s", repoTableWorkspace, repoColumnWorkspace.ID), userID, false)).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for user workspaces"))
+	}
+	if _, err = db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to delete user workspaces")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to delete user workspaces"))
+	}
+
+	query, args, err = r.pgsq.Delete(repoTableWorkspaceMember).
+		Where(sq.Eq{repoColumnWorkspaceMember.UserID: userID}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for workspace members"))
+	}
+	if _, err = db.ExecContext(ctx, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to delete workspace members")
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to delete workspace members"))
+	}
+
+	return nil
+}
+
+// otherWorkspaceMembers matches with EXISTS or NOT EXISTS the workspaces, identified by the outer column, that have
+// members other than the user, or with owners set, other owners
+func (r *PostgresRepository) otherWorkspaceMembers(exists string, workspaceColumn string, userID uuid.UUID, owners bool) sq.Sqlizer {
+	query := fmt.Sprintf("%s (SELECT 1 FROM %s AS others WHERE others.%s = %s AND others.%s <> ?",
+		exists, repoTableWorkspaceMember, repoColumnWorkspaceMember.WorkspaceID, workspaceColumn, repoColumnWorkspaceMember.UserID)
+	if owners {
+		return sq.Expr(fmt.Sprintf("%s AND others.%s = ?)", query, repoColumnWorkspaceMember.Role), userID, workspace.RoleOwner)
+	}
+	return sq.Expr(query+")", userID)
+}
+
+// changeWorkspaceMembers runs a statement changing one member while the workspace is locked,
+// so that two owners demoting each other at the same time cannot leave the workspace without an owner
+func (r *PostgresRepository) changeWorkspaceMembers(ctx context.Context, workspaceID uuid.UUID, query string, args []interface{}, failure string) common.Error {
+	tx, cerr := r.beginTx()
+	if cerr != nil {
+		return cerr
+	}
+
+	cerr = r.lockWorkspace(ctx, tx, workspaceID)
+	if cerr == nil {
+		cerr = r.execKeepingWorkspaceOwner(ctx, tx, query, args, failure)
+	}
+	return r.finishTx(cerr, tx)
+}
+
+func (r *PostgresRepository) execKeepingWorkspaceOwner(ctx context.Context, db sqlContextGetter, query string, args []interface{}, failure string) common.Error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg(failure)
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, failure))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+	if rowsAffected == 0 {
+		msg := "a workspace needs at least one owner"
+		return common.NewError(common.ErrorCodeResourceConflict, errors.New(msg), common.WithMsg(msg))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) lockWorkspace(ctx context.Context, db sqlContextGetter, workspaceID uuid.UUID) common.Error {
+	query, args, err := r.pgsq.Select(repoColumnWorkspace.ID).
+		From(repoTableWorkspace).
+		Where(sq.Eq{repoColumnWorkspace.ID: workspaceID}).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build lock query for workspace"))
+	}
+
+	var id uuid.UUID
+	if err = db.GetContext(ctx, &id, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("workspace is not found"))
+		}
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to lock workspace"))
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) CreateWorkspaceInvitation(ctx context.Context, invitation *workspace.Invitation) (*workspace.Invitation, common.Error) {
+	query, args, err := r.pgsq.Insert(repoTableWorkspaceInvitation).
+		SetMap(map[string]interface{}{
+			repoColumnWorkspaceInvitation.WorkspaceID: invitation.WorkspaceID,
+			repoColumnWorkspaceInvitation.Email:       invitation.Email,
+			repoColumnWorkspaceInvitation.Role:        invitation.Role,
+			repoColumnWorkspaceInvitation.TokenHash:   invitation.TokenHash,
+			repoColumnWorkspaceInvitation.InvitedBy:   invitation.InvitedBy,
+			repoColumnWorkspaceInvitation.ExpiresAt:   invitation.ExpiresAt,
+		}).
+		Suffix(fmt.Sprintf("RETURNING %s", repoColumnWorkspaceInvitation.columns())).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build insert query for workspace invitation"))
+	}
+
+	var row repoWorkspaceInvitation
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		r.logger(ctx).Error().Str("query", query).Err(err).Msg("failed to insert workspace invitation")
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to insert workspace invitation"))
+	}
+
+	return row.toDomain(), nil
+}
+
+// ListWorkspaceInvitations returns the invitations of the workspace that were not accepted yet, newest first.
+func (r *PostgresRepository) ListWorkspaceInvitations(ctx context.Context, workspaceID uuid.UUID) ([]*workspace.Invitation, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnWorkspaceInvitation.columns()).
+		From(repoTableWorkspaceInvitation).
+		Where(sq.And{
+			sq.Eq{repoColumnWorkspaceInvitation.WorkspaceID: workspaceID},
+			sq.Eq{repoColumnWorkspaceInvitation.AcceptedAt: nil},
+		}).
+		OrderBy(fmt.Sprintf("%s DESC", repoColumnWorkspaceInvitation.CreatedAt)).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for workspace invitations"))
+	}
+
+	var rows []repoWorkspaceInvitation
+	if err = r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select workspace invitations"))
+	}
+
+	invitations := make([]*workspace.Invitation, 0, len(rows))
+	for _, row := range rows {
+		invitations = append(invitations, row.toDomain())
+	}
+
+	return invitations, nil
+}
+
+func (r *PostgresRepository) GetWorkspaceInvitationByHash(ctx context.Context, tokenHash string) (*workspace.Invitation, common.Error) {
+	query, args, err := r.pgsq.Select(repoColumnWorkspaceInvitation.columns()).
+		From(repoTableWorkspaceInvitation).
+		Where(sq.Eq{repoColumnWorkspaceInvitation.TokenHash: tokenHash}).
+		ToSql()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build select query for workspace invitation"))
+	}
+
+	var row repoWorkspaceInvitation
+	if err = r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, common.NewError(common.ErrorCodeResourceNotFound, err, common.WithMsg("workspace invitation is not found"))
+		}
+		return nil, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to select workspace invitation"))
+	}
+
+	return row.toDomain(), nil
+}
+
+func (r *PostgresRepository) DeleteWorkspaceInvitation(ctx context.Context, workspaceID uuid.UUID, invitationID int64) common.Error {
+	query, args, err := r.pgsq.Delete(repoTableWorkspaceInvitation).
+		Where(sq.Eq{
+			repoColumnWorkspaceInvitation.ID:          invitationID,
+			repoColumnWorkspaceInvitation.WorkspaceID: workspaceID,
+		}).
+		Where(sq.Eq{repoColumnWorkspaceInvitation.AcceptedAt: nil}).
+		ToSql()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build delete query for workspace invitation"))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to delete workspace invitation"))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+	if rowsAffected == 0 {
+		msg := "workspace invitation is not found"
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New(msg), common.WithMsg(msg))
+	}
+
+	return nil
+}
+
+// AcceptWorkspaceInvitation marks the invitation as accepted and adds the user to its workspace, in one transaction.
+// It returns false if the invitation was accepted concurrently or before.
+func (r *PostgresRepository) AcceptWorkspaceInvitation(ctx context.Context, invitation *workspace.Invitation, userID uuid.UUID) (bool, common.Error) {
+	tx, cerr := r.beginTx()
+	if cerr != nil {
+		return false, cerr
+	}
+
+	accepted, cerr := r.useWorkspaceInvitation(ctx, tx, invitation.ID)
+	if cerr == nil && accepted {
+		cerr = r.addWorkspaceMember(ctx, tx, invitation.WorkspaceID, userID, invitation.Role)
+	}
+	if cerr = r.finishTx(cerr, tx); cerr != nil {
+		return false, cerr
+	}
+
+	return accepted, nil
+}
+
+func (r *PostgresRepository) useWorkspaceInvitation(ctx context.Context, db sqlContextGetter, invitationID int64) (bool, common.Error) {
+	query, args, err := r.pgsq.Update(repoTableWorkspaceInvitation).
+		Set(repoColumnWorkspaceInvitation.AcceptedAt, time.Now()).
+		Where(sq.And{
+			sq.Eq{repoColumnWorkspaceInvitation.ID: invitationID},
+			sq.Eq{repoColumnWorkspaceInvitation.AcceptedAt: nil},
+		}).
+		ToSql()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeInternalProcess, errors.Wrap(err, "failed to build update query for workspace invitation"))
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to accept workspace invitation"))
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeRemoteProcess, errors.Wrap(err, "failed to get rows affected"))
+	}
+
+	return rowsAffected == 1, nil
+}
@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+	"github.com/sappy5678/DeeliAi/internal/domain/workspace"
+	"github.com/sappy5678/DeeliAi/testdata"
+)
+
+// createWorkspaceMembers creates a workspace owned by the first user, which the other users join with their role
+func createWorkspaceMembers(t *testing.T, repo *PostgresRepository, owner uuid.UUID, members map[uuid.UUID]string) uuid.UUID {
+	t.Helper()
+	ctx := context.Background()
+	ws, err := repo.CreateWorkspace(ctx, &workspace.Workspace{Name: "Reading club"}, owner)
+	require.NoError(t, err)
+	for userID, role := range members {
+		require.NoError(t, repo.addWorkspaceMember(ctx, repo.db, ws.ID, userID, role))
+	}
+	return ws.ID
+}
+
+func createTestUser(t *testing.T, repo *PostgresRepository, username string) uuid.UUID {
+	t.Helper()
+	created, err := repo.CreateUser(context.Background(), &user.User{Email: username + "@example.com", Username: username, PasswordHash: "hash"})
+	require.NoError(t, err)
+	return created.ID
+}
+
+// workspaceRoles returns the role of every member of the workspace
+func workspaceRoles(t *testing.T, repo *PostgresRepository, workspaceID uuid.UUID) map[uuid.UUID]string {
+	t.Helper()
+	members, err := repo.ListWorkspaceMembers(context.Background(), workspaceID)
+	require.NoError(t, err)
+	roles := map[uuid.UUID]string{}
+	for _, m := range members {
+		roles[m.UserID] = m.Role
+	}
+	return roles
+}
+
+func TestPostgresRepository_WorkspaceKeepsOwner(t *testing.T) {
+	db := getTestPostgresDB()
+	repo := initRepository(t, db, testdata.Path(testdata.TestDataUser))
+	ctx := context.Background()
+
+	alice, bob := createTestUser(t, repo, "ws-alice"), createTestUser(t, repo, "ws-bob")
+	workspaceID := createWorkspaceMembers(t, repo, alice, map[uuid.UUID]string{bob: workspace.RoleEditor})
+
+	// The only owner can neither step down nor leave
+	err := repo.UpdateWorkspaceMemberRole(ctx, workspaceID, alice, workspace.RoleEditor)
+	assert.True(t, common.IsErrorCode(err, common.ErrorCodeResourceConflict))
+	err = repo.DeleteWorkspaceMember(ctx, workspaceID, alice)
+	assert.True(t, common.IsErrorCode(err, common.ErrorCodeResourceConflict))
+
+	// Members who are no owner leave freely, and so do owners once there is another one
+	require.NoError(t, repo.UpdateWorkspaceMemberRole(ctx, workspaceID, bob, workspace.RoleOwner))
+	require.NoError(t, repo.UpdateWorkspaceMemberRole(ctx, workspaceID, alice, workspace.RoleViewer))
+	err = repo.DeleteWorkspaceMember(ctx, workspaceID, bob)
+	assert.True(t, common.IsErrorCode(err, common.ErrorCodeResourceConflict))
+	require.NoError(t, repo.DeleteWorkspaceMember(ctx, workspaceID, alice))
+	assert.Equal(t, map[uuid.UUID]string{bob: workspace.RoleOwner}, workspaceRoles(t, repo, workspaceID))
+}
+
+func TestPostgresRepository_WorkspaceOwnersStepDownConcurrently(t *testing.T) {
+	db := getTestPostgresDB()
+	repo := initRepository(t, db, testdata.Path(testdata.TestDataUser))
+	ctx := context.Background()
+
+	alice, bob := createTestUser(t, repo, "ws-alice"), createTestUser(t, repo, "ws-bob")
+	workspaceID := createWorkspaceMembers(t, repo, alice, map[uuid.UUID]string{bob: workspace.RoleOwner})
+
+	// Without the workspace lock both would see the other owner and step down
+	var wg sync.WaitGroup
+	errs := make([]common.Error, 2)
+	for i, userID := range []uuid.UUID{alice, bob} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = repo.UpdateWorkspaceMemberRole(ctx, workspaceID, userID, workspace.RoleEditor)
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			assert.True(t, common.IsErrorCode(err, common.ErrorCodeResourceConflict))
+			failed++
+		}
+	}
+	assert.Equal(t, 1, failed)
+
+	owners := 0
+	for _, role := range workspaceRoles(t, repo, workspaceID) {
+		if role == workspace.RoleOwner {
+			owners++
+		}
+	}
+	assert.Equal(t, 1, owners)
+}
+
+func TestPostgresRepository_LeaveUserWorkspaces(t *testing.T) {
+	db := getTestPostgresDB()
+	repo := initRepository(t, db, testdata.Path(testdata.TestDataUser))
+	ctx := context.Background()
+
+	alice, bob, carol := createTestUser(t, repo, "ws-alice"), createTestUser(t, repo, "ws-bob"), createTestUser(t, repo, "ws-carol")
+	solo := createWorkspaceMembers(t, repo, alice, nil)
+	shared := createWorkspaceMembers(t, repo, alice, map[uuid.UUID]string{bob: workspace.RoleEditor})
+	joined := createWorkspaceMembers(t, repo, carol, map[uuid.UUID]string{alice: workspace.RoleViewer})
+
+	// The last owner of a workspace with other members changes nothing
+	err := repo.LeaveUserWorkspaces(ctx, alice)
+	require.True(t, common.IsErrorCode(err, common.ErrorCodeResourceConflict))
+	var domainError common.DomainError
+	require.True(t, errors.As(err, &domainError))
+	assert.Equal(t, []uuid.UUID{shared}, domainError.Detail()["workspace_ids"])
+	_, err = repo.GetWorkspace(ctx, solo)
+	require.NoError(t, err)
+	assert.Len(t, workspaceRoles(t, repo, shared), 2)
+	assert.Len(t, workspaceRoles(t, repo, joined), 2)
+
+	// With another owner, the user leaves every workspace, and the one nobody else is in goes
+	require.NoError(t, repo.UpdateWorkspaceMemberRole(ctx, shared, bob, workspace.RoleOwner))
+	require.NoError(t, repo.LeaveUserWorkspaces(ctx, alice))
+	_, err = repo.GetWorkspace(ctx, solo)
+	assert.True(t, common.IsErrorCode(err, common.ErrorCodeResourceNotFound))
+	assert.Equal(t, map[uuid.UUID]string{bob: workspace.RoleOwner}, workspaceRoles(t, repo, shared))
+	assert.Equal(t, map[uuid.UUID]string{carol: workspace.RoleOwner}, workspaceRoles(t, repo, joined))
+	memberships, err := repo.ListUserWorkspaces(ctx, alice)
+	require.NoError(t, err)
+	assert.Empty(t, memberships)
+
+	// Users without workspaces have nothing to leave
+	require.NoError(t, repo.LeaveUserWorkspaces(ctx, alice))
+}
+
+func TestPostgresRepository_LeaveUserWorkspacesConcurrently(t *testing.T) {
+	db := getTestPostgresDB()
+	repo := initRepository(t, db, testdata.Path(testdata.TestDataUser))
+	ctx := context.Background()
+
+	alice, bob, carol := createTestUser(t, repo, "ws-alice"), createTestUser(t, repo, "ws-bob"), createTestUser(t, repo, "ws-carol")
+	workspaceID := createWorkspaceMembers(t, repo, alice, map[uuid.UUID]string{bob: workspace.RoleOwner, carol: workspace.RoleViewer})
+
+	// One owner deleting their account while the other steps down leaves the workspace with an owner
+	var wg sync.WaitGroup
+	var leaveErr, stepDownErr common.Error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		leaveErr = repo.LeaveUserWorkspaces(ctx, alice)
+	}()
+	go func() {
+		defer wg.Done()
+		stepDownErr = repo.UpdateWorkspaceMemberRole(ctx, workspaceID, bob, workspace.RoleEditor)
+	}()
+	wg.Wait()
+
+	require.True(t, (leaveErr == nil) != (stepDownErr == nil), "exactly one of them succeeds")
+	roles := workspaceRoles(t, repo, workspaceID)
+	if leaveErr == nil {
+		assert.True(t, common.IsErrorCode(stepDownErr, common.ErrorCodeResourceConflict))
+		assert.Equal(t, map[uuid.UUID]string{bob: workspace.RoleOwner, carol: workspace.RoleViewer}, roles)
+	} else {
+		assert.True(t, common.IsErrorCode(leaveErr, common.ErrorCodeResourceConflict))
+		assert.Equal(t, workspace.RoleOwner, roles[alice])
+	}
+}
@@ -2,23 +2,45 @@ package app
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 
+	"github.com/sappy5678/DeeliAi/internal/adapter/embedder"
+	"github.com/sappy5678/DeeliAi/internal/adapter/mailer"
+	"github.com/sappy5678/DeeliAi/internal/adapter/oidc"
+	"github.com/sappy5678/DeeliAi/internal/adapter/repository/memory"
 	"github.com/sappy5678/DeeliAi/internal/adapter/repository/postgres"
 
 	"github.com/sappy5678/DeeliAi/internal/app/service/article"
+	"github.com/sappy5678/DeeliAi/internal/app/service/audit"
 	"github.com/sappy5678/DeeliAi/internal/app/service/user"
+	"github.com/sappy5678/DeeliAi/internal/app/service/workspace"
+)
+
+// Supported mail drivers
+const (
+	MailDriverSMTP = "smtp"
+	MailDriverFile = "file"
+)
+
+// Supported stores of failed login counters
+const (
+	LoginAttemptStorePostgres = "postgres"
+	LoginAttemptStoreMemory   = "memory"
 )
 
 type Application struct {
-	Params         ApplicationParams
-	ArticleService article.ArticleService
-	UserService    user.Service
+	Params           ApplicationParams
+	ArticleService   article.ArticleService
+	UserService      user.Service
+	WorkspaceService workspace.Service
+	AuditService     audit.Service
 }
 
 type ApplicationParams struct {
@@ -29,9 +51,48 @@ type ApplicationParams struct {
 	DatabaseDSN string
 
 	// Token parameter
-	TokenSigningKey     []byte
-	TokenExpiryDuration time.Duration
-	TokenIssuer         string
+	TokenSigningAlgorithm      string
+	TokenKeyRotationInterval   time.Duration
+	TokenExpiryDuration        time.Duration
+	RefreshTokenExpiryDuration time.Duration
+	TokenIssuer                string
+
+	// User account parameters
+	AppBaseURL                   string
+	PasswordResetTokenExpiry     time.Duration
+	EmailVerificationTokenExpiry time.Duration
+	RequireVerifiedEmail         bool
+
+	// Password parameters
+	PasswordHashAlgorithm string
+	PasswordMinLength     int
+
+	// Login throttling parameters
+	LoginAttemptStore       string
+	LoginMaxAccountFailures int
+	LoginMaxIPFailures      int
+	LoginLockoutDuration    time.Duration
+	LoginBaseDelay          time.Duration
+
+	// Two-factor authentication parameters
+	TwoFactorIssuer      string
+	LoginChallengeExpiry time.Duration
+
+	// OpenID Connect parameters, each provider as accepted by oidc.ParseConfig
+	OIDCProviders   []string
+	OIDCStateExpiry time.Duration
+
+	// Workspace parameters
+	WorkspaceInvitationExpiry time.Duration
+
+	// Mail parameters
+	MailDriver   string
+	MailFrom     string
+	MailFileDir  string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
 }
 
 func MustNewApplication(ctx context.Context, wg *sync.WaitGroup, params ApplicationParams) *Application {
@@ -51,14 +112,89 @@ func NewApplication(ctx context.Context, wg *sync.WaitGroup, params ApplicationP
 	pgRepo := postgres.NewPostgresRepository(ctx, db)
 
 	// service initialization
-	tokenService := user.NewTokenService(ctx, params.TokenSigningKey, params.TokenExpiryDuration, params.TokenIssuer)
+	keyManager, cerr := user.NewKeyManager(ctx, pgRepo, params.TokenSigningAlgorithm, params.TokenKeyRotationInterval, params.TokenExpiryDuration)
+	if cerr != nil {
+		return nil, cerr
+	}
+	tokenService := user.NewTokenService(ctx, pgRepo, pgRepo, keyManager, params.TokenExpiryDuration, params.RefreshTokenExpiryDuration, params.TokenIssuer)
+	loginGuard, cerr := user.NewLoginGuard(ctx, newLoginAttemptRepository(params, pgRepo), user.LoginPolicy{
+		MaxAccountFailures: params.LoginMaxAccountFailures,
+		MaxIPFailures:      params.LoginMaxIPFailures,
+		LockoutDuration:    params.LoginLockoutDuration,
+		BaseDelay:          params.LoginBaseDelay,
+	})
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	userConfig := user.Config{
+		AppBaseURL:                   params.AppBaseURL,
+		PasswordResetTokenExpiry:     params.PasswordResetTokenExpiry,
+		EmailVerificationTokenExpiry: params.EmailVerificationTokenExpiry,
+		TwoFactorIssuer:              params.TwoFactorIssuer,
+		LoginChallengeExpiry:         params.LoginChallengeExpiry,
+		OIDCStateExpiry:              params.OIDCStateExpiry,
+		PasswordHashAlgorithm:        params.PasswordHashAlgorithm,
+		PasswordMinLength:            params.PasswordMinLength,
+	}
+	oidcProviders, err := newOIDCProviders(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	appMailer := newMailer(ctx, params)
+	auditService, cerr := audit.NewAuditService(ctx, pgRepo)
+	if cerr != nil {
+		return nil, cerr
+	}
+	workspaceService := workspace.NewWorkspaceService(ctx, pgRepo, pgRepo, appMailer, workspace.Config{
+		AppBaseURL:       params.AppBaseURL,
+		InvitationExpiry: params.WorkspaceInvitationExpiry,
+	})
 
 	// Create application
 	app := &Application{
-		Params:         params,
-		ArticleService: article.NewArticleService(ctx, pgRepo),
-		UserService:    user.NewUserService(ctx, pgRepo, tokenService),
+		Params:           params,
+		ArticleService:   article.NewArticleService(ctx, pgRepo, workspaceService, auditService, embedder.NewHashedEmbedder(embedder.DefaultHashedDimensions)),
+		UserService:      user.NewUserService(ctx, pgRepo, pgRepo, pgRepo, pgRepo, pgRepo, pgRepo, tokenService, user.NewAPIKeyService(ctx, pgRepo), loginGuard, oidcProviders, appMailer, auditService, userConfig),
+		WorkspaceService: workspaceService,
+		AuditService:     auditService,
 	}
 
 	return app, nil
 }
+
+func newLoginAttemptRepository(params ApplicationParams, pgRepo *postgres.PostgresRepository) user.LoginAttemptRepository {
+	switch params.LoginAttemptStore {
+	case LoginAttemptStoreMemory:
+		return memory.NewLoginAttemptRepository()
+	default:
+		return pgRepo
+	}
+}
+
+// newOIDCProviders creates a client of every configured provider, keyed by the provider name
+func newOIDCProviders(ctx context.Context, params ApplicationParams) (map[string]oidc.Provider, error) {
+	providers := make(map[string]oidc.Provider, len(params.OIDCProviders))
+	for _, spec := range params.OIDCProviders {
+		config, err := oidc.ParseConfig(spec)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := providers[config.Name]; ok {
+			return nil, fmt.Errorf("oidc provider %q is configured twice", config.Name)
+		}
+		config.RedirectURL = fmt.Sprintf("%s/api/v1/user/oidc/%s/callback", strings.TrimSuffix(params.AppBaseURL, "/"), config.Name)
+		providers[config.Name] = oidc.NewClient(ctx, config)
+	}
+	return providers, nil
+}
+
+func newMailer(ctx context.Context, params ApplicationParams) mailer.Mailer {
+	switch params.MailDriver {
+	case MailDriverSMTP:
+		return mailer.NewSMTPMailer(ctx, params.SMTPHost, params.SMTPPort, params.SMTPUsername, params.SMTPPassword, params.MailFrom)
+	default:
+		return mailer.NewFileMailer(ctx, params.MailFileDir, params.MailFrom)
+	}
+}
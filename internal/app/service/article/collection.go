@@ -0,0 +1,119 @@
+package article
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+func (s *articleService) CreateCollection(ctx context.Context, userID uuid.UUID, name string) (*article.Collection, common.Error) {
+	name, err := normalizeCollectionName(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.articleRepo.CreateCollection(ctx, userID, name)
+}
+
+func (s *articleService) GetCollection(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID) (*article.Collection, common.Error) {
+	return s.articleRepo.GetCollection(ctx, userID, collectionID)
+}
+
+func (s *articleService) ListCollections(ctx context.Context, userID uuid.UUID) ([]*article.Collection, common.Error) {
+	return s.articleRepo.ListCollections(ctx, userID)
+}
+
+// ExportCollections returns every collection of the user with the IDs of its articles in order,
+// reading the articles of a collection exportBatchSize at a time.
+func (s *articleService) ExportCollections(ctx context.Context, userID uuid.UUID) ([]*article.ExportedCollection, common.Error) {
+	collections, err := s.articleRepo.ListCollections(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	exported := make([]*article.ExportedCollection, 0, len(collections))
+	for _, collection := range collections {
+		articleIDs := make([]uuid.UUID, 0, collection.ArticleCount)
+		afterID := uuid.Nil
+		for {
+			articles, err := s.articleRepo.ListCollectionArticles(ctx, userID, collection.ID, afterID, exportBatchSize)
+			if err != nil {
+				return nil, err
+			}
+			for _, art := range articles {
+				articleIDs = append(articleIDs, art.ID)
+			}
+			if len(articles) < exportBatchSize {
+				break
+			}
+			afterID = articles[len(articles)-1].ID
+		}
+		exported = append(exported, &article.ExportedCollection{Collection: *collection, ArticleIDs: articleIDs})
+	}
+	return exported, nil
+}
+
+func (s *articleService) RenameCollection(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, name string) (*article.Collection, common.Error) {
+	name, err := normalizeCollectionName(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.articleRepo.UpdateCollectionName(ctx, userID, collectionID, name)
+}
+
+// DeleteCollection deletes the collection, the articles in it stay saved.
+func (s *articleService) DeleteCollection(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID) common.Error {
+	return s.articleRepo.DeleteCollection(ctx, userID, collectionID)
+}
+
+// AddCollectionArticle appends a saved article to the end of the collection.
+func (s *articleService) AddCollectionArticle(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID) common.Error {
+	if _, err := s.articleRepo.GetUserArticle(ctx, userID, articleID); err != nil {
+		return err
+	}
+	return s.articleRepo.AddCollectionArticle(ctx, userID, collectionID, articleID)
+}
+
+// RemoveCollectionArticle takes an article out of the collection, the user keeps it saved.
+func (s *articleService) RemoveCollectionArticle(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID) common.Error {
+	if _, err := s.articleRepo.GetCollection(ctx, userID, collectionID); err != nil {
+		return err
+	}
+	return s.articleRepo.RemoveCollectionArticle(ctx, userID, collectionID, articleID)
+}
+
+// MoveCollectionArticle moves an article of the collection to the 0-based position.
+// Positions past the end move the article to the end.
+func (s *articleService) MoveCollectionArticle(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID, position int) common.Error {
+	if position < 0 {
+		return common.NewError(common.ErrorCodeParameterInvalid, errors.New("negative position"), common.WithMsg("position must not be negative"))
+	}
+	return s.articleRepo.MoveCollectionArticle(ctx, userID, collectionID, articleID, position)
+}
+
+// ListCollectionArticles lists the articles of the collection in its order, with their tags.
+func (s *articleService) ListCollectionArticles(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, afterID uuid.UUID, limit int) ([]*article.SavedArticle, common.Error) {
+	if _, err := s.articleRepo.GetCollection(ctx, userID, collectionID); err != nil {
+		return nil, err
+	}
+
+	articles, err := s.articleRepo.ListCollectionArticles(ctx, userID, collectionID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachTags(ctx, userID, articles); err != nil {
+		return nil, err
+	}
+	return articles, nil
+}
+
+func normalizeCollectionName(name string) (string, common.Error) {
+	name, err := article.NormalizeCollectionName(name)
+	if err != nil {
+		return "", common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+	return name, nil
+}
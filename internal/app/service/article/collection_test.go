@@ -0,0 +1,146 @@
+package article
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+func TestArticleService_CreateCollection(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := &articleService{articleRepo: newFakeArticleRepository()}
+
+	collection, cerr := s.CreateCollection(ctx, uuid.New(), "  Reading list ")
+	require.NoError(t, cerr)
+	assert.Equal(t, "Reading list", collection.Name)
+
+	_, cerr = s.CreateCollection(ctx, uuid.New(), " ")
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+}
+
+func TestArticleService_AddCollectionArticle(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID, otherUserID, articleID := uuid.New(), uuid.New(), uuid.New()
+	repo.saved[userID] = []uuid.UUID{articleID}
+	repo.saved[otherUserID] = []uuid.UUID{articleID}
+	collection, cerr := s.CreateCollection(ctx, userID, "Go")
+	require.NoError(t, cerr)
+
+	require.NoError(t, s.AddCollectionArticle(ctx, userID, collection.ID, articleID))
+	cerr = s.AddCollectionArticle(ctx, userID, collection.ID, articleID)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceConflict))
+
+	// Only articles the user saved can be added, and only to their own collections
+	cerr = s.AddCollectionArticle(ctx, userID, collection.ID, uuid.New())
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+	cerr = s.AddCollectionArticle(ctx, otherUserID, collection.ID, articleID)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+	assert.Equal(t, []uuid.UUID{articleID}, repo.collectionArticles[collection.ID])
+}
+
+func TestArticleService_ExportCollections(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	// More articles than a batch, read a page at a time
+	userID := uuid.New()
+	for i := 0; i < exportBatchSize+1; i++ {
+		repo.saved[userID] = append(repo.saved[userID], uuid.New())
+	}
+	collection, cerr := s.CreateCollection(ctx, userID, "Go")
+	require.NoError(t, cerr)
+	for _, id := range repo.saved[userID] {
+		require.NoError(t, s.AddCollectionArticle(ctx, userID, collection.ID, id))
+	}
+	_, cerr = s.CreateCollection(ctx, uuid.New(), "Not mine")
+	require.NoError(t, cerr)
+
+	exported, cerr := s.ExportCollections(ctx, userID)
+	require.NoError(t, cerr)
+	require.Len(t, exported, 1)
+	assert.Equal(t, "Go", exported[0].Name)
+	assert.Equal(t, repo.saved[userID], exported[0].ArticleIDs)
+}
+
+func TestArticleService_MoveCollectionArticle(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID := uuid.New()
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+	repo.saved[userID] = []uuid.UUID{first, second, third}
+	collection, cerr := s.CreateCollection(ctx, userID, "Go")
+	require.NoError(t, cerr)
+	for _, id := range repo.saved[userID] {
+		require.NoError(t, s.AddCollectionArticle(ctx, userID, collection.ID, id))
+	}
+
+	listed := func() []uuid.UUID {
+		articles, cerr := s.ListCollectionArticles(ctx, userID, collection.ID, uuid.Nil, 10)
+		require.NoError(t, cerr)
+		return savedArticleIDs(articles)
+	}
+
+	// Articles are listed in the order they were added until they are moved
+	assert.Equal(t, []uuid.UUID{first, second, third}, listed())
+	require.NoError(t, s.MoveCollectionArticle(ctx, userID, collection.ID, third, 0))
+	assert.Equal(t, []uuid.UUID{third, first, second}, listed())
+	require.NoError(t, s.MoveCollectionArticle(ctx, userID, collection.ID, third, 1))
+	assert.Equal(t, []uuid.UUID{first, third, second}, listed())
+
+	// Positions past the end move the article to the end
+	require.NoError(t, s.MoveCollectionArticle(ctx, userID, collection.ID, first, 10))
+	assert.Equal(t, []uuid.UUID{third, second, first}, listed())
+
+	// Pages continue after an article in the order of the collection
+	articles, cerr := s.ListCollectionArticles(ctx, userID, collection.ID, third, 1)
+	require.NoError(t, cerr)
+	assert.Equal(t, []uuid.UUID{second}, savedArticleIDs(articles))
+
+	cerr = s.MoveCollectionArticle(ctx, userID, collection.ID, first, -1)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+	cerr = s.MoveCollectionArticle(ctx, userID, collection.ID, uuid.New(), 0)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+	assert.Equal(t, []uuid.UUID{third, second, first}, listed())
+}
+
+func TestArticleService_CollectionOfAnotherUser(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID, otherUserID, articleID := uuid.New(), uuid.New(), uuid.New()
+	repo.saved[userID] = []uuid.UUID{articleID}
+	collection, cerr := s.CreateCollection(ctx, userID, "Go")
+	require.NoError(t, cerr)
+	require.NoError(t, s.AddCollectionArticle(ctx, userID, collection.ID, articleID))
+
+	// Other users can neither see nor change the collection
+	_, cerr = s.ListCollectionArticles(ctx, otherUserID, collection.ID, uuid.Nil, 10)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+	cerr = s.MoveCollectionArticle(ctx, otherUserID, collection.ID, articleID, 0)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+	cerr = s.RemoveCollectionArticle(ctx, otherUserID, collection.ID, articleID)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+	assert.Equal(t, []uuid.UUID{articleID}, repo.collectionArticles[collection.ID])
+
+	// Removing an article from the collection keeps it saved
+	require.NoError(t, s.RemoveCollectionArticle(ctx, userID, collection.ID, articleID))
+	assert.Empty(t, repo.collectionArticles[collection.ID])
+	assert.Equal(t, []uuid.UUID{articleID}, repo.saved[userID])
+}
@@ -0,0 +1,470 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// fakeAuditRecorder keeps the recorded audit events in memory
+type fakeAuditRecorder struct {
+	events []audit.Event
+}
+
+func (r *fakeAuditRecorder) Record(_ context.Context, event *audit.Event) {
+	r.events = append(r.events, *event)
+}
+
+// fakeArticleRepository keeps saved articles and what users attach to them in memory, other repository methods are not implemented.
+// Like the database, everything of a user is only found through the articles they saved.
+type fakeArticleRepository struct {
+	ArticleRepository
+	saved   map[uuid.UUID][]uuid.UUID // article IDs by user, in the order they are listed
+	filters []article.ArticleFilter   // the filters articles were listed with
+
+	tags        map[uuid.UUID]*article.Tag
+	articleTags map[uuid.UUID]map[uuid.UUID]bool // tag IDs by article
+
+	collections        map[uuid.UUID]*article.Collection
+	collectionArticles map[uuid.UUID][]uuid.UUID // article IDs by collection, in order
+
+	notes      map[uuid.UUID]*article.Note
+	highlights map[uuid.UUID]*article.Highlight
+	owners     map[uuid.UUID]article.UserArticle // saved articles by note or highlight ID
+
+	states map[uuid.UUID]string // read states by article, unread when missing
+
+	unindexed []*article.Article
+	vectors   map[uuid.UUID]string // search vectors by article
+
+	urls        map[string]uuid.UUID    // article IDs by URL
+	collectedAt map[uuid.UUID]time.Time // when imported articles were saved
+	progress    []article.ImportJob     // the import job at every update
+	failAt      int                     // the import job update failing, from 1, 0 never fails
+	claimable   []*article.ImportJob    // import jobs claimed in order
+	jobs        []*article.ImportJob    // import jobs created, never more than maxUnfinished per user
+}
+
+func newFakeArticleRepository() *fakeArticleRepository {
+	return &fakeArticleRepository{
+		saved:              make(map[uuid.UUID][]uuid.UUID),
+		tags:               make(map[uuid.UUID]*article.Tag),
+		articleTags:        make(map[uuid.UUID]map[uuid.UUID]bool),
+		collections:        make(map[uuid.UUID]*article.Collection),
+		collectionArticles: make(map[uuid.UUID][]uuid.UUID),
+		notes:              make(map[uuid.UUID]*article.Note),
+		highlights:         make(map[uuid.UUID]*article.Highlight),
+		owners:             make(map[uuid.UUID]article.UserArticle),
+		states:             make(map[uuid.UUID]string),
+		vectors:            make(map[uuid.UUID]string),
+		urls:               make(map[string]uuid.UUID),
+		collectedAt:        make(map[uuid.UUID]time.Time),
+	}
+}
+
+func tagNames(tags []*article.Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+func savedArticleIDs(articles []*article.SavedArticle) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(articles))
+	for _, saved := range articles {
+		ids = append(ids, saved.ID)
+	}
+	return ids
+}
+
+func (r *fakeArticleRepository) CreateArticle(_ context.Context, url string) (*article.Article, common.Error) {
+	if _, ok := r.urls[url]; !ok {
+		r.urls[url] = uuid.New()
+	}
+	return &article.Article{ID: r.urls[url], URL: url}, nil
+}
+
+func (r *fakeArticleRepository) GetArticleByID(_ context.Context, articleID uuid.UUID) (*article.Article, common.Error) {
+	return &article.Article{ID: articleID}, nil
+}
+
+func (r *fakeArticleRepository) GetUserArticle(_ context.Context, userID uuid.UUID, articleID uuid.UUID) (*article.UserArticle, common.Error) {
+	for _, id := range r.saved[userID] {
+		if id == articleID {
+			state, ok := r.states[articleID]
+			if !ok {
+				state = article.ReadStateUnread
+			}
+			return &article.UserArticle{UserID: userID, ArticleID: articleID, State: state}, nil
+		}
+	}
+	return nil, common.NewError(common.ErrorCodeResourceNotFound, nil)
+}
+
+func (r *fakeArticleRepository) GetSavedArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) (*article.SavedArticle, common.Error) {
+	if _, err := r.GetUserArticle(ctx, userID, articleID); err != nil {
+		return nil, err
+	}
+	return &article.SavedArticle{Article: article.Article{ID: articleID}}, nil
+}
+
+// ListArticles lists the saved articles in the order they were saved whatever the sort, starting after the cursor
+func (r *fakeArticleRepository) ListArticles(_ context.Context, userID uuid.UUID, filter article.ArticleFilter, _ article.ArticleSort, after *article.ArticleCursor, limit int) ([]*article.SavedArticle, common.Error) {
+	r.filters = append(r.filters, filter)
+	ids := r.saved[userID]
+	if after != nil {
+		for i, id := range ids {
+			if id == after.ID {
+				ids = ids[i+1:]
+				break
+			}
+		}
+	}
+
+	var articles []*article.SavedArticle
+	for _, id := range ids {
+		articles = append(articles, &article.SavedArticle{Article: article.Article{ID: id}})
+	}
+	if len(articles) > limit {
+		articles = articles[:limit]
+	}
+	return articles, nil
+}
+
+func (r *fakeArticleRepository) IterateUserArticles(_ context.Context, userID uuid.UUID, filter article.ArticleFilter, fn func(*article.SavedArticle) common.Error) common.Error {
+	r.filters = append(r.filters, filter)
+	for _, id := range r.saved[userID] {
+		if err := fn(&article.SavedArticle{Article: article.Article{ID: id}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *fakeArticleRepository) GetMetadataFetchRetry(_ context.Context, _ uuid.UUID) (*article.MetadataFetchRetry, common.Error) {
+	return nil, common.NewError(common.ErrorCodeResourceNotFound, nil)
+}
+
+func (r *fakeArticleRepository) CreateMetadataFetchRetry(_ context.Context, _ uuid.UUID, _ string) common.Error {
+	return nil
+}
+
+func (r *fakeArticleRepository) findTag(userID uuid.UUID, name string) *article.Tag {
+	for _, tag := range r.tags {
+		if tag.UserID == userID && strings.EqualFold(tag.Name, name) {
+			return tag
+		}
+	}
+	return nil
+}
+
+func (r *fakeArticleRepository) CreateTag(_ context.Context, userID uuid.UUID, name string) (*article.Tag, common.Error) {
+	if r.findTag(userID, name) != nil {
+		return nil, common.NewError(common.ErrorCodeResourceConflict, nil)
+	}
+	tag := &article.Tag{ID: uuid.New(), UserID: userID, Name: name}
+	r.tags[tag.ID] = tag
+	return tag, nil
+}
+
+func (r *fakeArticleRepository) GetTag(_ context.Context, userID uuid.UUID, tagID uuid.UUID) (*article.Tag, common.Error) {
+	tag, ok := r.tags[tagID]
+	if !ok || tag.UserID != userID {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, nil)
+	}
+	count := 0
+	for _, tagIDs := range r.articleTags {
+		if tagIDs[tagID] {
+			count++
+		}
+	}
+	return &article.Tag{ID: tag.ID, UserID: tag.UserID, Name: tag.Name, ArticleCount: count}, nil
+}
+
+func (r *fakeArticleRepository) MergeTags(ctx context.Context, userID uuid.UUID, sourceID uuid.UUID, targetID uuid.UUID) common.Error {
+	if _, err := r.GetTag(ctx, userID, sourceID); err != nil {
+		return err
+	}
+	for _, tagIDs := range r.articleTags {
+		if tagIDs[sourceID] {
+			delete(tagIDs, sourceID)
+			tagIDs[targetID] = true
+		}
+	}
+	delete(r.tags, sourceID)
+	return nil
+}
+
+func (r *fakeArticleRepository) AddUserArticleTags(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, names []string) common.Error {
+	if r.articleTags[articleID] == nil {
+		r.articleTags[articleID] = make(map[uuid.UUID]bool)
+	}
+	for _, name := range names {
+		tag := r.findTag(userID, name)
+		if tag == nil {
+			tag, _ = r.CreateTag(ctx, userID, name)
+		}
+		r.articleTags[articleID][tag.ID] = true
+	}
+	return nil
+}
+
+func (r *fakeArticleRepository) ListUserArticleTags(_ context.Context, _ uuid.UUID, articleIDs []uuid.UUID) (map[uuid.UUID][]*article.Tag, common.Error) {
+	tags := make(map[uuid.UUID][]*article.Tag)
+	for _, articleID := range articleIDs {
+		for tagID := range r.articleTags[articleID] {
+			tags[articleID] = append(tags[articleID], r.tags[tagID])
+		}
+	}
+	return tags, nil
+}
+
+func (r *fakeArticleRepository) CreateCollection(_ context.Context, userID uuid.UUID, name string) (*article.Collection, common.Error) {
+	collection := &article.Collection{ID: uuid.New(), UserID: userID, Name: name}
+	r.collections[collection.ID] = collection
+	return collection, nil
+}
+
+func (r *fakeArticleRepository) GetCollection(_ context.Context, userID uuid.UUID, collectionID uuid.UUID) (*article.Collection, common.Error) {
+	collection, ok := r.collections[collectionID]
+	if !ok || collection.UserID != userID {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, nil)
+	}
+	return &article.Collection{ID: collection.ID, UserID: userID, Name: collection.Name, ArticleCount: len(r.collectionArticles[collectionID])}, nil
+}
+
+func (r *fakeArticleRepository) AddCollectionArticle(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID) common.Error {
+	if _, err := r.GetCollection(ctx, userID, collectionID); err != nil {
+		return err
+	}
+	for _, id := range r.collectionArticles[collectionID] {
+		if id == articleID {
+			return common.NewError(common.ErrorCodeResourceConflict, nil)
+		}
+	}
+	r.collectionArticles[collectionID] = append(r.collectionArticles[collectionID], articleID)
+	return nil
+}
+
+func (r *fakeArticleRepository) RemoveCollectionArticle(_ context.Context, _ uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID) common.Error {
+	ids := r.collectionArticles[collectionID]
+	for i, id := range ids {
+		if id == articleID {
+			r.collectionArticles[collectionID] = append(ids[:i], ids[i+1:]...)
+			return nil
+		}
+	}
+	return common.NewError(common.ErrorCodeResourceNotFound, article.ErrNotInCollection)
+}
+
+func (r *fakeArticleRepository) MoveCollectionArticle(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID, position int) common.Error {
+	if _, err := r.GetCollection(ctx, userID, collectionID); err != nil {
+		return err
+	}
+	moved, err := article.MoveArticle(r.collectionArticles[collectionID], articleID, position)
+	if errors.Is(err, article.ErrNotInCollection) {
+		return common.NewError(common.ErrorCodeResourceNotFound, err)
+	}
+	if err != nil {
+		return common.NewError(common.ErrorCodeParameterInvalid, err)
+	}
+	r.collectionArticles[collectionID] = moved
+	return nil
+}
+
+func (r *fakeArticleRepository) ListCollections(_ context.Context, userID uuid.UUID) ([]*article.Collection, common.Error) {
+	var collections []*article.Collection
+	for _, collection := range r.collections {
+		if collection.UserID == userID {
+			collections = append(collections, &article.Collection{ID: collection.ID, UserID: userID, Name: collection.Name, ArticleCount: len(r.collectionArticles[collection.ID])})
+		}
+	}
+	return collections, nil
+}
+
+func (r *fakeArticleRepository) ListCollectionArticles(_ context.Context, _ uuid.UUID, collectionID uuid.UUID, afterID uuid.UUID, limit int) ([]*article.SavedArticle, common.Error) {
+	ids := r.collectionArticles[collectionID]
+	if afterID != uuid.Nil {
+		for i, id := range ids {
+			if id == afterID {
+				ids = ids[i+1:]
+				break
+			}
+		}
+	}
+
+	var articles []*article.SavedArticle
+	for _, id := range ids {
+		articles = append(articles, &article.SavedArticle{Article: article.Article{ID: id}})
+	}
+	if len(articles) > limit {
+		articles = articles[:limit]
+	}
+	return articles, nil
+}
+
+// owns tells whether the note or highlight is on the article saved by the user
+func (r *fakeArticleRepository) owns(userID uuid.UUID, articleID uuid.UUID, id uuid.UUID) bool {
+	owner, ok := r.owners[id]
+	return ok && owner.UserID == userID && owner.ArticleID == articleID
+}
+
+func (r *fakeArticleRepository) CreateNote(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, body string) (*article.Note, common.Error) {
+	if _, err := r.GetUserArticle(ctx, userID, articleID); err != nil {
+		return nil, err
+	}
+	note := &article.Note{ID: uuid.New(), ArticleID: articleID, Body: body}
+	r.notes[note.ID] = note
+	r.owners[note.ID] = article.UserArticle{UserID: userID, ArticleID: articleID}
+	return note, nil
+}
+
+func (r *fakeArticleRepository) UpdateNote(_ context.Context, userID uuid.UUID, articleID uuid.UUID, noteID uuid.UUID, body string) (*article.Note, common.Error) {
+	if !r.owns(userID, articleID, noteID) {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, nil)
+	}
+	note := r.notes[noteID]
+	note.Body = body
+	return note, nil
+}
+
+func (r *fakeArticleRepository) DeleteNote(_ context.Context, userID uuid.UUID, articleID uuid.UUID, noteID uuid.UUID) common.Error {
+	if !r.owns(userID, articleID, noteID) {
+		return common.NewError(common.ErrorCodeResourceNotFound, nil)
+	}
+	delete(r.notes, noteID)
+	delete(r.owners, noteID)
+	return nil
+}
+
+func (r *fakeArticleRepository) CreateHighlight(ctx context.Context, userID uuid.UUID, highlight *article.Highlight) (*article.Highlight, common.Error) {
+	if _, err := r.GetUserArticle(ctx, userID, highlight.ArticleID); err != nil {
+		return nil, err
+	}
+	created := *highlight
+	created.ID = uuid.New()
+	r.highlights[created.ID] = &created
+	r.owners[created.ID] = article.UserArticle{UserID: userID, ArticleID: created.ArticleID}
+	return &created, nil
+}
+
+func (r *fakeArticleRepository) UpdateHighlightComment(_ context.Context, userID uuid.UUID, articleID uuid.UUID, highlightID uuid.UUID, comment string) (*article.Highlight, common.Error) {
+	if !r.owns(userID, articleID, highlightID) {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, nil)
+	}
+	highlight := r.highlights[highlightID]
+	highlight.Comment = comment
+	return highlight, nil
+}
+
+func (r *fakeArticleRepository) DeleteHighlight(_ context.Context, userID uuid.UUID, articleID uuid.UUID, highlightID uuid.UUID) common.Error {
+	if !r.owns(userID, articleID, highlightID) {
+		return common.NewError(common.ErrorCodeResourceNotFound, nil)
+	}
+	delete(r.highlights, highlightID)
+	delete(r.owners, highlightID)
+	return nil
+}
+
+func (r *fakeArticleRepository) ListUserArticleNotes(_ context.Context, userID uuid.UUID, articleIDs []uuid.UUID) (map[uuid.UUID][]*article.Note, common.Error) {
+	notes := make(map[uuid.UUID][]*article.Note)
+	for _, articleID := range articleIDs {
+		for id, note := range r.notes {
+			if r.owns(userID, articleID, id) {
+				notes[articleID] = append(notes[articleID], note)
+			}
+		}
+	}
+	return notes, nil
+}
+
+func (r *fakeArticleRepository) ListUserArticleHighlights(_ context.Context, userID uuid.UUID, articleIDs []uuid.UUID) (map[uuid.UUID][]*article.Highlight, common.Error) {
+	highlights := make(map[uuid.UUID][]*article.Highlight)
+	for _, articleID := range articleIDs {
+		for id, highlight := range r.highlights {
+			if r.owns(userID, articleID, id) {
+				highlights[articleID] = append(highlights[articleID], highlight)
+			}
+		}
+	}
+	return highlights, nil
+}
+
+func (r *fakeArticleRepository) UpdateUserArticleStates(ctx context.Context, userID uuid.UUID, articleIDs []uuid.UUID, state string) (int64, common.Error) {
+	var updated int64
+	for _, id := range articleIDs {
+		if _, err := r.GetUserArticle(ctx, userID, id); err == nil {
+			r.states[id] = state
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+func (r *fakeArticleRepository) ListUnindexedArticles(_ context.Context, limit int) ([]*article.Article, common.Error) {
+	var arts []*article.Article
+	for _, art := range r.unindexed {
+		if _, ok := r.vectors[art.ID]; !ok && len(arts) < limit {
+			arts = append(arts, art)
+		}
+	}
+	return arts, nil
+}
+
+func (r *fakeArticleRepository) UpdateArticleSearchVector(_ context.Context, articleID uuid.UUID, vector string) common.Error {
+	r.vectors[articleID] = vector
+	return nil
+}
+
+func (r *fakeArticleRepository) ImportUserArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, collectedAt time.Time) (bool, common.Error) {
+	if _, err := r.GetUserArticle(ctx, userID, articleID); err == nil {
+		return false, nil
+	}
+	r.saved[userID] = append(r.saved[userID], articleID)
+	r.collectedAt[articleID] = collectedAt
+	return true, nil
+}
+
+func (r *fakeArticleRepository) CreateImportJob(_ context.Context, job *article.ImportJob, maxUnfinished int) (*article.ImportJob, common.Error) {
+	unfinished := 0
+	for _, created := range r.jobs {
+		if created.UserID == job.UserID && !created.IsFinished() {
+			unfinished++
+		}
+	}
+	if unfinished >= maxUnfinished {
+		return nil, common.NewError(common.ErrorCodeResourceConflict, errors.New("too many unfinished import jobs"))
+	}
+	created := *job
+	created.ID = uuid.New()
+	r.jobs = append(r.jobs, &created)
+	return &created, nil
+}
+
+func (r *fakeArticleRepository) ClaimImportJob(_ context.Context, _ time.Time) (*article.ImportJob, common.Error) {
+	if len(r.claimable) == 0 {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("no import job"))
+	}
+	job := r.claimable[0]
+	r.claimable = r.claimable[1:]
+	job.Status = article.ImportStatusRunning
+	job.Attempts++
+	return job, nil
+}
+
+func (r *fakeArticleRepository) UpdateImportJobProgress(_ context.Context, job *article.ImportJob) common.Error {
+	if len(r.progress)+1 == r.failAt {
+		r.failAt = 0
+		return common.NewError(common.ErrorCodeRemoteProcess, nil)
+	}
+	r.progress = append(r.progress, *job)
+	return nil
+}
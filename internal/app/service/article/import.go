@@ -0,0 +1,176 @@
+package article
+
+import (
+	"context"
+	"time"
+
+	gocron "github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// importStaleAfter is how long a running import job can go without progress before another worker takes it over
+const importStaleAfter = 5 * time.Minute
+
+// ImportArticles parses the file in the format, guessing the format when it is empty,
+// and queues a job importing its articles for the user, unless the user has ImportMaxUnfinished jobs queued already.
+// Queuing the job is recorded in the audit log.
+func (s *articleService) ImportArticles(ctx context.Context, userID uuid.UUID, format string, data []byte) (*article.ImportJob, common.Error) {
+	format, items, err := article.ParseImport(format, data)
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+
+	job, cerr := s.articleRepo.CreateImportJob(ctx, article.NewImportJob(userID, format, items), article.ImportMaxUnfinished)
+	if cerr != nil {
+		s.auditor.Record(ctx, audit.NewEvent(audit.ActionArticleImport, userID, "", "", cerr))
+		return nil, cerr
+	}
+	s.auditor.Record(ctx, audit.NewEvent(audit.ActionArticleImport, userID, audit.TargetImport, job.ID.String(), nil))
+	if s.importWorker != nil {
+		s.importWorker.wake()
+	}
+	return job, nil
+}
+
+// GetImportJob returns the progress and errors of an import job of the user.
+func (s *articleService) GetImportJob(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*article.ImportJob, common.Error) {
+	return s.articleRepo.GetImportJob(ctx, userID, jobID)
+}
+
+// runImportJob imports the items of the job from where it stopped, storing the progress after every item.
+// It stops at the first error of the database, leaving the rest of the job to a later run.
+func (s *articleService) runImportJob(ctx context.Context, job *article.ImportJob) common.Error {
+	for job.Processed < len(job.Items) {
+		item := job.Items[job.Processed]
+		if item.Error != "" {
+			job.Fail(item, item.Error)
+		} else {
+			created, err := s.importItem(ctx, job.UserID, item)
+			if err != nil {
+				return err
+			}
+			if created {
+				job.Imported++
+			} else {
+				job.Duplicates++
+			}
+		}
+
+		job.Processed++
+		if job.Processed == len(job.Items) {
+			break
+		}
+		if err := s.articleRepo.UpdateImportJobProgress(ctx, job); err != nil {
+			return err
+		}
+	}
+
+	job.Status = article.ImportStatusDone
+	return s.articleRepo.UpdateImportJobProgress(ctx, job)
+}
+
+// importItem saves the article of the item for the user like CreateArticle, keeping when it was saved.
+// Articles the user had already saved only get the tags of the item, and false is returned for them.
+func (s *articleService) importItem(ctx context.Context, userID uuid.UUID, item *article.ImportItem) (bool, common.Error) {
+	var created bool
+	art, err := s.saveArticle(ctx, item.URL, func(art *article.Article) common.Error {
+		var err common.Error
+		created, err = s.articleRepo.ImportUserArticle(ctx, userID, art.ID, item.CollectedAt)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if created && item.State != "" {
+		if _, err := s.articleRepo.UpdateUserArticleStates(ctx, userID, []uuid.UUID{art.ID}, item.State); err != nil {
+			return false, err
+		}
+	}
+	if tags := item.Tags; len(tags) > 0 {
+		if len(tags) > maxTagsPerRequest {
+			tags = tags[:maxTagsPerRequest]
+		}
+		if err := s.articleRepo.AddUserArticleTags(ctx, userID, art.ID, tags); err != nil {
+			return false, err
+		}
+	}
+	return created, nil
+}
+
+// ImportWorker runs the import jobs in the background, one at a time
+type ImportWorker struct {
+	scheduler gocron.Scheduler
+	job       gocron.Job
+	service   *articleService
+}
+
+func NewImportWorker(ctx context.Context, service *articleService) *ImportWorker {
+	s, err := gocron.NewScheduler(gocron.WithLocation(time.UTC))
+	if err != nil {
+		return nil
+	}
+	w := &ImportWorker{
+		scheduler: s,
+		service:   service,
+	}
+	w.job, err = w.scheduler.NewJob(
+		gocron.DurationJob(30*time.Second),
+		gocron.NewTask(w.runImportJobs, ctx),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		gocron.WithName("ImportWorker"),
+	)
+	if err != nil {
+		return nil
+	}
+
+	w.scheduler.Start()
+
+	return w
+}
+
+// wake runs the queued jobs now rather than at the next interval
+func (w *ImportWorker) wake() {
+	_ = w.job.RunNow()
+}
+
+// runImportJobs runs queued jobs until there are none left.
+// A job which failed or was left stale by its worker ImportMaxAttempts times fails rather than run again.
+func (w *ImportWorker) runImportJobs(ctx context.Context) {
+	for {
+		job, err := w.service.articleRepo.ClaimImportJob(ctx, time.Now().Add(-importStaleAfter))
+		if err != nil {
+			if !common.IsErrorCode(err, common.ErrorCodeResourceNotFound) {
+				w.logger(ctx).Err(err).Msg("failed to claim import job")
+			}
+			return
+		}
+
+		if job.Attempts > article.ImportMaxAttempts {
+			w.logger(ctx).Warn().Str("import_id", job.ID.String()).Int("processed", job.Processed).Int("total", job.Total).Msg("giving up import job")
+			job.Status = article.ImportStatusFailed
+			if err := w.service.articleRepo.UpdateImportJobProgress(ctx, job); err != nil {
+				w.logger(ctx).Err(err).Str("import_id", job.ID.String()).Msg("failed to fail import job")
+				return
+			}
+			continue
+		}
+
+		w.logger(ctx).Info().Str("import_id", job.ID.String()).Int("processed", job.Processed).Int("total", job.Total).Msg("running import job")
+		if err := w.service.runImportJob(ctx, job); err != nil {
+			w.logger(ctx).Err(err).Str("import_id", job.ID.String()).Msg("failed to run import job")
+			return
+		}
+	}
+}
+
+// logger wrap the execution context with component info
+func (w *ImportWorker) logger(ctx context.Context) *zerolog.Logger {
+	l := zerolog.Ctx(ctx).With().Str("component", "import-worker").Logger()
+	return &l
+}
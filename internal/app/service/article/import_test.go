@@ -0,0 +1,119 @@
+package article
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+func TestArticleService_RunImportJob(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID := uuid.New()
+	saved, _ := repo.CreateArticle(ctx, "https://example.com/saved")
+	repo.saved[userID] = []uuid.UUID{saved.ID}
+
+	collectedAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	job := article.NewImportJob(userID, article.ImportFormatText, []*article.ImportItem{
+		{Line: 1, URL: "https://example.com/a", CollectedAt: collectedAt, Tags: []string{"Go"}, State: article.ReadStateArchived},
+		{Line: 2, URL: "example.com/b", Error: "not an http or https url"},
+		{Line: 3, URL: "https://example.com/saved", Tags: []string{"Go"}},
+	})
+	job.Status = article.ImportStatusRunning // as claimed by the worker
+	repo.failAt = 2
+
+	// The job stops at the failing update and resumes with the next item
+	require.Error(t, s.runImportJob(ctx, job))
+	assert.Equal(t, 2, job.Processed)
+	require.NoError(t, s.runImportJob(ctx, job))
+
+	assert.Equal(t, article.ImportStatusDone, job.Status)
+	assert.Equal(t, 3, job.Processed)
+	assert.Equal(t, 1, job.Imported)
+	assert.Equal(t, 1, job.Duplicates)
+	assert.Equal(t, 1, job.Failed)
+	assert.Equal(t, []article.ImportError{{Line: 2, URL: "example.com/b", Message: "not an http or https url"}}, job.Errors)
+	require.Len(t, repo.progress, 2)
+	assert.Equal(t, article.ImportStatusRunning, repo.progress[0].Status, "progress is stored before the job is done")
+
+	imported := repo.urls["https://example.com/a"]
+	assert.Equal(t, collectedAt, repo.collectedAt[imported])
+	assert.Equal(t, article.ReadStateArchived, repo.states[imported])
+	assert.NotContains(t, repo.states, saved.ID, "articles saved before keep their state")
+	assert.Len(t, repo.articleTags[saved.ID], 1, "articles saved before get the tags")
+}
+
+func TestImportWorker_GivesUpImportJob(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	w := &ImportWorker{service: &articleService{articleRepo: repo}}
+
+	userID := uuid.New()
+	items := func(url string) []*article.ImportItem {
+		return []*article.ImportItem{{Line: 1, URL: "https://example.com/kept"}, {Line: 2, URL: url}}
+	}
+	exhausted := article.NewImportJob(userID, article.ImportFormatText, items("https://example.com/given-up"))
+	exhausted.Attempts = article.ImportMaxAttempts
+	exhausted.Processed = 1
+	retried := article.NewImportJob(userID, article.ImportFormatText, items("https://example.com/retried"))
+	retried.Attempts = article.ImportMaxAttempts - 1
+	repo.claimable = []*article.ImportJob{exhausted, retried}
+
+	w.runImportJobs(ctx)
+
+	// The job claimed once more than allowed fails where it stopped, the other one still runs to the end
+	assert.Equal(t, article.ImportStatusFailed, exhausted.Status)
+	assert.True(t, exhausted.IsFinished())
+	assert.Equal(t, 1, exhausted.Processed)
+	assert.NotContains(t, repo.urls, "https://example.com/given-up")
+	assert.Equal(t, article.ImportStatusFailed, repo.progress[0].Status)
+
+	assert.Equal(t, article.ImportStatusDone, retried.Status)
+	assert.Equal(t, 2, retried.Imported)
+	assert.Contains(t, repo.urls, "https://example.com/retried")
+}
+
+func TestArticleService_ImportArticles(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	auditor := &fakeAuditRecorder{}
+	s := &articleService{articleRepo: newFakeArticleRepository(), auditor: auditor}
+
+	userID := uuid.New()
+	var jobs []*article.ImportJob
+	for i := 0; i < article.ImportMaxUnfinished; i++ {
+		job, err := s.ImportArticles(ctx, userID, "", []byte("https://example.com/a\n"))
+		require.NoError(t, err)
+		jobs = append(jobs, job)
+	}
+	_, err := s.ImportArticles(ctx, userID, "", []byte("https://example.com/a\n"))
+	assert.True(t, common.IsErrorCode(err, common.ErrorCodeResourceConflict))
+
+	// Every queued job is audited, and so is the refused one
+	require.Len(t, auditor.events, article.ImportMaxUnfinished+1)
+	assert.Equal(t, audit.ActionArticleImport, auditor.events[0].Action)
+	assert.Equal(t, userID, auditor.events[0].ActorID)
+	assert.Equal(t, audit.TargetImport, auditor.events[0].TargetType)
+	assert.Equal(t, jobs[0].ID.String(), auditor.events[0].TargetID)
+	assert.Equal(t, audit.OutcomeFailure, auditor.events[article.ImportMaxUnfinished].Outcome)
+}
+
+func TestArticleService_ImportArticlesInvalid(t *testing.T) {
+	t.Parallel()
+	s := &articleService{articleRepo: newFakeArticleRepository()}
+
+	_, err := s.ImportArticles(context.Background(), uuid.New(), "", []byte("# nothing to import\n"))
+	assert.True(t, common.IsErrorCode(err, common.ErrorCodeParameterInvalid))
+}
@@ -2,45 +2,167 @@ package article
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
 	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/workspace"
 )
 
 // ArticleRepository defines the interface for interacting with article and user_article data.
 type ArticleRepository interface {
 	CreateArticle(ctx context.Context, url string) (*article.Article, common.Error)
 	UpdateArticle(ctx context.Context, art *article.Article) common.Error
+	UpdateArticleSearchVector(ctx context.Context, articleID uuid.UUID, vector string) common.Error
 
 	CreateUserArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) (*article.UserArticle, common.Error)
+	ImportUserArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, collectedAt time.Time) (bool, common.Error)
 	GetUserArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) (*article.UserArticle, common.Error)
-	ListArticles(ctx context.Context, userID uuid.UUID, afterID uuid.UUID, limit int) ([]*article.Article, common.Error)
+	GetSavedArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) (*article.SavedArticle, common.Error)
+	ListArticles(ctx context.Context, userID uuid.UUID, filter article.ArticleFilter, sort article.ArticleSort, after *article.ArticleCursor, limit int) ([]*article.SavedArticle, common.Error)
+	SearchArticles(ctx context.Context, userID uuid.UUID, tsquery string, limit int) ([]*article.SearchResult, common.Error)
+	IterateUserArticles(ctx context.Context, userID uuid.UUID, filter article.ArticleFilter, fn func(*article.SavedArticle) common.Error) common.Error
 	DeleteUserArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) common.Error
 	UpdateUserArticleRate(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, rate int16) common.Error
 	DeleteUserArticleRate(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) common.Error
+	UpdateUserArticleStates(ctx context.Context, userID uuid.UUID, articleIDs []uuid.UUID, state string) (int64, common.Error)
+	CountUserArticlesByState(ctx context.Context, userID uuid.UUID) (map[string]int, common.Error)
+
+	CreateTag(ctx context.Context, userID uuid.UUID, name string) (*article.Tag, common.Error)
+	GetTag(ctx context.Context, userID uuid.UUID, tagID uuid.UUID) (*article.Tag, common.Error)
+	ListTags(ctx context.Context, userID uuid.UUID) ([]*article.Tag, common.Error)
+	UpdateTagName(ctx context.Context, userID uuid.UUID, tagID uuid.UUID, name string) (*article.Tag, common.Error)
+	DeleteTag(ctx context.Context, userID uuid.UUID, tagID uuid.UUID) common.Error
+	MergeTags(ctx context.Context, userID uuid.UUID, sourceID uuid.UUID, targetID uuid.UUID) common.Error
+	AddUserArticleTags(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, names []string) common.Error
+	RemoveUserArticleTag(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, tagID uuid.UUID) common.Error
+	ListUserArticleTags(ctx context.Context, userID uuid.UUID, articleIDs []uuid.UUID) (map[uuid.UUID][]*article.Tag, common.Error)
+
+	CreateCollection(ctx context.Context, userID uuid.UUID, name string) (*article.Collection, common.Error)
+	GetCollection(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID) (*article.Collection, common.Error)
+	ListCollections(ctx context.Context, userID uuid.UUID) ([]*article.Collection, common.Error)
+	UpdateCollectionName(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, name string) (*article.Collection, common.Error)
+	DeleteCollection(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID) common.Error
+	AddCollectionArticle(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID) common.Error
+	RemoveCollectionArticle(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID) common.Error
+	MoveCollectionArticle(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID, position int) common.Error
+	ListCollectionArticles(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, afterID uuid.UUID, limit int) ([]*article.SavedArticle, common.Error)
+
+	CreateNote(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, body string) (*article.Note, common.Error)
+	ListNotes(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) ([]*article.Note, common.Error)
+	UpdateNote(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, noteID uuid.UUID, body string) (*article.Note, common.Error)
+	DeleteNote(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, noteID uuid.UUID) common.Error
+	CreateHighlight(ctx context.Context, userID uuid.UUID, highlight *article.Highlight) (*article.Highlight, common.Error)
+	ListHighlights(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) ([]*article.Highlight, common.Error)
+	UpdateHighlightComment(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, highlightID uuid.UUID, comment string) (*article.Highlight, common.Error)
+	DeleteHighlight(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, highlightID uuid.UUID) common.Error
+	ListUserArticleNotes(ctx context.Context, userID uuid.UUID, articleIDs []uuid.UUID) (map[uuid.UUID][]*article.Note, common.Error)
+	ListUserArticleHighlights(ctx context.Context, userID uuid.UUID, articleIDs []uuid.UUID) (map[uuid.UUID][]*article.Highlight, common.Error)
+
+	CreateImportJob(ctx context.Context, job *article.ImportJob, maxUnfinished int) (*article.ImportJob, common.Error)
+	GetImportJob(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*article.ImportJob, common.Error)
+	ClaimImportJob(ctx context.Context, staleBefore time.Time) (*article.ImportJob, common.Error)
+	UpdateImportJobProgress(ctx context.Context, job *article.ImportJob) common.Error
 
 	CreateMetadataFetchRetry(ctx context.Context, articleID uuid.UUID, url string) common.Error
+	GetMetadataFetchRetry(ctx context.Context, articleID uuid.UUID) (*article.MetadataFetchRetry, common.Error)
 	GetPendingMetadataFetchRetries(ctx context.Context) ([]*article.MetadataFetchRetry, common.Error)
 	UpdateMetadataFetchRetryStatus(ctx context.Context, retryID int64, status int16, errorMessage string) common.Error
 	IncrementMetadataFetchRetryCount(ctx context.Context, retryID int64) common.Error
 	GetArticleByID(ctx context.Context, articleID uuid.UUID) (*article.Article, common.Error)
+	ListUnindexedArticles(ctx context.Context, limit int) ([]*article.Article, common.Error)
+	UpsertArticleEmbedding(ctx context.Context, articleID uuid.UUID, model string, embedding []float32) common.Error
+	ListUnembeddedArticles(ctx context.Context, model string, limit int) ([]*article.Article, common.Error)
+	SearchSimilarArticles(ctx context.Context, userID uuid.UUID, model string, embedding []float32, minSimilarity float64, limit int) ([]*article.SearchResult, common.Error)
 
 	GetTopRatedArticlesExcludingUser(ctx context.Context, excludeUserID uuid.UUID, limit int) (article.RecommendationArticles, common.Error)
 	RefreshMaterializedView(ctx context.Context) common.Error
+
+	CreateWorkspaceArticle(ctx context.Context, workspaceID uuid.UUID, articleID uuid.UUID, addedBy uuid.UUID) common.Error
+	GetWorkspaceArticle(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, articleID uuid.UUID) (*article.WorkspaceArticle, common.Error)
+	ListWorkspaceArticles(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, afterID uuid.UUID, limit int) ([]*article.WorkspaceArticle, common.Error)
+	UpsertWorkspaceArticleRating(ctx context.Context, workspaceID uuid.UUID, articleID uuid.UUID, userID uuid.UUID, rate int16) common.Error
+}
+
+// WorkspaceAuthorizer checks the role users have in a workspace.
+type WorkspaceAuthorizer interface {
+	Authorize(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, role string) (*workspace.Member, common.Error)
+}
+
+// AuditRecorder appends events to the audit log.
+type AuditRecorder interface {
+	Record(ctx context.Context, event *audit.Event)
 }
 
 // ArticleService defines the interface for article-related business logic.
 type ArticleService interface {
 	RecommendationService
 	CreateArticle(ctx context.Context, userID uuid.UUID, url string) (*article.Article, common.Error)
-	ListArticles(ctx context.Context, userID uuid.UUID, afterID uuid.UUID, limit int) ([]*article.Article, common.Error)
+	GetArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) (*article.ArticleDetail, common.Error)
+	// ListArticles returns a page of the saved articles of the user and the cursor of the next page.
+	// The cursor is empty for the first page, and the next cursor is empty on the last page.
+	ListArticles(ctx context.Context, userID uuid.UUID, filter article.ArticleFilter, sort article.ArticleSort, cursor string, limit int) ([]*article.SavedArticle, string, common.Error)
+	// ArticleCursor returns the cursor of the page starting after the saved article in the sort, for clients paging by article ID
+	ArticleCursor(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, sort article.ArticleSort) (string, common.Error)
+	SearchArticles(ctx context.Context, userID uuid.UUID, q string, mode string, limit int) ([]*article.SearchResult, common.Error)
+	// ExportArticles calls fn with every saved article of the user matching the filter, with its tags
+	ExportArticles(ctx context.Context, userID uuid.UUID, filter article.ArticleFilter, fn func(*article.SavedArticle) common.Error) common.Error
+	// ExportUserArticles calls fn with every saved article of the user, with its tags, notes and highlights
+	ExportUserArticles(ctx context.Context, userID uuid.UUID, fn func(*article.SavedArticle) common.Error) common.Error
+	// ExportCollections returns every collection of the user with the IDs of its articles in order
+	ExportCollections(ctx context.Context, userID uuid.UUID) ([]*article.ExportedCollection, common.Error)
+	// ImportArticles queues a job importing the articles of a file, GetImportJob tells how it is going
+	ImportArticles(ctx context.Context, userID uuid.UUID, format string, data []byte) (*article.ImportJob, common.Error)
+	GetImportJob(ctx context.Context, userID uuid.UUID, jobID uuid.UUID) (*article.ImportJob, common.Error)
 	DeleteArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) common.Error
 
 	RateArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, rate int16) common.Error
 	GetArticleRating(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) (*article.UserArticle, common.Error)
 	DeleteArticleRating(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) common.Error
+
+	// Read states of saved articles, unread -> read -> archived
+	SetArticleState(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, state string) common.Error
+	SetArticlesState(ctx context.Context, userID uuid.UUID, articleIDs []uuid.UUID, state string) (int, common.Error)
+	CountArticlesByState(ctx context.Context, userID uuid.UUID) (map[string]int, common.Error)
+	OpenArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) (*article.Article, common.Error)
+
+	// Tags of the user, which are put on their saved articles
+	CreateTag(ctx context.Context, userID uuid.UUID, name string) (*article.Tag, common.Error)
+	ListTags(ctx context.Context, userID uuid.UUID) ([]*article.Tag, common.Error)
+	RenameTag(ctx context.Context, userID uuid.UUID, tagID uuid.UUID, name string) (*article.Tag, common.Error)
+	MergeTags(ctx context.Context, userID uuid.UUID, sourceID uuid.UUID, targetID uuid.UUID) (*article.Tag, common.Error)
+	DeleteTag(ctx context.Context, userID uuid.UUID, tagID uuid.UUID) common.Error
+	AddArticleTags(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, names []string) ([]*article.Tag, common.Error)
+	RemoveArticleTag(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, tagID uuid.UUID) common.Error
+
+	// Collections group saved articles in an order the user picks
+	CreateCollection(ctx context.Context, userID uuid.UUID, name string) (*article.Collection, common.Error)
+	GetCollection(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID) (*article.Collection, common.Error)
+	ListCollections(ctx context.Context, userID uuid.UUID) ([]*article.Collection, common.Error)
+	RenameCollection(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, name string) (*article.Collection, common.Error)
+	DeleteCollection(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID) common.Error
+	AddCollectionArticle(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID) common.Error
+	RemoveCollectionArticle(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID) common.Error
+	MoveCollectionArticle(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, articleID uuid.UUID, position int) common.Error
+	ListCollectionArticles(ctx context.Context, userID uuid.UUID, collectionID uuid.UUID, afterID uuid.UUID, limit int) ([]*article.SavedArticle, common.Error)
+
+	// Private notes and highlights on saved articles, deleted along with the saved article
+	CreateNote(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, body string) (*article.Note, common.Error)
+	ListNotes(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) ([]*article.Note, common.Error)
+	UpdateNote(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, noteID uuid.UUID, body string) (*article.Note, common.Error)
+	DeleteNote(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, noteID uuid.UUID) common.Error
+	CreateHighlight(ctx context.Context, userID uuid.UUID, highlight *article.Highlight) (*article.Highlight, common.Error)
+	ListHighlights(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) ([]*article.Highlight, common.Error)
+	UpdateHighlightComment(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, highlightID uuid.UUID, comment string) (*article.Highlight, common.Error)
+	DeleteHighlight(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, highlightID uuid.UUID) common.Error
+
+	// Workspace articles, editors can save and rate them and viewers can list them
+	CreateWorkspaceArticle(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID, url string) (*article.Article, common.Error)
+	ListWorkspaceArticles(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID, afterID uuid.UUID, limit int) ([]*article.WorkspaceArticle, common.Error)
+	RateWorkspaceArticle(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID, articleID uuid.UUID, rate int16) common.Error
 }
 
 // RecommendationService defines the interface for article recommendation operations.
@@ -60,6 +60,9 @@ func (w *MetadataWorker) fetchFail(ctx context.Context, retry *article.MetadataF
 func (w *MetadataWorker) runMetadataFetchJob(ctx context.Context) {
 	w.logger(ctx).Info().Msg("running metadata fetch job")
 
+	// Indexing runs in the same job, so that it never writes an article the fetch is updating
+	w.indexArticles(ctx)
+
 	retries, err := w.service.articleRepo.GetPendingMetadataFetchRetries(ctx)
 	if err != nil {
 		w.logger(ctx).Err(err).Msg("failed to get pending metadata fetch retries")
@@ -111,7 +114,63 @@ func (w *MetadataWorker) runMetadataFetchJob(ctx context.Context) {
 		if err != nil {
 			w.logger(ctx).Err(err).Str("retry_id", fmt.Sprintf("%d", retry.ID)).Msg("failed to update metadata fetch retry status to success")
 		}
+		if err := w.service.embedArticle(ctx, art); err != nil {
+			w.logger(ctx).Err(err).Str("article_id", retry.ArticleID.String()).Msg("failed to embed article")
+		}
+
+	}
+}
+
+// searchIndexBatchSize is the number of articles indexed for search at once
+const searchIndexBatchSize = 100
+
+// indexArticles indexes the articles saved before search existed, and new ones until their metadata is fetched.
+func (w *MetadataWorker) indexArticles(ctx context.Context) {
+	w.indexSearchVectors(ctx)
+	w.indexEmbeddings(ctx)
+}
+
+func (w *MetadataWorker) indexSearchVectors(ctx context.Context) {
+	for {
+		arts, err := w.service.articleRepo.ListUnindexedArticles(ctx, searchIndexBatchSize)
+		if err != nil {
+			w.logger(ctx).Err(err).Msg("failed to list articles to index for search")
+			return
+		}
+
+		for _, art := range arts {
+			// Only the index is written, the metadata may have been fetched since the article was listed
+			if err := w.service.articleRepo.UpdateArticleSearchVector(ctx, art.ID, article.SearchVector(art)); err != nil {
+				w.logger(ctx).Err(err).Str("article_id", art.ID.String()).Msg("failed to index article for search")
+				return
+			}
+		}
 
+		if len(arts) < searchIndexBatchSize {
+			return
+		}
+	}
+}
+
+// indexEmbeddings embeds the articles which have no embedding by the model of the embedder
+func (w *MetadataWorker) indexEmbeddings(ctx context.Context) {
+	for {
+		arts, err := w.service.articleRepo.ListUnembeddedArticles(ctx, w.service.embedder.Model(), searchIndexBatchSize)
+		if err != nil {
+			w.logger(ctx).Err(err).Msg("failed to list articles to embed")
+			return
+		}
+
+		for _, art := range arts {
+			if err := w.service.embedArticle(ctx, art); err != nil {
+				w.logger(ctx).Err(err).Str("article_id", art.ID.String()).Msg("failed to embed article")
+				return
+			}
+		}
+
+		if len(arts) < searchIndexBatchSize {
+			return
+		}
 	}
 }
 
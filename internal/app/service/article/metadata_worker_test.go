@@ -0,0 +1,25 @@
+package article
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+)
+
+func TestMetadataWorker_IndexSearchVectors(t *testing.T) {
+	t.Parallel()
+	repo := newFakeArticleRepository()
+	for i := 0; i < searchIndexBatchSize+1; i++ {
+		repo.unindexed = append(repo.unindexed, &article.Article{ID: uuid.New(), Title: "Go"})
+	}
+	w := &MetadataWorker{service: &articleService{articleRepo: repo}}
+
+	// Every batch is indexed, and only the search vector is written
+	w.indexSearchVectors(context.Background())
+	assert.Len(t, repo.vectors, searchIndexBatchSize+1)
+	assert.Equal(t, article.SearchVector(repo.unindexed[0]), repo.vectors[repo.unindexed[0].ID])
+}
@@ -0,0 +1,97 @@
+package article
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// CreateNote adds a markdown note to a saved article.
+func (s *articleService) CreateNote(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, body string) (*article.Note, common.Error) {
+	body, err := normalizeNoteBody(body)
+	if err != nil {
+		return nil, err
+	}
+	return s.articleRepo.CreateNote(ctx, userID, articleID, body)
+}
+
+// attachNotes sets the notes and highlights of the saved articles of the user
+func (s *articleService) attachNotes(ctx context.Context, userID uuid.UUID, articles []*article.SavedArticle) common.Error {
+	articleIDs := make([]uuid.UUID, 0, len(articles))
+	for _, art := range articles {
+		articleIDs = append(articleIDs, art.ID)
+	}
+
+	notes, err := s.articleRepo.ListUserArticleNotes(ctx, userID, articleIDs)
+	if err != nil {
+		return err
+	}
+	highlights, err := s.articleRepo.ListUserArticleHighlights(ctx, userID, articleIDs)
+	if err != nil {
+		return err
+	}
+	for _, art := range articles {
+		art.Notes = notes[art.ID]
+		art.Highlights = highlights[art.ID]
+	}
+	return nil
+}
+
+// ListNotes lists the notes on a saved article, oldest first.
+func (s *articleService) ListNotes(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) ([]*article.Note, common.Error) {
+	if _, err := s.articleRepo.GetUserArticle(ctx, userID, articleID); err != nil {
+		return nil, err
+	}
+	return s.articleRepo.ListNotes(ctx, userID, articleID)
+}
+
+func (s *articleService) UpdateNote(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, noteID uuid.UUID, body string) (*article.Note, common.Error) {
+	body, err := normalizeNoteBody(body)
+	if err != nil {
+		return nil, err
+	}
+	return s.articleRepo.UpdateNote(ctx, userID, articleID, noteID, body)
+}
+
+func (s *articleService) DeleteNote(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, noteID uuid.UUID) common.Error {
+	return s.articleRepo.DeleteNote(ctx, userID, articleID, noteID)
+}
+
+// CreateHighlight adds a highlight to the saved article of highlight.ArticleID.
+func (s *articleService) CreateHighlight(ctx context.Context, userID uuid.UUID, highlight *article.Highlight) (*article.Highlight, common.Error) {
+	if err := highlight.Validate(); err != nil {
+		return nil, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+	return s.articleRepo.CreateHighlight(ctx, userID, highlight)
+}
+
+// ListHighlights lists the highlights on a saved article in the order of their positions.
+func (s *articleService) ListHighlights(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) ([]*article.Highlight, common.Error) {
+	if _, err := s.articleRepo.GetUserArticle(ctx, userID, articleID); err != nil {
+		return nil, err
+	}
+	return s.articleRepo.ListHighlights(ctx, userID, articleID)
+}
+
+// UpdateHighlightComment changes the comment on a highlight, the highlighted text stays as it is.
+func (s *articleService) UpdateHighlightComment(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, highlightID uuid.UUID, comment string) (*article.Highlight, common.Error) {
+	if err := article.ValidateHighlightComment(comment); err != nil {
+		return nil, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+	return s.articleRepo.UpdateHighlightComment(ctx, userID, articleID, highlightID, comment)
+}
+
+func (s *articleService) DeleteHighlight(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, highlightID uuid.UUID) common.Error {
+	return s.articleRepo.DeleteHighlight(ctx, userID, articleID, highlightID)
+}
+
+func normalizeNoteBody(body string) (string, common.Error) {
+	body, err := article.NormalizeNoteBody(body)
+	if err != nil {
+		return "", common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+	return body, nil
+}
@@ -0,0 +1,122 @@
+package article
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+func TestArticleService_Note(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID, articleID := uuid.New(), uuid.New()
+	repo.saved[userID] = []uuid.UUID{articleID}
+
+	// Bodies are trimmed
+	note, cerr := s.CreateNote(ctx, userID, articleID, "  # Summary\nworth a read \n")
+	require.NoError(t, cerr)
+	assert.Equal(t, "# Summary\nworth a read", note.Body)
+
+	note, cerr = s.UpdateNote(ctx, userID, articleID, note.ID, "updated")
+	require.NoError(t, cerr)
+	assert.Equal(t, "updated", repo.notes[note.ID].Body)
+
+	for _, body := range []string{" \n ", strings.Repeat("a", article.NoteBodyMaxLength+1)} {
+		_, cerr = s.CreateNote(ctx, userID, articleID, body)
+		assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+		_, cerr = s.UpdateNote(ctx, userID, articleID, note.ID, body)
+		assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+	}
+	assert.Equal(t, "updated", repo.notes[note.ID].Body)
+
+	// Notes can only be put on saved articles
+	_, cerr = s.CreateNote(ctx, userID, uuid.New(), "note")
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+
+	require.NoError(t, s.DeleteNote(ctx, userID, articleID, note.ID))
+	cerr = s.DeleteNote(ctx, userID, articleID, note.ID)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+}
+
+func TestArticleService_NoteOfAnotherUser(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	// Both users saved the same article
+	userID, otherUserID, articleID := uuid.New(), uuid.New(), uuid.New()
+	repo.saved[userID] = []uuid.UUID{articleID}
+	repo.saved[otherUserID] = []uuid.UUID{articleID}
+	note, cerr := s.CreateNote(ctx, userID, articleID, "mine")
+	require.NoError(t, cerr)
+	highlight, cerr := s.CreateHighlight(ctx, userID, &article.Highlight{ArticleID: articleID, Text: "quote"})
+	require.NoError(t, cerr)
+
+	// The other user can neither edit nor delete them
+	_, cerr = s.UpdateNote(ctx, otherUserID, articleID, note.ID, "theirs")
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+	cerr = s.DeleteNote(ctx, otherUserID, articleID, note.ID)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+	_, cerr = s.UpdateHighlightComment(ctx, otherUserID, articleID, highlight.ID, "theirs")
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+	cerr = s.DeleteHighlight(ctx, otherUserID, articleID, highlight.ID)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+
+	// Nor can a note be reached through another article
+	otherArticleID := uuid.New()
+	repo.saved[userID] = append(repo.saved[userID], otherArticleID)
+	_, cerr = s.UpdateNote(ctx, userID, otherArticleID, note.ID, "moved")
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+
+	assert.Equal(t, "mine", repo.notes[note.ID].Body)
+	assert.Empty(t, repo.highlights[highlight.ID].Comment)
+}
+
+func TestArticleService_Highlight(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID, articleID := uuid.New(), uuid.New()
+	repo.saved[userID] = []uuid.UUID{articleID}
+
+	highlight, cerr := s.CreateHighlight(ctx, userID, &article.Highlight{
+		ArticleID: articleID,
+		Text:      "quote",
+		Position:  &article.HighlightPosition{Start: 10, End: 15},
+	})
+	require.NoError(t, cerr)
+
+	// Only the comment changes, the text stays as it is
+	highlight, cerr = s.UpdateHighlightComment(ctx, userID, articleID, highlight.ID, "agreed")
+	require.NoError(t, cerr)
+	assert.Equal(t, "quote", highlight.Text)
+	assert.Equal(t, "agreed", highlight.Comment)
+	_, cerr = s.UpdateHighlightComment(ctx, userID, articleID, highlight.ID, strings.Repeat("a", article.HighlightCommentMaxLength+1))
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+
+	for _, invalid := range []*article.Highlight{
+		{ArticleID: articleID, Text: " "},
+		{ArticleID: articleID, Text: "quote", Position: &article.HighlightPosition{Start: 5, End: 5}},
+	} {
+		_, cerr = s.CreateHighlight(ctx, userID, invalid)
+		assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+	}
+	_, cerr = s.CreateHighlight(ctx, userID, &article.Highlight{ArticleID: uuid.New(), Text: "quote"})
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+
+	require.NoError(t, s.DeleteHighlight(ctx, userID, articleID, highlight.ID))
+	assert.Empty(t, repo.highlights)
+}
@@ -0,0 +1,79 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// SetArticleState moves a saved article to the read state.
+func (s *articleService) SetArticleState(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, state string) common.Error {
+	if err := article.ValidateReadState(state); err != nil {
+		return common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+
+	updated, err := s.articleRepo.UpdateUserArticleStates(ctx, userID, []uuid.UUID{articleID}, state)
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("user article not found"))
+	}
+	return nil
+}
+
+// SetArticlesState moves saved articles to the read state at once and returns how many it moved.
+// Articles the user did not save are skipped. The change is recorded in the audit log.
+func (s *articleService) SetArticlesState(ctx context.Context, userID uuid.UUID, articleIDs []uuid.UUID, state string) (int, common.Error) {
+	if len(articleIDs) == 0 || len(articleIDs) > article.MaxBulkStateChange {
+		msg := fmt.Sprintf("1 to %d articles can be changed at once", article.MaxBulkStateChange)
+		return 0, common.NewError(common.ErrorCodeParameterInvalid, errors.New("invalid number of articles"), common.WithMsg(msg))
+	}
+	if err := article.ValidateReadState(state); err != nil {
+		return 0, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+
+	updated, err := s.articleRepo.UpdateUserArticleStates(ctx, userID, articleIDs, state)
+	s.auditor.Record(ctx, audit.NewEvent(audit.ActionArticleStates, userID, "", "", err))
+	if err != nil {
+		return 0, err
+	}
+	return int(updated), nil
+}
+
+// CountArticlesByState returns the number of saved articles of the user in every read state.
+func (s *articleService) CountArticlesByState(ctx context.Context, userID uuid.UUID) (map[string]int, common.Error) {
+	counts, err := s.articleRepo.CountUserArticlesByState(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, state := range []string{article.ReadStateUnread, article.ReadStateRead, article.ReadStateArchived} {
+		if _, ok := counts[state]; !ok {
+			counts[state] = 0
+		}
+	}
+	return counts, nil
+}
+
+// OpenArticle returns a saved article to be opened, which marks it read if it was unread.
+// Archived articles stay archived.
+func (s *articleService) OpenArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) (*article.Article, common.Error) {
+	userArticle, err := s.articleRepo.GetUserArticle(ctx, userID, articleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if userArticle.State == article.ReadStateUnread {
+		if _, err := s.articleRepo.UpdateUserArticleStates(ctx, userID, []uuid.UUID{articleID}, article.ReadStateRead); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.articleRepo.GetArticleByID(ctx, articleID)
+}
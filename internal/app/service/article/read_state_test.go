@@ -0,0 +1,83 @@
+package article
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+func TestArticleService_SetArticleState(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID, articleID := uuid.New(), uuid.New()
+	repo.saved[userID] = []uuid.UUID{articleID}
+
+	// unread -> read -> archived -> unread
+	for _, state := range []string{article.ReadStateRead, article.ReadStateArchived, article.ReadStateUnread} {
+		require.NoError(t, s.SetArticleState(ctx, userID, articleID, state))
+		assert.Equal(t, state, repo.states[articleID])
+	}
+
+	cerr := s.SetArticleState(ctx, userID, articleID, "starred")
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+	cerr = s.SetArticleState(ctx, uuid.New(), articleID, article.ReadStateRead)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+	assert.Equal(t, article.ReadStateUnread, repo.states[articleID])
+}
+
+func TestArticleService_SetArticlesState(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	auditor := &fakeAuditRecorder{}
+	s := &articleService{articleRepo: repo, auditor: auditor}
+
+	userID, first, second := uuid.New(), uuid.New(), uuid.New()
+	repo.saved[userID] = []uuid.UUID{first, second}
+
+	// Articles the user did not save are skipped
+	updated, cerr := s.SetArticlesState(ctx, userID, []uuid.UUID{first, second, uuid.New()}, article.ReadStateArchived)
+	require.NoError(t, cerr)
+	assert.Equal(t, 2, updated)
+	require.Len(t, auditor.events, 1)
+	assert.Equal(t, audit.ActionArticleStates, auditor.events[0].Action)
+	assert.Equal(t, userID, auditor.events[0].ActorID)
+	assert.True(t, auditor.events[0].IsSuccess())
+
+	_, cerr = s.SetArticlesState(ctx, userID, nil, article.ReadStateRead)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+	_, cerr = s.SetArticlesState(ctx, userID, make([]uuid.UUID, article.MaxBulkStateChange+1), article.ReadStateRead)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+}
+
+func TestArticleService_OpenArticle(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID, unread, archived := uuid.New(), uuid.New(), uuid.New()
+	repo.saved[userID] = []uuid.UUID{unread, archived}
+	repo.states[archived] = article.ReadStateArchived
+
+	// Opening marks unread articles read, archived articles stay archived
+	_, cerr := s.OpenArticle(ctx, userID, unread)
+	require.NoError(t, cerr)
+	assert.Equal(t, article.ReadStateRead, repo.states[unread])
+	_, cerr = s.OpenArticle(ctx, userID, archived)
+	require.NoError(t, cerr)
+	assert.Equal(t, article.ReadStateArchived, repo.states[archived])
+
+	_, cerr = s.OpenArticle(ctx, uuid.New(), unread)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+}
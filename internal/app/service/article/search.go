@@ -0,0 +1,78 @@
+package article
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// SearchArticles searches the saved articles of the user in the mode, best matches first, with their tags
+// and the keyword matches highlighted.
+func (s *articleService) SearchArticles(ctx context.Context, userID uuid.UUID, q string, mode string, limit int) ([]*article.SearchResult, common.Error) {
+	if mode == "" {
+		mode = article.SearchModeKeyword
+	}
+	if err := article.ValidateSearchMode(mode); err != nil {
+		return nil, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+	query, err := article.ParseSearchQuery(q)
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+
+	var results []*article.SearchResult
+	var cerr common.Error
+	switch mode {
+	case article.SearchModeKeyword:
+		results, cerr = s.articleRepo.SearchArticles(ctx, userID, query.TSQuery(), limit)
+	case article.SearchModeSemantic:
+		results, cerr = s.searchSimilarArticles(ctx, userID, q, limit)
+	case article.SearchModeHybrid:
+		var keyword, similar []*article.SearchResult
+		if keyword, cerr = s.articleRepo.SearchArticles(ctx, userID, query.TSQuery(), limit); cerr != nil {
+			return nil, cerr
+		}
+		if similar, cerr = s.searchSimilarArticles(ctx, userID, q, limit); cerr != nil {
+			return nil, cerr
+		}
+		results = article.FuseSearchResults(limit, keyword, similar)
+	}
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	articles := make([]*article.SavedArticle, 0, len(results))
+	for _, result := range results {
+		articles = append(articles, &result.SavedArticle)
+	}
+	if cerr := s.attachTags(ctx, userID, articles); cerr != nil {
+		return nil, cerr
+	}
+
+	for _, result := range results {
+		result.TitleHighlight = query.Highlight(result.Title)
+		result.Snippet = query.Snippet(result.Description)
+	}
+	return results, nil
+}
+
+// searchSimilarArticles returns the saved articles of the user which are about what q is about, most similar first
+func (s *articleService) searchSimilarArticles(ctx context.Context, userID uuid.UUID, q string, limit int) ([]*article.SearchResult, common.Error) {
+	embedding, err := s.embedder.Embed(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return s.articleRepo.SearchSimilarArticles(ctx, userID, s.embedder.Model(), embedding, article.SearchMinSimilarity, limit)
+}
+
+// embedArticle stores the embedding of what the article says, for searching it semantically
+func (s *articleService) embedArticle(ctx context.Context, art *article.Article) common.Error {
+	embedding, err := s.embedder.Embed(ctx, art.Title+"\n"+art.Description)
+	if err != nil {
+		return err
+	}
+	return s.articleRepo.UpsertArticleEmbedding(ctx, art.ID, s.embedder.Model(), embedding)
+}
@@ -2,22 +2,33 @@ package article
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
 
+	"github.com/sappy5678/DeeliAi/internal/adapter/embedder"
 	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
 	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/workspace"
 )
 
 type articleService struct {
 	RecommendationService
 	articleRepo    ArticleRepository
+	workspaces     WorkspaceAuthorizer
+	auditor        AuditRecorder
+	embedder       embedder.Embedder
 	metadataWorker *MetadataWorker
+	importWorker   *ImportWorker
 }
 
-func NewArticleService(ctx context.Context, articleRepo ArticleRepository) ArticleService {
+func NewArticleService(ctx context.Context, articleRepo ArticleRepository, workspaces WorkspaceAuthorizer, auditor AuditRecorder, embedder embedder.Embedder) ArticleService {
 	service := &articleService{
 		articleRepo: articleRepo,
+		workspaces:  workspaces,
+		auditor:     auditor,
+		embedder:    embedder,
 	}
 
 	recommendationService := NewRecommendationService(ctx, articleRepo)
@@ -26,18 +37,37 @@ func NewArticleService(ctx context.Context, articleRepo ArticleRepository) Artic
 	worker := NewMetadataWorker(ctx, service)
 
 	service.metadataWorker = worker
+	service.importWorker = NewImportWorker(ctx, service)
 
 	return service
 }
 
 func (s *articleService) CreateArticle(ctx context.Context, userID uuid.UUID, url string) (*article.Article, common.Error) {
+	return s.saveArticle(ctx, url, func(art *article.Article) common.Error {
+		_, err := s.articleRepo.CreateUserArticle(ctx, userID, art.ID)
+		return err
+	})
+}
+
+// CreateWorkspaceArticle saves an article to the workspace, which needs the editor role.
+func (s *articleService) CreateWorkspaceArticle(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID, url string) (*article.Article, common.Error) {
+	if _, err := s.workspaces.Authorize(ctx, workspaceID, userID, workspace.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	return s.saveArticle(ctx, url, func(art *article.Article) common.Error {
+		return s.articleRepo.CreateWorkspaceArticle(ctx, workspaceID, art.ID, userID)
+	})
+}
+
+// saveArticle creates the article of the URL, links it with link and queues fetching its metadata
+func (s *articleService) saveArticle(ctx context.Context, url string, link func(*article.Article) common.Error) (*article.Article, common.Error) {
 	art, err := s.articleRepo.CreateArticle(ctx, url)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = s.articleRepo.CreateUserArticle(ctx, userID, art.ID)
-	if err != nil {
+	if err = link(art); err != nil {
 		return nil, err
 	}
 
@@ -49,12 +79,156 @@ func (s *articleService) CreateArticle(ctx context.Context, userID uuid.UUID, ur
 	return art, nil
 }
 
-func (s *articleService) ListArticles(ctx context.Context, userID uuid.UUID, afterID uuid.UUID, limit int) ([]*article.Article, common.Error) {
-	return s.articleRepo.ListArticles(ctx, userID, afterID, limit)
+// GetArticle returns the article the user saved with its tags and how fetching its metadata went.
+func (s *articleService) GetArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) (*article.ArticleDetail, common.Error) {
+	saved, err := s.articleRepo.GetSavedArticle(ctx, userID, articleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachTags(ctx, userID, []*article.SavedArticle{saved}); err != nil {
+		return nil, err
+	}
+
+	detail := &article.ArticleDetail{SavedArticle: *saved}
+	detail.Fetch, err = s.articleRepo.GetMetadataFetchRetry(ctx, articleID)
+	if err != nil && !common.IsErrorCode(err, common.ErrorCodeResourceNotFound) {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// ListArticles lists the saved articles of the user which match the filter in the order of the sort, with their tags.
+func (s *articleService) ListArticles(ctx context.Context, userID uuid.UUID, filter article.ArticleFilter, sort article.ArticleSort, cursor string, limit int) ([]*article.SavedArticle, string, common.Error) {
+	if err := filter.Validate(); err != nil {
+		return nil, "", common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+	if err := sort.Validate(); err != nil {
+		return nil, "", common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+	if limit < 1 {
+		msg := "limit must be positive"
+		return nil, "", common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+
+	var after *article.ArticleCursor
+	if cursor != "" {
+		var err error
+		if after, err = article.DecodeArticleCursor(cursor); err != nil {
+			return nil, "", common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+		}
+		if after.Sort != sort {
+			msg := "cursor is of another sort"
+			return nil, "", common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+		}
+	}
+
+	// One more article tells whether there is a next page
+	articles, err := s.articleRepo.ListArticles(ctx, userID, filter, sort, after, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	nextCursor := ""
+	if len(articles) > limit {
+		articles = articles[:limit]
+		nextCursor = article.NewArticleCursor(sort, articles[limit-1]).Encode()
+	}
+
+	if err := s.attachTags(ctx, userID, articles); err != nil {
+		return nil, "", err
+	}
+	return articles, nextCursor, nil
+}
+
+// ArticleCursor returns the cursor ListArticles continues with after the saved article, in the order of the sort.
+func (s *articleService) ArticleCursor(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, sort article.ArticleSort) (string, common.Error) {
+	if err := sort.Validate(); err != nil {
+		return "", common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+
+	saved, err := s.articleRepo.GetSavedArticle(ctx, userID, articleID)
+	if err != nil {
+		if common.IsErrorCode(err, common.ErrorCodeResourceNotFound) {
+			msg := "after is not a saved article"
+			return "", common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+		}
+		return "", err
+	}
+	return article.NewArticleCursor(sort, saved).Encode(), nil
+}
+
+// ListWorkspaceArticles lists the articles of the workspace with the ratings of its members, which needs the viewer role.
+func (s *articleService) ListWorkspaceArticles(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID, afterID uuid.UUID, limit int) ([]*article.WorkspaceArticle, common.Error) {
+	if _, err := s.workspaces.Authorize(ctx, workspaceID, userID, workspace.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.articleRepo.ListWorkspaceArticles(ctx, workspaceID, userID, afterID, limit)
+}
+
+// exportBatchSize is how many exported articles get their tags, notes and highlights at once
+const exportBatchSize = 100
+
+// ExportArticles calls fn with every article the user saved which matches the filter, oldest first, with its tags.
+// Articles are read in batches of exportBatchSize, so the whole collection never has to fit in memory.
+// The outcome of the export is recorded in the audit log.
+func (s *articleService) ExportArticles(ctx context.Context, userID uuid.UUID, filter article.ArticleFilter, fn func(*article.SavedArticle) common.Error) common.Error {
+	if err := filter.Validate(); err != nil {
+		return common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+	err := s.exportArticles(ctx, userID, filter, false, fn)
+	s.auditor.Record(ctx, audit.NewEvent(audit.ActionArticleExport, userID, "", "", err))
+	return err
+}
+
+// ExportUserArticles calls fn with every article the user saved, oldest first, with its tags, notes and highlights.
+// The export is recorded in the audit log as an export of the account.
+func (s *articleService) ExportUserArticles(ctx context.Context, userID uuid.UUID, fn func(*article.SavedArticle) common.Error) common.Error {
+	err := s.exportArticles(ctx, userID, article.ArticleFilter{}, true, fn)
+	s.auditor.Record(ctx, audit.NewEvent(audit.ActionAccountExport, userID, audit.TargetUser, userID.String(), err))
+	return err
+}
+
+// exportArticles reads the saved articles in batches of exportBatchSize, attaching their notes and highlights as well when withNotes
+func (s *articleService) exportArticles(ctx context.Context, userID uuid.UUID, filter article.ArticleFilter, withNotes bool, fn func(*article.SavedArticle) common.Error) common.Error {
+	batch := make([]*article.SavedArticle, 0, exportBatchSize)
+	flush := func() common.Error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.attachTags(ctx, userID, batch); err != nil {
+			return err
+		}
+		if withNotes {
+			if err := s.attachNotes(ctx, userID, batch); err != nil {
+				return err
+			}
+		}
+		for _, saved := range batch {
+			if err := fn(saved); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err := s.articleRepo.IterateUserArticles(ctx, userID, filter, func(saved *article.SavedArticle) common.Error {
+		batch = append(batch, saved)
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+		return flush()
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
 }
 
+// DeleteArticle removes the article from the saved articles of the user, which is recorded in the audit log.
 func (s *articleService) DeleteArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID) common.Error {
-	return s.articleRepo.DeleteUserArticle(ctx, userID, articleID)
+	err := s.articleRepo.DeleteUserArticle(ctx, userID, articleID)
+	s.auditor.Record(ctx, audit.NewEvent(audit.ActionArticleDelete, userID, audit.TargetArticle, articleID.String(), err))
+	return err
 }
 
 func (s *articleService) RateArticle(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, rate int16) common.Error {
@@ -81,3 +255,21 @@ func (s *articleService) DeleteArticleRating(ctx context.Context, userID uuid.UU
 	}
 	return s.articleRepo.DeleteUserArticleRate(ctx, userID, articleID)
 }
+
+// RateWorkspaceArticle sets the rating the user gives an article of the workspace, which needs the editor role.
+// Every member has their own rating, the workspace lists their average.
+func (s *articleService) RateWorkspaceArticle(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID, articleID uuid.UUID, rate int16) common.Error {
+	if _, err := s.workspaces.Authorize(ctx, workspaceID, userID, workspace.RoleEditor); err != nil {
+		return err
+	}
+
+	workspaceArticle, err := s.articleRepo.GetWorkspaceArticle(ctx, workspaceID, userID, articleID)
+	if err != nil {
+		return err
+	}
+	if err := workspaceArticle.Rating(rate); err != nil {
+		return common.NewError(common.ErrorCodeParameterInvalid, err)
+	}
+
+	return s.articleRepo.UpsertWorkspaceArticleRating(ctx, workspaceID, articleID, userID, workspaceArticle.Rate)
+}
@@ -0,0 +1,217 @@
+package article
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+func TestArticleService_ListArticles(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID, articleID := uuid.New(), uuid.New()
+	repo.saved[userID] = []uuid.UUID{articleID, uuid.New()}
+	_, cerr := s.AddArticleTags(ctx, userID, articleID, []string{"Go"})
+	require.NoError(t, cerr)
+
+	articles, nextCursor, cerr := s.ListArticles(ctx, userID, article.ArticleFilter{Tags: []string{"GO"}}, article.ArticleSort{}, "", 10)
+	require.NoError(t, cerr)
+	require.Len(t, articles, 2)
+	assert.Empty(t, nextCursor)
+	assert.Equal(t, []string{"Go"}, tagNames(articles[0].Tags))
+	assert.Empty(t, articles[1].Tags)
+	assert.Equal(t, article.ArticleFilter{Tags: []string{"go"}, TagMatch: article.TagMatchAll}, repo.filters[0])
+
+	_, _, cerr = s.ListArticles(ctx, userID, article.ArticleFilter{TagMatch: "some"}, article.ArticleSort{}, "", 10)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+	_, _, cerr = s.ListArticles(ctx, userID, article.ArticleFilter{}, article.ArticleSort{}, "", 0)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+}
+
+func TestArticleService_ListArticlesCursor(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID := uuid.New()
+	repo.saved[userID] = []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	// A full page has a cursor starting after its last article, in the same sort
+	sort := article.ArticleSort{By: article.SortByTitle}
+	articles, nextCursor, cerr := s.ListArticles(ctx, userID, article.ArticleFilter{}, sort, "", 2)
+	require.NoError(t, cerr)
+	require.Len(t, articles, 2)
+	cursor, err := article.DecodeArticleCursor(nextCursor)
+	require.NoError(t, err)
+	assert.Equal(t, article.ArticleSort{By: article.SortByTitle, Order: article.SortOrderAsc}, cursor.Sort)
+	assert.Equal(t, articles[1].ID, cursor.ID)
+
+	// The last page has no cursor
+	articles, lastCursor, cerr := s.ListArticles(ctx, userID, article.ArticleFilter{}, sort, nextCursor, 2)
+	require.NoError(t, cerr)
+	require.Len(t, articles, 1)
+	assert.Equal(t, repo.saved[userID][2], articles[0].ID)
+	assert.Empty(t, lastCursor)
+
+	// Neither does a last page which is full
+	articles, lastCursor, cerr = s.ListArticles(ctx, userID, article.ArticleFilter{}, sort, "", 3)
+	require.NoError(t, cerr)
+	assert.Len(t, articles, 3)
+	assert.Empty(t, lastCursor)
+}
+
+func TestArticleService_ListArticlesInvalidCursor(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID := uuid.New()
+	repo.saved[userID] = []uuid.UUID{uuid.New(), uuid.New()}
+	sort := article.ArticleSort{By: article.SortByTitle}
+	_, nextCursor, cerr := s.ListArticles(ctx, userID, article.ArticleFilter{}, sort, "", 1)
+	require.NoError(t, cerr)
+	require.NotEmpty(t, nextCursor)
+
+	for name, tc := range map[string]struct {
+		sort   article.ArticleSort
+		cursor string
+	}{
+		"not base64":              {sort: sort, cursor: "garbage!"},
+		"not a cursor":            {sort: sort, cursor: base64.RawURLEncoding.EncodeToString([]byte(`{"b":"title"}`))},
+		"cursor of another sort":  {sort: article.ArticleSort{}, cursor: nextCursor},
+		"cursor of another order": {sort: article.ArticleSort{By: article.SortByTitle, Order: article.SortOrderDesc}, cursor: nextCursor},
+	} {
+		_, _, cerr := s.ListArticles(ctx, userID, article.ArticleFilter{}, tc.sort, tc.cursor, 1)
+		assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid), name)
+	}
+}
+
+func TestArticleService_ArticleCursor(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID := uuid.New()
+	repo.saved[userID] = []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+
+	// Listing after an article ID continues like its cursor
+	cursor, cerr := s.ArticleCursor(ctx, userID, repo.saved[userID][0], article.ArticleSort{})
+	require.NoError(t, cerr)
+	articles, _, cerr := s.ListArticles(ctx, userID, article.ArticleFilter{}, article.ArticleSort{}, cursor, 10)
+	require.NoError(t, cerr)
+	assert.Equal(t, repo.saved[userID][1:], savedArticleIDs(articles))
+
+	_, cerr = s.ArticleCursor(ctx, userID, uuid.New(), article.ArticleSort{})
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+	_, cerr = s.ArticleCursor(ctx, userID, repo.saved[userID][0], article.ArticleSort{By: "url"})
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+}
+
+func TestArticleService_GetArticle(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID := uuid.New()
+	articleID := uuid.New()
+	repo.saved[userID] = []uuid.UUID{articleID}
+	require.NoError(t, repo.AddUserArticleTags(ctx, userID, articleID, []string{"Go"}))
+
+	// Articles never queued for fetching have no fetch status
+	detail, cerr := s.GetArticle(ctx, userID, articleID)
+	require.NoError(t, cerr)
+	assert.Equal(t, articleID, detail.ID)
+	assert.Equal(t, []string{"Go"}, tagNames(detail.Tags))
+	assert.Nil(t, detail.Fetch)
+
+	_, cerr = s.GetArticle(ctx, uuid.New(), articleID)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+}
+
+func TestArticleService_ExportUserArticles(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	auditor := &fakeAuditRecorder{}
+	s := &articleService{articleRepo: repo, auditor: auditor}
+
+	userID, otherUserID := uuid.New(), uuid.New()
+	noted, bare := uuid.New(), uuid.New()
+	repo.saved[userID] = []uuid.UUID{noted, bare}
+	repo.saved[otherUserID] = []uuid.UUID{noted}
+	note, cerr := repo.CreateNote(ctx, userID, noted, "Worth a second read")
+	require.NoError(t, cerr)
+	highlight, cerr := repo.CreateHighlight(ctx, userID, &article.Highlight{ArticleID: noted, Text: "quoted"})
+	require.NoError(t, cerr)
+	_, cerr = repo.CreateNote(ctx, otherUserID, noted, "Someone else's")
+	require.NoError(t, cerr)
+
+	var exported []*article.SavedArticle
+	require.NoError(t, s.ExportUserArticles(ctx, userID, func(saved *article.SavedArticle) common.Error {
+		exported = append(exported, saved)
+		return nil
+	}))
+	require.Len(t, exported, 2)
+	assert.Equal(t, []*article.Note{note}, exported[0].Notes)
+	assert.Equal(t, []*article.Highlight{highlight}, exported[0].Highlights)
+	assert.Empty(t, exported[1].Notes)
+	assert.Empty(t, exported[1].Highlights)
+
+	// The filtered export leaves notes and highlights out
+	require.NoError(t, s.ExportArticles(ctx, userID, article.ArticleFilter{}, func(saved *article.SavedArticle) common.Error {
+		assert.Empty(t, saved.Notes)
+		return nil
+	}))
+
+	require.Len(t, auditor.events, 2)
+	assert.Equal(t, audit.ActionAccountExport, auditor.events[0].Action)
+	assert.Equal(t, userID.String(), auditor.events[0].TargetID)
+	assert.Equal(t, audit.ActionArticleExport, auditor.events[1].Action)
+}
+
+func TestArticleService_ExportArticles(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo, auditor: &fakeAuditRecorder{}}
+
+	// More articles than a batch, the last one tagged
+	userID := uuid.New()
+	for i := 0; i < exportBatchSize+1; i++ {
+		repo.saved[userID] = append(repo.saved[userID], uuid.New())
+	}
+	last := repo.saved[userID][exportBatchSize]
+	require.NoError(t, repo.AddUserArticleTags(ctx, userID, last, []string{"Go"}))
+
+	var exported []*article.SavedArticle
+	cerr := s.ExportArticles(ctx, userID, article.ArticleFilter{Tags: []string{"GO"}}, func(saved *article.SavedArticle) common.Error {
+		exported = append(exported, saved)
+		return nil
+	})
+	require.NoError(t, cerr)
+	require.Len(t, exported, exportBatchSize+1)
+	assert.Equal(t, last, exported[exportBatchSize].ID)
+	assert.Equal(t, []string{"Go"}, tagNames(exported[exportBatchSize].Tags))
+	assert.Equal(t, []string{"go"}, repo.filters[0].Tags, "the filter is validated")
+
+	cerr = s.ExportArticles(ctx, userID, article.ArticleFilter{TagMatch: "some"}, func(*article.SavedArticle) common.Error {
+		t.Fatal("no article is exported with an invalid filter")
+		return nil
+	})
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+}
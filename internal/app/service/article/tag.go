@@ -0,0 +1,122 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// maxTagsPerRequest is the number of tags which can be put on an article at once
+const maxTagsPerRequest = 20
+
+func (s *articleService) CreateTag(ctx context.Context, userID uuid.UUID, name string) (*article.Tag, common.Error) {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.articleRepo.CreateTag(ctx, userID, name)
+}
+
+func (s *articleService) ListTags(ctx context.Context, userID uuid.UUID) ([]*article.Tag, common.Error) {
+	return s.articleRepo.ListTags(ctx, userID)
+}
+
+// RenameTag renames the tag on every article carrying it.
+// Changing only the case of the name is allowed, taking the name of another tag is a conflict to be solved by merging.
+func (s *articleService) RenameTag(ctx context.Context, userID uuid.UUID, tagID uuid.UUID, name string) (*article.Tag, common.Error) {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.articleRepo.UpdateTagName(ctx, userID, tagID, name)
+}
+
+// MergeTags moves the articles of the source tag to the target tag and deletes the source tag.
+// It returns the target tag afterwards.
+func (s *articleService) MergeTags(ctx context.Context, userID uuid.UUID, sourceID uuid.UUID, targetID uuid.UUID) (*article.Tag, common.Error) {
+	if sourceID == targetID {
+		return nil, common.NewError(common.ErrorCodeParameterInvalid, errors.New("merging a tag into itself"), common.WithMsg("a tag cannot be merged into itself"))
+	}
+
+	if _, err := s.articleRepo.GetTag(ctx, userID, targetID); err != nil {
+		return nil, err
+	}
+	if err := s.articleRepo.MergeTags(ctx, userID, sourceID, targetID); err != nil {
+		return nil, err
+	}
+	return s.articleRepo.GetTag(ctx, userID, targetID)
+}
+
+// DeleteTag deletes the tag and takes it off every article carrying it.
+func (s *articleService) DeleteTag(ctx context.Context, userID uuid.UUID, tagID uuid.UUID) common.Error {
+	return s.articleRepo.DeleteTag(ctx, userID, tagID)
+}
+
+// AddArticleTags puts the tags of the names on a saved article, creating the tags the user does not have yet.
+// It returns every tag the article carries afterwards.
+func (s *articleService) AddArticleTags(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, names []string) ([]*article.Tag, common.Error) {
+	if len(names) == 0 || len(names) > maxTagsPerRequest {
+		return nil, common.NewError(common.ErrorCodeParameterInvalid, errors.New("invalid number of tags"), common.WithMsg("1 to 20 tags can be added at once"))
+	}
+
+	seen := make(map[string]bool, len(names))
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		name, err := normalizeTagName(name)
+		if err != nil {
+			return nil, err
+		}
+		if key := strings.ToLower(name); !seen[key] {
+			seen[key] = true
+			normalized = append(normalized, name)
+		}
+	}
+
+	if _, err := s.articleRepo.GetUserArticle(ctx, userID, articleID); err != nil {
+		return nil, err
+	}
+	if err := s.articleRepo.AddUserArticleTags(ctx, userID, articleID, normalized); err != nil {
+		return nil, err
+	}
+
+	tags, err := s.articleRepo.ListUserArticleTags(ctx, userID, []uuid.UUID{articleID})
+	if err != nil {
+		return nil, err
+	}
+	return tags[articleID], nil
+}
+
+// RemoveArticleTag takes the tag off a saved article.
+func (s *articleService) RemoveArticleTag(ctx context.Context, userID uuid.UUID, articleID uuid.UUID, tagID uuid.UUID) common.Error {
+	return s.articleRepo.RemoveUserArticleTag(ctx, userID, articleID, tagID)
+}
+
+// attachTags sets the tags of the saved articles of the user
+func (s *articleService) attachTags(ctx context.Context, userID uuid.UUID, articles []*article.SavedArticle) common.Error {
+	articleIDs := make([]uuid.UUID, 0, len(articles))
+	for _, art := range articles {
+		articleIDs = append(articleIDs, art.ID)
+	}
+
+	tags, err := s.articleRepo.ListUserArticleTags(ctx, userID, articleIDs)
+	if err != nil {
+		return err
+	}
+	for _, art := range articles {
+		art.Tags = tags[art.ID]
+	}
+	return nil
+}
+
+func normalizeTagName(name string) (string, common.Error) {
+	name, err := article.NormalizeTagName(name)
+	if err != nil {
+		return "", common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg(err.Error()))
+	}
+	return name, nil
+}
@@ -0,0 +1,69 @@
+package article
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+func TestArticleService_AddArticleTags(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID, articleID := uuid.New(), uuid.New()
+	repo.saved[userID] = []uuid.UUID{articleID}
+	_, cerr := s.CreateTag(ctx, userID, "Go")
+	require.NoError(t, cerr)
+
+	// Names are trimmed, matched with existing tags ignoring case and deduplicated
+	tags, cerr := s.AddArticleTags(ctx, userID, articleID, []string{" go ", "Database", "DATABASE"})
+	require.NoError(t, cerr)
+	assert.ElementsMatch(t, []string{"Go", "Database"}, tagNames(tags))
+	assert.Len(t, repo.tags, 2)
+
+	// Only saved articles can be tagged
+	_, cerr = s.AddArticleTags(ctx, userID, uuid.New(), []string{"go"})
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+	_, cerr = s.AddArticleTags(ctx, uuid.New(), articleID, []string{"go"})
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+
+	_, cerr = s.AddArticleTags(ctx, userID, articleID, nil)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+	_, cerr = s.AddArticleTags(ctx, userID, articleID, []string{strings.Repeat("a", article.TagNameMaxLength+1)})
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+}
+
+func TestArticleService_MergeTags(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeArticleRepository()
+	s := &articleService{articleRepo: repo}
+
+	userID, first, second := uuid.New(), uuid.New(), uuid.New()
+	repo.saved[userID] = []uuid.UUID{first, second}
+	_, cerr := s.AddArticleTags(ctx, userID, first, []string{"golang", "go"})
+	require.NoError(t, cerr)
+	_, cerr = s.AddArticleTags(ctx, userID, second, []string{"golang"})
+	require.NoError(t, cerr)
+	source, target := repo.findTag(userID, "golang"), repo.findTag(userID, "go")
+
+	_, cerr = s.MergeTags(ctx, userID, source.ID, source.ID)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeParameterInvalid))
+	_, cerr = s.MergeTags(ctx, uuid.New(), source.ID, target.ID)
+	assert.True(t, common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound))
+
+	merged, cerr := s.MergeTags(ctx, userID, source.ID, target.ID)
+	require.NoError(t, cerr)
+	assert.Equal(t, target.ID, merged.ID)
+	assert.Equal(t, 2, merged.ArticleCount)
+	assert.Nil(t, repo.findTag(userID, "golang"))
+}
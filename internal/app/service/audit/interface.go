@@ -0,0 +1,27 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// Service writes and queries the audit log.
+type Service interface {
+	// Record appends the event to the audit log, attributed to the request ctx carries.
+	// A failure to write it is logged rather than failing the action the event is about.
+	Record(ctx context.Context, event *audit.Event)
+	// RecordAggregated counts the event with the alike ones from the same client IP instead of writing it right away.
+	// They are appended to the audit log as one event every minute, for actions any client can trigger at will.
+	RecordAggregated(ctx context.Context, event *audit.Event)
+	// ListEvents returns the events matching the filter, newest first, and the cursor of the next page.
+	// The cursor is empty for the first page, and the next cursor is empty on the last page.
+	ListEvents(ctx context.Context, filter audit.Filter, cursor string, limit int) ([]*audit.Event, string, common.Error)
+}
+
+// EventRepository defines the interface for persisting audit events. Events are never updated nor deleted.
+type EventRepository interface {
+	CreateAuditEvent(ctx context.Context, event *audit.Event) common.Error
+	ListAuditEvents(ctx context.Context, filter audit.Filter, beforeID int64, limit int) ([]*audit.Event, common.Error)
+}
@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	gocron "github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+const (
+	// maxListLimit caps the number of events returned at once
+	maxListLimit = 100
+	// userAgentMaxLength is the length of the audit_events.user_agent column
+	userAgentMaxLength = 255
+	// aggregateInterval is how often aggregated events are written
+	aggregateInterval = time.Minute
+	// maxAggregated caps the distinct aggregated events kept in memory between writes.
+	// Past it, new events are counted together regardless of their client IP.
+	maxAggregated = 1000
+)
+
+// aggregateKey tells which events are counted together
+type aggregateKey struct {
+	actorID    uuid.UUID
+	action     string
+	targetType string
+	targetID   string
+	ip         string
+	outcome    string
+	reason     string
+}
+
+func aggregateKeyOf(event *audit.Event) aggregateKey {
+	return aggregateKey{
+		actorID:    event.ActorID,
+		action:     event.Action,
+		targetType: event.TargetType,
+		targetID:   event.TargetID,
+		ip:         event.IP,
+		outcome:    event.Outcome,
+		reason:     event.Reason,
+	}
+}
+
+type auditService struct {
+	eventRepo EventRepository
+	scheduler gocron.Scheduler
+
+	mu         sync.Mutex
+	aggregated map[aggregateKey]*audit.Event // events waiting to be written, with the request of the first one
+}
+
+// NewAuditService creates the audit service and schedules writing the aggregated events.
+// The events aggregated last are written when ctx is done, as the server stops.
+func NewAuditService(ctx context.Context, eventRepo EventRepository) (Service, common.Error) {
+	s := &auditService{
+		eventRepo:  eventRepo,
+		aggregated: make(map[aggregateKey]*audit.Event),
+	}
+
+	scheduler, err := gocron.NewScheduler(gocron.WithLocation(time.UTC))
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	s.scheduler = scheduler
+	if _, err := s.scheduler.NewJob(
+		gocron.DurationJob(aggregateInterval),
+		gocron.NewTask(s.flushAggregated, ctx),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		gocron.WithName("AuditEventAggregator"),
+	); err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	s.scheduler.Start()
+
+	go func() {
+		<-ctx.Done()
+		s.flushAggregated(context.WithoutCancel(ctx))
+	}()
+
+	return s, nil
+}
+
+func (s *auditService) Record(ctx context.Context, event *audit.Event) {
+	s.attribute(ctx, event)
+	s.create(ctx, event)
+}
+
+func (s *auditService) RecordAggregated(ctx context.Context, event *audit.Event) {
+	s.attribute(ctx, event)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := aggregateKeyOf(event)
+	if _, ok := s.aggregated[key]; !ok && len(s.aggregated) >= maxAggregated {
+		event.IP, event.UserAgent, event.RequestID = "", "", ""
+		key = aggregateKeyOf(event)
+	}
+	if pending, ok := s.aggregated[key]; ok {
+		pending.Count += event.Count
+		return
+	}
+	s.aggregated[key] = event
+}
+
+// flushAggregated writes the events aggregated since the last time
+func (s *auditService) flushAggregated(ctx context.Context) {
+	s.mu.Lock()
+	events := s.aggregated
+	s.aggregated = make(map[aggregateKey]*audit.Event)
+	s.mu.Unlock()
+
+	for _, event := range events {
+		s.create(ctx, event)
+	}
+}
+
+// attribute sets the request ctx carries on the event
+func (s *auditService) attribute(ctx context.Context, event *audit.Event) {
+	request := audit.RequestFromContext(ctx)
+	event.RequestID = request.ID
+	event.IP = request.IP
+	event.UserAgent = truncate(request.UserAgent, userAgentMaxLength)
+}
+
+func (s *auditService) create(ctx context.Context, event *audit.Event) {
+	if cerr := s.eventRepo.CreateAuditEvent(ctx, event); cerr != nil {
+		s.logger(ctx).Error().Err(cerr).Str("action", event.Action).Str("outcome", event.Outcome).Int("count", event.Count).Msg("failed to record audit event")
+	}
+}
+
+func (s *auditService) ListEvents(ctx context.Context, filter audit.Filter, cursor string, limit int) ([]*audit.Event, string, common.Error) {
+	if limit < 1 || limit > maxListLimit {
+		msg := fmt.Sprintf("limit must be between 1 and %d", maxListLimit)
+		return nil, "", common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		msg := "since must be before until"
+		return nil, "", common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+
+	var beforeID int64
+	if cursor != "" {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || id <= 0 {
+			msg := "invalid cursor"
+			return nil, "", common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+		}
+		beforeID = id
+	}
+
+	// One more event tells whether there is a next page
+	events, cerr := s.eventRepo.ListAuditEvents(ctx, filter, beforeID, limit+1)
+	if cerr != nil {
+		return nil, "", cerr
+	}
+	if len(events) <= limit {
+		return events, "", nil
+	}
+	events = events[:limit]
+	return events, strconv.FormatInt(events[limit-1].ID, 10), nil
+}
+
+// logger wrap the execution context with component info
+func (s *auditService) logger(ctx context.Context) *zerolog.Logger {
+	l := zerolog.Ctx(ctx).With().Str("component", "audit-service").Logger()
+	return &l
+}
+
+// truncate cuts s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
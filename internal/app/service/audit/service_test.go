@@ -0,0 +1,189 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// fakeEventRepository is an in-memory EventRepository for unit tests
+type fakeEventRepository struct {
+	mu     sync.Mutex
+	events []*audit.Event
+	err    common.Error
+}
+
+func (r *fakeEventRepository) CreateAuditEvent(_ context.Context, event *audit.Event) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	created := *event
+	created.ID = int64(len(r.events) + 1)
+	created.CreatedAt = time.Now()
+	r.events = append(r.events, &created)
+	return nil
+}
+
+func (r *fakeEventRepository) ListAuditEvents(_ context.Context, filter audit.Filter, beforeID int64, limit int) ([]*audit.Event, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []*audit.Event
+	for _, e := range r.events {
+		if beforeID > 0 && e.ID >= beforeID {
+			continue
+		}
+		if filter.UserID != uuid.Nil && e.ActorID != filter.UserID && !(e.TargetType == audit.TargetUser && e.TargetID == filter.UserID.String()) {
+			continue
+		}
+		if filter.Action != "" && e.Action != filter.Action {
+			continue
+		}
+		copied := *e
+		events = append(events, &copied)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID > events[j].ID })
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// assertErrorCode asserts err is a domain error with the given code
+func assertErrorCode(t *testing.T, code common.ErrorCode, err common.Error) {
+	t.Helper()
+	var domainError common.DomainError
+	require.True(t, errors.As(err, &domainError), "not a domain error: %v", err)
+	assert.Equal(t, code.Name, domainError.Name())
+}
+
+func newTestAuditService(t *testing.T, repo EventRepository) *auditService {
+	t.Helper()
+	svc, err := NewAuditService(context.Background(), repo)
+	require.Nil(t, err)
+	return svc.(*auditService)
+}
+
+func TestAuditService_Record(t *testing.T) {
+	t.Parallel()
+	repo := &fakeEventRepository{}
+	svc := newTestAuditService(t, repo)
+
+	ctx := audit.WithRequest(context.Background(), audit.Request{ID: "req-1", IP: "192.0.2.1", UserAgent: strings.Repeat("a", 300)})
+	svc.Record(ctx, audit.NewEvent(audit.ActionLogin, uuid.Nil, audit.TargetEmail, "amy@example.com", nil))
+
+	require.Len(t, repo.events, 1)
+	assert.Equal(t, "req-1", repo.events[0].RequestID)
+	assert.Equal(t, "192.0.2.1", repo.events[0].IP)
+	assert.Len(t, repo.events[0].UserAgent, userAgentMaxLength)
+
+	// Events recorded outside of requests have no request, and failing to write one does not panic
+	svc.Record(context.Background(), audit.NewEvent(audit.ActionLogin, uuid.Nil, "", "", nil))
+	assert.Empty(t, repo.events[1].RequestID)
+	repo.err = common.NewError(common.ErrorCodeRemoteProcess, errors.New("db down"))
+	svc.Record(context.Background(), audit.NewEvent(audit.ActionLogin, uuid.Nil, "", "", nil))
+	assert.Len(t, repo.events, 2)
+}
+
+func TestAuditService_RecordAggregated(t *testing.T) {
+	t.Parallel()
+	repo := &fakeEventRepository{}
+	svc := newTestAuditService(t, repo)
+
+	rejected := common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New("expired"))
+	fromIP := func(ip string) context.Context {
+		return audit.WithRequest(context.Background(), audit.Request{ID: "req-" + ip, IP: ip})
+	}
+	for i := 0; i < 3; i++ {
+		svc.RecordAggregated(fromIP("192.0.2.1"), audit.NewEvent(audit.ActionTokenValidate, uuid.Nil, "", "", rejected))
+	}
+	svc.RecordAggregated(fromIP("192.0.2.2"), audit.NewEvent(audit.ActionTokenValidate, uuid.Nil, "", "", rejected))
+	assert.Empty(t, repo.events, "nothing is written right away")
+
+	// One event per client IP, with the request of the first one
+	svc.flushAggregated(context.Background())
+	require.Len(t, repo.events, 2)
+	counts := map[string]int{}
+	for _, e := range repo.events {
+		counts[e.IP] = e.Count
+		assert.Equal(t, "req-"+e.IP, e.RequestID)
+		assert.Equal(t, audit.OutcomeFailure, e.Outcome)
+	}
+	assert.Equal(t, map[string]int{"192.0.2.1": 3, "192.0.2.2": 1}, counts)
+
+	svc.flushAggregated(context.Background())
+	assert.Len(t, repo.events, 2, "written events are not written again")
+
+	// Past the limit, new clients are counted together
+	for i := 0; i < maxAggregated+2; i++ {
+		svc.RecordAggregated(fromIP(fmt.Sprintf("10.0.%d.%d", i/256, i%256)), audit.NewEvent(audit.ActionTokenValidate, uuid.Nil, "", "", rejected))
+	}
+	svc.flushAggregated(context.Background())
+	require.Len(t, repo.events, 2+maxAggregated+1)
+	last := map[string]int{}
+	for _, e := range repo.events[2:] {
+		last[e.IP] += e.Count
+	}
+	assert.Equal(t, 2, last[""])
+}
+
+func TestAuditService_ListEvents(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := &fakeEventRepository{}
+	svc := newTestAuditService(t, repo)
+
+	userID := uuid.New()
+	for i := 0; i < 5; i++ {
+		svc.Record(ctx, audit.NewEvent(audit.ActionLogin, userID, audit.TargetUser, userID.String(), nil))
+	}
+	svc.Record(ctx, audit.NewEvent(audit.ActionLogin, uuid.New(), audit.TargetUser, uuid.NewString(), nil))
+	// Events taken on the user match as well
+	svc.Record(ctx, audit.NewEvent(audit.ActionUserDisable, uuid.New(), audit.TargetUser, userID.String(), nil))
+
+	filter := audit.Filter{UserID: userID}
+	events, cursor, err := svc.ListEvents(ctx, filter, "", 4)
+	require.Nil(t, err)
+	require.Len(t, events, 4)
+	assert.Equal(t, audit.ActionUserDisable, events[0].Action, "newest first")
+	assert.NotEmpty(t, cursor)
+
+	events, cursor, err = svc.ListEvents(ctx, filter, cursor, 4)
+	require.Nil(t, err)
+	require.Len(t, events, 2)
+	assert.Empty(t, cursor, "no more pages")
+
+	events, _, err = svc.ListEvents(ctx, audit.Filter{Action: audit.ActionUserDisable}, "", 10)
+	require.Nil(t, err)
+	assert.Len(t, events, 1)
+}
+
+func TestAuditService_ListEventsInvalid(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestAuditService(t, &fakeEventRepository{})
+
+	_, _, err := svc.ListEvents(ctx, audit.Filter{}, "", 0)
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+	_, _, err = svc.ListEvents(ctx, audit.Filter{}, "", maxListLimit+1)
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+	_, _, err = svc.ListEvents(ctx, audit.Filter{}, "abc", 10)
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+
+	now := time.Now()
+	_, _, err = svc.ListEvents(ctx, audit.Filter{Since: now, Until: now.Add(-time.Hour)}, "", 10)
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+}
@@ -0,0 +1,106 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// ListUsers returns users ordered by ID, starting after afterID.
+func (s *userService) ListUsers(ctx context.Context, afterID uuid.UUID, limit int) ([]*user.User, common.Error) {
+	return s.userRepo.ListUsers(ctx, afterID, limit)
+}
+
+// SetUserRole changes the role of a user. It takes effect when the current access tokens of the user expire.
+func (s *userService) SetUserRole(ctx context.Context, actorID uuid.UUID, userID uuid.UUID, role string) common.Error {
+	if !user.IsValidRole(role) {
+		msg := fmt.Sprintf("invalid role %s", role)
+		return common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+	if actorID == userID && role != user.RoleAdmin {
+		msg := "admins cannot demote themselves"
+		return common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+
+	if cerr := s.userRepo.UpdateUserRole(ctx, userID, role); cerr != nil {
+		return cerr
+	}
+	s.recordAudit(ctx, audit.ActionUserRoleChange, actorID, audit.TargetUser, userID.String(), nil)
+	s.logger(ctx).Info().Str("actor_id", actorID.String()).Str("user_id", userID.String()).Str("role", role).Msg("user role changed")
+
+	return nil
+}
+
+// DisableUser blocks the user from logging in and revokes every login of the user.
+func (s *userService) DisableUser(ctx context.Context, actorID uuid.UUID, userID uuid.UUID) common.Error {
+	if actorID == userID {
+		msg := "admins cannot disable themselves"
+		return common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+
+	now := time.Now()
+	if cerr := s.userRepo.UpdateUserDisabledAt(ctx, userID, &now); cerr != nil {
+		return cerr
+	}
+	if cerr := s.TokenService.RevokeUserTokens(ctx, userID); cerr != nil {
+		return cerr
+	}
+	s.recordAudit(ctx, audit.ActionUserDisable, actorID, audit.TargetUser, userID.String(), nil)
+	s.logger(ctx).Info().Str("actor_id", actorID.String()).Str("user_id", userID.String()).Msg("user disabled")
+
+	return nil
+}
+
+// EnableUser lets a disabled user log in again.
+func (s *userService) EnableUser(ctx context.Context, actorID uuid.UUID, userID uuid.UUID) common.Error {
+	if cerr := s.userRepo.UpdateUserDisabledAt(ctx, userID, nil); cerr != nil {
+		return cerr
+	}
+	s.recordAudit(ctx, audit.ActionUserEnable, actorID, audit.TargetUser, userID.String(), nil)
+
+	return nil
+}
+
+// UnlockUser lifts a lockout caused by failed logins, before it expires on its own.
+// Lockouts of client IPs are left in place.
+func (s *userService) UnlockUser(ctx context.Context, actorID uuid.UUID, userID uuid.UUID) common.Error {
+	foundUser, cerr := s.userRepo.GetUserByID(ctx, userID)
+	if cerr != nil {
+		return cerr
+	}
+	if cerr := s.loginGuard.Unlock(ctx, foundUser.Email); cerr != nil {
+		return cerr
+	}
+	s.recordAudit(ctx, audit.ActionUserUnlock, actorID, audit.TargetUser, userID.String(), nil)
+
+	return nil
+}
+
+// AuthenticateAPIKey rejects the keys of disabled users on top of APIKeyService.AuthenticateAPIKey.
+// Rejected keys are recorded in the audit log, unknown ones counted per client IP and reason like rejected access tokens.
+func (s *userService) AuthenticateAPIKey(ctx context.Context, key string) (*user.APIKey, common.Error) {
+	apiKey, cerr := s.APIKeyService.AuthenticateAPIKey(ctx, key)
+	if cerr != nil {
+		s.auditor.RecordAggregated(ctx, audit.NewEvent(audit.ActionAPIKeyValidate, uuid.Nil, "", "", cerr))
+		return nil, cerr
+	}
+
+	owner, cerr := s.userRepo.GetUserByID(ctx, apiKey.UserID)
+	if cerr != nil {
+		return nil, cerr
+	}
+	if owner.IsDisabled() {
+		cerr := errAccountDisabled()
+		s.recordAudit(ctx, audit.ActionAPIKeyValidate, uuid.Nil, audit.TargetAPIKey, apiKey.ID.String(), cerr)
+		return nil, cerr
+	}
+
+	return apiKey, nil
+}
@@ -0,0 +1,62 @@
+package user
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+func TestUserService_DisableUser(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+	admin := newTestUser(t, svc.userRepo, user.RoleAdmin)
+
+	created, session, err := svc.SignUp(ctx, "grace@example.com", "grace", testPassword, testDevice)
+	require.Nil(t, err)
+	_, apiKey, err := svc.CreateAPIKey(ctx, created.ID, "script", []string{user.ScopeArticlesRead})
+	require.Nil(t, err)
+
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, svc.DisableUser(ctx, admin.ID, admin.ID))
+	require.Nil(t, svc.DisableUser(ctx, admin.ID, created.ID))
+
+	// Every way in is closed
+	_, err = svc.ValidateToken(ctx, session.AccessToken)
+	assert.NotNil(t, err)
+	_, err = svc.Login(ctx, "grace@example.com", testPassword, testDevice)
+	assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+	_, err = svc.AuthenticateAPIKey(ctx, apiKey)
+	assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+
+	require.Nil(t, svc.EnableUser(ctx, admin.ID, created.ID))
+	_, err = svc.Login(ctx, "grace@example.com", testPassword, testDevice)
+	assert.Nil(t, err)
+	_, err = svc.AuthenticateAPIKey(ctx, apiKey)
+	assert.Nil(t, err)
+}
+
+func TestUserService_SetUserRole(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+	admin := newTestUser(t, svc.userRepo, user.RoleAdmin)
+	member := newTestUser(t, svc.userRepo, user.RoleUser)
+
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, svc.SetUserRole(ctx, admin.ID, member.ID, "owner"))
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, svc.SetUserRole(ctx, admin.ID, admin.ID, user.RoleUser))
+
+	require.Nil(t, svc.SetUserRole(ctx, admin.ID, member.ID, user.RoleAdmin))
+	promoted, err := svc.GetUser(ctx, member.ID)
+	require.Nil(t, err)
+	assert.Equal(t, user.RoleAdmin, promoted.Role)
+
+	users, err := svc.ListUsers(ctx, uuid.Nil, 10)
+	require.Nil(t, err)
+	assert.Len(t, users, 2)
+}
@@ -0,0 +1,95 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// apiKeyDisplayLength is how much of a key is kept in clear to tell keys apart
+const apiKeyDisplayLength = 12
+
+type apiKeyService struct {
+	apiKeyRepo APIKeyRepository
+}
+
+func NewAPIKeyService(_ context.Context, apiKeyRepo APIKeyRepository) APIKeyService {
+	return &apiKeyService{
+		apiKeyRepo: apiKeyRepo,
+	}
+}
+
+// CreateAPIKey creates a key with the given scopes. The key itself is only returned here, we keep its hash.
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, userID uuid.UUID, name string, scopes []string) (*user.APIKey, string, common.Error) {
+	if len(scopes) == 0 {
+		msg := "at least one scope is needed"
+		return nil, "", common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+	granted := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !user.IsValidScope(scope) {
+			msg := fmt.Sprintf("invalid scope %s", scope)
+			return nil, "", common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+		}
+		if !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, "", common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	key := user.APIKeyPrefix + secret
+
+	created, cerr := s.apiKeyRepo.CreateAPIKey(ctx, &user.APIKey{
+		UserID:  userID,
+		Name:    name,
+		Prefix:  key[:apiKeyDisplayLength],
+		KeyHash: hashOpaqueToken(key),
+		Scopes:  granted,
+	})
+	if cerr != nil {
+		return nil, "", cerr
+	}
+
+	return created, key, nil
+}
+
+func (s *apiKeyService) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]*user.APIKey, common.Error) {
+	return s.apiKeyRepo.ListUserAPIKeys(ctx, userID)
+}
+
+func (s *apiKeyService) DeleteAPIKey(ctx context.Context, userID uuid.UUID, keyID uuid.UUID) common.Error {
+	return s.apiKeyRepo.DeleteAPIKey(ctx, userID, keyID)
+}
+
+// AuthenticateAPIKey returns the key a bearer token stands for and records that it was used.
+func (s *apiKeyService) AuthenticateAPIKey(ctx context.Context, key string) (*user.APIKey, common.Error) {
+	found, cerr := s.apiKeyRepo.GetAPIKeyByHash(ctx, hashOpaqueToken(key))
+	if cerr != nil {
+		msg := "invalid API key"
+		return nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(cerr.Error()), common.WithMsg(msg))
+	}
+
+	// Failing to record the usage must not fail the request
+	if cerr := s.apiKeyRepo.TouchAPIKey(ctx, found.ID, time.Now()); cerr != nil {
+		s.logger(ctx).Error().Err(cerr).Str("api_key_id", found.ID.String()).Msg("failed to record api key usage")
+	}
+
+	return found, nil
+}
+
+// logger wrap the execution context with component info
+func (s *apiKeyService) logger(ctx context.Context) *zerolog.Logger {
+	l := zerolog.Ctx(ctx).With().Str("component", "api-key-service").Logger()
+	return &l
+}
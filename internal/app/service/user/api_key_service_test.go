@@ -0,0 +1,128 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// fakeAPIKeyRepository is an in-memory APIKeyRepository for unit tests
+type fakeAPIKeyRepository struct {
+	mu   sync.Mutex
+	keys map[uuid.UUID]*user.APIKey
+}
+
+func newFakeAPIKeyRepository() *fakeAPIKeyRepository {
+	return &fakeAPIKeyRepository{keys: map[uuid.UUID]*user.APIKey{}}
+}
+
+func (r *fakeAPIKeyRepository) CreateAPIKey(_ context.Context, key *user.APIKey) (*user.APIKey, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	created := *key
+	created.ID = uuid.New()
+	created.CreatedAt = time.Now()
+	r.keys[created.ID] = &created
+	copied := created
+	return &copied, nil
+}
+
+func (r *fakeAPIKeyRepository) ListUserAPIKeys(_ context.Context, userID uuid.UUID) ([]*user.APIKey, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var keys []*user.APIKey
+	for _, k := range r.keys {
+		if k.UserID == userID {
+			copied := *k
+			keys = append(keys, &copied)
+		}
+	}
+	return keys, nil
+}
+
+func (r *fakeAPIKeyRepository) GetAPIKeyByHash(_ context.Context, keyHash string) (*user.APIKey, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range r.keys {
+		if k.KeyHash == keyHash {
+			copied := *k
+			return &copied, nil
+		}
+	}
+	return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("not found"))
+}
+
+func (r *fakeAPIKeyRepository) DeleteAPIKey(_ context.Context, userID uuid.UUID, keyID uuid.UUID) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.keys[keyID]
+	if !ok || k.UserID != userID {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("not found"))
+	}
+	delete(r.keys, keyID)
+	return nil
+}
+
+func (r *fakeAPIKeyRepository) TouchAPIKey(_ context.Context, keyID uuid.UUID, usedAt time.Time) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if k, ok := r.keys[keyID]; ok {
+		k.LastUsedAt = &usedAt
+	}
+	return nil
+}
+
+func TestAPIKeyService_CreateAPIKeyValidatesScopes(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := NewAPIKeyService(ctx, newFakeAPIKeyRepository())
+
+	_, _, err := svc.CreateAPIKey(ctx, uuid.New(), "no scopes", nil)
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+	_, _, err = svc.CreateAPIKey(ctx, uuid.New(), "unknown scope", []string{"users:write"})
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+
+	created, _, err := svc.CreateAPIKey(ctx, uuid.New(), "duplicated scope", []string{user.ScopeArticlesRead, user.ScopeArticlesRead})
+	require.Nil(t, err)
+	assert.Equal(t, []string{user.ScopeArticlesRead}, created.Scopes)
+}
+
+func TestAPIKeyService_AuthenticateAPIKey(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := newFakeAPIKeyRepository()
+	svc := NewAPIKeyService(ctx, repo)
+	userID := uuid.New()
+
+	created, key, err := svc.CreateAPIKey(ctx, userID, "reader", []string{user.ScopeArticlesRead})
+	require.Nil(t, err)
+	assert.True(t, user.IsAPIKey(key))
+	assert.NotContains(t, created.KeyHash, key)
+	assert.Nil(t, created.LastUsedAt)
+
+	authenticated, err := svc.AuthenticateAPIKey(ctx, key)
+	require.Nil(t, err)
+	assert.Equal(t, userID, authenticated.UserID)
+	assert.True(t, authenticated.HasScope(user.ScopeArticlesRead))
+	assert.False(t, authenticated.HasScope(user.ScopeArticlesWrite))
+
+	keys, err := svc.ListAPIKeys(ctx, userID)
+	require.Nil(t, err)
+	require.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].LastUsedAt)
+
+	// Only the owner can delete a key, and deleted keys stop working
+	assertErrorCode(t, common.ErrorCodeResourceNotFound, svc.DeleteAPIKey(ctx, uuid.New(), created.ID))
+	require.Nil(t, svc.DeleteAPIKey(ctx, userID, created.ID))
+	_, err = svc.AuthenticateAPIKey(ctx, key)
+	assertErrorCode(t, common.ErrorCodeAuthNotAuthenticated, err)
+}
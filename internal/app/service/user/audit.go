@@ -0,0 +1,59 @@
+package user
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// ValidateToken records rejected access tokens in the audit log on top of TokenService.ValidateToken.
+// Any client can send as many as it likes, so they are counted per client IP and reason rather than written one by one.
+func (s *userService) ValidateToken(ctx context.Context, token string) (*user.TokenClaims, common.Error) {
+	claims, cerr := s.TokenService.ValidateToken(ctx, token)
+	if cerr != nil {
+		s.auditor.RecordAggregated(ctx, audit.NewEvent(audit.ActionTokenValidate, uuid.Nil, "", "", cerr))
+	}
+	return claims, cerr
+}
+
+// RefreshToken records rejected refresh tokens, reused ones among them, on top of TokenService.RefreshToken.
+func (s *userService) RefreshToken(ctx context.Context, refreshToken string, device user.Device) (*user.Token, common.Error) {
+	token, cerr := s.TokenService.RefreshToken(ctx, refreshToken, device)
+	if cerr != nil {
+		s.recordAudit(ctx, audit.ActionTokenRefresh, uuid.Nil, "", "", cerr)
+	}
+	return token, cerr
+}
+
+// DeleteAPIKey records the deletion in the audit log on top of APIKeyService.DeleteAPIKey.
+func (s *userService) DeleteAPIKey(ctx context.Context, userID uuid.UUID, keyID uuid.UUID) common.Error {
+	cerr := s.APIKeyService.DeleteAPIKey(ctx, userID, keyID)
+	s.recordAudit(ctx, audit.ActionAPIKeyDelete, userID, audit.TargetAPIKey, keyID.String(), cerr)
+	return cerr
+}
+
+// recordAudit appends the outcome of an action the actor took on a target to the audit log, cerr is nil on success
+func (s *userService) recordAudit(ctx context.Context, action string, actorID uuid.UUID, targetType string, targetID string, cerr common.Error) {
+	s.auditor.Record(ctx, audit.NewEvent(action, actorID, targetType, targetID, cerr))
+}
+
+// auditLogin records a login attempt on an account, which is addressed by userID once known and by email before.
+// Attempts failing before either is known have no target. The user is the actor only once the login succeeded.
+func (s *userService) auditLogin(ctx context.Context, email string, userID uuid.UUID, cerr common.Error) {
+	var targetType, targetID string
+	switch {
+	case userID != uuid.Nil:
+		targetType, targetID = audit.TargetUser, userID.String()
+	case email != "":
+		targetType, targetID = audit.TargetEmail, email
+	}
+	actorID := uuid.Nil
+	if cerr == nil {
+		actorID = userID
+	}
+	s.recordAudit(ctx, audit.ActionLogin, actorID, targetType, targetID, cerr)
+}
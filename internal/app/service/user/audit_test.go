@@ -0,0 +1,115 @@
+package user
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// fakeAuditRecorder keeps the recorded audit events in memory
+type fakeAuditRecorder struct {
+	mu         sync.Mutex
+	events     []audit.Event
+	aggregated int // how many of the events were recorded aggregated
+}
+
+func (r *fakeAuditRecorder) Record(_ context.Context, event *audit.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, *event)
+}
+
+func (r *fakeAuditRecorder) RecordAggregated(ctx context.Context, event *audit.Event) {
+	r.Record(ctx, event)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.aggregated++
+}
+
+// Events returns the recorded events of the action
+func (r *fakeAuditRecorder) Events(action string) []audit.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []audit.Event
+	for _, e := range r.events {
+		if e.Action == action {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func TestUserService_AuditLogin(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+
+	created, _, err := svc.SignUp(ctx, "judy@example.com", "judy", testPassword, testDevice)
+	require.Nil(t, err)
+	signUps := svc.auditor.Events(audit.ActionSignUp)
+	require.Len(t, signUps, 1)
+	assert.Equal(t, created.ID, signUps[0].ActorID)
+	assert.True(t, signUps[0].IsSuccess())
+
+	_, err = svc.Login(ctx, "nobody@example.com", testPassword, testDevice)
+	require.NotNil(t, err)
+	_, err = svc.Login(ctx, "judy@example.com", "wrong-password", testDevice)
+	require.NotNil(t, err)
+	_, err = svc.Login(ctx, "judy@example.com", testPassword, testDevice)
+	require.Nil(t, err)
+
+	logins := svc.auditor.Events(audit.ActionLogin)
+	require.Len(t, logins, 3)
+
+	// Unknown accounts are addressed by email, nobody is the actor of a failed login
+	assert.Equal(t, audit.OutcomeFailure, logins[0].Outcome)
+	assert.Equal(t, uuid.Nil, logins[0].ActorID)
+	assert.Equal(t, audit.TargetEmail, logins[0].TargetType)
+	assert.Equal(t, "nobody@example.com", logins[0].TargetID)
+
+	assert.Equal(t, audit.OutcomeFailure, logins[1].Outcome)
+	assert.Equal(t, uuid.Nil, logins[1].ActorID)
+	assert.Equal(t, audit.TargetUser, logins[1].TargetType)
+	assert.Equal(t, created.ID.String(), logins[1].TargetID)
+	assert.Contains(t, logins[1].Reason, common.ErrorCodeAuthNotAuthenticated.Name)
+
+	assert.True(t, logins[2].IsSuccess())
+	assert.Equal(t, created.ID, logins[2].ActorID)
+	assert.Empty(t, logins[2].Reason)
+}
+
+func TestUserService_AuditTokenAndDeletion(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+
+	created, _, err := svc.SignUp(ctx, "ken@example.com", "ken", testPassword, testDevice)
+	require.Nil(t, err)
+
+	_, err = svc.ValidateToken(ctx, "not-a-token")
+	require.NotNil(t, err)
+	validations := svc.auditor.Events(audit.ActionTokenValidate)
+	require.Len(t, validations, 1)
+	assert.Equal(t, audit.OutcomeFailure, validations[0].Outcome)
+	assert.Equal(t, 1, svc.auditor.aggregated, "rejected access tokens are counted rather than written one by one")
+
+	_, err = svc.RefreshToken(ctx, "not-a-token", testDevice)
+	require.NotNil(t, err)
+	require.Len(t, svc.auditor.Events(audit.ActionTokenRefresh), 1)
+
+	require.NotNil(t, svc.DeleteAccount(ctx, created.ID, uuid.Nil, "wrong-password"))
+	require.Nil(t, svc.DeleteAccount(ctx, created.ID, uuid.Nil, testPassword))
+	deletions := svc.auditor.Events(audit.ActionAccountDelete)
+	require.Len(t, deletions, 2)
+	assert.Equal(t, audit.OutcomeFailure, deletions[0].Outcome)
+	assert.True(t, deletions[1].IsSuccess())
+	assert.Equal(t, created.ID, deletions[1].ActorID)
+	assert.Equal(t, created.ID.String(), deletions[1].TargetID)
+}
@@ -2,23 +2,65 @@ package user
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
 	"github.com/sappy5678/DeeliAi/internal/domain/common"
 	"github.com/sappy5678/DeeliAi/internal/domain/user"
 )
 
 type Service interface {
 	TokenService
-	SignUp(ctx context.Context, email string, username string, password string) (*user.User, string, common.Error)
-	Login(ctx context.Context, email string, password string) (*user.User, string, common.Error)
+	APIKeyService
+	SignUp(ctx context.Context, email string, username string, password string, device user.Device) (*user.User, *user.Token, common.Error)
+	Login(ctx context.Context, email string, password string, device user.Device) (*user.LoginResult, common.Error)
+	CompleteTwoFactorLogin(ctx context.Context, challengeToken string, code string, device user.Device) (*user.User, *user.Token, common.Error)
+	StartOIDCLogin(ctx context.Context, provider string) (string, common.Error)
+	CompleteOIDCLogin(ctx context.Context, provider string, state string, code string, device user.Device) (*user.LoginResult, common.Error)
 	GetUser(ctx context.Context, userID uuid.UUID) (*user.User, common.Error)
+	ForgotPassword(ctx context.Context, email string) common.Error
+	ResetPassword(ctx context.Context, resetToken string, newPassword string) common.Error
+	VerifyEmail(ctx context.Context, verificationToken string) (*user.User, common.Error)
+	ResendVerificationEmail(ctx context.Context, userID uuid.UUID) common.Error
+	UpdateProfile(ctx context.Context, userID uuid.UUID, username *string, email *string) (*user.User, common.Error)
+	ChangePassword(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID, currentPassword string, newPassword string, device user.Device) (*user.Token, common.Error)
+	DeleteAccount(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID, password string) common.Error
+
+	// Two-factor authentication
+	EnrollTwoFactor(ctx context.Context, userID uuid.UUID) (*user.TwoFactorEnrollment, common.Error)
+	ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, code string) ([]string, common.Error)
+	DisableTwoFactor(ctx context.Context, userID uuid.UUID, code string) common.Error
+
+	// Administration
+	ListUsers(ctx context.Context, afterID uuid.UUID, limit int) ([]*user.User, common.Error)
+	SetUserRole(ctx context.Context, actorID uuid.UUID, userID uuid.UUID, role string) common.Error
+	DisableUser(ctx context.Context, actorID uuid.UUID, userID uuid.UUID) common.Error
+	EnableUser(ctx context.Context, actorID uuid.UUID, userID uuid.UUID) common.Error
+	UnlockUser(ctx context.Context, actorID uuid.UUID, userID uuid.UUID) common.Error
 }
 
 type TokenService interface {
-	GenerateToken(ctx context.Context, userID uuid.UUID) (string, common.Error)
-	ValidateToken(ctx context.Context, token string) (uuid.UUID, common.Error)
+	GenerateToken(ctx context.Context, userID uuid.UUID, device user.Device) (*user.Token, common.Error)
+	RefreshToken(ctx context.Context, refreshToken string, device user.Device) (*user.Token, common.Error)
+	RevokeToken(ctx context.Context, refreshToken string) common.Error
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID) common.Error
+	ValidateToken(ctx context.Context, token string) (*user.TokenClaims, common.Error)
+	PublicKeys(ctx context.Context) ([]user.PublicKey, common.Error)
+
+	// Sessions, which are the token families of a user
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]*user.TokenFamily, common.Error)
+	GetSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) (*user.TokenFamily, common.Error)
+	RevokeSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) common.Error
+	RevokeOtherSessions(ctx context.Context, userID uuid.UUID, currentSessionID uuid.UUID) common.Error
+}
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, userID uuid.UUID, name string, scopes []string) (*user.APIKey, string, common.Error)
+	ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]*user.APIKey, common.Error)
+	DeleteAPIKey(ctx context.Context, userID uuid.UUID, keyID uuid.UUID) common.Error
+	AuthenticateAPIKey(ctx context.Context, key string) (*user.APIKey, common.Error)
 }
 
 //go:generate mockgen -destination automock/user_repository.go -package=automock . UserRepository
@@ -26,4 +68,110 @@ type UserRepository interface {
 	CreateUser(ctx context.Context, user *user.User) (*user.User, common.Error)
 	GetUserByEmail(ctx context.Context, email string) (*user.User, common.Error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*user.User, common.Error)
+	UpdateUserPassword(ctx context.Context, id uuid.UUID, passwordHash string) common.Error
+	MarkUserEmailVerified(ctx context.Context, id uuid.UUID, email string) common.Error
+	UpdateUserProfile(ctx context.Context, id uuid.UUID, username string, email string) (*user.User, common.Error)
+	DeleteUser(ctx context.Context, id uuid.UUID) common.Error
+	ListUsers(ctx context.Context, afterID uuid.UUID, limit int) ([]*user.User, common.Error)
+	UpdateUserRole(ctx context.Context, id uuid.UUID, role string) common.Error
+	UpdateUserDisabledAt(ctx context.Context, id uuid.UUID, disabledAt *time.Time) common.Error
+}
+
+// TokenRepository defines the interface for persisting token families and refresh tokens.
+type TokenRepository interface {
+	CreateTokenFamily(ctx context.Context, family *user.TokenFamily) (*user.TokenFamily, common.Error)
+	GetTokenFamily(ctx context.Context, familyID uuid.UUID) (*user.TokenFamily, common.Error)
+	// ListActiveTokenFamilies returns the families that are not revoked and still hold an unexpired refresh token
+	ListActiveTokenFamilies(ctx context.Context, userID uuid.UUID, now time.Time) ([]*user.TokenFamily, common.Error)
+	// TouchTokenFamily sets the last-seen time of the family, and its device unless device is nil
+	TouchTokenFamily(ctx context.Context, familyID uuid.UUID, seenAt time.Time, device *user.Device) common.Error
+	RevokeTokenFamily(ctx context.Context, familyID uuid.UUID) common.Error
+	RevokeUserTokenFamilies(ctx context.Context, userID uuid.UUID) common.Error
+	RevokeOtherUserTokenFamilies(ctx context.Context, userID uuid.UUID, keepFamilyID uuid.UUID) common.Error
+
+	CreateRefreshToken(ctx context.Context, token *user.RefreshToken) common.Error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*user.RefreshToken, common.Error)
+	RotateRefreshToken(ctx context.Context, tokenID int64) (bool, common.Error)
+}
+
+// PasswordResetRepository defines the interface for persisting password reset tokens.
+type PasswordResetRepository interface {
+	CreatePasswordResetToken(ctx context.Context, token *user.PasswordResetToken) common.Error
+	GetPasswordResetTokenByHash(ctx context.Context, tokenHash string) (*user.PasswordResetToken, common.Error)
+	UsePasswordResetToken(ctx context.Context, tokenID int64) (bool, common.Error)
+	InvalidateUserPasswordResetTokens(ctx context.Context, userID uuid.UUID) common.Error
+}
+
+// EmailVerificationRepository defines the interface for persisting email verification tokens.
+type EmailVerificationRepository interface {
+	CreateEmailVerificationToken(ctx context.Context, token *user.EmailVerificationToken) common.Error
+	GetEmailVerificationTokenByHash(ctx context.Context, tokenHash string) (*user.EmailVerificationToken, common.Error)
+	UseEmailVerificationToken(ctx context.Context, tokenID int64) (bool, common.Error)
+	InvalidateUserEmailVerificationTokens(ctx context.Context, userID uuid.UUID) common.Error
+}
+
+// APIKeyRepository defines the interface for persisting API keys.
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *user.APIKey) (*user.APIKey, common.Error)
+	ListUserAPIKeys(ctx context.Context, userID uuid.UUID) ([]*user.APIKey, common.Error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*user.APIKey, common.Error)
+	DeleteAPIKey(ctx context.Context, userID uuid.UUID, keyID uuid.UUID) common.Error
+	TouchAPIKey(ctx context.Context, keyID uuid.UUID, usedAt time.Time) common.Error
+}
+
+// TwoFactorRepository defines the interface for persisting TOTP secrets, recovery codes and login challenges.
+type TwoFactorRepository interface {
+	SaveTOTP(ctx context.Context, totp *user.TOTP) common.Error
+	GetTOTP(ctx context.Context, userID uuid.UUID) (*user.TOTP, common.Error)
+	ConfirmTOTP(ctx context.Context, userID uuid.UUID, usedStep int64, recoveryCodeHashes []string) common.Error
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, common.Error)
+	DeleteTOTP(ctx context.Context, userID uuid.UUID) common.Error
+	GetRecoveryCodeByHash(ctx context.Context, userID uuid.UUID, codeHash string) (*user.RecoveryCode, common.Error)
+	UseRecoveryCode(ctx context.Context, codeID int64) (bool, common.Error)
+	CreateLoginChallenge(ctx context.Context, challenge *user.LoginChallenge) common.Error
+	GetLoginChallengeByHash(ctx context.Context, tokenHash string) (*user.LoginChallenge, common.Error)
+	UseLoginChallenge(ctx context.Context, challengeID int64) (bool, common.Error)
+}
+
+// IdentityRepository defines the interface for persisting linked external identities and pending OIDC sign ins.
+type IdentityRepository interface {
+	CreateIdentity(ctx context.Context, identity *user.Identity) (*user.Identity, common.Error)
+	GetIdentity(ctx context.Context, provider string, subject string) (*user.Identity, common.Error)
+	CreateOIDCState(ctx context.Context, state *user.OIDCState) common.Error
+	// ConsumeOIDCState marks the state as used and returns it, failing if it was used before
+	ConsumeOIDCState(ctx context.Context, stateHash string) (*user.OIDCState, common.Error)
+}
+
+// WorkspaceRepository is the part of the workspace store deleting an account needs.
+type WorkspaceRepository interface {
+	// LeaveUserWorkspaces removes the user from their workspaces and deletes those without other members.
+	// It fails with RESOURCE_CONFLICT while the user is the last owner of a workspace with other members.
+	LeaveUserWorkspaces(ctx context.Context, userID uuid.UUID) common.Error
+}
+
+// SigningKeyRepository defines the interface for persisting token signing keys.
+type SigningKeyRepository interface {
+	CreateSigningKey(ctx context.Context, key *user.SigningKey) common.Error
+	ListSigningKeys(ctx context.Context) ([]*user.SigningKey, common.Error)
+	DeleteExpiredSigningKeys(ctx context.Context) common.Error
+}
+
+// LoginAttemptRepository defines the interface for counting failed logins.
+// It is shared by every instance, so that lockouts hold across a multi-pod deployment.
+type LoginAttemptRepository interface {
+	// GetLoginAttempt returns the counter of the key, which is empty if the key never failed
+	GetLoginAttempt(ctx context.Context, key string) (*user.LoginAttempt, common.Error)
+	// RecordLoginFailure adds a failure to the key, restarting the count if the last failure is older than window
+	RecordLoginFailure(ctx context.Context, key string, failedAt time.Time, window time.Duration) (*user.LoginAttempt, common.Error)
+	LockLoginAttempt(ctx context.Context, key string, until time.Time) common.Error
+	DeleteLoginAttempt(ctx context.Context, key string) common.Error
+	// DeleteStaleLoginAttempts removes the counters that neither failed since before nor are locked
+	DeleteStaleLoginAttempts(ctx context.Context, before time.Time) common.Error
+}
+
+// AuditRecorder appends events to the audit log.
+type AuditRecorder interface {
+	Record(ctx context.Context, event *audit.Event)
+	// RecordAggregated counts the event with the alike ones from the same client, for actions any client can trigger at will
+	RecordAggregated(ctx context.Context, event *audit.Event)
 }
@@ -0,0 +1,218 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	gocron "github.com/go-co-op/gocron/v2"
+	"github.com/rs/zerolog"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// minKeyReloadInterval throttles reloading keys from the repository when a token carries an unknown kid
+const minKeyReloadInterval = 5 * time.Second
+
+// keyPublishLead is how long a new key is published before it signs tokens. It outlasts the
+// 5 minutes verifiers may cache the JWKS and the 1 minute other instances take to reload keys,
+// so no verifier meets a kid it has not fetched yet.
+const keyPublishLead = 10 * time.Minute
+
+// KeyManager owns the set of asymmetric keys used to sign and verify access tokens.
+// Keys are persisted through SigningKeyRepository so that every instance signs with,
+// and publishes, the same keys.
+type KeyManager struct {
+	keyRepo          SigningKeyRepository
+	algorithm        string
+	rotationInterval time.Duration
+	tokenLifetime    time.Duration
+	scheduler        gocron.Scheduler
+
+	mu       sync.RWMutex
+	keys     []*user.SigningKey // newest first
+	loadedAt time.Time
+}
+
+// NewKeyManager loads the persisted keys, creates the first key if there is none,
+// and schedules key rotation. A key is published keyPublishLead before it signs new
+// tokens, signs them for rotationInterval and stays verifiable for tokenLifetime after that.
+func NewKeyManager(ctx context.Context, keyRepo SigningKeyRepository, algorithm string, rotationInterval time.Duration, tokenLifetime time.Duration) (*KeyManager, common.Error) {
+	m := &KeyManager{
+		keyRepo:          keyRepo,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		tokenLifetime:    tokenLifetime,
+	}
+
+	if err := m.rotateIfNeeded(ctx); err != nil {
+		return nil, err
+	}
+
+	scheduler, err := gocron.NewScheduler(gocron.WithLocation(time.UTC))
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	m.scheduler = scheduler
+	if _, err := m.scheduler.NewJob(
+		gocron.DurationJob(1*time.Minute),
+		gocron.NewTask(m.runKeyRotationJob, ctx),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		gocron.WithName("SigningKeyRotator"),
+	); err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	m.scheduler.Start()
+
+	return m, nil
+}
+
+// SigningKey returns the key new tokens should be signed with.
+func (m *KeyManager) SigningKey(ctx context.Context) (*user.SigningKey, common.Error) {
+	if key := m.currentKey(time.Now()); key != nil {
+		return key, nil
+	}
+
+	// The scheduled rotation has not caught up yet, rotate inline
+	if err := m.rotateIfNeeded(ctx); err != nil {
+		return nil, err
+	}
+	if key := m.currentKey(time.Now()); key != nil {
+		return key, nil
+	}
+	return nil, common.NewError(common.ErrorCodeInternalProcess, errors.New("no signing key available"))
+}
+
+// VerificationKey returns the key identified by kid, reloading keys once if it is not known yet.
+func (m *KeyManager) VerificationKey(ctx context.Context, kid string) (*user.SigningKey, common.Error) {
+	if key := m.findKey(kid); key != nil {
+		return key, nil
+	}
+
+	m.mu.RLock()
+	canReload := time.Since(m.loadedAt) >= minKeyReloadInterval
+	m.mu.RUnlock()
+	if canReload {
+		if err := m.reload(ctx); err != nil {
+			return nil, err
+		}
+		if key := m.findKey(kid); key != nil {
+			return key, nil
+		}
+	}
+
+	msg := "unknown signing key"
+	return nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg(msg))
+}
+
+// VerificationKeys returns every key that may still have signed a valid token.
+func (m *KeyManager) VerificationKeys() []*user.SigningKey {
+	now := time.Now()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]*user.SigningKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		if !key.IsExpired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (m *KeyManager) runKeyRotationJob(ctx context.Context) {
+	if err := m.keyRepo.DeleteExpiredSigningKeys(ctx); err != nil {
+		m.logger(ctx).Error().Err(err).Msg("failed to delete expired signing keys")
+	}
+	if err := m.rotateIfNeeded(ctx); err != nil {
+		m.logger(ctx).Error().Err(err).Msg("failed to rotate signing key")
+	}
+}
+
+// rotateIfNeeded reloads the keys and creates the next one once the current key is due for rotation
+// within keyPublishLead. The next key only signs when the current one is rotated out, and is published
+// until then. Without a current key, the new key signs at once since nothing else can.
+func (m *KeyManager) rotateIfNeeded(ctx context.Context) common.Error {
+	if err := m.reload(ctx); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	activatesAt := now
+	if key := m.currentKey(now); key != nil {
+		// currentKey stops returning the key once a token issued then would outlive it
+		activatesAt = key.ExpiresAt.Add(-m.tokenLifetime)
+		if now.Before(activatesAt.Add(-keyPublishLead)) || m.pendingKey(now) != nil {
+			return nil
+		}
+	}
+
+	key, err := user.NewSigningKey(m.algorithm, now, activatesAt, activatesAt.Add(m.rotationInterval+m.tokenLifetime))
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	if cerr := m.keyRepo.CreateSigningKey(ctx, key); cerr != nil {
+		return cerr
+	}
+	m.logger(ctx).Info().Str("kid", key.ID).Str("algorithm", key.Algorithm).Time("activates_at", activatesAt).Msg("rotated token signing key")
+
+	return m.reload(ctx)
+}
+
+func (m *KeyManager) reload(ctx context.Context) common.Error {
+	keys, err := m.keyRepo.ListSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = keys
+	m.loadedAt = time.Now()
+	return nil
+}
+
+// currentKey returns the newest active key of the configured algorithm that outlives a token issued now
+func (m *KeyManager) currentKey(now time.Time) *user.SigningKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, key := range m.keys {
+		if key.Algorithm == m.algorithm && key.IsActive(now) && !key.IsExpired(now.Add(m.tokenLifetime)) {
+			return key
+		}
+	}
+	return nil
+}
+
+// pendingKey returns a key of the configured algorithm that is published but does not sign yet
+func (m *KeyManager) pendingKey(now time.Time) *user.SigningKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, key := range m.keys {
+		if key.Algorithm == m.algorithm && !key.IsActive(now) {
+			return key
+		}
+	}
+	return nil
+}
+
+func (m *KeyManager) findKey(kid string) *user.SigningKey {
+	now := time.Now()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, key := range m.keys {
+		if key.ID == kid && !key.IsExpired(now) {
+			return key
+		}
+	}
+	return nil
+}
+
+// logger wrap the execution context with component info
+func (m *KeyManager) logger(ctx context.Context) *zerolog.Logger {
+	l := zerolog.Ctx(ctx).With().Str("component", "key-manager").Logger()
+	return &l
+}
@@ -0,0 +1,69 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+func TestKeyManager_FirstKey(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// Without any key, the first one signs at once
+	m, cerr := NewKeyManager(ctx, &fakeSigningKeyRepository{}, user.SigningAlgorithmEdDSA, time.Hour, time.Minute)
+	require.Nil(t, cerr)
+	key, cerr := m.SigningKey(ctx)
+	require.Nil(t, cerr)
+	assert.Len(t, m.VerificationKeys(), 1)
+	assert.True(t, key.IsActive(time.Now()))
+}
+
+func TestKeyManager_RotationPublishesKeyFirst(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	now := time.Now()
+
+	// The current key is rotated out in 5 minutes, sooner than keyPublishLead
+	current, err := user.NewSigningKey(user.SigningAlgorithmEdDSA, now.Add(-time.Hour), now.Add(-time.Hour), now.Add(5*time.Minute+time.Minute))
+	require.NoError(t, err)
+	m, cerr := NewKeyManager(ctx, &fakeSigningKeyRepository{keys: []*user.SigningKey{current}}, user.SigningAlgorithmEdDSA, time.Hour, time.Minute)
+	require.Nil(t, cerr)
+
+	// The next key is published, and signs from when the current key is rotated out
+	keys := m.VerificationKeys()
+	require.Len(t, keys, 2)
+	assert.Equal(t, current.ExpiresAt.Add(-time.Minute), keys[0].ActivatesAt)
+	assert.Equal(t, keys[0].ActivatesAt.Add(time.Hour+time.Minute), keys[0].ExpiresAt)
+	key, cerr := m.SigningKey(ctx)
+	require.Nil(t, cerr)
+	assert.Equal(t, current.ID, key.ID)
+
+	// The next key is only published once
+	require.Nil(t, m.rotateIfNeeded(ctx))
+	assert.Len(t, m.VerificationKeys(), 2)
+}
+
+func TestKeyManager_PublishedKeySigns(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	now := time.Now()
+
+	// The current key was rotated out a second ago, the next key published 10 minutes before
+	previous, err := user.NewSigningKey(user.SigningAlgorithmEdDSA, now.Add(-time.Hour), now.Add(-time.Hour), now.Add(time.Minute-time.Second))
+	require.NoError(t, err)
+	next, err := user.NewSigningKey(user.SigningAlgorithmEdDSA, now.Add(-keyPublishLead), now.Add(-time.Second), now.Add(time.Hour))
+	require.NoError(t, err)
+	m, cerr := NewKeyManager(ctx, &fakeSigningKeyRepository{keys: []*user.SigningKey{next, previous}}, user.SigningAlgorithmEdDSA, time.Hour, time.Minute)
+	require.Nil(t, cerr)
+
+	key, cerr := m.SigningKey(ctx)
+	require.Nil(t, cerr)
+	assert.Equal(t, next.ID, key.ID)
+	assert.Len(t, m.VerificationKeys(), 2, "tokens signed by the previous key stay verifiable")
+}
@@ -0,0 +1,176 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	gocron "github.com/go-co-op/gocron/v2"
+	"github.com/rs/zerolog"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// Prefixes of the login attempt keys, so that accounts and client IPs are counted apart
+const (
+	loginAttemptKeyAccount = "account:"
+	loginAttemptKeyIP      = "ip:"
+)
+
+// LoginPolicy decides when failed logins slow down and lock out further attempts
+type LoginPolicy struct {
+	// MaxAccountFailures locks an account out after that many failures in a row
+	MaxAccountFailures int
+	// MaxIPFailures locks a client IP out after that many failures in a row, on any account
+	MaxIPFailures int
+	// LockoutDuration is both how long a lockout lasts and how long a failure is remembered
+	LockoutDuration time.Duration
+	// BaseDelay is the wait imposed on an account after its second failure, doubled after each further failure
+	BaseDelay time.Duration
+}
+
+// LoginGuard throttles logins per account and per client IP.
+// Accounts are keyed by email rather than ID, so that guessing unknown emails is throttled as well.
+type LoginGuard struct {
+	attemptRepo LoginAttemptRepository
+	policy      LoginPolicy
+	scheduler   gocron.Scheduler
+}
+
+// NewLoginGuard creates a LoginGuard and schedules the removal of counters that outlived the policy.
+func NewLoginGuard(ctx context.Context, attemptRepo LoginAttemptRepository, policy LoginPolicy) (*LoginGuard, common.Error) {
+	g := &LoginGuard{
+		attemptRepo: attemptRepo,
+		policy:      policy,
+	}
+
+	scheduler, err := gocron.NewScheduler(gocron.WithLocation(time.UTC))
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	g.scheduler = scheduler
+	if _, err := g.scheduler.NewJob(
+		gocron.DurationJob(10*time.Minute),
+		gocron.NewTask(g.runCleanupJob, ctx),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		gocron.WithName("LoginAttemptCleaner"),
+	); err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	g.scheduler.Start()
+
+	return g, nil
+}
+
+// Check fails with AUTH_TOO_MANY_ATTEMPTS if the account or the client IP may not try to log in yet.
+func (g *LoginGuard) Check(ctx context.Context, email string, clientIP string) common.Error {
+	now := time.Now()
+	var retryAt time.Time
+	for _, key := range g.keys(email, clientIP) {
+		attempt, err := g.attemptRepo.GetLoginAttempt(ctx, key)
+		if err != nil {
+			return err
+		}
+		if t := g.retryAt(attempt, now); t.After(retryAt) {
+			retryAt = t
+		}
+	}
+
+	if retryAt.IsZero() {
+		return nil
+	}
+	return errTooManyLoginAttempts(retryAt.Sub(now))
+}
+
+// RecordFailure counts a failed login and locks the account or the client IP out once it reaches its limit.
+func (g *LoginGuard) RecordFailure(ctx context.Context, email string, clientIP string) common.Error {
+	now := time.Now()
+	for _, key := range g.keys(email, clientIP) {
+		attempt, err := g.attemptRepo.RecordLoginFailure(ctx, key, now, g.policy.LockoutDuration)
+		if err != nil {
+			return err
+		}
+		if attempt.Failures < g.maxFailures(key) || attempt.IsLocked(now) {
+			continue
+		}
+
+		if err := g.attemptRepo.LockLoginAttempt(ctx, key, now.Add(g.policy.LockoutDuration)); err != nil {
+			return err
+		}
+		g.logger(ctx).Warn().Str("key", key).Int("failures", attempt.Failures).Msg("too many failed logins, locking out")
+	}
+	return nil
+}
+
+// RecordSuccess clears the failures of the account. The client IP keeps its count,
+// so that logging into an own account does not reset a guessing run on others.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, email string) common.Error {
+	return g.attemptRepo.DeleteLoginAttempt(ctx, accountLoginAttemptKey(email))
+}
+
+// Unlock lifts the lockout and clears the failures of the account.
+func (g *LoginGuard) Unlock(ctx context.Context, email string) common.Error {
+	return g.attemptRepo.DeleteLoginAttempt(ctx, accountLoginAttemptKey(email))
+}
+
+// retryAt returns when the key may try again, or the zero time if it may right away
+func (g *LoginGuard) retryAt(attempt *user.LoginAttempt, now time.Time) time.Time {
+	if attempt.IsLocked(now) {
+		return *attempt.LockedUntil
+	}
+
+	// Client IPs may be shared by many users behind a NAT, so only accounts are slowed down
+	if !strings.HasPrefix(attempt.Key, loginAttemptKeyAccount) || attempt.Failures < 2 || g.policy.BaseDelay <= 0 {
+		return time.Time{}
+	}
+	delay := g.policy.LockoutDuration
+	if shift := attempt.Failures - 2; shift < 32 {
+		delay = min(g.policy.BaseDelay<<shift, delay)
+	}
+	if t := attempt.LastFailedAt.Add(delay); now.Before(t) {
+		return t
+	}
+	return time.Time{}
+}
+
+func (g *LoginGuard) maxFailures(key string) int {
+	if strings.HasPrefix(key, loginAttemptKeyIP) {
+		return g.policy.MaxIPFailures
+	}
+	return g.policy.MaxAccountFailures
+}
+
+func (g *LoginGuard) keys(email string, clientIP string) []string {
+	keys := []string{accountLoginAttemptKey(email)}
+	if clientIP != "" {
+		keys = append(keys, loginAttemptKeyIP+clientIP)
+	}
+	return keys
+}
+
+func (g *LoginGuard) runCleanupJob(ctx context.Context) {
+	if err := g.attemptRepo.DeleteStaleLoginAttempts(ctx, time.Now().Add(-g.policy.LockoutDuration)); err != nil {
+		g.logger(ctx).Error().Err(err).Msg("failed to delete stale login attempts")
+	}
+}
+
+// logger wrap the execution context with component info
+func (g *LoginGuard) logger(ctx context.Context) *zerolog.Logger {
+	l := zerolog.Ctx(ctx).With().Str("component", "login-guard").Logger()
+	return &l
+}
+
+func accountLoginAttemptKey(email string) string {
+	return loginAttemptKeyAccount + strings.ToLower(strings.TrimSpace(email))
+}
+
+// errTooManyLoginAttempts carries the wait in whole seconds, which the router exposes as the Retry-After header
+func errTooManyLoginAttempts(wait time.Duration) common.Error {
+	msg := "too many failed login attempts, please try again later"
+	return common.NewError(common.ErrorCodeAuthTooManyAttempts, errors.New(msg), common.WithMsg(msg), common.WithDetail(map[string]interface{}{
+		"retry_after": int(math.Ceil(wait.Seconds())),
+	}))
+}
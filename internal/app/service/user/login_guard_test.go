@@ -0,0 +1,119 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/adapter/repository/memory"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+const testClientIP = "192.0.2.1"
+
+func newTestLoginGuard(t *testing.T, repo LoginAttemptRepository, baseDelay time.Duration) *LoginGuard {
+	guard, err := NewLoginGuard(context.Background(), repo, LoginPolicy{
+		MaxAccountFailures: 3,
+		MaxIPFailures:      5,
+		LockoutDuration:    15 * time.Minute,
+		BaseDelay:          baseDelay,
+	})
+	require.Nil(t, err)
+	return guard
+}
+
+// retryAfter returns the retry_after detail of a too many attempts error
+func retryAfter(t *testing.T, err common.Error) int {
+	t.Helper()
+	assertErrorCode(t, common.ErrorCodeAuthTooManyAttempts, err)
+	var domainError common.DomainError
+	require.True(t, errors.As(err, &domainError))
+	seconds, ok := domainError.Detail()["retry_after"].(int)
+	require.True(t, ok)
+	return seconds
+}
+
+func TestLoginGuard_ProgressiveDelay(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	guard := newTestLoginGuard(t, memory.NewLoginAttemptRepository(), time.Minute)
+
+	// The first failure is free
+	require.Nil(t, guard.RecordFailure(ctx, "frank@example.com", testClientIP))
+	require.Nil(t, guard.Check(ctx, "frank@example.com", testClientIP))
+
+	// Each further failure doubles the wait
+	require.Nil(t, guard.RecordFailure(ctx, "frank@example.com", testClientIP))
+	seconds := retryAfter(t, guard.Check(ctx, "Frank@example.com", testClientIP))
+	assert.InDelta(t, 60, seconds, 1)
+
+	// Other accounts from the same client IP are not slowed down
+	assert.Nil(t, guard.Check(ctx, "judy@example.com", testClientIP))
+}
+
+func TestLoginGuard_IPLockout(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	guard := newTestLoginGuard(t, memory.NewLoginAttemptRepository(), 0)
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+		require.Nil(t, guard.RecordFailure(ctx, email, testClientIP))
+	}
+
+	// Every account is locked for the client IP, but not for others
+	seconds := retryAfter(t, guard.Check(ctx, "f@example.com", testClientIP))
+	assert.InDelta(t, 15*60, seconds, 1)
+	assert.Nil(t, guard.Check(ctx, "f@example.com", "198.51.100.7"))
+}
+
+func TestUserService_LoginLockout(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+
+	created, _, err := svc.SignUp(ctx, "ivan@example.com", "ivan", testPassword, testDevice)
+	require.Nil(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = svc.Login(ctx, "ivan@example.com", "wrong-password", testDevice)
+		assertErrorCode(t, common.ErrorCodeAuthNotAuthenticated, err)
+	}
+
+	// The right password no longer helps, even from another client IP
+	_, err = svc.Login(ctx, "ivan@example.com", testPassword, user.Device{IP: "198.51.100.7"})
+	assert.Greater(t, retryAfter(t, err), 0)
+
+	require.Nil(t, svc.UnlockUser(ctx, uuid.New(), created.ID))
+	_, err = svc.Login(ctx, "ivan@example.com", testPassword, testDevice)
+	assert.Nil(t, err)
+}
+
+func TestUserService_LoginSuccessClearsFailures(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+
+	_, _, err := svc.SignUp(ctx, "mallory@example.com", "mallory", testPassword, testDevice)
+	require.Nil(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = svc.Login(ctx, "mallory@example.com", "wrong-password", testDevice)
+		require.NotNil(t, err)
+	}
+	_, err = svc.Login(ctx, "mallory@example.com", testPassword, testDevice)
+	require.Nil(t, err)
+
+	// The count restarted, so two more failures do not reach the limit
+	for i := 0; i < 2; i++ {
+		_, err = svc.Login(ctx, "mallory@example.com", "wrong-password", testDevice)
+		require.NotNil(t, err)
+	}
+	_, err = svc.Login(ctx, "mallory@example.com", testPassword, testDevice)
+	assert.Nil(t, err)
+}
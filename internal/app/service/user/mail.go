@@ -0,0 +1,62 @@
+package user
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sappy5678/DeeliAi/internal/adapter/mailer"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// humanizeDuration renders an expiry such as "30 minutes" or "24 hours" for mail bodies
+func humanizeDuration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	}
+	return fmt.Sprintf("%d minutes", d/time.Minute)
+}
+
+// buildLink joins the app base URL with a path and a token query parameter
+func buildLink(baseURL string, path string, token string) string {
+	return fmt.Sprintf("%s%s?token=%s", strings.TrimRight(baseURL, "/"), path, url.QueryEscape(token))
+}
+
+func newPasswordResetMail(u *user.User, baseURL string, resetToken string, expiry time.Duration) mailer.Message {
+	link := buildLink(baseURL, "/reset-password", resetToken)
+	body := fmt.Sprintf(`Hi %s,
+
+We received a request to reset the password of your DeeliAi account.
+Open the link below to choose a new password. It expires in %s and can be used once.
+
+%s
+
+If you did not ask for a password reset, you can ignore this mail.
+`, u.Username, humanizeDuration(expiry), link)
+
+	return mailer.Message{
+		To:      u.Email,
+		Subject: "Reset your DeeliAi password",
+		Body:    body,
+	}
+}
+
+func newEmailVerificationMail(u *user.User, baseURL string, verificationToken string, expiry time.Duration) mailer.Message {
+	link := buildLink(baseURL, "/api/v1/user/verify", verificationToken)
+	body := fmt.Sprintf(`Hi %s,
+
+Please confirm that %s is the email address of your DeeliAi account by opening the link below.
+It expires in %s.
+
+%s
+
+If you did not sign up for DeeliAi, you can ignore this mail.
+`, u.Username, u.Email, humanizeDuration(expiry), link)
+
+	return mailer.Message{
+		To:      u.Email,
+		Subject: "Confirm your DeeliAi email address",
+		Body:    body,
+	}
+}
@@ -0,0 +1,221 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/adapter/oidc"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+const (
+	// oidcUsernameAttempts is how many usernames are tried for an account created on first sign in
+	oidcUsernameAttempts = 3
+	// usernameMaxLength is the length of the users.username column
+	usernameMaxLength = 50
+)
+
+var usernameDisallowedChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// StartOIDCLogin prepares a sign in at the identity provider and returns the URL to send the user to.
+// The state, nonce and PKCE verifier stay on the server, the state parameter only carries a random token.
+func (s *userService) StartOIDCLogin(ctx context.Context, provider string) (string, common.Error) {
+	p, cerr := s.getOIDCProvider(provider)
+	if cerr != nil {
+		return "", cerr
+	}
+
+	state, err := generateOpaqueToken()
+	if err != nil {
+		return "", common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	nonce, err := generateOpaqueToken()
+	if err != nil {
+		return "", common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	codeVerifier, err := generateOpaqueToken()
+	if err != nil {
+		return "", common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+
+	cerr = s.identityRepo.CreateOIDCState(ctx, &user.OIDCState{
+		StateHash:    hashOpaqueToken(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().Add(s.config.OIDCStateExpiry),
+	})
+	if cerr != nil {
+		return "", cerr
+	}
+
+	return p.AuthCodeURL(ctx, state, nonce, codeVerifier)
+}
+
+// CompleteOIDCLogin redeems the code the identity provider sent back and logs in the user linked to the identity.
+// An identity signing in for the first time is linked to the account with the same email if both sides
+// verified it, or gets a new account if no account uses the email.
+// Users who enabled two-factor authentication still get a challenge for their second factor.
+func (s *userService) CompleteOIDCLogin(ctx context.Context, provider string, state string, code string, device user.Device) (*user.LoginResult, common.Error) {
+	p, cerr := s.getOIDCProvider(provider)
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	invalidState := func() common.Error {
+		msg := "invalid or expired sign in, please try again"
+		cerr := common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg(msg))
+		s.auditLogin(ctx, "", uuid.Nil, cerr)
+		return cerr
+	}
+	stored, cerr := s.identityRepo.ConsumeOIDCState(ctx, hashOpaqueToken(state))
+	if cerr != nil {
+		if common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound) {
+			return nil, invalidState()
+		}
+		return nil, cerr
+	}
+	if stored.Provider != provider || stored.IsExpired(time.Now()) {
+		return nil, invalidState()
+	}
+
+	external, cerr := p.Exchange(ctx, code, stored.CodeVerifier, stored.Nonce)
+	if cerr != nil {
+		s.auditLogin(ctx, "", uuid.Nil, cerr)
+		return nil, cerr
+	}
+
+	u, cerr := s.resolveIdentityUser(ctx, external)
+	if cerr != nil {
+		s.auditLogin(ctx, external.Email, uuid.Nil, cerr)
+		return nil, cerr
+	}
+
+	return s.finishLogin(ctx, u, device)
+}
+
+// resolveIdentityUser returns the user an external identity signs in as, linking or creating it on first sign in
+func (s *userService) resolveIdentityUser(ctx context.Context, external *user.ExternalIdentity) (*user.User, common.Error) {
+	identity, cerr := s.identityRepo.GetIdentity(ctx, external.Provider, external.Subject)
+	if cerr == nil {
+		return s.userRepo.GetUserByID(ctx, identity.UserID)
+	}
+	if !common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound) {
+		return nil, cerr
+	}
+
+	// Without a verified email anyone could claim the address of an existing or future account
+	if external.Email == "" || !external.EmailVerified {
+		msg := "the identity provider did not confirm an email address"
+		return nil, common.NewError(common.ErrorCodeAuthPermissionDenied, errors.New(msg), common.WithMsg(msg))
+	}
+
+	u, cerr := s.userRepo.GetUserByEmail(ctx, external.Email)
+	switch {
+	case cerr == nil:
+		// An unverified local email may have been registered by someone else than its owner,
+		// linking it would hand the account over to whoever registered it
+		if !u.IsEmailVerified() {
+			msg := "an account with this email exists, log in with its password and verify the email first"
+			return nil, common.NewError(common.ErrorCodeResourceConflict, errors.New(msg), common.WithMsg(msg))
+		}
+	case common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound):
+		if u, cerr = s.createIdentityUser(ctx, external); cerr != nil {
+			return nil, cerr
+		}
+	default:
+		return nil, cerr
+	}
+
+	_, cerr = s.identityRepo.CreateIdentity(ctx, &user.Identity{
+		UserID:   u.ID,
+		Provider: external.Provider,
+		Subject:  external.Subject,
+		Email:    external.Email,
+	})
+	if cerr != nil {
+		return nil, cerr
+	}
+	s.logger(ctx).Info().Str("user_id", u.ID.String()).Str("provider", external.Provider).Msg("external identity linked")
+
+	return u, nil
+}
+
+// createIdentityUser creates the account of an identity signing in for the first time.
+// The account has no password, one can be set right after a login or through the password reset flow.
+func (s *userService) createIdentityUser(ctx context.Context, external *user.ExternalIdentity) (*user.User, common.Error) {
+	base := oidcUsername(external)
+
+	var created *user.User
+	var cerr common.Error
+	for attempt := 0; attempt < oidcUsernameAttempts; attempt++ {
+		username := base
+		if attempt > 0 {
+			suffix, err := randomUsernameSuffix()
+			if err != nil {
+				return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+			}
+			username = truncateUsername(base, usernameMaxLength-len(suffix)-1) + "-" + suffix
+		}
+
+		created, cerr = s.userRepo.CreateUser(ctx, &user.User{
+			Email:    external.Email,
+			Username: username,
+		})
+		if cerr == nil || !common.IsErrorCode(cerr, common.ErrorCodeResourceConflict) {
+			break
+		}
+	}
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	if cerr := s.userRepo.MarkUserEmailVerified(ctx, created.ID, created.Email); cerr != nil {
+		return nil, cerr
+	}
+	return s.userRepo.GetUserByID(ctx, created.ID)
+}
+
+func (s *userService) getOIDCProvider(name string) (oidc.Provider, common.Error) {
+	p, ok := s.oidcProviders[name]
+	if !ok {
+		msg := "identity provider is not found"
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New(msg), common.WithMsg(msg))
+	}
+	return p, nil
+}
+
+// oidcUsername picks the username of a new account from what the identity provider shared
+func oidcUsername(external *user.ExternalIdentity) string {
+	localPart, _, _ := strings.Cut(external.Email, "@")
+	for _, candidate := range []string{external.PreferredUsername, external.Name, localPart} {
+		username := strings.Trim(usernameDisallowedChars.ReplaceAllString(candidate, "-"), "-")
+		if username != "" {
+			return truncateUsername(username, usernameMaxLength)
+		}
+	}
+	return "user"
+}
+
+func truncateUsername(username string, maxLength int) string {
+	if len(username) > maxLength {
+		return username[:maxLength]
+	}
+	return username
+}
+
+func randomUsernameSuffix() (string, error) {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,255 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/adapter/oidc"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// fakeIdentityRepository is an in-memory IdentityRepository for unit tests
+type fakeIdentityRepository struct {
+	mu         sync.Mutex
+	identities []*user.Identity
+	states     map[string]*user.OIDCState
+	nextID     int64
+}
+
+func newFakeIdentityRepository() *fakeIdentityRepository {
+	return &fakeIdentityRepository{states: map[string]*user.OIDCState{}}
+}
+
+func (r *fakeIdentityRepository) CreateIdentity(_ context.Context, identity *user.Identity) (*user.Identity, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.identities {
+		if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			return nil, common.NewError(common.ErrorCodeResourceConflict, errors.New("identity already linked"))
+		}
+	}
+	r.nextID++
+	created := *identity
+	created.ID = r.nextID
+	created.CreatedAt = time.Now()
+	r.identities = append(r.identities, &created)
+	copied := created
+	return &copied, nil
+}
+
+func (r *fakeIdentityRepository) GetIdentity(_ context.Context, provider string, subject string) (*user.Identity, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			copied := *identity
+			return &copied, nil
+		}
+	}
+	return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("not found"))
+}
+
+func (r *fakeIdentityRepository) CreateOIDCState(_ context.Context, state *user.OIDCState) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	copied := *state
+	copied.ID = r.nextID
+	r.states[state.StateHash] = &copied
+	return nil
+}
+
+func (r *fakeIdentityRepository) ConsumeOIDCState(_ context.Context, stateHash string) (*user.OIDCState, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, ok := r.states[stateHash]
+	if !ok || state.UsedAt != nil {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("not found"))
+	}
+	now := time.Now()
+	state.UsedAt = &now
+	copied := *state
+	return &copied, nil
+}
+
+// newTestOIDCUserService returns a user service with a "mock" provider backed by a local mock provider
+func newTestOIDCUserService(t *testing.T, identity user.ExternalIdentity) (*testUserService, *oidc.MockProvider) {
+	mock, err := oidc.NewMockProvider("deeliai", "s3cret", identity)
+	require.NoError(t, err)
+	server := httptest.NewServer(mock)
+	t.Cleanup(server.Close)
+
+	client := oidc.NewClient(context.Background(), oidc.Config{
+		Name:         "mock",
+		Issuer:       server.URL,
+		ClientID:     "deeliai",
+		ClientSecret: "s3cret",
+		RedirectURL:  "https://deeliai.test/api/v1/user/oidc/mock/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	})
+	return newTestUserServiceWithProviders(t, map[string]oidc.Provider{"mock": client}), mock
+}
+
+// oidcLogin runs a whole sign in through the mock provider and returns the state and the result
+func oidcLogin(t *testing.T, svc *testUserService) (string, *user.LoginResult, common.Error) {
+	ctx := context.Background()
+	authURL, cerr := svc.StartOIDCLogin(ctx, "mock")
+	require.Nil(t, cerr)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	state := callback.Query().Get("state")
+	result, cerr := svc.CompleteOIDCLogin(ctx, "mock", state, callback.Query().Get("code"), testDevice)
+	return state, result, cerr
+}
+
+func TestUserService_OIDCLoginCreatesAccount(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc, _ := newTestOIDCUserService(t, user.ExternalIdentity{
+		Subject:           "1001",
+		Email:             "quinn@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "Quinn Doe",
+	})
+
+	state, result, err := oidcLogin(t, svc)
+	require.Nil(t, err)
+	require.NotNil(t, result.Token)
+	assert.Equal(t, "Quinn-Doe", result.User.Username)
+	assert.True(t, result.User.IsEmailVerified())
+
+	claims, err := svc.ValidateToken(ctx, result.Token.AccessToken)
+	require.Nil(t, err)
+	assert.Equal(t, result.User.ID, claims.UserID)
+
+	// The identity stays linked to the account
+	_, again, err := oidcLogin(t, svc)
+	require.Nil(t, err)
+	assert.Equal(t, result.User.ID, again.User.ID)
+
+	// A state is single-use
+	_, err = svc.CompleteOIDCLogin(ctx, "mock", state, "any-code", testDevice)
+	assertErrorCode(t, common.ErrorCodeAuthNotAuthenticated, err)
+}
+
+func TestUserService_OIDCLoginLinksVerifiedAccount(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc, _ := newTestOIDCUserService(t, user.ExternalIdentity{Subject: "1002", Email: "rita@example.com", EmailVerified: true})
+	created, _, err := svc.SignUp(ctx, "rita@example.com", "rita", testPassword, testDevice)
+	require.Nil(t, err)
+
+	// Whoever registered the email has not proven owning it yet
+	_, _, err = oidcLogin(t, svc)
+	assertErrorCode(t, common.ErrorCodeResourceConflict, err)
+
+	_, err = svc.VerifyEmail(ctx, tokenFromMail(t, svc.mailer.Messages()[0]))
+	require.Nil(t, err)
+	_, result, err := oidcLogin(t, svc)
+	require.Nil(t, err)
+	assert.Equal(t, created.ID, result.User.ID)
+}
+
+func TestUserService_OIDCLoginRejectsUnverifiedEmail(t *testing.T) {
+	t.Parallel()
+	svc, mock := newTestOIDCUserService(t, user.ExternalIdentity{Subject: "1003", Email: "sam@example.com"})
+
+	_, _, err := oidcLogin(t, svc)
+	assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+
+	mock.SetIdentity(user.ExternalIdentity{Subject: "1003", Email: "sam@example.com", EmailVerified: true})
+	_, result, err := oidcLogin(t, svc)
+	require.Nil(t, err)
+	assert.Equal(t, "sam", result.User.Username)
+}
+
+func TestUserService_OIDCLoginUnknownProvider(t *testing.T) {
+	t.Parallel()
+	svc := newTestUserService(t)
+
+	_, err := svc.StartOIDCLogin(context.Background(), "unknown")
+	assertErrorCode(t, common.ErrorCodeResourceNotFound, err)
+}
+
+// oidcSession signs in through the mock provider and returns the account and the session of the login
+func oidcSession(t *testing.T, svc *testUserService) (*user.User, uuid.UUID) {
+	_, result, err := oidcLogin(t, svc)
+	require.Nil(t, err)
+	claims, err := svc.ValidateToken(context.Background(), result.Token.AccessToken)
+	require.Nil(t, err)
+	return result.User, claims.FamilyID
+}
+
+// backdateSession moves the login of the session to before the reauthentication window
+func backdateSession(svc *testUserService, sessionID uuid.UUID) {
+	svc.tokenRepo.mu.Lock()
+	defer svc.tokenRepo.mu.Unlock()
+	svc.tokenRepo.families[sessionID].CreatedAt = time.Now().Add(-reauthenticationWindow - time.Minute)
+}
+
+func TestUserService_DeleteOIDCAccount(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc, _ := newTestOIDCUserService(t, user.ExternalIdentity{Subject: "1004", Email: "tess@example.com", EmailVerified: true})
+
+	u, staleSession := oidcSession(t, svc)
+	require.False(t, u.HasPassword())
+
+	// Without a password, only a fresh login of the account confirms it is the owner
+	backdateSession(svc, staleSession)
+	err := svc.DeleteAccount(ctx, u.ID, staleSession, "")
+	assertErrorCode(t, common.ErrorCodeAuthNotAuthenticated, err)
+	_, other, err := svc.SignUp(ctx, "victor@example.com", "victor", testPassword, testDevice)
+	require.Nil(t, err)
+	otherClaims, err := svc.ValidateToken(ctx, other.AccessToken)
+	require.Nil(t, err)
+	err = svc.DeleteAccount(ctx, u.ID, otherClaims.FamilyID, "")
+	assertErrorCode(t, common.ErrorCodeAuthNotAuthenticated, err)
+
+	_, freshSession := oidcSession(t, svc)
+	require.Nil(t, svc.DeleteAccount(ctx, u.ID, freshSession, ""))
+	_, err = svc.GetUser(ctx, u.ID)
+	assertErrorCode(t, common.ErrorCodeResourceNotFound, err)
+}
+
+func TestUserService_OIDCAccountSetsPassword(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc, _ := newTestOIDCUserService(t, user.ExternalIdentity{Subject: "1005", Email: "uma@example.com", EmailVerified: true})
+
+	u, session := oidcSession(t, svc)
+	backdateSession(svc, session)
+	_, err := svc.ChangePassword(ctx, u.ID, session, "", testPassword, testDevice)
+	assertErrorCode(t, common.ErrorCodeAuthNotAuthenticated, err)
+
+	_, session = oidcSession(t, svc)
+	_, err = svc.ChangePassword(ctx, u.ID, session, "", testPassword, testDevice)
+	require.Nil(t, err)
+	_, err = svc.Login(ctx, "uma@example.com", testPassword, testDevice)
+	require.Nil(t, err)
+
+	// Once set, the password is needed again whatever the session
+	_, session = oidcSession(t, svc)
+	_, err = svc.ChangePassword(ctx, u.ID, session, "", "another-long-password", testDevice)
+	assertErrorCode(t, common.ErrorCodeAuthNotAuthenticated, err)
+}
@@ -0,0 +1,247 @@
+package user
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// Supported password hash algorithms
+const (
+	PasswordHashArgon2id = "argon2id"
+	PasswordHashBcrypt   = "bcrypt"
+)
+
+// argon2idParams are the cost parameters of an argon2id hash
+type argon2idParams struct {
+	Memory      uint32 // in KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  int
+	KeyLength   int
+}
+
+// defaultArgon2idParams follow the OWASP recommendation for argon2id
+var defaultArgon2idParams = argon2idParams{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// PasswordHasher hashes new passwords with one algorithm and verifies hashes of every supported algorithm.
+// Hashes are self-describing, they carry the algorithm and its parameters, so that the algorithm can change
+// while existing hashes keep working and get replaced on the next successful login.
+type PasswordHasher struct {
+	algorithm  string
+	argon2id   argon2idParams
+	bcryptCost int
+}
+
+// NewPasswordHasher creates a hasher writing hashes of the algorithm, argon2id unless it is PasswordHashBcrypt.
+func NewPasswordHasher(algorithm string) *PasswordHasher {
+	if algorithm != PasswordHashBcrypt {
+		algorithm = PasswordHashArgon2id
+	}
+	return &PasswordHasher{
+		algorithm:  algorithm,
+		argon2id:   defaultArgon2idParams,
+		bcryptCost: bcrypt.DefaultCost,
+	}
+}
+
+// Hash returns the hash of the password with the configured algorithm and parameters.
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.algorithm == PasswordHashBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, h.argon2id.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return encodeArgon2id(h.argon2id, salt, deriveArgon2id(password, salt, h.argon2id)), nil
+}
+
+// Verify reports whether the password matches the hash, and if so whether the hash should be replaced
+// because it was written with another algorithm or outdated parameters.
+// An empty hash, as accounts created through an identity provider have, matches no password.
+func (h *PasswordHasher) Verify(password string, hash string) (bool, bool, error) {
+	switch {
+	case hash == "":
+		return false, false, nil
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, false, err
+		}
+		derived := deriveArgon2id(password, salt, params)
+		if subtle.ConstantTimeCompare(derived, key) != 1 {
+			return false, false, nil
+		}
+		return true, h.algorithm != PasswordHashArgon2id || params != h.argon2id, nil
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		// bcrypt hashes no password over 72 bytes, so none of them can match
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) || errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return false, false, err
+		}
+		return true, h.algorithm != PasswordHashBcrypt || cost != h.bcryptCost, nil
+	default:
+		return false, false, errors.New("unknown password hash format")
+	}
+}
+
+func deriveArgon2id(password string, salt []byte, params argon2idParams) []byte {
+	return argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(params.KeyLength))
+}
+
+// encodeArgon2id writes the PHC string format, e.g. $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+func encodeArgon2id(params argon2idParams, salt []byte, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeArgon2id(hash string) (argon2idParams, []byte, []byte, error) {
+	var params argon2idParams
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+	params.SaltLength = len(salt)
+	params.KeyLength = len(key)
+
+	return params, salt, key, nil
+}
+
+// Reasons a password policy rejects a password for
+const (
+	PasswordViolationTooShort = "too_short"
+	PasswordViolationTooLong  = "too_long"
+	PasswordViolationCommon   = "common"
+	PasswordViolationPersonal = "personal"
+)
+
+const (
+	defaultPasswordMinLength = 8
+	// passwordMaxLength bounds the work of hashing a password
+	passwordMaxLength = 256
+	// bcryptMaxBytes is the longest password bcrypt hashes
+	bcryptMaxBytes = 72
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// PasswordPolicy decides which passwords users may choose.
+type PasswordPolicy struct {
+	MinLength int
+	// MaxBytes limits the UTF-8 length of passwords when the hash algorithm has a limit, 0 means none
+	MaxBytes int
+	common   map[string]struct{}
+}
+
+// NewPasswordPolicy creates a policy requiring minLength characters, which rejects the passwords of
+// the embedded list of common and breached passwords, and passwords too long for the hash algorithm.
+func NewPasswordPolicy(minLength int, hashAlgorithm string) *PasswordPolicy {
+	if minLength <= 0 {
+		minLength = defaultPasswordMinLength
+	}
+
+	commonPasswords := map[string]struct{}{}
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswordList))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			commonPasswords[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	policy := &PasswordPolicy{MinLength: minLength, common: commonPasswords}
+	if hashAlgorithm == PasswordHashBcrypt {
+		policy.MaxBytes = bcryptMaxBytes
+	}
+	return policy
+}
+
+// Validate checks a new password of the user with the email and username.
+// It returns a PARAMETER_INVALID error whose detail lists every violation.
+func (p *PasswordPolicy) Validate(password string, email string, username string) common.Error {
+	var violations []string
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, PasswordViolationTooShort)
+	}
+	if length > passwordMaxLength || (p.MaxBytes > 0 && len(password) > p.MaxBytes) {
+		violations = append(violations, PasswordViolationTooLong)
+	}
+
+	lowered := strings.ToLower(password)
+	if _, ok := p.common[lowered]; ok {
+		violations = append(violations, PasswordViolationCommon)
+	}
+	localPart, _, _ := strings.Cut(strings.ToLower(email), "@")
+	if lowered == strings.ToLower(email) || lowered == localPart || lowered == strings.ToLower(username) {
+		violations = append(violations, PasswordViolationPersonal)
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	msg := "password does not meet the password policy"
+	detail := map[string]interface{}{
+		"field":      "password",
+		"violations": violations,
+		"min_length": p.MinLength,
+		"max_length": passwordMaxLength,
+	}
+	if p.MaxBytes > 0 {
+		detail["max_bytes"] = p.MaxBytes
+	}
+	return common.NewError(common.ErrorCodeParameterInvalid, fmt.Errorf("%s: %s", msg, strings.Join(violations, ", ")),
+		common.WithMsg(msg),
+		common.WithDetail(detail),
+	)
+}
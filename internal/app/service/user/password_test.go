@@ -0,0 +1,153 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+func TestPasswordHasher_Argon2id(t *testing.T) {
+	t.Parallel()
+	hasher := NewPasswordHasher(PasswordHashArgon2id)
+
+	hash, err := hasher.Hash(testPassword)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=19456,t=2,p=1$"), hash)
+
+	another, err := hasher.Hash(testPassword)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, another, "hashes are salted")
+
+	ok, needsRehash, err := hasher.Verify(testPassword, hash)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, needsRehash)
+
+	ok, _, err = hasher.Verify("wrong-password", hash)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Hashes of weaker parameters still verify, but are due for a rehash
+	weaker := encodeArgon2id(argon2idParams{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		[]byte("0123456789abcdef"), deriveArgon2id(testPassword, []byte("0123456789abcdef"), argon2idParams{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, KeyLength: 32}))
+	ok, needsRehash, err = hasher.Verify(testPassword, weaker)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, needsRehash)
+}
+
+func TestPasswordHasher_LegacyBcrypt(t *testing.T) {
+	t.Parallel()
+	legacy, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.DefaultCost)
+	require.NoError(t, err)
+
+	ok, needsRehash, err := NewPasswordHasher(PasswordHashArgon2id).Verify(testPassword, string(legacy))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, needsRehash)
+
+	ok, needsRehash, err = NewPasswordHasher(PasswordHashBcrypt).Verify(testPassword, string(legacy))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, needsRehash)
+
+	ok, _, err = NewPasswordHasher(PasswordHashArgon2id).Verify("wrong-password", string(legacy))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Passwords bcrypt cannot hash are a mismatch, not an error
+	ok, _, err = NewPasswordHasher(PasswordHashBcrypt).Verify(strings.Repeat("x", 73), string(legacy))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Accounts without a password match nothing
+	ok, _, err = NewPasswordHasher(PasswordHashArgon2id).Verify("", "")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	t.Parallel()
+	policy := NewPasswordPolicy(10, PasswordHashArgon2id)
+
+	assert.Nil(t, policy.Validate(testPassword, "alice@example.com", "alice"))
+
+	tests := []struct {
+		password   string
+		violations []string
+	}{
+		{"short", []string{PasswordViolationTooShort}},
+		{"Password123", []string{PasswordViolationCommon}},
+		{"alice@example.com", []string{PasswordViolationPersonal}},
+		{"alice-the-user", []string{PasswordViolationPersonal}},
+		{strings.Repeat("x", 257), []string{PasswordViolationTooLong}},
+	}
+	for _, tt := range tests {
+		err := policy.Validate(tt.password, "alice@example.com", "alice-the-user")
+		assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+		var domainError common.DomainError
+		require.True(t, errors.As(err, &domainError))
+		assert.Equal(t, "password", domainError.Detail()["field"])
+		assert.Equal(t, tt.violations, domainError.Detail()["violations"], tt.password)
+		assert.Equal(t, 10, domainError.Detail()["min_length"])
+	}
+}
+
+func TestPasswordPolicy_BcryptMaxBytes(t *testing.T) {
+	t.Parallel()
+	policy := NewPasswordPolicy(10, PasswordHashBcrypt)
+
+	assert.Nil(t, policy.Validate(strings.Repeat("x", 72), "alice@example.com", "alice"))
+
+	// 25 characters of 3 bytes each are 75 bytes
+	for _, password := range []string{strings.Repeat("x", 73), strings.Repeat("密", 25)} {
+		err := policy.Validate(password, "alice@example.com", "alice")
+		assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+		var domainError common.DomainError
+		require.True(t, errors.As(err, &domainError))
+		assert.Equal(t, []string{PasswordViolationTooLong}, domainError.Detail()["violations"])
+		assert.Equal(t, 72, domainError.Detail()["max_bytes"])
+	}
+
+	// Hashes are written with argon2id, which takes the same passwords
+	assert.Nil(t, NewPasswordPolicy(10, PasswordHashArgon2id).Validate(strings.Repeat("x", 73), "alice@example.com", "alice"))
+}
+
+func TestUserService_SignUpRejectsCommonPassword(t *testing.T) {
+	t.Parallel()
+	svc := newTestUserService(t)
+
+	_, _, err := svc.SignUp(context.Background(), "trent@example.com", "trent", "password", testDevice)
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+	_, err = svc.userRepo.GetUserByEmail(context.Background(), "trent@example.com")
+	assert.NotNil(t, err)
+}
+
+func TestUserService_LoginRehashesLegacyPassword(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+	legacy, hashErr := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	require.NoError(t, hashErr)
+	created, err := svc.userRepo.CreateUser(ctx, &user.User{Email: "uma@example.com", Username: "uma", PasswordHash: string(legacy)})
+	require.Nil(t, err)
+
+	_, err = svc.Login(ctx, "uma@example.com", testPassword, testDevice)
+	require.Nil(t, err)
+
+	stored, err := svc.userRepo.GetUserByID(ctx, created.ID)
+	require.Nil(t, err)
+	assert.True(t, strings.HasPrefix(stored.PasswordHash, "$argon2id$"), stored.PasswordHash)
+
+	// The password keeps working with the new hash
+	_, err = svc.Login(ctx, "uma@example.com", testPassword, testDevice)
+	assert.Nil(t, err)
+}
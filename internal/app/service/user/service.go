@@ -2,69 +2,185 @@ package user
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/rs/zerolog"
 
+	"github.com/sappy5678/DeeliAi/internal/adapter/mailer"
+	"github.com/sappy5678/DeeliAi/internal/adapter/oidc"
 	"github.com/sappy5678/DeeliAi/internal/adapter/repository/postgres"
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
 	"github.com/sappy5678/DeeliAi/internal/domain/common"
 	"github.com/sappy5678/DeeliAi/internal/domain/user"
 )
 
+// reauthenticationWindow is how long after logging in a user without a password may change or delete the account
+const reauthenticationWindow = 5 * time.Minute
+
+// Config holds the settings of the user service
+type Config struct {
+	// AppBaseURL is the public URL links in mails point to
+	AppBaseURL                   string
+	PasswordResetTokenExpiry     time.Duration
+	EmailVerificationTokenExpiry time.Duration
+	// TwoFactorIssuer is the name authenticator apps show next to the account
+	TwoFactorIssuer string
+	// LoginChallengeExpiry is how long a login may wait for its second factor
+	LoginChallengeExpiry time.Duration
+	// OIDCStateExpiry is how long a sign in at an identity provider may take
+	OIDCStateExpiry time.Duration
+	// PasswordHashAlgorithm is what new password hashes are written with, PasswordHashArgon2id by default
+	PasswordHashAlgorithm string
+	// PasswordMinLength is the number of characters a new password needs at least
+	PasswordMinLength int
+}
+
 type userService struct {
 	TokenService
-	userRepo postgres.UserRepository
+	APIKeyService
+	userRepo         postgres.UserRepository
+	resetRepo        PasswordResetRepository
+	verificationRepo EmailVerificationRepository
+	twoFactorRepo    TwoFactorRepository
+	identityRepo     IdentityRepository
+	workspaceRepo    WorkspaceRepository
+	loginGuard       *LoginGuard
+	oidcProviders    map[string]oidc.Provider
+	passwords        *PasswordHasher
+	passwordPolicy   *PasswordPolicy
+	mailer           mailer.Mailer
+	auditor          AuditRecorder
+	config           Config
 }
 
-func NewUserService(ctx context.Context, userRepo postgres.UserRepository, authService TokenService) Service {
+func NewUserService(ctx context.Context, userRepo postgres.UserRepository, resetRepo PasswordResetRepository, verificationRepo EmailVerificationRepository, twoFactorRepo TwoFactorRepository, identityRepo IdentityRepository, workspaceRepo WorkspaceRepository, authService TokenService, apiKeyService APIKeyService, loginGuard *LoginGuard, oidcProviders map[string]oidc.Provider, mailer mailer.Mailer, auditor AuditRecorder, config Config) Service {
 	return &userService{
-		userRepo:     userRepo,
-		TokenService: authService,
+		userRepo:         userRepo,
+		resetRepo:        resetRepo,
+		verificationRepo: verificationRepo,
+		twoFactorRepo:    twoFactorRepo,
+		identityRepo:     identityRepo,
+		workspaceRepo:    workspaceRepo,
+		TokenService:     authService,
+		APIKeyService:    apiKeyService,
+		loginGuard:       loginGuard,
+		oidcProviders:    oidcProviders,
+		passwords:        NewPasswordHasher(config.PasswordHashAlgorithm),
+		passwordPolicy:   NewPasswordPolicy(config.PasswordMinLength, config.PasswordHashAlgorithm),
+		mailer:           mailer,
+		auditor:          auditor,
+		config:           config,
 	}
 }
 
-func (s *userService) SignUp(ctx context.Context, email string, username string, password string) (*user.User, string, common.Error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+func (s *userService) SignUp(ctx context.Context, email string, username string, password string, device user.Device) (*user.User, *user.Token, common.Error) {
+	if cerr := s.passwordPolicy.Validate(password, email, username); cerr != nil {
+		s.recordAudit(ctx, audit.ActionSignUp, uuid.Nil, audit.TargetEmail, email, cerr)
+		return nil, nil, cerr
+	}
+	hashedPassword, err := s.passwords.Hash(password)
 	if err != nil {
-		return nil, "", common.NewError(common.ErrorCodeInternalProcess, err)
+		return nil, nil, common.NewError(common.ErrorCodeInternalProcess, err)
 	}
 
 	newUser := &user.User{
 		Email:        email,
 		Username:     username,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 	}
 
 	createdUser, cerr := s.userRepo.CreateUser(ctx, newUser)
 	if cerr != nil {
-		return nil, "", cerr
+		s.recordAudit(ctx, audit.ActionSignUp, uuid.Nil, audit.TargetEmail, email, cerr)
+		return nil, nil, cerr
 	}
+	s.recordAudit(ctx, audit.ActionSignUp, createdUser.ID, audit.TargetUser, createdUser.ID.String(), nil)
 
-	token, err := s.TokenService.GenerateToken(ctx, createdUser.ID)
-	if err != nil {
-		return nil, "", common.NewError(common.ErrorCodeInternalProcess, err)
+	// The account is usable right away, a failed confirmation mail can be resent later
+	if cerr := s.sendVerificationMail(ctx, createdUser); cerr != nil {
+		s.logger(ctx).Error().Err(cerr).Str("user_id", createdUser.ID.String()).Msg("failed to send email verification mail")
+	}
+
+	token, cerr := s.TokenService.GenerateToken(ctx, createdUser.ID, device)
+	if cerr != nil {
+		return nil, nil, cerr
 	}
 
 	return createdUser, token, nil
 }
 
-func (s *userService) Login(ctx context.Context, email string, password string) (*user.User, string, common.Error) {
+// Login checks the credentials and issues a token pair, or a challenge for the second factor
+// if the user enabled two-factor authentication.
+// Failures are counted per account and per client IP, and too many of them lock further attempts out
+// before any password is compared.
+func (s *userService) Login(ctx context.Context, email string, password string, device user.Device) (*user.LoginResult, common.Error) {
+	if cerr := s.loginGuard.Check(ctx, email, device.IP); cerr != nil {
+		s.auditLogin(ctx, email, uuid.Nil, cerr)
+		return nil, cerr
+	}
+
 	foundUser, cerr := s.userRepo.GetUserByEmail(ctx, email)
 	if cerr != nil {
-		return nil, "", cerr
+		s.recordLoginFailure(ctx, email, device.IP)
+		s.auditLogin(ctx, email, uuid.Nil, cerr)
+		return nil, cerr
 	}
 
-	err := bcrypt.CompareHashAndPassword([]byte(foundUser.PasswordHash), []byte(password))
-	if err != nil {
-		return nil, "", common.NewError(common.ErrorCodeAuthNotAuthenticated, err, common.WithMsg("invalid password")) // Changed here
+	needsRehash, cerr := s.verifyPassword(foundUser, password)
+	if cerr != nil {
+		s.recordLoginFailure(ctx, email, device.IP)
+		s.auditLogin(ctx, email, foundUser.ID, cerr)
+		return nil, cerr
+	}
+	if needsRehash {
+		s.rehashPassword(ctx, foundUser, password)
 	}
 
-	token, err := s.TokenService.GenerateToken(ctx, foundUser.ID)
-	if err != nil {
-		return nil, "", common.NewError(common.ErrorCodeInternalProcess, err)
+	return s.finishLogin(ctx, foundUser, device)
+}
+
+// finishLogin continues a login once the first factor passed,
+// returning a challenge if the user enabled two-factor authentication and a token pair otherwise
+func (s *userService) finishLogin(ctx context.Context, u *user.User, device user.Device) (*user.LoginResult, common.Error) {
+	if u.IsDisabled() {
+		cerr := errAccountDisabled()
+		s.auditLogin(ctx, u.Email, u.ID, cerr)
+		return nil, cerr
+	}
+
+	totp, cerr := s.getTOTP(ctx, u.ID)
+	if cerr != nil {
+		return nil, cerr
+	}
+	if totp != nil && totp.IsConfirmed() {
+		// Failures are only cleared once the second factor passed,
+		// so that knowing the password does not buy unlimited code guesses
+		challenge, cerr := s.createLoginChallenge(ctx, u.ID)
+		if cerr != nil {
+			return nil, cerr
+		}
+		return &user.LoginResult{User: u, Challenge: challenge}, nil
 	}
 
-	return foundUser, token, nil
+	token, cerr := s.completeLogin(ctx, u, device)
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	return &user.LoginResult{User: u, Token: token}, nil
+}
+
+// completeLogin clears the failed logins of a user who passed every factor and issues a token pair
+func (s *userService) completeLogin(ctx context.Context, u *user.User, device user.Device) (*user.Token, common.Error) {
+	if cerr := s.loginGuard.RecordSuccess(ctx, u.Email); cerr != nil {
+		s.logger(ctx).Error().Err(cerr).Str("user_id", u.ID.String()).Msg("failed to clear failed logins")
+	}
+
+	token, cerr := s.TokenService.GenerateToken(ctx, u.ID, device)
+	s.auditLogin(ctx, u.Email, u.ID, cerr)
+	return token, cerr
 }
 
 func (s *userService) GetUser(ctx context.Context, userID uuid.UUID) (*user.User, common.Error) {
@@ -75,3 +191,310 @@ func (s *userService) GetUser(ctx context.Context, userID uuid.UUID) (*user.User
 
 	return foundUser, nil
 }
+
+// ForgotPassword mails a password reset link to the user owning the email.
+// It succeeds even if no such user exists, so that callers cannot probe for registered emails.
+func (s *userService) ForgotPassword(ctx context.Context, email string) common.Error {
+	foundUser, cerr := s.userRepo.GetUserByEmail(ctx, email)
+	if cerr != nil {
+		s.logger(ctx).Info().Err(cerr).Msg("skip password reset for unknown email")
+		return nil
+	}
+
+	resetToken, err := generateOpaqueToken()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	cerr = s.resetRepo.CreatePasswordResetToken(ctx, &user.PasswordResetToken{
+		UserID:    foundUser.ID,
+		TokenHash: hashOpaqueToken(resetToken),
+		ExpiresAt: time.Now().Add(s.config.PasswordResetTokenExpiry),
+	})
+	if cerr != nil {
+		return cerr
+	}
+
+	msg := newPasswordResetMail(foundUser, s.config.AppBaseURL, resetToken, s.config.PasswordResetTokenExpiry)
+	if cerr := s.mailer.Send(ctx, msg); cerr != nil {
+		// Do not surface delivery failures, they would tell the caller the email is registered
+		s.logger(ctx).Error().Err(cerr).Str("user_id", foundUser.ID.String()).Msg("failed to send password reset mail")
+	}
+
+	return nil
+}
+
+// ResetPassword consumes a reset token, sets the new password and logs the user out everywhere.
+func (s *userService) ResetPassword(ctx context.Context, resetToken string, newPassword string) common.Error {
+	invalidToken := func() common.Error {
+		msg := "invalid or expired password reset token"
+		cerr := common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+		s.recordAudit(ctx, audit.ActionPasswordReset, uuid.Nil, "", "", cerr)
+		return cerr
+	}
+
+	stored, cerr := s.resetRepo.GetPasswordResetTokenByHash(ctx, hashOpaqueToken(resetToken))
+	if cerr != nil {
+		return invalidToken()
+	}
+	if stored.IsUsed() || stored.IsExpired(time.Now()) {
+		return invalidToken()
+	}
+
+	// The password is checked before the token is used up, so that the user can pick another one
+	foundUser, cerr := s.userRepo.GetUserByID(ctx, stored.UserID)
+	if cerr != nil {
+		return cerr
+	}
+	if cerr := s.passwordPolicy.Validate(newPassword, foundUser.Email, foundUser.Username); cerr != nil {
+		return cerr
+	}
+
+	used, cerr := s.resetRepo.UsePasswordResetToken(ctx, stored.ID)
+	if cerr != nil {
+		return cerr
+	}
+	if !used {
+		return invalidToken()
+	}
+
+	hashedPassword, err := s.passwords.Hash(newPassword)
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	if cerr := s.userRepo.UpdateUserPassword(ctx, stored.UserID, hashedPassword); cerr != nil {
+		return cerr
+	}
+	s.recordAudit(ctx, audit.ActionPasswordReset, stored.UserID, audit.TargetUser, stored.UserID.String(), nil)
+
+	if cerr := s.resetRepo.InvalidateUserPasswordResetTokens(ctx, stored.UserID); cerr != nil {
+		return cerr
+	}
+	return s.TokenService.RevokeUserTokens(ctx, stored.UserID)
+}
+
+// UpdateProfile changes the username and/or email of the user, nil leaves a field unchanged.
+// A new email has to be verified again, so a confirmation mail is sent to it.
+func (s *userService) UpdateProfile(ctx context.Context, userID uuid.UUID, username *string, email *string) (*user.User, common.Error) {
+	foundUser, cerr := s.userRepo.GetUserByID(ctx, userID)
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	newUsername, newEmail := foundUser.Username, foundUser.Email
+	if username != nil {
+		newUsername = *username
+	}
+	if email != nil {
+		newEmail = *email
+	}
+	if newUsername == foundUser.Username && newEmail == foundUser.Email {
+		return foundUser, nil
+	}
+
+	updatedUser, cerr := s.userRepo.UpdateUserProfile(ctx, userID, newUsername, newEmail)
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	if updatedUser.Email != foundUser.Email {
+		if cerr := s.verificationRepo.InvalidateUserEmailVerificationTokens(ctx, userID); cerr != nil {
+			return nil, cerr
+		}
+		if cerr := s.sendVerificationMail(ctx, updatedUser); cerr != nil {
+			s.logger(ctx).Error().Err(cerr).Str("user_id", userID.String()).Msg("failed to send email verification mail")
+		}
+	}
+
+	return updatedUser, nil
+}
+
+// ChangePassword sets a new password after checking the current one, users without a password set their first one.
+// Every existing login of the user is revoked and a new token pair is issued to the caller.
+func (s *userService) ChangePassword(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID, currentPassword string, newPassword string, device user.Device) (*user.Token, common.Error) {
+	foundUser, cerr := s.userRepo.GetUserByID(ctx, userID)
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	if cerr := s.reauthenticate(ctx, foundUser, sessionID, currentPassword); cerr != nil {
+		s.recordAudit(ctx, audit.ActionPasswordChange, userID, audit.TargetUser, userID.String(), cerr)
+		return nil, cerr
+	}
+	if cerr := s.passwordPolicy.Validate(newPassword, foundUser.Email, foundUser.Username); cerr != nil {
+		return nil, cerr
+	}
+
+	hashedPassword, err := s.passwords.Hash(newPassword)
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	if cerr := s.userRepo.UpdateUserPassword(ctx, userID, hashedPassword); cerr != nil {
+		return nil, cerr
+	}
+	s.recordAudit(ctx, audit.ActionPasswordChange, userID, audit.TargetUser, userID.String(), nil)
+	if cerr := s.resetRepo.InvalidateUserPasswordResetTokens(ctx, userID); cerr != nil {
+		return nil, cerr
+	}
+	if cerr := s.TokenService.RevokeUserTokens(ctx, userID); cerr != nil {
+		return nil, cerr
+	}
+
+	return s.TokenService.GenerateToken(ctx, userID, device)
+}
+
+// DeleteAccount deletes the user after checking the password again, together with the workspaces nobody else is a member of.
+// The last owner of a workspace with other members has to hand it over or delete it first.
+func (s *userService) DeleteAccount(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID, password string) common.Error {
+	foundUser, cerr := s.userRepo.GetUserByID(ctx, userID)
+	if cerr != nil {
+		return cerr
+	}
+
+	if cerr := s.reauthenticate(ctx, foundUser, sessionID, password); cerr != nil {
+		s.recordAudit(ctx, audit.ActionAccountDelete, userID, audit.TargetUser, userID.String(), cerr)
+		return cerr
+	}
+
+	if cerr := s.workspaceRepo.LeaveUserWorkspaces(ctx, userID); cerr != nil {
+		s.recordAudit(ctx, audit.ActionAccountDelete, userID, audit.TargetUser, userID.String(), cerr)
+		return cerr
+	}
+	if cerr := s.TokenService.RevokeUserTokens(ctx, userID); cerr != nil {
+		return cerr
+	}
+	if cerr := s.userRepo.DeleteUser(ctx, userID); cerr != nil {
+		return cerr
+	}
+	s.recordAudit(ctx, audit.ActionAccountDelete, userID, audit.TargetUser, userID.String(), nil)
+	s.logger(ctx).Info().Str("user_id", userID.String()).Msg("user account deleted")
+
+	return nil
+}
+
+// VerifyEmail consumes a verification token and confirms the address it was sent to.
+func (s *userService) VerifyEmail(ctx context.Context, verificationToken string) (*user.User, common.Error) {
+	invalidToken := func() common.Error {
+		msg := "invalid or expired email verification token"
+		return common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+
+	stored, cerr := s.verificationRepo.GetEmailVerificationTokenByHash(ctx, hashOpaqueToken(verificationToken))
+	if cerr != nil {
+		return nil, invalidToken()
+	}
+	if stored.IsUsed() || stored.IsExpired(time.Now()) {
+		return nil, invalidToken()
+	}
+
+	used, cerr := s.verificationRepo.UseEmailVerificationToken(ctx, stored.ID)
+	if cerr != nil {
+		return nil, cerr
+	}
+	if !used {
+		return nil, invalidToken()
+	}
+
+	// Fails if the user changed the email after the token was sent
+	if cerr := s.userRepo.MarkUserEmailVerified(ctx, stored.UserID, stored.Email); cerr != nil {
+		return nil, invalidToken()
+	}
+
+	return s.userRepo.GetUserByID(ctx, stored.UserID)
+}
+
+// ResendVerificationEmail sends a new confirmation mail and invalidates the previous ones.
+func (s *userService) ResendVerificationEmail(ctx context.Context, userID uuid.UUID) common.Error {
+	foundUser, cerr := s.userRepo.GetUserByID(ctx, userID)
+	if cerr != nil {
+		return cerr
+	}
+	if foundUser.IsEmailVerified() {
+		msg := "email is already verified"
+		return common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+
+	if cerr := s.verificationRepo.InvalidateUserEmailVerificationTokens(ctx, userID); cerr != nil {
+		return cerr
+	}
+	return s.sendVerificationMail(ctx, foundUser)
+}
+
+// verifyPassword checks the password of the user, and reports whether its hash is outdated
+func (s *userService) verifyPassword(u *user.User, password string) (bool, common.Error) {
+	ok, needsRehash, err := s.passwords.Verify(password, u.PasswordHash)
+	if err != nil {
+		return false, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	if !ok {
+		msg := "invalid password"
+		return false, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg(msg))
+	}
+	return needsRehash, nil
+}
+
+// reauthenticate makes sure the owner of the account is at hand before a change to it.
+// Users with a password type it again. Users who only sign in through an identity provider have
+// no password to type, so the session must come from a login within reauthenticationWindow.
+func (s *userService) reauthenticate(ctx context.Context, u *user.User, sessionID uuid.UUID, password string) common.Error {
+	if u.HasPassword() {
+		_, cerr := s.verifyPassword(u, password)
+		return cerr
+	}
+
+	session, cerr := s.GetSession(ctx, u.ID, sessionID)
+	if cerr != nil && !common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound) {
+		return cerr
+	}
+	if cerr != nil || time.Since(session.CreatedAt) > reauthenticationWindow {
+		msg := "log in again to confirm it is you"
+		return common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg(msg))
+	}
+	return nil
+}
+
+// rehashPassword replaces an outdated password hash, which is only possible while the password is at hand.
+// A failure is logged, the login goes on with the old hash.
+func (s *userService) rehashPassword(ctx context.Context, u *user.User, password string) {
+	hashedPassword, err := s.passwords.Hash(password)
+	if err != nil {
+		s.logger(ctx).Error().Err(err).Str("user_id", u.ID.String()).Msg("failed to rehash password")
+		return
+	}
+	if cerr := s.userRepo.UpdateUserPassword(ctx, u.ID, hashedPassword); cerr != nil {
+		s.logger(ctx).Error().Err(cerr).Str("user_id", u.ID.String()).Msg("failed to store rehashed password")
+		return
+	}
+	s.logger(ctx).Info().Str("user_id", u.ID.String()).Msg("password rehashed")
+}
+
+// recordLoginFailure counts a failed login. A failure to count it is logged rather than failing the login,
+// the lockout check ahead of it already fails closed when the store is unavailable.
+func (s *userService) recordLoginFailure(ctx context.Context, email string, clientIP string) {
+	if cerr := s.loginGuard.RecordFailure(ctx, email, clientIP); cerr != nil {
+		s.logger(ctx).Error().Err(cerr).Msg("failed to record failed login")
+	}
+}
+
+func (s *userService) sendVerificationMail(ctx context.Context, u *user.User) common.Error {
+	verificationToken, err := generateOpaqueToken()
+	if err != nil {
+		return common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	cerr := s.verificationRepo.CreateEmailVerificationToken(ctx, &user.EmailVerificationToken{
+		UserID:    u.ID,
+		Email:     u.Email,
+		TokenHash: hashOpaqueToken(verificationToken),
+		ExpiresAt: time.Now().Add(s.config.EmailVerificationTokenExpiry),
+	})
+	if cerr != nil {
+		return cerr
+	}
+
+	return s.mailer.Send(ctx, newEmailVerificationMail(u, s.config.AppBaseURL, verificationToken, s.config.EmailVerificationTokenExpiry))
+}
+
+// logger wrap the execution context with component info
+func (s *userService) logger(ctx context.Context) *zerolog.Logger {
+	l := zerolog.Ctx(ctx).With().Str("component", "user-service").Logger()
+	return &l
+}
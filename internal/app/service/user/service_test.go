@@ -0,0 +1,559 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/adapter/mailer"
+	"github.com/sappy5678/DeeliAi/internal/adapter/oidc"
+	"github.com/sappy5678/DeeliAi/internal/adapter/repository/memory"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+	"github.com/sappy5678/DeeliAi/internal/domain/workspace"
+)
+
+// fakeUserRepository is an in-memory UserRepository for unit tests
+type fakeUserRepository struct {
+	mu    sync.Mutex
+	users map[uuid.UUID]*user.User
+}
+
+func newFakeUserRepository() *fakeUserRepository {
+	return &fakeUserRepository{users: map[uuid.UUID]*user.User{}}
+}
+
+func (r *fakeUserRepository) CreateUser(_ context.Context, u *user.User) (*user.User, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	created := *u
+	created.ID = uuid.New()
+	if created.Role == "" {
+		created.Role = user.RoleUser
+	}
+	r.users[created.ID] = &created
+	copied := created
+	return &copied, nil
+}
+
+func (r *fakeUserRepository) GetUserByEmail(_ context.Context, email string) (*user.User, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Email == email {
+			copied := *u
+			return &copied, nil
+		}
+	}
+	return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("user is not found"))
+}
+
+func (r *fakeUserRepository) GetUserByID(_ context.Context, id uuid.UUID) (*user.User, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("user is not found"))
+	}
+	copied := *u
+	return &copied, nil
+}
+
+func (r *fakeUserRepository) UpdateUserPassword(_ context.Context, id uuid.UUID, passwordHash string) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("user is not found"))
+	}
+	u.PasswordHash = passwordHash
+	return nil
+}
+
+func (r *fakeUserRepository) MarkUserEmailVerified(_ context.Context, id uuid.UUID, email string) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok || u.Email != email {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("user is not found"))
+	}
+	now := time.Now()
+	u.EmailVerifiedAt = &now
+	return nil
+}
+
+func (r *fakeUserRepository) UpdateUserProfile(_ context.Context, id uuid.UUID, username string, email string) (*user.User, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("user is not found"))
+	}
+	for _, other := range r.users {
+		if other.ID != id && (other.Username == username || other.Email == email) {
+			return nil, common.NewError(common.ErrorCodeResourceConflict, errors.New("user already exists"))
+		}
+	}
+	if u.Email != email {
+		u.EmailVerifiedAt = nil
+	}
+	u.Username, u.Email = username, email
+	copied := *u
+	return &copied, nil
+}
+
+func (r *fakeUserRepository) DeleteUser(_ context.Context, id uuid.UUID) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[id]; !ok {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("user is not found"))
+	}
+	delete(r.users, id)
+	return nil
+}
+
+func (r *fakeUserRepository) ListUsers(_ context.Context, afterID uuid.UUID, limit int) ([]*user.User, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []*user.User
+	for _, u := range r.users {
+		if afterID == uuid.Nil || u.ID.String() > afterID.String() {
+			copied := *u
+			users = append(users, &copied)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID.String() < users[j].ID.String() })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (r *fakeUserRepository) UpdateUserRole(_ context.Context, id uuid.UUID, role string) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("user is not found"))
+	}
+	u.Role = role
+	return nil
+}
+
+func (r *fakeUserRepository) UpdateUserDisabledAt(_ context.Context, id uuid.UUID, disabledAt *time.Time) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("user is not found"))
+	}
+	u.DisabledAt = disabledAt
+	return nil
+}
+
+// fakePasswordResetRepository is an in-memory PasswordResetRepository for unit tests
+type fakePasswordResetRepository struct {
+	mu     sync.Mutex
+	tokens map[string]*user.PasswordResetToken
+	nextID int64
+}
+
+func newFakePasswordResetRepository() *fakePasswordResetRepository {
+	return &fakePasswordResetRepository{tokens: map[string]*user.PasswordResetToken{}}
+}
+
+func (r *fakePasswordResetRepository) CreatePasswordResetToken(_ context.Context, token *user.PasswordResetToken) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	copied := *token
+	copied.ID = r.nextID
+	r.tokens[token.TokenHash] = &copied
+	return nil
+}
+
+func (r *fakePasswordResetRepository) GetPasswordResetTokenByHash(_ context.Context, tokenHash string) (*user.PasswordResetToken, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("not found"))
+	}
+	copied := *t
+	return &copied, nil
+}
+
+func (r *fakePasswordResetRepository) UsePasswordResetToken(_ context.Context, tokenID int64) (bool, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.ID == tokenID && t.UsedAt == nil {
+			now := time.Now()
+			t.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakePasswordResetRepository) InvalidateUserPasswordResetTokens(_ context.Context, userID uuid.UUID) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.UserID == userID && t.UsedAt == nil {
+			now := time.Now()
+			t.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+// fakeEmailVerificationRepository is an in-memory EmailVerificationRepository for unit tests
+type fakeEmailVerificationRepository struct {
+	mu     sync.Mutex
+	tokens map[string]*user.EmailVerificationToken
+	nextID int64
+}
+
+func newFakeEmailVerificationRepository() *fakeEmailVerificationRepository {
+	return &fakeEmailVerificationRepository{tokens: map[string]*user.EmailVerificationToken{}}
+}
+
+func (r *fakeEmailVerificationRepository) CreateEmailVerificationToken(_ context.Context, token *user.EmailVerificationToken) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	copied := *token
+	copied.ID = r.nextID
+	r.tokens[token.TokenHash] = &copied
+	return nil
+}
+
+func (r *fakeEmailVerificationRepository) GetEmailVerificationTokenByHash(_ context.Context, tokenHash string) (*user.EmailVerificationToken, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("not found"))
+	}
+	copied := *t
+	return &copied, nil
+}
+
+func (r *fakeEmailVerificationRepository) UseEmailVerificationToken(_ context.Context, tokenID int64) (bool, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.ID == tokenID && t.UsedAt == nil {
+			now := time.Now()
+			t.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeEmailVerificationRepository) InvalidateUserEmailVerificationTokens(_ context.Context, userID uuid.UUID) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.UserID == userID && t.UsedAt == nil {
+			now := time.Now()
+			t.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+// fakeWorkspaceRepository is an in-memory WorkspaceRepository for unit tests, holding the role of every member
+type fakeWorkspaceRepository struct {
+	mu      sync.Mutex
+	members map[uuid.UUID]map[uuid.UUID]string
+}
+
+func newFakeWorkspaceRepository() *fakeWorkspaceRepository {
+	return &fakeWorkspaceRepository{members: map[uuid.UUID]map[uuid.UUID]string{}}
+}
+
+func (r *fakeWorkspaceRepository) addMember(workspaceID uuid.UUID, userID uuid.UUID, role string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.members[workspaceID] == nil {
+		r.members[workspaceID] = map[uuid.UUID]string{}
+	}
+	r.members[workspaceID][userID] = role
+}
+
+func (r *fakeWorkspaceRepository) LeaveUserWorkspaces(_ context.Context, userID uuid.UUID) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, members := range r.members {
+		if members[userID] != workspace.RoleOwner || len(members) == 1 {
+			continue
+		}
+		otherOwner := false
+		for memberID, role := range members {
+			otherOwner = otherOwner || (memberID != userID && role == workspace.RoleOwner)
+		}
+		if !otherOwner {
+			return common.NewError(common.ErrorCodeResourceConflict, errors.New("last owner"))
+		}
+	}
+	for workspaceID, members := range r.members {
+		delete(members, userID)
+		if len(members) == 0 {
+			delete(r.members, workspaceID)
+		}
+	}
+	return nil
+}
+
+// testPassword passes the password policy
+const testPassword = "correct-horse-battery"
+
+// testDevice is the client test logins come from
+var testDevice = user.Device{UserAgent: "test-agent", IP: testClientIP}
+
+type testUserService struct {
+	Service
+	userRepo      *fakeUserRepository
+	tokenRepo     *fakeTokenRepository
+	workspaceRepo *fakeWorkspaceRepository
+	mailer        *mailer.MemoryMailer
+	auditor       *fakeAuditRecorder
+}
+
+func newTestUserService(t *testing.T) *testUserService {
+	return newTestUserServiceWithProviders(t, nil)
+}
+
+func newTestUserServiceWithProviders(t *testing.T, oidcProviders map[string]oidc.Provider) *testUserService {
+	userRepo := newFakeUserRepository()
+	tokenRepo := newFakeTokenRepository()
+	workspaceRepo := newFakeWorkspaceRepository()
+	memoryMailer := mailer.NewMemoryMailer()
+	auditor := &fakeAuditRecorder{}
+	loginGuard := newTestLoginGuard(t, memory.NewLoginAttemptRepository(), 0)
+	svc := NewUserService(context.Background(), userRepo, newFakePasswordResetRepository(), newFakeEmailVerificationRepository(), newFakeTwoFactorRepository(), newFakeIdentityRepository(), workspaceRepo, newTestTokenService(t, tokenRepo, userRepo), NewAPIKeyService(context.Background(), newFakeAPIKeyRepository()), loginGuard, oidcProviders, memoryMailer, auditor, Config{
+		AppBaseURL:                   "https://deeliai.test",
+		PasswordResetTokenExpiry:     30 * time.Minute,
+		EmailVerificationTokenExpiry: 24 * time.Hour,
+		TwoFactorIssuer:              "DeeliAi",
+		LoginChallengeExpiry:         5 * time.Minute,
+		OIDCStateExpiry:              10 * time.Minute,
+	})
+
+	return &testUserService{
+		Service:       svc,
+		userRepo:      userRepo,
+		tokenRepo:     tokenRepo,
+		workspaceRepo: workspaceRepo,
+		mailer:        memoryMailer,
+		auditor:       auditor,
+	}
+}
+
+var mailTokenPattern = regexp.MustCompile(`\?token=(\S+)`)
+
+// tokenFromMail extracts the token of the link in a mail body
+func tokenFromMail(t *testing.T, msg mailer.Message) string {
+	matches := mailTokenPattern.FindStringSubmatch(msg.Body)
+	require.Len(t, matches, 2, "no link in mail body")
+	token, err := url.QueryUnescape(matches[1])
+	require.NoError(t, err)
+	return token
+}
+
+// assertErrorCode asserts err is a domain error with the given code
+func assertErrorCode(t *testing.T, code common.ErrorCode, err common.Error) {
+	t.Helper()
+	var domainError common.DomainError
+	require.True(t, errors.As(err, &domainError), "not a domain error: %v", err)
+	assert.Equal(t, code.Name, domainError.Name())
+}
+
+func TestUserService_ForgotPasswordUnknownEmail(t *testing.T) {
+	t.Parallel()
+	svc := newTestUserService(t)
+
+	err := svc.ForgotPassword(context.Background(), "nobody@example.com")
+	require.Nil(t, err)
+	assert.Empty(t, svc.mailer.Messages())
+}
+
+func TestUserService_ResetPassword(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+
+	created, session, err := svc.SignUp(ctx, "alice@example.com", "alice", "old-password", testDevice)
+	require.Nil(t, err)
+
+	require.Nil(t, svc.ForgotPassword(ctx, "alice@example.com"))
+	messages := svc.mailer.Messages()
+	require.Len(t, messages, 2)
+	assert.Equal(t, "alice@example.com", messages[1].To)
+	resetToken := tokenFromMail(t, messages[1])
+
+	require.Nil(t, svc.ResetPassword(ctx, resetToken, "new-password"))
+
+	stored, err := svc.userRepo.GetUserByID(ctx, created.ID)
+	require.Nil(t, err)
+	ok, _, hashErr := NewPasswordHasher("").Verify("new-password", stored.PasswordHash)
+	require.NoError(t, hashErr)
+	assert.True(t, ok)
+
+	// The reset token is single-use and existing sessions are revoked
+	assert.NotNil(t, svc.ResetPassword(ctx, resetToken, "another-password"))
+	_, err = svc.ValidateToken(ctx, session.AccessToken)
+	assert.NotNil(t, err)
+	_, err = svc.RefreshToken(ctx, session.RefreshToken, testDevice)
+	assert.NotNil(t, err)
+}
+
+func TestUserService_VerifyEmail(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+
+	created, _, err := svc.SignUp(ctx, "bob@example.com", "bob", testPassword, testDevice)
+	require.Nil(t, err)
+	assert.False(t, created.IsEmailVerified())
+
+	// Signing up sends the first confirmation mail, resending invalidates it
+	require.Len(t, svc.mailer.Messages(), 1)
+	staleToken := tokenFromMail(t, svc.mailer.Messages()[0])
+	require.Nil(t, svc.ResendVerificationEmail(ctx, created.ID))
+	messages := svc.mailer.Messages()
+	require.Len(t, messages, 2)
+	assert.Equal(t, "bob@example.com", messages[1].To)
+
+	_, err = svc.VerifyEmail(ctx, staleToken)
+	assert.NotNil(t, err)
+
+	verified, err := svc.VerifyEmail(ctx, tokenFromMail(t, messages[1]))
+	require.Nil(t, err)
+	assert.True(t, verified.IsEmailVerified())
+
+	// Verified addresses can not ask for another mail
+	err = svc.ResendVerificationEmail(ctx, created.ID)
+	require.NotNil(t, err)
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+}
+
+func TestUserService_UpdateProfile(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+
+	created, _, err := svc.SignUp(ctx, "carol@example.com", "carol", testPassword, testDevice)
+	require.Nil(t, err)
+	_, _, err = svc.SignUp(ctx, "dave@example.com", "dave", testPassword, testDevice)
+	require.Nil(t, err)
+	_, err = svc.VerifyEmail(ctx, tokenFromMail(t, svc.mailer.Messages()[0]))
+	require.Nil(t, err)
+
+	taken := "dave"
+	_, err = svc.UpdateProfile(ctx, created.ID, &taken, nil)
+	require.NotNil(t, err)
+	assertErrorCode(t, common.ErrorCodeResourceConflict, err)
+
+	// A new email is unverified until the mail sent to it is confirmed
+	username, email := "caroline", "caroline@example.com"
+	updated, err := svc.UpdateProfile(ctx, created.ID, &username, &email)
+	require.Nil(t, err)
+	assert.Equal(t, "caroline", updated.Username)
+	assert.Equal(t, "caroline@example.com", updated.Email)
+	assert.False(t, updated.IsEmailVerified())
+
+	messages := svc.mailer.Messages()
+	require.Len(t, messages, 3)
+	assert.Equal(t, "caroline@example.com", messages[2].To)
+	verified, err := svc.VerifyEmail(ctx, tokenFromMail(t, messages[2]))
+	require.Nil(t, err)
+	assert.True(t, verified.IsEmailVerified())
+}
+
+func TestUserService_ChangePassword(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+
+	created, session, err := svc.SignUp(ctx, "erin@example.com", "erin", "old-password", testDevice)
+	require.Nil(t, err)
+
+	_, err = svc.ChangePassword(ctx, created.ID, uuid.Nil, "wrong-password", "new-password", testDevice)
+	require.NotNil(t, err)
+	assertErrorCode(t, common.ErrorCodeAuthNotAuthenticated, err)
+
+	newSession, err := svc.ChangePassword(ctx, created.ID, uuid.Nil, "old-password", "new-password", testDevice)
+	require.Nil(t, err)
+
+	// Only the login returned by the change survives
+	_, err = svc.ValidateToken(ctx, session.AccessToken)
+	assert.NotNil(t, err)
+	claims, err := svc.ValidateToken(ctx, newSession.AccessToken)
+	require.Nil(t, err)
+	assert.Equal(t, created.ID, claims.UserID)
+
+	_, err = svc.Login(ctx, "erin@example.com", "new-password", testDevice)
+	assert.Nil(t, err)
+}
+
+func TestUserService_DeleteAccount(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+
+	created, session, err := svc.SignUp(ctx, "frank@example.com", "frank", testPassword, testDevice)
+	require.Nil(t, err)
+
+	err = svc.DeleteAccount(ctx, created.ID, uuid.Nil, "wrong-password")
+	require.NotNil(t, err)
+	assertErrorCode(t, common.ErrorCodeAuthNotAuthenticated, err)
+
+	require.Nil(t, svc.DeleteAccount(ctx, created.ID, uuid.Nil, testPassword))
+
+	_, err = svc.GetUser(ctx, created.ID)
+	assertErrorCode(t, common.ErrorCodeResourceNotFound, err)
+	_, err = svc.ValidateToken(ctx, session.AccessToken)
+	assert.NotNil(t, err)
+}
+
+func TestUserService_DeleteAccountWorkspaces(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+
+	created, _, err := svc.SignUp(ctx, "gina@example.com", "gina", testPassword, testDevice)
+	require.Nil(t, err)
+	solo, shared := uuid.New(), uuid.New()
+	svc.workspaceRepo.addMember(solo, created.ID, workspace.RoleOwner)
+	svc.workspaceRepo.addMember(shared, created.ID, workspace.RoleOwner)
+	svc.workspaceRepo.addMember(shared, uuid.New(), workspace.RoleEditor)
+
+	// The last owner of a workspace with other members cannot leave it behind
+	err = svc.DeleteAccount(ctx, created.ID, uuid.Nil, testPassword)
+	assertErrorCode(t, common.ErrorCodeResourceConflict, err)
+	_, err = svc.GetUser(ctx, created.ID)
+	require.Nil(t, err)
+	assert.Len(t, svc.workspaceRepo.members, 2)
+
+	// Once it has another owner, the account goes, and with it the workspace nobody else is a member of
+	svc.workspaceRepo.addMember(shared, uuid.New(), workspace.RoleOwner)
+	require.Nil(t, svc.DeleteAccount(ctx, created.ID, uuid.Nil, testPassword))
+	_, err = svc.GetUser(ctx, created.ID)
+	assertErrorCode(t, common.ErrorCodeResourceNotFound, err)
+	assert.NotContains(t, svc.workspaceRepo.members, solo)
+	assert.Len(t, svc.workspaceRepo.members[shared], 2)
+	assert.NotContains(t, svc.workspaceRepo.members[shared], created.ID)
+}
@@ -0,0 +1,50 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// ListSessions returns the sessions the user is still logged in with, the most recently seen first.
+func (s *TokenServiceImpl) ListSessions(ctx context.Context, userID uuid.UUID) ([]*user.TokenFamily, common.Error) {
+	return s.tokenRepo.ListActiveTokenFamilies(ctx, userID, time.Now())
+}
+
+// GetSession returns a session the user is still logged in with. Sessions of other users are reported as not found.
+func (s *TokenServiceImpl) GetSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) (*user.TokenFamily, common.Error) {
+	family, cerr := s.tokenRepo.GetTokenFamily(ctx, sessionID)
+	if cerr != nil {
+		return nil, cerr
+	}
+	if family.UserID != userID || family.IsRevoked() {
+		msg := "session is not found"
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New(msg), common.WithMsg(msg))
+	}
+
+	return family, nil
+}
+
+// RevokeSession logs the user out of one session. Sessions of other users are reported as not found.
+func (s *TokenServiceImpl) RevokeSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) common.Error {
+	family, cerr := s.tokenRepo.GetTokenFamily(ctx, sessionID)
+	if cerr != nil {
+		return cerr
+	}
+	if family.UserID != userID {
+		msg := "session is not found"
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New(msg), common.WithMsg(msg))
+	}
+
+	return s.tokenRepo.RevokeTokenFamily(ctx, sessionID)
+}
+
+// RevokeOtherSessions logs the user out everywhere but the current session.
+func (s *TokenServiceImpl) RevokeOtherSessions(ctx context.Context, userID uuid.UUID, currentSessionID uuid.UUID) common.Error {
+	return s.tokenRepo.RevokeOtherUserTokenFamilies(ctx, userID, currentSessionID)
+}
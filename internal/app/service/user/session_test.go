@@ -0,0 +1,81 @@
+package user
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+func TestTokenService_Sessions(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	userRepo := newFakeUserRepository()
+	svc := newTestTokenService(t, newFakeTokenRepository(), userRepo)
+	u := newTestUser(t, userRepo, user.RoleUser)
+
+	laptop, err := svc.GenerateToken(ctx, u.ID, user.Device{UserAgent: "Firefox", IP: "192.0.2.10"})
+	require.Nil(t, err)
+	phone, err := svc.GenerateToken(ctx, u.ID, user.Device{UserAgent: "iOS", IP: "192.0.2.20"})
+	require.Nil(t, err)
+
+	sessions, err := svc.ListSessions(ctx, u.ID)
+	require.Nil(t, err)
+	require.Len(t, sessions, 2)
+	laptopClaims, err := svc.ValidateToken(ctx, laptop.AccessToken)
+	require.Nil(t, err)
+
+	// A refresh records the device it came from
+	phone, err = svc.RefreshToken(ctx, phone.RefreshToken, user.Device{UserAgent: "iOS", IP: "198.51.100.20"})
+	require.Nil(t, err)
+	phoneClaims, err := svc.ValidateToken(ctx, phone.AccessToken)
+	require.Nil(t, err)
+	sessions, err = svc.ListSessions(ctx, u.ID)
+	require.Nil(t, err)
+	for _, session := range sessions {
+		if session.ID == phoneClaims.FamilyID {
+			assert.Equal(t, "198.51.100.20", session.IPAddress)
+		}
+	}
+
+	// Sessions of other users can not be revoked
+	other := newTestUser(t, userRepo, user.RoleUser)
+	assertErrorCode(t, common.ErrorCodeResourceNotFound, svc.RevokeSession(ctx, other.ID, laptopClaims.FamilyID))
+
+	require.Nil(t, svc.RevokeSession(ctx, u.ID, laptopClaims.FamilyID))
+	_, err = svc.ValidateToken(ctx, laptop.AccessToken)
+	assert.NotNil(t, err)
+	sessions, err = svc.ListSessions(ctx, u.ID)
+	require.Nil(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, phoneClaims.FamilyID, sessions[0].ID)
+	assert.Equal(t, "iOS", sessions[0].UserAgent)
+}
+
+func TestTokenService_RevokeOtherSessions(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	userRepo := newFakeUserRepository()
+	svc := newTestTokenService(t, newFakeTokenRepository(), userRepo)
+	u := newTestUser(t, userRepo, user.RoleUser)
+
+	current, err := svc.GenerateToken(ctx, u.ID, testDevice)
+	require.Nil(t, err)
+	elsewhere, err := svc.GenerateToken(ctx, u.ID, testDevice)
+	require.Nil(t, err)
+	claims, err := svc.ValidateToken(ctx, current.AccessToken)
+	require.Nil(t, err)
+
+	require.Nil(t, svc.RevokeOtherSessions(ctx, u.ID, claims.FamilyID))
+
+	_, err = svc.ValidateToken(ctx, current.AccessToken)
+	assert.Nil(t, err)
+	_, err = svc.ValidateToken(ctx, elsewhere.AccessToken)
+	assert.NotNil(t, err)
+	_, err = svc.RefreshToken(ctx, elsewhere.RefreshToken, testDevice)
+	assert.NotNil(t, err)
+}
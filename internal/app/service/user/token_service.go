@@ -2,66 +2,321 @@ package user
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog"
 
 	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
 )
 
+const (
+	refreshTokenBytes = 32
+	// sessionTouchInterval is how stale the last-seen time of a session may get before an access token updates it
+	sessionTouchInterval = time.Minute
+	// userAgentMaxLength is the length of the token_families.user_agent column
+	userAgentMaxLength = 512
+)
+
+// validSigningMethods pins the algorithms ValidateToken accepts, so that a token can never pick its own
+var validSigningMethods = []string{user.SigningAlgorithmRS256, user.SigningAlgorithmEdDSA}
+
 type TokenServiceImpl struct {
-	signingKey     []byte
-	expiryDuration time.Duration
-	issuer         string
+	tokenRepo             TokenRepository
+	userRepo              UserRepository
+	keys                  *KeyManager
+	expiryDuration        time.Duration
+	refreshExpiryDuration time.Duration
+	issuer                string
 }
 
-func NewTokenService(_ context.Context, signingKey []byte, expiryDuration time.Duration, issuer string) TokenService {
+func NewTokenService(_ context.Context, tokenRepo TokenRepository, userRepo UserRepository, keys *KeyManager, expiryDuration time.Duration, refreshExpiryDuration time.Duration, issuer string) TokenService {
 	return &TokenServiceImpl{
-		signingKey:     signingKey,
-		expiryDuration: expiryDuration,
-		issuer:         issuer,
+		tokenRepo:             tokenRepo,
+		userRepo:              userRepo,
+		keys:                  keys,
+		expiryDuration:        expiryDuration,
+		refreshExpiryDuration: refreshExpiryDuration,
+		issuer:                issuer,
 	}
 }
 
 type Claims struct {
-	UserID uuid.UUID `json:"user_id"`
+	UserID   uuid.UUID `json:"user_id"`
+	FamilyID uuid.UUID `json:"fid"`
+	Role     string    `json:"role"`
 	jwt.RegisteredClaims
 }
 
-func (s *TokenServiceImpl) GenerateToken(ctx context.Context, userID uuid.UUID) (string, common.Error) {
+// GenerateToken starts a new token family, which is a session on the device, and issues its first token pair.
+func (s *TokenServiceImpl) GenerateToken(ctx context.Context, userID uuid.UUID, device user.Device) (*user.Token, common.Error) {
+	u, err := s.getActiveUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	device = normalizeDevice(device)
+	family, err := s.tokenRepo.CreateTokenFamily(ctx, &user.TokenFamily{
+		UserID:    userID,
+		UserAgent: device.UserAgent,
+		IPAddress: device.IP,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueToken(ctx, u, family.ID)
+}
+
+// RefreshToken exchanges a refresh token for a new token pair in the same family, and records the device it came from.
+// Presenting a refresh token that has already been rotated revokes the whole family.
+func (s *TokenServiceImpl) RefreshToken(ctx context.Context, refreshToken string, device user.Device) (*user.Token, common.Error) {
+	stored, family, err := s.getRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.IsRotated() {
+		return nil, s.revokeReusedFamily(ctx, family)
+	}
+	if stored.IsExpired(time.Now()) {
+		msg := "refresh token is expired"
+		return nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg(msg))
+	}
+
+	rotated, err := s.tokenRepo.RotateRefreshToken(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// Another request rotated the token between our read and update
+		return nil, s.revokeReusedFamily(ctx, family)
+	}
+
+	// The role is read again, so a refreshed token carries the current one
+	u, err := s.getActiveUser(ctx, family.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	device = normalizeDevice(device)
+	if err := s.tokenRepo.TouchTokenFamily(ctx, family.ID, time.Now(), &device); err != nil {
+		return nil, err
+	}
+
+	return s.issueToken(ctx, u, family.ID)
+}
+
+// RevokeUserTokens revokes every token family of the user.
+func (s *TokenServiceImpl) RevokeUserTokens(ctx context.Context, userID uuid.UUID) common.Error {
+	return s.tokenRepo.RevokeUserTokenFamilies(ctx, userID)
+}
+
+// RevokeToken revokes the family the given refresh token belongs to.
+func (s *TokenServiceImpl) RevokeToken(ctx context.Context, refreshToken string) common.Error {
+	_, family, err := s.getRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	return s.tokenRepo.RevokeTokenFamily(ctx, family.ID)
+}
+
+// ValidateToken checks the signature, expiry and family of an access token and returns its claims.
+func (s *TokenServiceImpl) ValidateToken(ctx context.Context, tokenString string) (*user.TokenClaims, common.Error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, cerr := s.keys.VerificationKey(ctx, kid)
+		if cerr != nil {
+			return nil, cerr
+		}
+		// Each key only verifies the algorithm it was created for
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method %s for key %s", token.Method.Alg(), kid)
+		}
+		return key.PublicKey(), nil
+	}, jwt.WithValidMethods(validSigningMethods))
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeParameterInvalid, err)
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, common.NewError(common.ErrorCodeParameterInvalid, jwt.ErrInvalidKey)
+	}
+
+	family, cerr := s.tokenRepo.GetTokenFamily(ctx, claims.FamilyID)
+	if cerr != nil {
+		return nil, cerr
+	}
+	if family.IsRevoked() || family.UserID != claims.UserID {
+		msg := "token has been revoked"
+		return nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg(msg))
+	}
+	s.touchSession(ctx, family)
+
+	return &user.TokenClaims{
+		UserID:   claims.UserID,
+		Role:     claims.Role,
+		FamilyID: claims.FamilyID,
+	}, nil
+}
+
+// PublicKeys returns the keys other services need to verify our access tokens.
+func (s *TokenServiceImpl) PublicKeys(_ context.Context) ([]user.PublicKey, common.Error) {
+	keys := s.keys.VerificationKeys()
+	publicKeys := make([]user.PublicKey, 0, len(keys))
+	for _, key := range keys {
+		publicKeys = append(publicKeys, key.Public())
+	}
+	return publicKeys, nil
+}
+
+func (s *TokenServiceImpl) issueToken(ctx context.Context, u *user.User, familyID uuid.UUID) (*user.Token, common.Error) {
+	now := time.Now()
+	accessExpiresAt := now.Add(s.expiryDuration)
 	claims := Claims{
-		UserID: userID,
+		UserID:   u.ID,
+		FamilyID: familyID,
+		Role:     u.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.expiryDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(accessExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    s.issuer,
-			Subject:   userID.String(),
+			Subject:   u.ID.String(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(s.signingKey)
+	key, cerr := s.keys.SigningKey(ctx)
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	signedToken, err := token.SignedString(key.PrivateKey)
 	if err != nil {
-		return "", common.NewError(common.ErrorCodeInternalProcess, err)
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
 	}
 
-	return signedToken, nil
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	refreshExpiresAt := now.Add(s.refreshExpiryDuration)
+	cerr = s.tokenRepo.CreateRefreshToken(ctx, &user.RefreshToken{
+		FamilyID:  familyID,
+		TokenHash: hashOpaqueToken(refreshToken),
+		ExpiresAt: refreshExpiresAt,
+	})
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	return &user.Token{
+		AccessToken:      signedToken,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
 }
 
-func (s *TokenServiceImpl) ValidateToken(ctx context.Context, tokenString string) (uuid.UUID, common.Error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return s.signingKey, nil
-	})
+// touchSession keeps the last-seen time of a session current, writing at most once per sessionTouchInterval.
+// A failed write is only logged, it must not fail the request the token came with.
+func (s *TokenServiceImpl) touchSession(ctx context.Context, family *user.TokenFamily) {
+	now := time.Now()
+	if now.Sub(family.LastSeenAt) < sessionTouchInterval {
+		return
+	}
+	if cerr := s.tokenRepo.TouchTokenFamily(ctx, family.ID, now, nil); cerr != nil {
+		s.logger(ctx).Error().Err(cerr).Str("family_id", family.ID.String()).Msg("failed to update last seen time of session")
+	}
+}
+
+// getActiveUser returns the user tokens are issued for, failing if the account is disabled
+func (s *TokenServiceImpl) getActiveUser(ctx context.Context, userID uuid.UUID) (*user.User, common.Error) {
+	u, err := s.userRepo.GetUserByID(ctx, userID)
 	if err != nil {
-		return uuid.Nil, common.NewError(common.ErrorCodeParameterInvalid, err)
+		return nil, err
+	}
+	if u.IsDisabled() {
+		return nil, errAccountDisabled()
 	}
+	return u, nil
+}
 
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
-		return uuid.Nil, common.NewError(common.ErrorCodeParameterInvalid, jwt.ErrInvalidKey)
+func (s *TokenServiceImpl) getRefreshToken(ctx context.Context, refreshToken string) (*user.RefreshToken, *user.TokenFamily, common.Error) {
+	stored, err := s.tokenRepo.GetRefreshTokenByHash(ctx, hashOpaqueToken(refreshToken))
+	if err != nil {
+		return nil, nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(err.Error()), common.WithMsg("invalid refresh token"))
+	}
+
+	family, err := s.tokenRepo.GetTokenFamily(ctx, stored.FamilyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if family.IsRevoked() {
+		msg := "refresh token has been revoked"
+		return nil, nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg(msg))
+	}
+
+	return stored, family, nil
+}
+
+func (s *TokenServiceImpl) revokeReusedFamily(ctx context.Context, family *user.TokenFamily) common.Error {
+	s.logger(ctx).Warn().
+		Str("family_id", family.ID.String()).
+		Str("user_id", family.UserID.String()).
+		Msg("refresh token reuse detected, revoking token family")
+
+	if err := s.tokenRepo.RevokeTokenFamily(ctx, family.ID); err != nil {
+		return err
+	}
+
+	msg := "refresh token has already been used"
+	return common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg(msg))
+}
+
+// logger wrap the execution context with component info
+func (s *TokenServiceImpl) logger(ctx context.Context) *zerolog.Logger {
+	l := zerolog.Ctx(ctx).With().Str("component", "token-service").Logger()
+	return &l
+}
+
+// normalizeDevice cuts the user agent down to what we store
+func normalizeDevice(device user.Device) user.Device {
+	if agent := []rune(device.UserAgent); len(agent) > userAgentMaxLength {
+		device.UserAgent = string(agent[:userAgentMaxLength])
 	}
+	return device
+}
+
+// generateOpaqueToken returns a random URL-safe token
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// errAccountDisabled is returned whenever a disabled user tries to authenticate
+func errAccountDisabled() common.Error {
+	msg := "account is disabled"
+	return common.NewError(common.ErrorCodeAuthPermissionDenied, errors.New(msg), common.WithMsg(msg))
+}
 
-	return claims.UserID, nil
+// hashOpaqueToken returns the hex encoded sha256 of an opaque token, which is what we persist
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
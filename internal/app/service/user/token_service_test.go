@@ -0,0 +1,319 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// fakeTokenRepository is an in-memory TokenRepository for unit tests
+type fakeTokenRepository struct {
+	mu       sync.Mutex
+	families map[uuid.UUID]*user.TokenFamily
+	tokens   map[string]*user.RefreshToken
+	nextID   int64
+}
+
+func newFakeTokenRepository() *fakeTokenRepository {
+	return &fakeTokenRepository{
+		families: map[uuid.UUID]*user.TokenFamily{},
+		tokens:   map[string]*user.RefreshToken{},
+	}
+}
+
+func (r *fakeTokenRepository) CreateTokenFamily(_ context.Context, family *user.TokenFamily) (*user.TokenFamily, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := *family
+	f.ID = uuid.New()
+	f.CreatedAt = time.Now()
+	f.LastSeenAt = f.CreatedAt
+	r.families[f.ID] = &f
+	copied := f
+	return &copied, nil
+}
+
+func (r *fakeTokenRepository) ListActiveTokenFamilies(_ context.Context, userID uuid.UUID, now time.Time) ([]*user.TokenFamily, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var families []*user.TokenFamily
+	for _, f := range r.families {
+		if f.UserID != userID || f.IsRevoked() {
+			continue
+		}
+		for _, t := range r.tokens {
+			if t.FamilyID == f.ID && !t.IsRotated() && !t.IsExpired(now) {
+				copied := *f
+				families = append(families, &copied)
+				break
+			}
+		}
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].LastSeenAt.After(families[j].LastSeenAt) })
+	return families, nil
+}
+
+func (r *fakeTokenRepository) TouchTokenFamily(_ context.Context, familyID uuid.UUID, seenAt time.Time, device *user.Device) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.families[familyID]; ok {
+		f.LastSeenAt = seenAt
+		if device != nil {
+			f.UserAgent, f.IPAddress = device.UserAgent, device.IP
+		}
+	}
+	return nil
+}
+
+func (r *fakeTokenRepository) GetTokenFamily(_ context.Context, familyID uuid.UUID) (*user.TokenFamily, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.families[familyID]
+	if !ok {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("not found"))
+	}
+	copied := *f
+	return &copied, nil
+}
+
+func (r *fakeTokenRepository) RevokeTokenFamily(_ context.Context, familyID uuid.UUID) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.families[familyID]; ok && f.RevokedAt == nil {
+		now := time.Now()
+		f.RevokedAt = &now
+	}
+	return nil
+}
+
+func (r *fakeTokenRepository) RevokeUserTokenFamilies(_ context.Context, userID uuid.UUID) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.families {
+		if f.UserID == userID && f.RevokedAt == nil {
+			now := time.Now()
+			f.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeTokenRepository) RevokeOtherUserTokenFamilies(_ context.Context, userID uuid.UUID, keepFamilyID uuid.UUID) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.families {
+		if f.UserID == userID && f.ID != keepFamilyID && f.RevokedAt == nil {
+			now := time.Now()
+			f.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeTokenRepository) CreateRefreshToken(_ context.Context, token *user.RefreshToken) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	copied := *token
+	copied.ID = r.nextID
+	r.tokens[token.TokenHash] = &copied
+	return nil
+}
+
+func (r *fakeTokenRepository) GetRefreshTokenByHash(_ context.Context, tokenHash string) (*user.RefreshToken, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("not found"))
+	}
+	copied := *t
+	return &copied, nil
+}
+
+func (r *fakeTokenRepository) RotateRefreshToken(_ context.Context, tokenID int64) (bool, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.ID == tokenID && t.RotatedAt == nil {
+			now := time.Now()
+			t.RotatedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fakeSigningKeyRepository is an in-memory SigningKeyRepository for unit tests
+type fakeSigningKeyRepository struct {
+	mu   sync.Mutex
+	keys []*user.SigningKey
+}
+
+func (r *fakeSigningKeyRepository) CreateSigningKey(_ context.Context, key *user.SigningKey) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = append([]*user.SigningKey{key}, r.keys...)
+	return nil
+}
+
+func (r *fakeSigningKeyRepository) ListSigningKeys(_ context.Context) ([]*user.SigningKey, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*user.SigningKey{}, r.keys...), nil
+}
+
+func (r *fakeSigningKeyRepository) DeleteExpiredSigningKeys(_ context.Context) common.Error {
+	return nil
+}
+
+func newTestTokenServiceWithAlgorithm(t *testing.T, repo TokenRepository, userRepo UserRepository, algorithm string) TokenService {
+	keys, err := NewKeyManager(context.Background(), &fakeSigningKeyRepository{}, algorithm, time.Hour, time.Minute)
+	require.Nil(t, err)
+	return NewTokenService(context.Background(), repo, userRepo, keys, time.Minute, time.Hour, "test")
+}
+
+func newTestTokenService(t *testing.T, repo TokenRepository, userRepo UserRepository) TokenService {
+	return newTestTokenServiceWithAlgorithm(t, repo, userRepo, user.SigningAlgorithmRS256)
+}
+
+// newTestUser stores a user with the given role and returns it
+func newTestUser(t *testing.T, userRepo *fakeUserRepository, role string) *user.User {
+	name := uuid.NewString()
+	created, err := userRepo.CreateUser(context.Background(), &user.User{Email: name + "@example.com", Username: name, Role: role})
+	require.Nil(t, err)
+	return created
+}
+
+func TestTokenService_RefreshToken(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	userRepo := newFakeUserRepository()
+	svc := newTestTokenService(t, newFakeTokenRepository(), userRepo)
+	u := newTestUser(t, userRepo, user.RoleUser)
+
+	first, err := svc.GenerateToken(ctx, u.ID, testDevice)
+	require.Nil(t, err)
+
+	// A refreshed token carries the current role
+	require.Nil(t, userRepo.UpdateUserRole(ctx, u.ID, user.RoleAdmin))
+	second, err := svc.RefreshToken(ctx, first.RefreshToken, testDevice)
+	require.Nil(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+	claims, err := svc.ValidateToken(ctx, second.AccessToken)
+	require.Nil(t, err)
+	assert.Equal(t, u.ID, claims.UserID)
+	assert.Equal(t, user.RoleAdmin, claims.Role)
+}
+
+func TestTokenService_RefreshTokenReuseRevokesFamily(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	userRepo := newFakeUserRepository()
+	svc := newTestTokenService(t, newFakeTokenRepository(), userRepo)
+
+	first, err := svc.GenerateToken(ctx, newTestUser(t, userRepo, user.RoleUser).ID, testDevice)
+	require.Nil(t, err)
+	second, err := svc.RefreshToken(ctx, first.RefreshToken, testDevice)
+	require.Nil(t, err)
+
+	// Reusing the rotated token revokes the family
+	_, err = svc.RefreshToken(ctx, first.RefreshToken, testDevice)
+	require.NotNil(t, err)
+
+	_, err = svc.RefreshToken(ctx, second.RefreshToken, testDevice)
+	assert.NotNil(t, err)
+	_, err = svc.ValidateToken(ctx, second.AccessToken)
+	assert.NotNil(t, err)
+}
+
+func TestTokenService_RevokeToken(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	userRepo := newFakeUserRepository()
+	svc := newTestTokenService(t, newFakeTokenRepository(), userRepo)
+
+	token, err := svc.GenerateToken(ctx, newTestUser(t, userRepo, user.RoleUser).ID, testDevice)
+	require.Nil(t, err)
+
+	require.Nil(t, svc.RevokeToken(ctx, token.RefreshToken))
+
+	_, err = svc.ValidateToken(ctx, token.AccessToken)
+	assert.NotNil(t, err)
+	_, err = svc.RefreshToken(ctx, token.RefreshToken, testDevice)
+	assert.NotNil(t, err)
+}
+
+func TestTokenService_ValidateTokenSigningAlgorithms(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	for _, algorithm := range []string{user.SigningAlgorithmRS256, user.SigningAlgorithmEdDSA} {
+		userRepo := newFakeUserRepository()
+		svc := newTestTokenServiceWithAlgorithm(t, newFakeTokenRepository(), userRepo, algorithm)
+		u := newTestUser(t, userRepo, user.RoleUser)
+
+		token, err := svc.GenerateToken(ctx, u.ID, testDevice)
+		require.Nil(t, err)
+
+		claims, err := svc.ValidateToken(ctx, token.AccessToken)
+		require.Nil(t, err, algorithm)
+		assert.Equal(t, u.ID, claims.UserID)
+
+		publicKeys, err := svc.PublicKeys(ctx)
+		require.Nil(t, err)
+		require.Len(t, publicKeys, 1)
+		assert.Equal(t, algorithm, publicKeys[0].Algorithm)
+	}
+}
+
+func TestTokenService_ValidateTokenRejectsSymmetricToken(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	userRepo := newFakeUserRepository()
+	svc := newTestTokenService(t, newFakeTokenRepository(), userRepo)
+
+	token, err := svc.GenerateToken(ctx, newTestUser(t, userRepo, user.RoleUser).ID, testDevice)
+	require.Nil(t, err)
+	parsed, _, parseErr := jwt.NewParser().ParseUnverified(token.AccessToken, &Claims{})
+	require.NoError(t, parseErr)
+
+	// Re-sign the same claims and kid with HS256, as if the public key were a shared secret
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, parsed.Claims)
+	forged.Header["kid"] = parsed.Header["kid"]
+	forgedToken, signErr := forged.SignedString([]byte("public-key-as-secret"))
+	require.NoError(t, signErr)
+
+	_, err = svc.ValidateToken(ctx, forgedToken)
+	assert.NotNil(t, err)
+}
+
+func TestTokenService_GenerateTokenRejectsDisabledUser(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	userRepo := newFakeUserRepository()
+	svc := newTestTokenService(t, newFakeTokenRepository(), userRepo)
+	u := newTestUser(t, userRepo, user.RoleUser)
+
+	token, err := svc.GenerateToken(ctx, u.ID, testDevice)
+	require.Nil(t, err)
+
+	now := time.Now()
+	require.Nil(t, userRepo.UpdateUserDisabledAt(ctx, u.ID, &now))
+	_, err = svc.GenerateToken(ctx, u.ID, testDevice)
+	assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+	_, err = svc.RefreshToken(ctx, token.RefreshToken, testDevice)
+	assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+}
@@ -0,0 +1,285 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+const (
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+	// recoveryCodeAlphabet leaves out characters that are easily confused when copied by hand
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// EnrollTwoFactor creates a new TOTP secret for the user. Two-factor authentication only takes effect
+// once the secret is confirmed with a code, enrolling again before that replaces the secret.
+func (s *userService) EnrollTwoFactor(ctx context.Context, userID uuid.UUID) (*user.TwoFactorEnrollment, common.Error) {
+	foundUser, cerr := s.userRepo.GetUserByID(ctx, userID)
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	existing, cerr := s.getTOTP(ctx, userID)
+	if cerr != nil {
+		return nil, cerr
+	}
+	if existing != nil && existing.IsConfirmed() {
+		return nil, errTwoFactorAlreadyEnabled()
+	}
+
+	totp, err := user.NewTOTP(userID)
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	if cerr := s.twoFactorRepo.SaveTOTP(ctx, totp); cerr != nil {
+		return nil, cerr
+	}
+
+	return &user.TwoFactorEnrollment{
+		Secret: totp.Secret,
+		URI:    totp.URI(s.config.TwoFactorIssuer, foundUser.Email),
+	}, nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication with the enrolled secret and returns fresh recovery codes.
+// The recovery codes are only ever shown here, we keep their hashes.
+func (s *userService) ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, code string) ([]string, common.Error) {
+	totp, cerr := s.getTOTP(ctx, userID)
+	if cerr != nil {
+		return nil, cerr
+	}
+	if totp == nil {
+		msg := "two-factor authentication has not been enrolled"
+		return nil, common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+	if totp.IsConfirmed() {
+		return nil, errTwoFactorAlreadyEnabled()
+	}
+
+	step, ok := totp.Verify(normalizeTwoFactorCode(code), time.Now())
+	if !ok {
+		return nil, errInvalidTwoFactorCode()
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	if cerr := s.twoFactorRepo.ConfirmTOTP(ctx, userID, step, hashes); cerr != nil {
+		return nil, cerr
+	}
+	s.recordAudit(ctx, audit.ActionTwoFactorEnable, userID, audit.TargetUser, userID.String(), nil)
+	s.logger(ctx).Info().Str("user_id", userID.String()).Msg("two-factor authentication enabled")
+
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off. It takes a TOTP or recovery code,
+// so that a stolen session alone cannot remove the second factor.
+func (s *userService) DisableTwoFactor(ctx context.Context, userID uuid.UUID, code string) common.Error {
+	foundUser, cerr := s.userRepo.GetUserByID(ctx, userID)
+	if cerr != nil {
+		return cerr
+	}
+
+	totp, cerr := s.getTOTP(ctx, userID)
+	if cerr != nil {
+		return cerr
+	}
+	if totp == nil || !totp.IsConfirmed() {
+		msg := "two-factor authentication is not enabled"
+		return common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+
+	// Wrong codes count towards the lockout of the account like failed logins do
+	if cerr := s.loginGuard.Check(ctx, foundUser.Email, ""); cerr != nil {
+		return cerr
+	}
+	if cerr := s.verifySecondFactor(ctx, totp, code); cerr != nil {
+		s.recordLoginFailure(ctx, foundUser.Email, "")
+		s.recordAudit(ctx, audit.ActionTwoFactorDisable, userID, audit.TargetUser, userID.String(), cerr)
+		return cerr
+	}
+
+	if cerr := s.twoFactorRepo.DeleteTOTP(ctx, userID); cerr != nil {
+		return cerr
+	}
+	s.recordAudit(ctx, audit.ActionTwoFactorDisable, userID, audit.TargetUser, userID.String(), nil)
+	s.logger(ctx).Info().Str("user_id", userID.String()).Msg("two-factor authentication disabled")
+
+	return nil
+}
+
+// CompleteTwoFactorLogin exchanges the challenge of a password login and a TOTP or recovery code for a token pair.
+func (s *userService) CompleteTwoFactorLogin(ctx context.Context, challengeToken string, code string, device user.Device) (*user.User, *user.Token, common.Error) {
+	challenge, cerr := s.twoFactorRepo.GetLoginChallengeByHash(ctx, hashOpaqueToken(challengeToken))
+	if cerr != nil {
+		msg := "invalid login challenge"
+		return nil, nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(cerr.Error()), common.WithMsg(msg))
+	}
+	if challenge.IsUsed() || challenge.IsExpired(time.Now()) {
+		msg := "login challenge is expired"
+		return nil, nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg(msg))
+	}
+
+	foundUser, cerr := s.userRepo.GetUserByID(ctx, challenge.UserID)
+	if cerr != nil {
+		return nil, nil, cerr
+	}
+	if cerr := s.loginGuard.Check(ctx, foundUser.Email, device.IP); cerr != nil {
+		s.auditLogin(ctx, foundUser.Email, foundUser.ID, cerr)
+		return nil, nil, cerr
+	}
+
+	totp, cerr := s.getTOTP(ctx, foundUser.ID)
+	if cerr != nil {
+		return nil, nil, cerr
+	}
+	if totp == nil || !totp.IsConfirmed() {
+		// Two-factor authentication was disabled after the challenge was issued
+		msg := "login challenge is expired"
+		return nil, nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg(msg))
+	}
+	if cerr := s.verifySecondFactor(ctx, totp, code); cerr != nil {
+		s.recordLoginFailure(ctx, foundUser.Email, device.IP)
+		s.auditLogin(ctx, foundUser.Email, foundUser.ID, cerr)
+		return nil, nil, cerr
+	}
+
+	used, cerr := s.twoFactorRepo.UseLoginChallenge(ctx, challenge.ID)
+	if cerr != nil {
+		return nil, nil, cerr
+	}
+	if !used {
+		msg := "login challenge has already been used"
+		return nil, nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg(msg))
+	}
+
+	token, cerr := s.completeLogin(ctx, foundUser, device)
+	if cerr != nil {
+		return nil, nil, cerr
+	}
+
+	return foundUser, token, nil
+}
+
+// verifySecondFactor accepts a TOTP code that has not been used yet, or an unused recovery code which it consumes
+func (s *userService) verifySecondFactor(ctx context.Context, totp *user.TOTP, code string) common.Error {
+	code = normalizeTwoFactorCode(code)
+
+	if user.IsTOTPCode(code) {
+		step, ok := totp.Verify(code, time.Now())
+		if !ok {
+			return errInvalidTwoFactorCode()
+		}
+		used, cerr := s.twoFactorRepo.UseTOTPStep(ctx, totp.UserID, step)
+		if cerr != nil {
+			return cerr
+		}
+		if !used {
+			return errInvalidTwoFactorCode()
+		}
+		return nil
+	}
+
+	recoveryCode, cerr := s.twoFactorRepo.GetRecoveryCodeByHash(ctx, totp.UserID, hashOpaqueToken(code))
+	if cerr != nil {
+		if common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound) {
+			return errInvalidTwoFactorCode()
+		}
+		return cerr
+	}
+	if recoveryCode.IsUsed() {
+		return errInvalidTwoFactorCode()
+	}
+	used, cerr := s.twoFactorRepo.UseRecoveryCode(ctx, recoveryCode.ID)
+	if cerr != nil {
+		return cerr
+	}
+	if !used {
+		return errInvalidTwoFactorCode()
+	}
+	s.logger(ctx).Info().Str("user_id", totp.UserID.String()).Msg("recovery code used")
+
+	return nil
+}
+
+func (s *userService) createLoginChallenge(ctx context.Context, userID uuid.UUID) (*user.TwoFactorChallenge, common.Error) {
+	challengeToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	expiresAt := time.Now().Add(s.config.LoginChallengeExpiry)
+	cerr := s.twoFactorRepo.CreateLoginChallenge(ctx, &user.LoginChallenge{
+		UserID:    userID,
+		TokenHash: hashOpaqueToken(challengeToken),
+		ExpiresAt: expiresAt,
+	})
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	return &user.TwoFactorChallenge{
+		Token:     challengeToken,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// getTOTP returns the TOTP secret of the user, or nil if the user never enrolled
+func (s *userService) getTOTP(ctx context.Context, userID uuid.UUID) (*user.TOTP, common.Error) {
+	totp, cerr := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if cerr != nil {
+		if common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound) {
+			return nil, nil
+		}
+		return nil, cerr
+	}
+	return totp, nil
+}
+
+// generateRecoveryCodes returns recovery codes formatted as "xxxxx-xxxxx", and the hashes we persist
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	alphabetSize := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for len(codes) < recoveryCodeCount {
+		b := make([]byte, recoveryCodeLength)
+		for i := range b {
+			n, err := rand.Int(rand.Reader, alphabetSize)
+			if err != nil {
+				return nil, nil, err
+			}
+			b[i] = recoveryCodeAlphabet[n.Int64()]
+		}
+		code := string(b[:recoveryCodeLength/2]) + "-" + string(b[recoveryCodeLength/2:])
+		codes = append(codes, code)
+		hashes = append(hashes, hashOpaqueToken(normalizeTwoFactorCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeTwoFactorCode drops the separators users may type along with a code
+func normalizeTwoFactorCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+func errInvalidTwoFactorCode() common.Error {
+	msg := "invalid two-factor code"
+	return common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg(msg))
+}
+
+func errTwoFactorAlreadyEnabled() common.Error {
+	msg := "two-factor authentication is already enabled"
+	return common.NewError(common.ErrorCodeResourceConflict, errors.New(msg), common.WithMsg(msg))
+}
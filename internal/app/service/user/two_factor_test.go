@@ -0,0 +1,269 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// fakeTwoFactorRepository is an in-memory TwoFactorRepository for unit tests
+type fakeTwoFactorRepository struct {
+	mu            sync.Mutex
+	totps         map[uuid.UUID]*user.TOTP
+	recoveryCodes []*user.RecoveryCode
+	challenges    []*user.LoginChallenge
+	nextID        int64
+}
+
+func newFakeTwoFactorRepository() *fakeTwoFactorRepository {
+	return &fakeTwoFactorRepository{
+		totps: map[uuid.UUID]*user.TOTP{},
+	}
+}
+
+func (r *fakeTwoFactorRepository) SaveTOTP(_ context.Context, totp *user.TOTP) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.totps[totp.UserID]; ok && existing.IsConfirmed() {
+		return nil
+	}
+	copied := *totp
+	copied.CreatedAt = time.Now()
+	r.totps[totp.UserID] = &copied
+	return nil
+}
+
+func (r *fakeTwoFactorRepository) GetTOTP(_ context.Context, userID uuid.UUID) (*user.TOTP, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	totp, ok := r.totps[userID]
+	if !ok {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("not found"))
+	}
+	copied := *totp
+	return &copied, nil
+}
+
+func (r *fakeTwoFactorRepository) ConfirmTOTP(_ context.Context, userID uuid.UUID, usedStep int64, recoveryCodeHashes []string) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	totp, ok := r.totps[userID]
+	if !ok || totp.IsConfirmed() {
+		return common.NewError(common.ErrorCodeResourceConflict, errors.New("conflict"))
+	}
+	now := time.Now()
+	totp.ConfirmedAt = &now
+	totp.LastUsedStep = usedStep
+
+	r.deleteRecoveryCodes(userID)
+	for _, codeHash := range recoveryCodeHashes {
+		r.nextID++
+		r.recoveryCodes = append(r.recoveryCodes, &user.RecoveryCode{ID: r.nextID, UserID: userID, CodeHash: codeHash, CreatedAt: now})
+	}
+	return nil
+}
+
+func (r *fakeTwoFactorRepository) UseTOTPStep(_ context.Context, userID uuid.UUID, step int64) (bool, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	totp, ok := r.totps[userID]
+	if !ok || totp.LastUsedStep >= step {
+		return false, nil
+	}
+	totp.LastUsedStep = step
+	return true, nil
+}
+
+func (r *fakeTwoFactorRepository) DeleteTOTP(_ context.Context, userID uuid.UUID) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.totps, userID)
+	r.deleteRecoveryCodes(userID)
+	return nil
+}
+
+func (r *fakeTwoFactorRepository) deleteRecoveryCodes(userID uuid.UUID) {
+	kept := r.recoveryCodes[:0]
+	for _, c := range r.recoveryCodes {
+		if c.UserID != userID {
+			kept = append(kept, c)
+		}
+	}
+	r.recoveryCodes = kept
+}
+
+func (r *fakeTwoFactorRepository) GetRecoveryCodeByHash(_ context.Context, userID uuid.UUID, codeHash string) (*user.RecoveryCode, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.recoveryCodes {
+		if c.UserID == userID && c.CodeHash == codeHash {
+			copied := *c
+			return &copied, nil
+		}
+	}
+	return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("not found"))
+}
+
+func (r *fakeTwoFactorRepository) UseRecoveryCode(_ context.Context, codeID int64) (bool, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.recoveryCodes {
+		if c.ID == codeID && c.UsedAt == nil {
+			now := time.Now()
+			c.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeTwoFactorRepository) CreateLoginChallenge(_ context.Context, challenge *user.LoginChallenge) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	copied := *challenge
+	copied.ID = r.nextID
+	r.challenges = append(r.challenges, &copied)
+	return nil
+}
+
+func (r *fakeTwoFactorRepository) GetLoginChallengeByHash(_ context.Context, tokenHash string) (*user.LoginChallenge, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.challenges {
+		if c.TokenHash == tokenHash {
+			copied := *c
+			return &copied, nil
+		}
+	}
+	return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("not found"))
+}
+
+func (r *fakeTwoFactorRepository) UseLoginChallenge(_ context.Context, challengeID int64) (bool, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.challenges {
+		if c.ID == challengeID && c.UsedAt == nil {
+			now := time.Now()
+			c.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// currentTOTPCode returns the code an authenticator app shows for the secret at the given offset of periods
+func currentTOTPCode(t *testing.T, secret string, offset int64) string {
+	code, err := user.GenerateTOTPCode(secret, user.TOTPStep(time.Now())+offset)
+	require.NoError(t, err)
+	return code
+}
+
+// enableTwoFactor signs up a user with two-factor authentication and returns the secret and recovery codes
+func enableTwoFactor(t *testing.T, svc *testUserService, email string, username string) (string, []string) {
+	ctx := context.Background()
+	created, _, err := svc.SignUp(ctx, email, username, testPassword, testDevice)
+	require.Nil(t, err)
+
+	enrollment, err := svc.EnrollTwoFactor(ctx, created.ID)
+	require.Nil(t, err)
+	assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+
+	// The code the secret is confirmed with is from the previous period,
+	// so that the current one is still unused for the login that follows
+	codes, err := svc.ConfirmTwoFactor(ctx, created.ID, currentTOTPCode(t, enrollment.Secret, -1))
+	require.Nil(t, err)
+	require.Len(t, codes, recoveryCodeCount)
+
+	return enrollment.Secret, codes
+}
+
+func TestUserService_TwoFactorLogin(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+	secret, _ := enableTwoFactor(t, svc, "olivia@example.com", "olivia")
+
+	result, err := svc.Login(ctx, "olivia@example.com", testPassword, testDevice)
+	require.Nil(t, err)
+	assert.Nil(t, result.Token)
+	require.NotNil(t, result.Challenge)
+
+	_, _, err = svc.CompleteTwoFactorLogin(ctx, result.Challenge.Token, "000000", testDevice)
+	assertErrorCode(t, common.ErrorCodeAuthNotAuthenticated, err)
+
+	code := currentTOTPCode(t, secret, 0)
+	loggedIn, token, err := svc.CompleteTwoFactorLogin(ctx, result.Challenge.Token, code, testDevice)
+	require.Nil(t, err)
+	assert.Equal(t, "olivia", loggedIn.Username)
+	assert.NotEmpty(t, token.AccessToken)
+
+	// Neither the challenge nor the code can be used twice
+	_, _, err = svc.CompleteTwoFactorLogin(ctx, result.Challenge.Token, code, testDevice)
+	assertErrorCode(t, common.ErrorCodeAuthNotAuthenticated, err)
+	again, err := svc.Login(ctx, "olivia@example.com", testPassword, testDevice)
+	require.Nil(t, err)
+	_, _, err = svc.CompleteTwoFactorLogin(ctx, again.Challenge.Token, code, testDevice)
+	assertErrorCode(t, common.ErrorCodeAuthNotAuthenticated, err)
+}
+
+func TestUserService_TwoFactorRecoveryCode(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+	_, codes := enableTwoFactor(t, svc, "peggy@example.com", "peggy")
+
+	result, err := svc.Login(ctx, "peggy@example.com", testPassword, testDevice)
+	require.Nil(t, err)
+	_, _, err = svc.CompleteTwoFactorLogin(ctx, result.Challenge.Token, " "+codes[0]+" ", testDevice)
+	require.Nil(t, err)
+
+	// A recovery code is single-use
+	result, err = svc.Login(ctx, "peggy@example.com", testPassword, testDevice)
+	require.Nil(t, err)
+	_, _, err = svc.CompleteTwoFactorLogin(ctx, result.Challenge.Token, codes[0], testDevice)
+	assertErrorCode(t, common.ErrorCodeAuthNotAuthenticated, err)
+	_, _, err = svc.CompleteTwoFactorLogin(ctx, result.Challenge.Token, codes[1], testDevice)
+	assert.Nil(t, err)
+}
+
+func TestUserService_DisableTwoFactor(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+	secret, _ := enableTwoFactor(t, svc, "rupert@example.com", "rupert")
+	u, err := svc.userRepo.GetUserByEmail(ctx, "rupert@example.com")
+	require.Nil(t, err)
+
+	err = svc.DisableTwoFactor(ctx, u.ID, "000000")
+	assertErrorCode(t, common.ErrorCodeAuthNotAuthenticated, err)
+
+	require.Nil(t, svc.DisableTwoFactor(ctx, u.ID, currentTOTPCode(t, secret, 0)))
+
+	// Logging in takes the password alone again
+	result, err := svc.Login(ctx, "rupert@example.com", testPassword, testDevice)
+	require.Nil(t, err)
+	assert.Nil(t, result.Challenge)
+	assert.NotNil(t, result.Token)
+}
+
+func TestUserService_EnrollTwoFactorWhenEnabled(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestUserService(t)
+	enableTwoFactor(t, svc, "sybil@example.com", "sybil")
+	u, err := svc.userRepo.GetUserByEmail(ctx, "sybil@example.com")
+	require.Nil(t, err)
+
+	_, err = svc.EnrollTwoFactor(ctx, u.ID)
+	assertErrorCode(t, common.ErrorCodeResourceConflict, err)
+}
@@ -0,0 +1,61 @@
+package workspace
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+	"github.com/sappy5678/DeeliAi/internal/domain/workspace"
+)
+
+// Service manages workspaces, their members and invitations.
+type Service interface {
+	CreateWorkspace(ctx context.Context, userID uuid.UUID, name string) (*workspace.Membership, common.Error)
+	ListWorkspaces(ctx context.Context, userID uuid.UUID) ([]*workspace.Membership, common.Error)
+	GetWorkspace(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID) (*workspace.Membership, common.Error)
+	RenameWorkspace(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID, name string) (*workspace.Membership, common.Error)
+	DeleteWorkspace(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID) common.Error
+
+	// Members
+	ListMembers(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID) ([]*workspace.Member, common.Error)
+	SetMemberRole(ctx context.Context, actorID uuid.UUID, workspaceID uuid.UUID, userID uuid.UUID, role string) common.Error
+	RemoveMember(ctx context.Context, actorID uuid.UUID, workspaceID uuid.UUID, userID uuid.UUID) common.Error
+
+	// Invitations
+	InviteMember(ctx context.Context, actorID uuid.UUID, workspaceID uuid.UUID, email string, role string) (*workspace.Invitation, string, common.Error)
+	ListInvitations(ctx context.Context, actorID uuid.UUID, workspaceID uuid.UUID) ([]*workspace.Invitation, common.Error)
+	RevokeInvitation(ctx context.Context, actorID uuid.UUID, workspaceID uuid.UUID, invitationID int64) common.Error
+	AcceptInvitation(ctx context.Context, userID uuid.UUID, invitationToken string) (*workspace.Membership, common.Error)
+
+	// Authorize returns the membership of the user if their role in the workspace is at least role.
+	// Users who are no member get AUTH_PERMISSION_DENIED as well, whether the workspace exists or not.
+	Authorize(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, role string) (*workspace.Member, common.Error)
+}
+
+// WorkspaceRepository defines the interface for persisting workspaces, members and invitations.
+type WorkspaceRepository interface {
+	CreateWorkspace(ctx context.Context, ws *workspace.Workspace, ownerID uuid.UUID) (*workspace.Workspace, common.Error)
+	GetWorkspace(ctx context.Context, workspaceID uuid.UUID) (*workspace.Workspace, common.Error)
+	ListUserWorkspaces(ctx context.Context, userID uuid.UUID) ([]*workspace.Membership, common.Error)
+	UpdateWorkspaceName(ctx context.Context, workspaceID uuid.UUID, name string) common.Error
+	DeleteWorkspace(ctx context.Context, workspaceID uuid.UUID) common.Error
+
+	GetWorkspaceMember(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID) (*workspace.Member, common.Error)
+	ListWorkspaceMembers(ctx context.Context, workspaceID uuid.UUID) ([]*workspace.Member, common.Error)
+	// UpdateWorkspaceMemberRole and DeleteWorkspaceMember fail with RESOURCE_CONFLICT rather than leave a workspace without an owner
+	UpdateWorkspaceMemberRole(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, role string) common.Error
+	DeleteWorkspaceMember(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID) common.Error
+
+	CreateWorkspaceInvitation(ctx context.Context, invitation *workspace.Invitation) (*workspace.Invitation, common.Error)
+	ListWorkspaceInvitations(ctx context.Context, workspaceID uuid.UUID) ([]*workspace.Invitation, common.Error)
+	GetWorkspaceInvitationByHash(ctx context.Context, tokenHash string) (*workspace.Invitation, common.Error)
+	DeleteWorkspaceInvitation(ctx context.Context, workspaceID uuid.UUID, invitationID int64) common.Error
+	AcceptWorkspaceInvitation(ctx context.Context, invitation *workspace.Invitation, userID uuid.UUID) (bool, common.Error)
+}
+
+// UserRepository is the part of the user store workspaces need.
+type UserRepository interface {
+	GetUserByID(ctx context.Context, id uuid.UUID) (*user.User, common.Error)
+}
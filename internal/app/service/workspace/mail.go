@@ -0,0 +1,30 @@
+package workspace
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sappy5678/DeeliAi/internal/adapter/mailer"
+	"github.com/sappy5678/DeeliAi/internal/domain/workspace"
+)
+
+func newInvitationMail(ws *workspace.Workspace, invitation *workspace.Invitation, inviterName string, baseURL string, token string, expiry time.Duration) mailer.Message {
+	link := fmt.Sprintf("%s/workspaces/join?token=%s", strings.TrimRight(baseURL, "/"), url.QueryEscape(token))
+	body := fmt.Sprintf(`Hi,
+
+%s invited you to join the DeeliAi workspace "%s" as %s.
+Open the link below and log in with the account of %s to accept. It expires in %d days.
+
+%s
+
+If you do not want to join, you can ignore this mail.
+`, inviterName, ws.Name, invitation.Role, invitation.Email, int(expiry.Hours()/24), link)
+
+	return mailer.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("Join %s on DeeliAi", ws.Name),
+		Body:    body,
+	}
+}
@@ -0,0 +1,337 @@
+package workspace
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/sappy5678/DeeliAi/internal/adapter/mailer"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/workspace"
+)
+
+const (
+	// nameMaxLength is the length of the workspaces.name column
+	nameMaxLength = 100
+	// invitationTokenBytes is the entropy of an invitation token
+	invitationTokenBytes = 32
+)
+
+// Config holds the settings of the workspace service.
+type Config struct {
+	// AppBaseURL is where the frontend serves the page accepting invitations
+	AppBaseURL string
+	// InvitationExpiry is how long an invitation can be accepted
+	InvitationExpiry time.Duration
+}
+
+type workspaceService struct {
+	workspaceRepo WorkspaceRepository
+	userRepo      UserRepository
+	mailer        mailer.Mailer
+	config        Config
+}
+
+func NewWorkspaceService(_ context.Context, workspaceRepo WorkspaceRepository, userRepo UserRepository, mailer mailer.Mailer, config Config) Service {
+	return &workspaceService{
+		workspaceRepo: workspaceRepo,
+		userRepo:      userRepo,
+		mailer:        mailer,
+		config:        config,
+	}
+}
+
+// CreateWorkspace creates a workspace owned by the user.
+func (s *workspaceService) CreateWorkspace(ctx context.Context, userID uuid.UUID, name string) (*workspace.Membership, common.Error) {
+	name, cerr := validateName(name)
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	created, cerr := s.workspaceRepo.CreateWorkspace(ctx, &workspace.Workspace{Name: name}, userID)
+	if cerr != nil {
+		return nil, cerr
+	}
+	s.logger(ctx).Info().Str("workspace_id", created.ID.String()).Str("user_id", userID.String()).Msg("workspace created")
+
+	return &workspace.Membership{Workspace: *created, Role: workspace.RoleOwner}, nil
+}
+
+// ListWorkspaces returns the workspaces the user is a member of.
+func (s *workspaceService) ListWorkspaces(ctx context.Context, userID uuid.UUID) ([]*workspace.Membership, common.Error) {
+	return s.workspaceRepo.ListUserWorkspaces(ctx, userID)
+}
+
+func (s *workspaceService) GetWorkspace(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID) (*workspace.Membership, common.Error) {
+	member, cerr := s.Authorize(ctx, workspaceID, userID, workspace.RoleViewer)
+	if cerr != nil {
+		return nil, cerr
+	}
+	return s.getMembership(ctx, workspaceID, member.Role)
+}
+
+// RenameWorkspace changes the name of the workspace, which only owners can do.
+func (s *workspaceService) RenameWorkspace(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID, name string) (*workspace.Membership, common.Error) {
+	name, cerr := validateName(name)
+	if cerr != nil {
+		return nil, cerr
+	}
+	member, cerr := s.Authorize(ctx, workspaceID, userID, workspace.RoleOwner)
+	if cerr != nil {
+		return nil, cerr
+	}
+
+	if cerr := s.workspaceRepo.UpdateWorkspaceName(ctx, workspaceID, name); cerr != nil {
+		return nil, cerr
+	}
+	return s.getMembership(ctx, workspaceID, member.Role)
+}
+
+// DeleteWorkspace deletes the workspace with its articles, which only owners can do.
+// The articles stay saved for members who also saved them themselves.
+func (s *workspaceService) DeleteWorkspace(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID) common.Error {
+	if _, cerr := s.Authorize(ctx, workspaceID, userID, workspace.RoleOwner); cerr != nil {
+		return cerr
+	}
+
+	if cerr := s.workspaceRepo.DeleteWorkspace(ctx, workspaceID); cerr != nil {
+		return cerr
+	}
+	s.logger(ctx).Info().Str("workspace_id", workspaceID.String()).Str("user_id", userID.String()).Msg("workspace deleted")
+
+	return nil
+}
+
+func (s *workspaceService) ListMembers(ctx context.Context, userID uuid.UUID, workspaceID uuid.UUID) ([]*workspace.Member, common.Error) {
+	if _, cerr := s.Authorize(ctx, workspaceID, userID, workspace.RoleViewer); cerr != nil {
+		return nil, cerr
+	}
+	return s.workspaceRepo.ListWorkspaceMembers(ctx, workspaceID)
+}
+
+// SetMemberRole changes the role of a member, which only owners can do.
+// A workspace always keeps at least one owner.
+func (s *workspaceService) SetMemberRole(ctx context.Context, actorID uuid.UUID, workspaceID uuid.UUID, userID uuid.UUID, role string) common.Error {
+	if !workspace.IsValidRole(role) {
+		msg := fmt.Sprintf("invalid role %s", role)
+		return common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+	if _, cerr := s.Authorize(ctx, workspaceID, actorID, workspace.RoleOwner); cerr != nil {
+		return cerr
+	}
+	if _, cerr := s.workspaceRepo.GetWorkspaceMember(ctx, workspaceID, userID); cerr != nil {
+		return cerr
+	}
+
+	if cerr := s.workspaceRepo.UpdateWorkspaceMemberRole(ctx, workspaceID, userID, role); cerr != nil {
+		return cerr
+	}
+	s.logger(ctx).Info().Str("workspace_id", workspaceID.String()).Str("actor_id", actorID.String()).
+		Str("user_id", userID.String()).Str("role", role).Msg("workspace member role changed")
+
+	return nil
+}
+
+// RemoveMember removes a member from the workspace. Owners can remove anyone, and every member can leave.
+// The last owner cannot leave, the workspace has to be deleted instead.
+func (s *workspaceService) RemoveMember(ctx context.Context, actorID uuid.UUID, workspaceID uuid.UUID, userID uuid.UUID) common.Error {
+	required := workspace.RoleOwner
+	if actorID == userID {
+		required = workspace.RoleViewer
+	}
+	if _, cerr := s.Authorize(ctx, workspaceID, actorID, required); cerr != nil {
+		return cerr
+	}
+	if _, cerr := s.workspaceRepo.GetWorkspaceMember(ctx, workspaceID, userID); cerr != nil {
+		return cerr
+	}
+
+	if cerr := s.workspaceRepo.DeleteWorkspaceMember(ctx, workspaceID, userID); cerr != nil {
+		return cerr
+	}
+	s.logger(ctx).Info().Str("workspace_id", workspaceID.String()).Str("actor_id", actorID.String()).
+		Str("user_id", userID.String()).Msg("workspace member removed")
+
+	return nil
+}
+
+// InviteMember creates an invitation to join the workspace with the role, which only owners can do.
+// An invitation with an email is mailed to it, one without is meant to be shared as a link.
+// The invitation token is only returned here, we keep its hash.
+func (s *workspaceService) InviteMember(ctx context.Context, actorID uuid.UUID, workspaceID uuid.UUID, email string, role string) (*workspace.Invitation, string, common.Error) {
+	if !workspace.IsValidRole(role) {
+		msg := fmt.Sprintf("invalid role %s", role)
+		return nil, "", common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+	if _, cerr := s.Authorize(ctx, workspaceID, actorID, workspace.RoleOwner); cerr != nil {
+		return nil, "", cerr
+	}
+	ws, cerr := s.workspaceRepo.GetWorkspace(ctx, workspaceID)
+	if cerr != nil {
+		return nil, "", cerr
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return nil, "", common.NewError(common.ErrorCodeInternalProcess, err)
+	}
+	invitation, cerr := s.workspaceRepo.CreateWorkspaceInvitation(ctx, &workspace.Invitation{
+		WorkspaceID: workspaceID,
+		Email:       strings.TrimSpace(email),
+		Role:        role,
+		TokenHash:   hashToken(token),
+		InvitedBy:   actorID,
+		ExpiresAt:   time.Now().Add(s.config.InvitationExpiry),
+	})
+	if cerr != nil {
+		return nil, "", cerr
+	}
+
+	if invitation.Email != "" {
+		if cerr := s.sendInvitationMail(ctx, ws, invitation, token); cerr != nil {
+			s.logger(ctx).Error().Err(cerr).Int64("invitation_id", invitation.ID).Msg("failed to send workspace invitation mail")
+		}
+	}
+
+	return invitation, token, nil
+}
+
+// ListInvitations returns the invitations which were not accepted yet, which only owners can see.
+func (s *workspaceService) ListInvitations(ctx context.Context, actorID uuid.UUID, workspaceID uuid.UUID) ([]*workspace.Invitation, common.Error) {
+	if _, cerr := s.Authorize(ctx, workspaceID, actorID, workspace.RoleOwner); cerr != nil {
+		return nil, cerr
+	}
+	return s.workspaceRepo.ListWorkspaceInvitations(ctx, workspaceID)
+}
+
+func (s *workspaceService) RevokeInvitation(ctx context.Context, actorID uuid.UUID, workspaceID uuid.UUID, invitationID int64) common.Error {
+	if _, cerr := s.Authorize(ctx, workspaceID, actorID, workspace.RoleOwner); cerr != nil {
+		return cerr
+	}
+	return s.workspaceRepo.DeleteWorkspaceInvitation(ctx, workspaceID, invitationID)
+}
+
+// AcceptInvitation adds the user to the workspace of the invitation with its role.
+// An invitation with an email can only be accepted by the user who verified owning that email.
+func (s *workspaceService) AcceptInvitation(ctx context.Context, userID uuid.UUID, invitationToken string) (*workspace.Membership, common.Error) {
+	invalidInvitation := func() common.Error {
+		msg := "invalid or expired invitation"
+		return common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+
+	invitation, cerr := s.workspaceRepo.GetWorkspaceInvitationByHash(ctx, hashToken(invitationToken))
+	if cerr != nil {
+		if common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound) {
+			return nil, invalidInvitation()
+		}
+		return nil, cerr
+	}
+	if invitation.IsAccepted() || invitation.IsExpired(time.Now()) {
+		return nil, invalidInvitation()
+	}
+
+	if invitation.Email != "" {
+		u, cerr := s.userRepo.GetUserByID(ctx, userID)
+		if cerr != nil {
+			return nil, cerr
+		}
+		if !u.IsEmailVerified() || !invitation.IsFor(u.Email) {
+			msg := "the invitation is for another email address, or your email address is not verified"
+			return nil, common.NewError(common.ErrorCodeAuthPermissionDenied, errors.New(msg), common.WithMsg(msg))
+		}
+	}
+
+	// Members keep their role, and the invitation stays open for whom it was meant
+	if _, cerr := s.workspaceRepo.GetWorkspaceMember(ctx, invitation.WorkspaceID, userID); cerr == nil {
+		msg := "you are already a member of the workspace"
+		return nil, common.NewError(common.ErrorCodeResourceConflict, errors.New(msg), common.WithMsg(msg))
+	} else if !common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound) {
+		return nil, cerr
+	}
+
+	accepted, cerr := s.workspaceRepo.AcceptWorkspaceInvitation(ctx, invitation, userID)
+	if cerr != nil {
+		return nil, cerr
+	}
+	if !accepted {
+		return nil, invalidInvitation()
+	}
+	s.logger(ctx).Info().Str("workspace_id", invitation.WorkspaceID.String()).Str("user_id", userID.String()).
+		Str("role", invitation.Role).Msg("workspace invitation accepted")
+
+	return s.getMembership(ctx, invitation.WorkspaceID, invitation.Role)
+}
+
+func (s *workspaceService) Authorize(ctx context.Context, workspaceID uuid.UUID, userID uuid.UUID, role string) (*workspace.Member, common.Error) {
+	member, cerr := s.workspaceRepo.GetWorkspaceMember(ctx, workspaceID, userID)
+	if cerr != nil {
+		if common.IsErrorCode(cerr, common.ErrorCodeResourceNotFound) {
+			msg := "you are not a member of the workspace"
+			return nil, common.NewError(common.ErrorCodeAuthPermissionDenied, errors.New(msg), common.WithMsg(msg))
+		}
+		return nil, cerr
+	}
+	if !member.HasRole(role) {
+		msg := fmt.Sprintf("%s role is needed in the workspace", role)
+		return nil, common.NewError(common.ErrorCodeAuthPermissionDenied, errors.New(msg), common.WithMsg(msg))
+	}
+
+	return member, nil
+}
+
+func (s *workspaceService) getMembership(ctx context.Context, workspaceID uuid.UUID, role string) (*workspace.Membership, common.Error) {
+	ws, cerr := s.workspaceRepo.GetWorkspace(ctx, workspaceID)
+	if cerr != nil {
+		return nil, cerr
+	}
+	return &workspace.Membership{Workspace: *ws, Role: role}, nil
+}
+
+func (s *workspaceService) sendInvitationMail(ctx context.Context, ws *workspace.Workspace, invitation *workspace.Invitation, token string) common.Error {
+	inviter, cerr := s.userRepo.GetUserByID(ctx, invitation.InvitedBy)
+	if cerr != nil {
+		return cerr
+	}
+	return s.mailer.Send(ctx, newInvitationMail(ws, invitation, inviter.Username, s.config.AppBaseURL, token, s.config.InvitationExpiry))
+}
+
+// logger wrap the execution context with component info
+func (s *workspaceService) logger(ctx context.Context) *zerolog.Logger {
+	l := zerolog.Ctx(ctx).With().Str("component", "workspace-service").Logger()
+	return &l
+}
+
+// validateName trims a workspace name and checks its length
+func validateName(name string) (string, common.Error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > nameMaxLength {
+		msg := fmt.Sprintf("workspace name must have 1 to %d characters", nameMaxLength)
+		return "", common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg))
+	}
+	return name, nil
+}
+
+// generateToken returns a random URL-safe invitation token
+func generateToken() (string, error) {
+	b := make([]byte, invitationTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex encoded sha256 of a token, which is what gets stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,473 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sappy5678/DeeliAi/internal/adapter/mailer"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+	"github.com/sappy5678/DeeliAi/internal/domain/workspace"
+)
+
+// fakeWorkspaceRepository is an in-memory WorkspaceRepository for unit tests
+type fakeWorkspaceRepository struct {
+	mu          sync.Mutex
+	workspaces  map[uuid.UUID]*workspace.Workspace
+	members     map[uuid.UUID]map[uuid.UUID]string
+	invitations map[int64]*workspace.Invitation
+	nextID      int64
+}
+
+func newFakeWorkspaceRepository() *fakeWorkspaceRepository {
+	return &fakeWorkspaceRepository{
+		workspaces:  map[uuid.UUID]*workspace.Workspace{},
+		members:     map[uuid.UUID]map[uuid.UUID]string{},
+		invitations: map[int64]*workspace.Invitation{},
+	}
+}
+
+func (r *fakeWorkspaceRepository) CreateWorkspace(_ context.Context, ws *workspace.Workspace, ownerID uuid.UUID) (*workspace.Workspace, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	created := *ws
+	created.ID = uuid.New()
+	created.CreatedAt = time.Now()
+	r.workspaces[created.ID] = &created
+	r.members[created.ID] = map[uuid.UUID]string{ownerID: workspace.RoleOwner}
+	copied := created
+	return &copied, nil
+}
+
+func (r *fakeWorkspaceRepository) GetWorkspace(_ context.Context, workspaceID uuid.UUID) (*workspace.Workspace, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ws, ok := r.workspaces[workspaceID]
+	if !ok {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("workspace is not found"))
+	}
+	copied := *ws
+	return &copied, nil
+}
+
+func (r *fakeWorkspaceRepository) ListUserWorkspaces(_ context.Context, userID uuid.UUID) ([]*workspace.Membership, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var memberships []*workspace.Membership
+	for id, members := range r.members {
+		if role, ok := members[userID]; ok {
+			memberships = append(memberships, &workspace.Membership{Workspace: *r.workspaces[id], Role: role})
+		}
+	}
+	sort.Slice(memberships, func(i, j int) bool { return memberships[i].Name < memberships[j].Name })
+	return memberships, nil
+}
+
+func (r *fakeWorkspaceRepository) UpdateWorkspaceName(_ context.Context, workspaceID uuid.UUID, name string) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ws, ok := r.workspaces[workspaceID]
+	if !ok {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("workspace is not found"))
+	}
+	ws.Name = name
+	return nil
+}
+
+func (r *fakeWorkspaceRepository) DeleteWorkspace(_ context.Context, workspaceID uuid.UUID) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.workspaces, workspaceID)
+	delete(r.members, workspaceID)
+	return nil
+}
+
+func (r *fakeWorkspaceRepository) GetWorkspaceMember(_ context.Context, workspaceID uuid.UUID, userID uuid.UUID) (*workspace.Member, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	role, ok := r.members[workspaceID][userID]
+	if !ok {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("workspace member is not found"))
+	}
+	return &workspace.Member{WorkspaceID: workspaceID, UserID: userID, Role: role}, nil
+}
+
+func (r *fakeWorkspaceRepository) ListWorkspaceMembers(_ context.Context, workspaceID uuid.UUID) ([]*workspace.Member, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var members []*workspace.Member
+	for userID, role := range r.members[workspaceID] {
+		members = append(members, &workspace.Member{WorkspaceID: workspaceID, UserID: userID, Role: role})
+	}
+	return members, nil
+}
+
+// keepsOwner reports whether the workspace still has an owner once the user is no owner
+func (r *fakeWorkspaceRepository) keepsOwner(workspaceID uuid.UUID, userID uuid.UUID) bool {
+	for id, role := range r.members[workspaceID] {
+		if id != userID && role == workspace.RoleOwner {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *fakeWorkspaceRepository) UpdateWorkspaceMemberRole(_ context.Context, workspaceID uuid.UUID, userID uuid.UUID, role string) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if role != workspace.RoleOwner && !r.keepsOwner(workspaceID, userID) {
+		return common.NewError(common.ErrorCodeResourceConflict, errors.New("a workspace needs at least one owner"))
+	}
+	r.members[workspaceID][userID] = role
+	return nil
+}
+
+func (r *fakeWorkspaceRepository) DeleteWorkspaceMember(_ context.Context, workspaceID uuid.UUID, userID uuid.UUID) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.keepsOwner(workspaceID, userID) {
+		return common.NewError(common.ErrorCodeResourceConflict, errors.New("a workspace needs at least one owner"))
+	}
+	delete(r.members[workspaceID], userID)
+	return nil
+}
+
+func (r *fakeWorkspaceRepository) CreateWorkspaceInvitation(_ context.Context, invitation *workspace.Invitation) (*workspace.Invitation, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	created := *invitation
+	created.ID = r.nextID
+	created.CreatedAt = time.Now()
+	r.invitations[created.ID] = &created
+	copied := created
+	return &copied, nil
+}
+
+func (r *fakeWorkspaceRepository) ListWorkspaceInvitations(_ context.Context, workspaceID uuid.UUID) ([]*workspace.Invitation, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var invitations []*workspace.Invitation
+	for _, invitation := range r.invitations {
+		if invitation.WorkspaceID == workspaceID && !invitation.IsAccepted() {
+			copied := *invitation
+			invitations = append(invitations, &copied)
+		}
+	}
+	return invitations, nil
+}
+
+func (r *fakeWorkspaceRepository) GetWorkspaceInvitationByHash(_ context.Context, tokenHash string) (*workspace.Invitation, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, invitation := range r.invitations {
+		if invitation.TokenHash == tokenHash {
+			copied := *invitation
+			return &copied, nil
+		}
+	}
+	return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("workspace invitation is not found"))
+}
+
+func (r *fakeWorkspaceRepository) DeleteWorkspaceInvitation(_ context.Context, workspaceID uuid.UUID, invitationID int64) common.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invitation, ok := r.invitations[invitationID]
+	if !ok || invitation.WorkspaceID != workspaceID || invitation.IsAccepted() {
+		return common.NewError(common.ErrorCodeResourceNotFound, errors.New("workspace invitation is not found"))
+	}
+	delete(r.invitations, invitationID)
+	return nil
+}
+
+func (r *fakeWorkspaceRepository) AcceptWorkspaceInvitation(_ context.Context, invitation *workspace.Invitation, userID uuid.UUID) (bool, common.Error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.invitations[invitation.ID]
+	if !ok || stored.IsAccepted() {
+		return false, nil
+	}
+	now := time.Now()
+	stored.AcceptedAt = &now
+	r.members[stored.WorkspaceID][userID] = stored.Role
+	return true, nil
+}
+
+// fakeUserRepository is an in-memory UserRepository for unit tests
+type fakeUserRepository struct {
+	users map[uuid.UUID]*user.User
+}
+
+func (r *fakeUserRepository) GetUserByID(_ context.Context, id uuid.UUID) (*user.User, common.Error) {
+	u, ok := r.users[id]
+	if !ok {
+		return nil, common.NewError(common.ErrorCodeResourceNotFound, errors.New("user is not found"))
+	}
+	copied := *u
+	return &copied, nil
+}
+
+type testWorkspaceService struct {
+	Service
+	repo   *fakeWorkspaceRepository
+	users  *fakeUserRepository
+	mailer *mailer.MemoryMailer
+}
+
+func newTestWorkspaceService() *testWorkspaceService {
+	repo := newFakeWorkspaceRepository()
+	users := &fakeUserRepository{users: map[uuid.UUID]*user.User{}}
+	memoryMailer := mailer.NewMemoryMailer()
+	svc := NewWorkspaceService(context.Background(), repo, users, memoryMailer, Config{
+		AppBaseURL:       "https://deeliai.test",
+		InvitationExpiry: 7 * 24 * time.Hour,
+	})
+	return &testWorkspaceService{Service: svc, repo: repo, users: users, mailer: memoryMailer}
+}
+
+// addUser stores a user, with a verified email unless verified is false
+func (s *testWorkspaceService) addUser(email string, verified bool) uuid.UUID {
+	u := &user.User{ID: uuid.New(), Email: email, Username: email}
+	if verified {
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+	}
+	s.users.users[u.ID] = u
+	return u.ID
+}
+
+// addMember adds a new user with the role to the workspace
+func (s *testWorkspaceService) addMember(workspaceID uuid.UUID, role string) uuid.UUID {
+	userID := s.addUser(uuid.NewString()+"@example.com", true)
+	s.repo.members[workspaceID][userID] = role
+	return userID
+}
+
+var mailTokenPattern = regexp.MustCompile(`\?token=(\S+)`)
+
+// tokenFromMail extracts the token of the link in a mail body
+func tokenFromMail(t *testing.T, msg mailer.Message) string {
+	matches := mailTokenPattern.FindStringSubmatch(msg.Body)
+	require.Len(t, matches, 2, "no link in mail body")
+	token, err := url.QueryUnescape(matches[1])
+	require.NoError(t, err)
+	return token
+}
+
+// assertErrorCode asserts err is a domain error with the given code
+func assertErrorCode(t *testing.T, code common.ErrorCode, err common.Error) {
+	t.Helper()
+	var domainError common.DomainError
+	require.True(t, errors.As(err, &domainError), "not a domain error: %v", err)
+	assert.Equal(t, code.Name, domainError.Name())
+}
+
+func TestWorkspaceService_CreateWorkspace(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestWorkspaceService()
+	ownerID := svc.addUser("owner@example.com", true)
+
+	_, err := svc.CreateWorkspace(ctx, ownerID, "   ")
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+
+	created, err := svc.CreateWorkspace(ctx, ownerID, "  Reading club ")
+	require.Nil(t, err)
+	assert.Equal(t, "Reading club", created.Name)
+	assert.Equal(t, workspace.RoleOwner, created.Role)
+
+	memberships, err := svc.ListWorkspaces(ctx, ownerID)
+	require.Nil(t, err)
+	require.Len(t, memberships, 1)
+	assert.Equal(t, created.ID, memberships[0].ID)
+}
+
+func TestWorkspaceService_Permissions(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestWorkspaceService()
+	ownerID := svc.addUser("owner@example.com", true)
+	ws, err := svc.CreateWorkspace(ctx, ownerID, "Team")
+	require.Nil(t, err)
+	editorID := svc.addMember(ws.ID, workspace.RoleEditor)
+	viewerID := svc.addMember(ws.ID, workspace.RoleViewer)
+	strangerID := svc.addUser("stranger@example.com", true)
+
+	for _, userID := range []uuid.UUID{ownerID, editorID, viewerID} {
+		_, err := svc.GetWorkspace(ctx, userID, ws.ID)
+		assert.Nil(t, err)
+		_, err = svc.ListMembers(ctx, userID, ws.ID)
+		assert.Nil(t, err)
+	}
+
+	_, err = svc.GetWorkspace(ctx, strangerID, ws.ID)
+	assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+	// Workspaces which do not exist look the same to strangers
+	_, err = svc.GetWorkspace(ctx, strangerID, uuid.New())
+	assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+
+	for _, userID := range []uuid.UUID{editorID, viewerID} {
+		_, err = svc.RenameWorkspace(ctx, userID, ws.ID, "Renamed")
+		assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+		_, _, err = svc.InviteMember(ctx, userID, ws.ID, "", workspace.RoleViewer)
+		assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+		err = svc.SetMemberRole(ctx, userID, ws.ID, viewerID, workspace.RoleEditor)
+		assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+		err = svc.DeleteWorkspace(ctx, userID, ws.ID)
+		assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+	}
+	err = svc.RemoveMember(ctx, editorID, ws.ID, viewerID)
+	assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+
+	renamed, err := svc.RenameWorkspace(ctx, ownerID, ws.ID, "Renamed")
+	require.Nil(t, err)
+	assert.Equal(t, "Renamed", renamed.Name)
+
+	member, err := svc.Authorize(ctx, ws.ID, editorID, workspace.RoleEditor)
+	require.Nil(t, err)
+	assert.Equal(t, workspace.RoleEditor, member.Role)
+	_, err = svc.Authorize(ctx, ws.ID, viewerID, workspace.RoleEditor)
+	assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+}
+
+func TestWorkspaceService_Members(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestWorkspaceService()
+	ownerID := svc.addUser("owner@example.com", true)
+	ws, err := svc.CreateWorkspace(ctx, ownerID, "Team")
+	require.Nil(t, err)
+	viewerID := svc.addMember(ws.ID, workspace.RoleViewer)
+
+	err = svc.SetMemberRole(ctx, ownerID, ws.ID, viewerID, "admin")
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+	err = svc.SetMemberRole(ctx, ownerID, ws.ID, uuid.New(), workspace.RoleEditor)
+	assertErrorCode(t, common.ErrorCodeResourceNotFound, err)
+
+	// The last owner can neither step down nor leave
+	err = svc.SetMemberRole(ctx, ownerID, ws.ID, ownerID, workspace.RoleEditor)
+	assertErrorCode(t, common.ErrorCodeResourceConflict, err)
+	err = svc.RemoveMember(ctx, ownerID, ws.ID, ownerID)
+	assertErrorCode(t, common.ErrorCodeResourceConflict, err)
+
+	// Once there is another owner, they can
+	require.Nil(t, svc.SetMemberRole(ctx, ownerID, ws.ID, viewerID, workspace.RoleOwner))
+	require.Nil(t, svc.RemoveMember(ctx, ownerID, ws.ID, ownerID))
+	_, err = svc.GetWorkspace(ctx, ownerID, ws.ID)
+	assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+
+	// Members can leave without being an owner
+	editorID := svc.addMember(ws.ID, workspace.RoleEditor)
+	require.Nil(t, svc.RemoveMember(ctx, editorID, ws.ID, editorID))
+	members, err := svc.ListMembers(ctx, viewerID, ws.ID)
+	require.Nil(t, err)
+	require.Len(t, members, 1)
+	assert.Equal(t, viewerID, members[0].UserID)
+}
+
+func TestWorkspaceService_InvitationByEmail(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestWorkspaceService()
+	ownerID := svc.addUser("owner@example.com", true)
+	ws, err := svc.CreateWorkspace(ctx, ownerID, "Team")
+	require.Nil(t, err)
+
+	_, _, err = svc.InviteMember(ctx, ownerID, ws.ID, "bob@example.com", "admin")
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+
+	invitation, token, err := svc.InviteMember(ctx, ownerID, ws.ID, "bob@example.com", workspace.RoleEditor)
+	require.Nil(t, err)
+	assert.NotEqual(t, token, invitation.TokenHash)
+	messages := svc.mailer.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "bob@example.com", messages[0].To)
+	assert.Equal(t, token, tokenFromMail(t, messages[0]))
+
+	// Someone else, and Bob before verifying his email, cannot use it
+	otherID := svc.addUser("eve@example.com", true)
+	_, err = svc.AcceptInvitation(ctx, otherID, token)
+	assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+	bobID := svc.addUser("Bob@example.com", false)
+	_, err = svc.AcceptInvitation(ctx, bobID, token)
+	assertErrorCode(t, common.ErrorCodeAuthPermissionDenied, err)
+
+	now := time.Now()
+	svc.users.users[bobID].EmailVerifiedAt = &now
+	membership, err := svc.AcceptInvitation(ctx, bobID, token)
+	require.Nil(t, err)
+	assert.Equal(t, ws.ID, membership.ID)
+	assert.Equal(t, workspace.RoleEditor, membership.Role)
+
+	// Invitations are single use
+	_, err = svc.AcceptInvitation(ctx, bobID, token)
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+	invitations, err := svc.ListInvitations(ctx, ownerID, ws.ID)
+	require.Nil(t, err)
+	assert.Empty(t, invitations)
+}
+
+func TestWorkspaceService_InvitationLink(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestWorkspaceService()
+	ownerID := svc.addUser("owner@example.com", true)
+	ws, err := svc.CreateWorkspace(ctx, ownerID, "Team")
+	require.Nil(t, err)
+
+	invitation, token, err := svc.InviteMember(ctx, ownerID, ws.ID, "", workspace.RoleViewer)
+	require.Nil(t, err)
+	assert.Empty(t, svc.mailer.Messages())
+
+	_, err = svc.AcceptInvitation(ctx, ownerID, token)
+	assertErrorCode(t, common.ErrorCodeResourceConflict, err)
+	_, err = svc.AcceptInvitation(ctx, ownerID, "unknown-token")
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+
+	// Any user with the link can join, even without a verified email
+	userID := svc.addUser("carol@example.com", false)
+	membership, err := svc.AcceptInvitation(ctx, userID, token)
+	require.Nil(t, err)
+	assert.Equal(t, workspace.RoleViewer, membership.Role)
+
+	err = svc.RevokeInvitation(ctx, ownerID, ws.ID, invitation.ID)
+	assertErrorCode(t, common.ErrorCodeResourceNotFound, err)
+}
+
+func TestWorkspaceService_InvitationExpiredOrRevoked(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	svc := newTestWorkspaceService()
+	ownerID := svc.addUser("owner@example.com", true)
+	ws, err := svc.CreateWorkspace(ctx, ownerID, "Team")
+	require.Nil(t, err)
+	userID := svc.addUser("dave@example.com", true)
+
+	expired, expiredToken, err := svc.InviteMember(ctx, ownerID, ws.ID, "", workspace.RoleViewer)
+	require.Nil(t, err)
+	svc.repo.invitations[expired.ID].ExpiresAt = time.Now().Add(-time.Minute)
+	_, err = svc.AcceptInvitation(ctx, userID, expiredToken)
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+
+	revoked, revokedToken, err := svc.InviteMember(ctx, ownerID, ws.ID, "", workspace.RoleViewer)
+	require.Nil(t, err)
+	require.Nil(t, svc.RevokeInvitation(ctx, ownerID, ws.ID, revoked.ID))
+	_, err = svc.AcceptInvitation(ctx, userID, revokedToken)
+	assertErrorCode(t, common.ErrorCodeParameterInvalid, err)
+
+	// Invitations cannot be revoked through another workspace
+	other, err := svc.CreateWorkspace(ctx, ownerID, "Other")
+	require.Nil(t, err)
+	err = svc.RevokeInvitation(ctx, ownerID, other.ID, expired.ID)
+	assertErrorCode(t, common.ErrorCodeResourceNotFound, err)
+}
@@ -2,6 +2,7 @@ package article
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,6 +15,7 @@ type Article struct {
 	Description string
 	ImageURL    string
 	Metadata    json.RawMessage
+	Domain      string // the host of the URL without www., kept by the database
 }
 
 type RetryStatus int8
@@ -24,6 +26,30 @@ const (
 	RetryStatusFailed                     // 2
 )
 
+var retryStatusNames = map[RetryStatus]string{
+	RetryStatusPending: "pending",
+	RetryStatusSuccess: "success",
+	RetryStatusFailed:  "failed",
+}
+
+// String returns the name of the status shown to users.
+func (s RetryStatus) String() string {
+	if name, ok := retryStatusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", int8(s))
+}
+
+// ParseRetryStatus returns the status of the name String returns.
+func ParseRetryStatus(name string) (RetryStatus, error) {
+	for status, statusName := range retryStatusNames {
+		if statusName == name {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("fetch status must be %s, %s or %s", RetryStatusPending, RetryStatusSuccess, RetryStatusFailed)
+}
+
 type MetadataFetchRetry struct {
 	ID            int64
 	ArticleID     uuid.UUID
@@ -0,0 +1,66 @@
+package article
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// CollectionNameMaxLength is the number of characters a collection name can have at most
+const CollectionNameMaxLength = 100
+
+// Collection is a named group of saved articles in an order the user picks.
+// An article can be in several collections, and collection names are unique per user, ignoring case.
+type Collection struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	Name         string
+	ArticleCount int
+	CreatedAt    time.Time
+}
+
+// ExportedCollection is a collection with the IDs of its articles in order, as in the personal export.
+type ExportedCollection struct {
+	Collection
+	ArticleIDs []uuid.UUID
+}
+
+// NormalizeCollectionName trims a collection name and checks its length.
+func NormalizeCollectionName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > CollectionNameMaxLength {
+		return "", fmt.Errorf("collection name must have 1 to %d characters", CollectionNameMaxLength)
+	}
+	return name, nil
+}
+
+// ErrNotInCollection is returned when moving an article which is not in the collection.
+var ErrNotInCollection = errors.New("the article is not in the collection")
+
+// MoveArticle returns the articles of a collection in order, with the article moved to the 0-based position.
+// Positions past the end move the article to the end.
+func MoveArticle(articleIDs []uuid.UUID, articleID uuid.UUID, position int) ([]uuid.UUID, error) {
+	if position < 0 {
+		return nil, errors.New("position must not be negative")
+	}
+
+	moved := make([]uuid.UUID, 0, len(articleIDs))
+	for _, id := range articleIDs {
+		if id != articleID {
+			moved = append(moved, id)
+		}
+	}
+	if len(moved) == len(articleIDs) {
+		return nil, ErrNotInCollection
+	}
+
+	if position > len(moved) {
+		position = len(moved)
+	}
+	moved = append(moved[:position], append([]uuid.UUID{articleID}, moved[position:]...)...)
+	return moved, nil
+}
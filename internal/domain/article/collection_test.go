@@ -0,0 +1,50 @@
+package article
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeCollectionName(t *testing.T) {
+	t.Parallel()
+
+	name, err := NormalizeCollectionName(" To read ")
+	require.NoError(t, err)
+	assert.Equal(t, "To read", name)
+
+	_, err = NormalizeCollectionName(strings.Repeat("a", CollectionNameMaxLength+1))
+	assert.Error(t, err)
+	_, err = NormalizeCollectionName("")
+	assert.Error(t, err)
+}
+
+func TestMoveArticle(t *testing.T) {
+	t.Parallel()
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	ids := []uuid.UUID{a, b, c}
+
+	moved, err := MoveArticle(ids, c, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{c, a, b}, moved)
+
+	moved, err = MoveArticle(ids, a, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{b, a, c}, moved)
+
+	// Positions past the end move the article to the end
+	moved, err = MoveArticle(ids, a, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{b, c, a}, moved)
+
+	// The order given is left as it is
+	assert.Equal(t, []uuid.UUID{a, b, c}, ids)
+
+	_, err = MoveArticle(ids, uuid.New(), 0)
+	assert.ErrorIs(t, err, ErrNotInCollection)
+	_, err = MoveArticle(ids, a, -1)
+	assert.Error(t, err)
+}
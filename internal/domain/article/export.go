@@ -0,0 +1,397 @@
+package article
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Formats of files articles are exported to
+const (
+	ExportFormatJSON = "json" // every field of the articles
+	ExportFormatCSV  = "csv"  // the columns of Pocket CSV exports
+	ExportFormatHTML = "html" // a Netscape bookmark file, which browsers and read-later services import
+	ExportFormatOPML = "opml" // an OPML 2.0 outline of links
+)
+
+// Exporter writes saved articles to a file one at a time, so the file never has to fit in memory.
+// The start of the file is written with the first article, or by Close when there is none.
+type Exporter interface {
+	Write(saved *SavedArticle) error
+	// Close writes the end of the file
+	Close() error
+	// ContentType is the media type of the file
+	ContentType() string
+	// Extension is the file name extension of the file, without the dot
+	Extension() string
+}
+
+// ExportOption changes what an exporter writes.
+type ExportOption func(*exportOptions)
+
+type exportOptions struct {
+	fields []exportField
+}
+
+type exportField struct {
+	name  string
+	value interface{}
+}
+
+// WithExportField adds a field with the value to the top of JSON exports, before the articles.
+// The other formats have no place for it and leave it out.
+func WithExportField(name string, value interface{}) ExportOption {
+	return func(o *exportOptions) {
+		o.fields = append(o.fields, exportField{name: name, value: value})
+	}
+}
+
+// NewExporter returns an exporter writing to w in the format, which tells the file was exported at exportedAt.
+func NewExporter(format string, w io.Writer, exportedAt time.Time, opts ...ExportOption) (Exporter, error) {
+	var options exportOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	exportedAt = exportedAt.UTC()
+	switch format {
+	case ExportFormatJSON:
+		return &jsonExporter{w: w, exportedAt: exportedAt, fields: options.fields}, nil
+	case ExportFormatCSV:
+		return &csvExporter{w: csv.NewWriter(w)}, nil
+	case ExportFormatHTML:
+		return &htmlExporter{w: w}, nil
+	case ExportFormatOPML:
+		return &opmlExporter{w: w, exportedAt: exportedAt}, nil
+	}
+	return nil, fmt.Errorf("format must be one of %s, %s, %s and %s", ExportFormatJSON, ExportFormatCSV, ExportFormatHTML, ExportFormatOPML)
+}
+
+// tagNames returns the names of the tags of the article
+func tagNames(saved *SavedArticle) []string {
+	names := make([]string, 0, len(saved.Tags))
+	for _, tag := range saved.Tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+// exportTitle returns the title of the article, or its URL when it has none
+func exportTitle(saved *SavedArticle) string {
+	if saved.Title != "" {
+		return saved.Title
+	}
+	return saved.URL
+}
+
+type jsonExportArticle struct {
+	ID          uuid.UUID             `json:"id"`
+	URL         string                `json:"url"`
+	Title       string                `json:"title,omitempty"`
+	Description string                `json:"description,omitempty"`
+	ImageURL    string                `json:"image_url,omitempty"`
+	Domain      string                `json:"domain,omitempty"`
+	Metadata    json.RawMessage       `json:"metadata,omitempty"`
+	Rate        int16                 `json:"rate"`
+	State       string                `json:"state"`
+	Tags        []string              `json:"tags"`
+	CollectedAt time.Time             `json:"collected_at"`
+	ReadAt      *time.Time            `json:"read_at,omitempty"`
+	ArchivedAt  *time.Time            `json:"archived_at,omitempty"`
+	Notes       []jsonExportNote      `json:"notes,omitempty"`
+	Highlights  []jsonExportHighlight `json:"highlights,omitempty"`
+}
+
+type jsonExportNote struct {
+	ID        uuid.UUID `json:"id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type jsonExportHighlight struct {
+	ID        uuid.UUID                    `json:"id"`
+	Text      string                       `json:"text"`
+	Comment   string                       `json:"comment"`
+	Position  *jsonExportHighlightPosition `json:"position,omitempty"`
+	CreatedAt time.Time                    `json:"created_at"`
+	UpdatedAt time.Time                    `json:"updated_at"`
+}
+
+type jsonExportHighlightPosition struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// jsonExportNotes returns the notes and highlights of the saved article as exported, nil when it has none
+func jsonExportNotes(saved *SavedArticle) ([]jsonExportNote, []jsonExportHighlight) {
+	var notes []jsonExportNote
+	for _, note := range saved.Notes {
+		notes = append(notes, jsonExportNote{ID: note.ID, Body: note.Body, CreatedAt: note.CreatedAt, UpdatedAt: note.UpdatedAt})
+	}
+	var highlights []jsonExportHighlight
+	for _, highlight := range saved.Highlights {
+		exported := jsonExportHighlight{
+			ID:        highlight.ID,
+			Text:      highlight.Text,
+			Comment:   highlight.Comment,
+			CreatedAt: highlight.CreatedAt,
+			UpdatedAt: highlight.UpdatedAt,
+		}
+		if highlight.Position != nil {
+			exported.Position = &jsonExportHighlightPosition{Start: highlight.Position.Start, End: highlight.Position.End}
+		}
+		highlights = append(highlights, exported)
+	}
+	return notes, highlights
+}
+
+// jsonExporter writes {"exported_at": ..., <fields>, "articles": [...]}
+type jsonExporter struct {
+	w          io.Writer
+	exportedAt time.Time
+	fields     []exportField
+	started    bool
+}
+
+func (e *jsonExporter) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	header := fmt.Sprintf(`{"exported_at":%q,`, e.exportedAt.Format(time.RFC3339))
+	for _, field := range e.fields {
+		name, err := json.Marshal(field.name)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return err
+		}
+		header += string(name) + ":" + string(value) + ","
+	}
+	_, err := io.WriteString(e.w, header+`"articles":[`)
+	return err
+}
+
+func (e *jsonExporter) Write(saved *SavedArticle) error {
+	separator := ","
+	if !e.started {
+		separator = ""
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	notes, highlights := jsonExportNotes(saved)
+	b, err := json.Marshal(jsonExportArticle{
+		ID:          saved.ID,
+		URL:         saved.URL,
+		Title:       saved.Title,
+		Description: saved.Description,
+		ImageURL:    saved.ImageURL,
+		Domain:      saved.Domain,
+		Metadata:    saved.Metadata,
+		Rate:        saved.Rate,
+		State:       saved.State,
+		Tags:        tagNames(saved),
+		CollectedAt: saved.CollectedAt,
+		ReadAt:      saved.ReadAt,
+		ArchivedAt:  saved.ArchivedAt,
+		Notes:       notes,
+		Highlights:  highlights,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(e.w, separator+string(b))
+	return err
+}
+
+func (e *jsonExporter) Close() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(e.w, "]}")
+	return err
+}
+
+func (e *jsonExporter) ContentType() string { return "application/json; charset=utf-8" }
+func (e *jsonExporter) Extension() string   { return "json" }
+
+// csvExporter writes the columns title, url, time_added, tags (separated by |) and status of Pocket,
+// where status is archive for archived articles and unread for the others
+type csvExporter struct {
+	w       *csv.Writer
+	started bool
+}
+
+func (e *csvExporter) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	return e.w.Write([]string{"title", "url", "time_added", "tags", "status"})
+}
+
+func (e *csvExporter) Write(saved *SavedArticle) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	status := "unread"
+	if saved.State == ReadStateArchived {
+		status = "archive"
+	}
+	return e.w.Write([]string{
+		csvCell(saved.Title),
+		csvCell(saved.URL),
+		strconv.FormatInt(saved.CollectedAt.Unix(), 10),
+		csvCell(strings.Join(tagNames(saved), "|")),
+		status,
+	})
+}
+
+// csvCell prefixes text starting like a formula with ', so spreadsheets opening the export show it as text.
+// Spreadsheets skip leading whitespace, so the first other character decides, and a leading tab or carriage return is prefixed too.
+func csvCell(s string) string {
+	if s == "" {
+		return s
+	}
+	trimmed := strings.TrimLeftFunc(s, unicode.IsSpace)
+	if strings.ContainsRune("\t\r", rune(s[0])) || (trimmed != "" && strings.ContainsRune("=+-@", rune(trimmed[0]))) {
+		return "'" + s
+	}
+	return s
+}
+
+func (e *csvExporter) Close() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) ContentType() string { return "text/csv; charset=utf-8" }
+func (e *csvExporter) Extension() string   { return "csv" }
+
+// htmlExporter writes a Netscape bookmark file without folders, with the tags of the articles in TAGS
+type htmlExporter struct {
+	w       io.Writer
+	started bool
+}
+
+func (e *htmlExporter) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	_, err := io.WriteString(e.w, `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+`)
+	return err
+}
+
+func (e *htmlExporter) Write(saved *SavedArticle) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `    <DT><A HREF="%s" ADD_DATE="%d"`, html.EscapeString(saved.URL), saved.CollectedAt.Unix())
+	if tags := tagNames(saved); len(tags) > 0 {
+		fmt.Fprintf(&b, ` TAGS="%s"`, html.EscapeString(strings.Join(tags, ",")))
+	}
+	fmt.Fprintf(&b, ">%s</A>\n", html.EscapeString(exportTitle(saved)))
+	if saved.Description != "" {
+		fmt.Fprintf(&b, "    <DD>%s\n", html.EscapeString(saved.Description))
+	}
+	_, err := e.w.Write(b.Bytes())
+	return err
+}
+
+func (e *htmlExporter) Close() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(e.w, "</DL><p>\n")
+	return err
+}
+
+func (e *htmlExporter) ContentType() string { return "text/html; charset=utf-8" }
+func (e *htmlExporter) Extension() string   { return "html" }
+
+// opmlExporter writes an outline of link outlines, with the tags of the articles as categories
+type opmlExporter struct {
+	w          io.Writer
+	exportedAt time.Time
+	started    bool
+}
+
+func (e *opmlExporter) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	_, err := fmt.Fprintf(e.w, `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+<head>
+<title>DeeliAi articles</title>
+<dateCreated>%s</dateCreated>
+</head>
+<body>
+`, e.exportedAt.Format(time.RFC1123Z))
+	return err
+}
+
+// xmlAttr escapes s to be the value of an XML attribute
+func xmlAttr(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func (e *opmlExporter) Write(saved *SavedArticle) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<outline type="link" text="%s" url="%s" created="%s"`,
+		xmlAttr(exportTitle(saved)), xmlAttr(saved.URL), saved.CollectedAt.UTC().Format(time.RFC1123Z))
+	if saved.Description != "" {
+		fmt.Fprintf(&b, ` description="%s"`, xmlAttr(saved.Description))
+	}
+	if tags := tagNames(saved); len(tags) > 0 {
+		// Categories are slash-delimited paths separated by commas
+		for i, tag := range tags {
+			tags[i] = "/" + tag
+		}
+		fmt.Fprintf(&b, ` category="%s"`, xmlAttr(strings.Join(tags, ",")))
+	}
+	b.WriteString("/>\n")
+	_, err := e.w.Write(b.Bytes())
+	return err
+}
+
+func (e *opmlExporter) Close() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(e.w, "</body>\n</opml>\n")
+	return err
+}
+
+func (e *opmlExporter) ContentType() string { return "text/x-opml; charset=utf-8" }
+func (e *opmlExporter) Extension() string   { return "opml" }
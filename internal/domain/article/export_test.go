@@ -0,0 +1,196 @@
+package article
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportedArticles() []*SavedArticle {
+	return []*SavedArticle{
+		{
+			Article:     Article{ID: uuid.New(), URL: "https://example.com/a?x=1&y=2", Title: `Quotes "and" <tags>`, Description: "About A"},
+			CollectedAt: time.Unix(1600000000, 0).UTC(),
+			State:       ReadStateUnread,
+			Tags:        []*Tag{{Name: "go"}, {Name: "web"}},
+		},
+		{
+			Article:     Article{ID: uuid.New(), URL: "https://example.com/b"},
+			CollectedAt: time.Unix(1600000001, 0).UTC(),
+			State:       ReadStateArchived,
+			Rate:        4,
+		},
+	}
+}
+
+func export(t *testing.T, format string, articles []*SavedArticle, opts ...ExportOption) []byte {
+	var b bytes.Buffer
+	e, err := NewExporter(format, &b, time.Unix(1700000000, 0), opts...)
+	require.NoError(t, err)
+	for _, saved := range articles {
+		require.NoError(t, e.Write(saved))
+	}
+	require.NoError(t, e.Close())
+	return b.Bytes()
+}
+
+func TestExportImportable(t *testing.T) {
+	t.Parallel()
+
+	// Exported files are imported back with their URLs, times and tags
+	for exportFormat, importFormat := range map[string]string{
+		ExportFormatCSV:  ImportFormatPocketCSV,
+		ExportFormatHTML: ImportFormatHTML,
+	} {
+		format, items, err := ParseImport("", export(t, exportFormat, exportedArticles()))
+		require.NoError(t, err, exportFormat)
+		assert.Equal(t, importFormat, format)
+		require.Len(t, items, 2, exportFormat)
+		assert.Equal(t, "https://example.com/a?x=1&y=2", items[0].URL, exportFormat)
+		assert.Equal(t, time.Unix(1600000000, 0).UTC(), items[0].CollectedAt, exportFormat)
+		assert.Equal(t, []string{"go", "web"}, items[0].Tags, exportFormat)
+		assert.Empty(t, items[1].Error, exportFormat)
+	}
+
+	_, items, err := ParseImport(ImportFormatPocketCSV, export(t, ExportFormatCSV, exportedArticles()))
+	require.NoError(t, err)
+	assert.Equal(t, ReadStateArchived, items[1].State)
+}
+
+func TestExportCSVFormulas(t *testing.T) {
+	t.Parallel()
+
+	// Cells starting like a formula are written as text, the others unchanged
+	exported := export(t, ExportFormatCSV, []*SavedArticle{
+		{Article: Article{URL: "https://example.com/a", Title: `=HYPERLINK("https://evil.example")`}, Tags: []*Tag{{Name: "+1"}}},
+		{Article: Article{URL: "https://example.com/b", Title: "-x"}, Tags: []*Tag{{Name: "go"}, {Name: "@SUM"}}},
+		{Article: Article{URL: "https://example.com/c", Title: "@SUM(A1)"}},
+		{Article: Article{URL: "https://example.com/d", Title: "1 + 1 = 2"}},
+	})
+	records, err := csv.NewReader(bytes.NewReader(exported)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 5)
+	assert.Equal(t, []string{`'=HYPERLINK("https://evil.example")`, "'+1"}, []string{records[1][0], records[1][3]})
+	assert.Equal(t, []string{"'-x", "go|@SUM"}, []string{records[2][0], records[2][3]})
+	assert.Equal(t, "'@SUM(A1)", records[3][0])
+	assert.Equal(t, "1 + 1 = 2", records[4][0])
+	assert.Equal(t, "https://example.com/d", records[4][1])
+}
+
+func TestCSVCell(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"", ""},
+		{"Go", "Go"},
+		{"1 + 1 = 2", "1 + 1 = 2"},
+		{"=1+1", "'=1+1"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{` =HYPERLINK("https://evil.example")`, `' =HYPERLINK("https://evil.example")`},
+		{"\n=cmd", "'\n=cmd"},
+		{"\u00a0=cmd", "'\u00a0=cmd"},
+		{"\tGo", "'\tGo"},
+		{"\rGo", "'\rGo"},
+		{"  Go", "  Go"},
+		{" \n ", " \n "},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, csvCell(tt.cell), "%q", tt.cell)
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	t.Parallel()
+
+	var doc struct {
+		ExportedAt time.Time           `json:"exported_at"`
+		Articles   []jsonExportArticle `json:"articles"`
+	}
+	require.NoError(t, json.Unmarshal(export(t, ExportFormatJSON, exportedArticles()), &doc))
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), doc.ExportedAt)
+	require.Len(t, doc.Articles, 2)
+	assert.Equal(t, []string{"go", "web"}, doc.Articles[0].Tags)
+	assert.Equal(t, int16(4), doc.Articles[1].Rate)
+
+	require.NoError(t, json.Unmarshal(export(t, ExportFormatJSON, nil), &doc))
+	assert.Empty(t, doc.Articles)
+}
+
+func TestExportJSONFields(t *testing.T) {
+	t.Parallel()
+
+	type profile struct {
+		Username string `json:"username"`
+	}
+	exported := export(t, ExportFormatJSON, exportedArticles(), WithExportField("user", profile{Username: "alice"}))
+	assert.True(t, bytes.HasPrefix(exported, []byte(`{"exported_at":"2023-11-14T22:13:20Z","user":{"username":"alice"},"articles":[`)), string(exported))
+	var doc struct {
+		User     profile             `json:"user"`
+		Articles []jsonExportArticle `json:"articles"`
+	}
+	require.NoError(t, json.Unmarshal(exported, &doc))
+	assert.Equal(t, "alice", doc.User.Username)
+	assert.Len(t, doc.Articles, 2)
+
+	// Without articles the fields are still written
+	require.NoError(t, json.Unmarshal(export(t, ExportFormatJSON, nil, WithExportField("user", profile{Username: "bob"})), &doc))
+	assert.Equal(t, "bob", doc.User.Username)
+	assert.Empty(t, doc.Articles)
+
+	// Other formats leave them out
+	assert.NotContains(t, string(export(t, ExportFormatCSV, exportedArticles(), WithExportField("user", profile{Username: "alice"}))), "alice")
+}
+
+func TestExportJSONNotes(t *testing.T) {
+	t.Parallel()
+
+	articles := exportedArticles()
+	articles[0].Notes = []*Note{{ID: uuid.New(), Body: "Worth a second read"}}
+	articles[0].Highlights = []*Highlight{{ID: uuid.New(), Text: "quoted", Position: &HighlightPosition{Start: 3, End: 9}}}
+	var doc struct {
+		Articles []map[string]json.RawMessage `json:"articles"`
+	}
+	require.NoError(t, json.Unmarshal(export(t, ExportFormatJSON, articles), &doc))
+	require.Len(t, doc.Articles, 2)
+	assert.Contains(t, string(doc.Articles[0]["notes"]), `"body":"Worth a second read"`)
+	assert.Contains(t, string(doc.Articles[0]["highlights"]), `"text":"quoted","comment":"","position":{"start":3,"end":9}`)
+
+	// Articles without notes or highlights leave them out
+	assert.NotContains(t, doc.Articles[1], "notes")
+	assert.NotContains(t, doc.Articles[1], "highlights")
+}
+
+func TestExportOPML(t *testing.T) {
+	t.Parallel()
+
+	var doc struct {
+		Outlines []struct {
+			Text     string `xml:"text,attr"`
+			URL      string `xml:"url,attr"`
+			Created  string `xml:"created,attr"`
+			Category string `xml:"category,attr"`
+		} `xml:"body>outline"`
+	}
+	require.NoError(t, xml.Unmarshal(export(t, ExportFormatOPML, exportedArticles()), &doc))
+	require.Len(t, doc.Outlines, 2)
+	assert.Equal(t, `Quotes "and" <tags>`, doc.Outlines[0].Text)
+	assert.Equal(t, "https://example.com/a?x=1&y=2", doc.Outlines[0].URL)
+	assert.Equal(t, "/go,/web", doc.Outlines[0].Category)
+	assert.Equal(t, "https://example.com/b", doc.Outlines[1].Text, "untitled articles are named by their URL")
+	assert.Equal(t, "Sun, 13 Sep 2020 12:26:41 +0000", doc.Outlines[1].Created)
+
+	_, err := NewExporter("pdf", &bytes.Buffer{}, time.Now())
+	assert.Error(t, err)
+}
@@ -0,0 +1,391 @@
+package article
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/net/html"
+)
+
+// Formats of files articles are imported from
+const (
+	ImportFormatHTML          = "html"           // Netscape bookmark files exported by browsers, and Pocket HTML exports
+	ImportFormatPocketCSV     = "pocket_csv"     // Pocket CSV exports
+	ImportFormatInstapaperCSV = "instapaper_csv" // Instapaper CSV exports
+	ImportFormatText          = "text"           // a URL per line
+)
+
+// Statuses of import jobs
+const (
+	ImportStatusPending = "pending"
+	ImportStatusRunning = "running"
+	ImportStatusDone    = "done"
+	ImportStatusFailed  = "failed" // given up after ImportMaxAttempts runs, the articles imported until then are kept
+)
+
+const (
+	ImportMaxSize       = 10 << 20 // bytes of an imported file
+	ImportMaxItems      = 10000    // articles of an imported file
+	ImportMaxErrors     = 1000     // errors an import job keeps, later ones are only counted
+	ImportMaxAttempts   = 3        // runs of an import job, a job claimed once more fails
+	ImportMaxUnfinished = 3        // import jobs a user can have pending or running at once
+
+	importMaxURLLength = 2048 // in bytes, longer URLs do not fit the index of article URLs
+)
+
+// ImportItem is an article of an imported file
+type ImportItem struct {
+	Line        int // where the article is in the file, from 1
+	URL         string
+	CollectedAt time.Time // when the article was saved, zero when the file does not tell
+	Tags        []string  // the tags and folders of the article
+	State       string    // ReadStateArchived for articles archived in the file, empty otherwise
+	Error       string    // why the article cannot be imported, empty when it can
+}
+
+// ImportError is an article of an import job which could not be imported
+type ImportError struct {
+	Line    int
+	URL     string
+	Message string
+}
+
+// ImportJob imports the articles of a file in the background.
+// Articles the user has already saved are counted as duplicates, and only get the tags of the file.
+type ImportJob struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Format     string
+	Status     string
+	Items      []*ImportItem // only kept until the job is done
+	Total      int
+	Processed  int // the items handled, the job resumes with the next one
+	Imported   int
+	Duplicates int
+	Failed     int
+	Errors     []ImportError
+	Attempts   int // how many runs claimed the job
+	CreatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+// NewImportJob returns a pending job importing the items for the user.
+func NewImportJob(userID uuid.UUID, format string, items []*ImportItem) *ImportJob {
+	return &ImportJob{
+		UserID: userID,
+		Format: format,
+		Status: ImportStatusPending,
+		Items:  items,
+		Total:  len(items),
+	}
+}
+
+// IsFinished reports whether the job is done or failed, so that no run will continue it.
+func (j *ImportJob) IsFinished() bool {
+	return j.Status == ImportStatusDone || j.Status == ImportStatusFailed
+}
+
+// Fail counts the item as failed, keeping the message up to ImportMaxErrors errors.
+func (j *ImportJob) Fail(item *ImportItem, message string) {
+	j.Failed++
+	if len(j.Errors) < ImportMaxErrors {
+		j.Errors = append(j.Errors, ImportError{Line: item.Line, URL: item.URL, Message: message})
+	}
+}
+
+// DetectImportFormat guesses the format of an imported file from its content.
+func DetectImportFormat(data []byte) string {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\ufeff")))
+	if bytes.HasPrefix(data, []byte("<")) {
+		return ImportFormatHTML
+	}
+
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	columns := make(map[string]bool)
+	for _, column := range strings.Split(string(header), ",") {
+		columns[strings.ToLower(strings.Trim(strings.TrimSpace(column), `"`))] = true
+	}
+	switch {
+	case columns["url"] && columns["time_added"]:
+		return ImportFormatPocketCSV
+	case columns["url"] && columns["folder"]:
+		return ImportFormatInstapaperCSV
+	}
+	return ImportFormatText
+}
+
+// ParseImport returns the articles of an imported file in the format, guessing the format when it is empty.
+// Articles which cannot be imported are returned with their Error set.
+func ParseImport(format string, data []byte) (string, []*ImportItem, error) {
+	if len(data) > ImportMaxSize {
+		return "", nil, fmt.Errorf("imported files must be at most %d MB", ImportMaxSize>>20)
+	}
+	if format == "" {
+		format = DetectImportFormat(data)
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	var items []*ImportItem
+	var err error
+	switch format {
+	case ImportFormatHTML:
+		items = parseImportHTML(data)
+	case ImportFormatPocketCSV:
+		items, err = parseImportCSV(data, parsePocketRecord, "url")
+	case ImportFormatInstapaperCSV:
+		items, err = parseImportCSV(data, parseInstapaperRecord, "url")
+	case ImportFormatText:
+		items = parseImportText(data)
+	default:
+		return "", nil, fmt.Errorf("format must be %s, %s, %s or %s",
+			ImportFormatHTML, ImportFormatPocketCSV, ImportFormatInstapaperCSV, ImportFormatText)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(items) == 0 {
+		return "", nil, errors.New("the file contains no articles")
+	}
+	if len(items) > ImportMaxItems {
+		return "", nil, fmt.Errorf("files with at most %d articles can be imported", ImportMaxItems)
+	}
+	return format, items, nil
+}
+
+// newImportItem returns the item of an article in the file, with the error of its URL if it has one
+func newImportItem(line int, rawURL string, collectedAt time.Time, tags []string, state string) *ImportItem {
+	item := &ImportItem{Line: line, URL: strings.TrimSpace(rawURL), CollectedAt: collectedAt, State: state}
+	if err := validateImportURL(item.URL); err != nil {
+		item.Error = err.Error()
+		return item
+	}
+
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		name, err := NormalizeTagName(tag)
+		if err != nil || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		item.Tags = append(item.Tags, name)
+	}
+	return item
+}
+
+// validateImportURL checks that an imported URL is a web page
+func validateImportURL(rawURL string) error {
+	if rawURL == "" {
+		return errors.New("missing url")
+	}
+	if len(rawURL) > importMaxURLLength {
+		return fmt.Errorf("url is longer than %d characters", importMaxURLLength)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("not an http or https url")
+	}
+	return nil
+}
+
+// parseImportTime parses a Unix time, telling seconds from milliseconds and microseconds by their size
+func parseImportTime(s string) time.Time {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	switch {
+	case err != nil || n <= 0:
+		return time.Time{}
+	case n > 1e14:
+		return time.UnixMicro(n).UTC()
+	case n > 1e11:
+		return time.UnixMilli(n).UTC()
+	}
+	return time.Unix(n, 0).UTC()
+}
+
+// splitTags splits a list of tags, dropping empty ones
+func splitTags(s string, sep string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, sep) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseImportHTML returns the links of a Netscape bookmark file or Pocket HTML export.
+// Bookmarks get the names of the folders they are in as tags, except for the bookmarks toolbar,
+// and the links under Pocket's "Read Archive" heading are archived.
+func parseImportHTML(data []byte) []*ImportItem {
+	z := html.NewTokenizer(bytes.NewReader(data))
+	var items []*ImportItem
+	var folders []string // the folder of every open <dl>, empty for the toolbar and lists without a heading
+	var folder string    // the folder of the next <dl>
+	var heading string   // the heading whose text is being read
+	var text strings.Builder
+	state := ""
+	line := 1
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return items
+		}
+		tokenLine := line
+		line += bytes.Count(z.Raw(), []byte("\n"))
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			attrs := tokenAttrs(z)
+			switch string(name) {
+			case "h1", "h3":
+				heading = string(name)
+				text.Reset()
+				if attrs["personal_toolbar_folder"] == "true" {
+					heading = "toolbar"
+				}
+			case "dl":
+				folders = append(folders, folder)
+				folder = ""
+			case "a":
+				if _, ok := attrs["href"]; !ok {
+					continue
+				}
+				addedAt := attrs["add_date"]
+				if addedAt == "" {
+					addedAt = attrs["time_added"]
+				}
+				tags := splitTags(attrs["tags"], ",")
+				for _, name := range folders {
+					if name != "" {
+						tags = append(tags, name)
+					}
+				}
+				items = append(items, newImportItem(tokenLine, attrs["href"], parseImportTime(addedAt), tags, state))
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "h1":
+				state = ""
+				if strings.EqualFold(strings.TrimSpace(text.String()), "Read Archive") {
+					state = ReadStateArchived
+				}
+				heading = ""
+			case "h3":
+				if heading == "h3" {
+					folder = strings.TrimSpace(text.String())
+				}
+				heading = ""
+			case "dl":
+				if len(folders) > 0 {
+					folders = folders[:len(folders)-1]
+				}
+			}
+		case html.TextToken:
+			if heading != "" {
+				text.Write(z.Text())
+			}
+		}
+	}
+}
+
+// tokenAttrs returns the attributes of the current tag, with lowercase keys
+func tokenAttrs(z *html.Tokenizer) map[string]string {
+	attrs := make(map[string]string)
+	for {
+		key, value, more := z.TagAttr()
+		if len(key) > 0 {
+			attrs[string(key)] = string(value)
+		}
+		if !more {
+			return attrs
+		}
+	}
+}
+
+// parseImportCSV returns the articles of a CSV file with a header, parsing every record with parse,
+// which gets the value of a column by its name
+func parseImportCSV(data []byte, parse func(line int, column func(name string) string) *ImportItem, required ...string) ([]*ImportItem, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, errors.New("the file has no csv header")
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range required {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("the csv header has no %s column", name)
+		}
+	}
+
+	var items []*ImportItem
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+		items = append(items, parse(line, func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}))
+	}
+}
+
+// parsePocketRecord parses a record of the columns title, url, time_added, tags (separated by |) and status
+func parsePocketRecord(line int, column func(name string) string) *ImportItem {
+	state := ""
+	if column("status") == "archive" {
+		state = ReadStateArchived
+	}
+	return newImportItem(line, column("url"), parseImportTime(column("time_added")), splitTags(column("tags"), "|"), state)
+}
+
+// parseInstapaperRecord parses a record of the columns URL, Title, Selection, Folder and Timestamp.
+// The Archive folder archives articles, and folders of the user are tags.
+func parseInstapaperRecord(line int, column func(name string) string) *ImportItem {
+	var tags []string
+	state := ""
+	switch folder := column("folder"); folder {
+	case "", "Unread":
+	case "Archive":
+		state = ReadStateArchived
+	default:
+		tags = append(tags, folder)
+	}
+	return newImportItem(line, column("url"), parseImportTime(column("timestamp")), tags, state)
+}
+
+// parseImportText returns a URL per line, skipping empty lines and comments starting with #
+func parseImportText(data []byte) []*ImportItem {
+	var items []*ImportItem
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items = append(items, newImportItem(i+1, line, time.Time{}, nil, ""))
+	}
+	return items
+}
@@ -0,0 +1,138 @@
+package article
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const netscapeBookmarks = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1700000000" PERSONAL_TOOLBAR_FOLDER="true">Bookmarks bar</H3>
+    <DL><p>
+        <DT><A HREF="https://go.dev/blog/" ADD_DATE="1700000001">The Go Blog</A>
+        <DT><H3 ADD_DATE="1700000000">Databases</H3>
+        <DL><p>
+            <DT><A HREF="https://www.postgresql.org/docs/" ADD_DATE="1700000002" TAGS="sql,Reference">PostgreSQL</A>
+        </DL><p>
+    </DL><p>
+    <DT><A HREF="javascript:alert(1)">Bookmarklet</A>
+</DL><p>
+`
+
+const pocketHTML = `<!DOCTYPE html>
+<html><head><title>Pocket Export</title></head><body>
+<h1>Unread</h1>
+<ul>
+<li><a href="https://example.com/a" time_added="1600000000" tags="go,web">A</a></li>
+</ul>
+<h1>Read Archive</h1>
+<ul>
+<li><a href="https://example.com/b" time_added="1600000001" tags="">B</a></li>
+</ul>
+</body></html>
+`
+
+func TestParseImportHTML(t *testing.T) {
+	t.Parallel()
+
+	format, items, err := ParseImport("", []byte(netscapeBookmarks))
+	require.NoError(t, err)
+	assert.Equal(t, ImportFormatHTML, format)
+	require.Len(t, items, 3)
+
+	assert.Equal(t, &ImportItem{Line: 8, URL: "https://go.dev/blog/", CollectedAt: time.Unix(1700000001, 0).UTC()}, items[0])
+	assert.Equal(t, 11, items[1].Line)
+	assert.Equal(t, []string{"sql", "Reference", "Databases"}, items[1].Tags, "the toolbar is no tag")
+	assert.Equal(t, "not an http or https url", items[2].Error)
+
+	_, items, err = ParseImport(ImportFormatHTML, []byte(pocketHTML))
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, []string{"go", "web"}, items[0].Tags)
+	assert.Empty(t, items[0].State)
+	assert.Equal(t, ReadStateArchived, items[1].State)
+	assert.Equal(t, time.Unix(1600000001, 0).UTC(), items[1].CollectedAt)
+}
+
+func TestParseImportCSV(t *testing.T) {
+	t.Parallel()
+
+	pocket := "title,url,time_added,tags,status\n" +
+		"A,https://example.com/a,1600000000,go|web,unread\n" +
+		"\"B, quoted\",https://example.com/b,1600000001,,archive\n" +
+		"C,,1600000002,,unread\n"
+	format, items, err := ParseImport("", []byte(pocket))
+	require.NoError(t, err)
+	assert.Equal(t, ImportFormatPocketCSV, format)
+	require.Len(t, items, 3)
+	assert.Equal(t, &ImportItem{Line: 2, URL: "https://example.com/a", CollectedAt: time.Unix(1600000000, 0).UTC(), Tags: []string{"go", "web"}}, items[0])
+	assert.Equal(t, ReadStateArchived, items[1].State)
+	assert.Equal(t, "missing url", items[2].Error)
+
+	instapaper := "URL,Title,Selection,Folder,Timestamp\r\n" +
+		"https://example.com/a,A,,Unread,1600000000\r\n" +
+		"https://example.com/b,B,,Archive,1600000001\r\n" +
+		"https://example.com/c,C,,Go,1600000002\r\n"
+	format, items, err = ParseImport("", []byte(instapaper))
+	require.NoError(t, err)
+	assert.Equal(t, ImportFormatInstapaperCSV, format)
+	require.Len(t, items, 3)
+	assert.Empty(t, items[0].Tags)
+	assert.Equal(t, ReadStateArchived, items[1].State)
+	assert.Equal(t, []string{"Go"}, items[2].Tags)
+	assert.Equal(t, 4, items[2].Line)
+
+	_, _, err = ParseImport(ImportFormatPocketCSV, []byte("title,link\nA,https://example.com/a\n"))
+	assert.Error(t, err, "csv without url column")
+}
+
+func TestParseImportText(t *testing.T) {
+	t.Parallel()
+
+	format, items, err := ParseImport("", []byte("# reading list\nhttps://example.com/a\n\n  https://example.com/b  \nexample.com/c\n"))
+	require.NoError(t, err)
+	assert.Equal(t, ImportFormatText, format)
+	require.Len(t, items, 3)
+	assert.Equal(t, &ImportItem{Line: 2, URL: "https://example.com/a"}, items[0])
+	assert.Equal(t, &ImportItem{Line: 4, URL: "https://example.com/b"}, items[1])
+	assert.Equal(t, 5, items[2].Line)
+	assert.NotEmpty(t, items[2].Error)
+
+	for _, data := range []string{"", "# only comments\n"} {
+		_, _, err = ParseImport("", []byte(data))
+		assert.Error(t, err, data)
+	}
+	_, _, err = ParseImport("opml", []byte("https://example.com/a"))
+	assert.Error(t, err)
+}
+
+func TestParseImportTime(t *testing.T) {
+	t.Parallel()
+
+	want := time.Unix(1700000000, 0).UTC()
+	assert.Equal(t, want, parseImportTime("1700000000"))
+	assert.Equal(t, want, parseImportTime("1700000000000"))
+	assert.Equal(t, want, parseImportTime("1700000000000000"))
+	assert.True(t, parseImportTime("").IsZero())
+	assert.True(t, parseImportTime("yesterday").IsZero())
+}
+
+func TestImportJobFail(t *testing.T) {
+	t.Parallel()
+
+	job := NewImportJob(uuid.New(), ImportFormatText, nil)
+	item := &ImportItem{Line: 3, URL: "https://example.com"}
+	for i := 0; i < ImportMaxErrors+5; i++ {
+		job.Fail(item, "boom")
+	}
+	assert.Equal(t, ImportMaxErrors+5, job.Failed)
+	assert.Len(t, job.Errors, ImportMaxErrors)
+	assert.Equal(t, ImportError{Line: 3, URL: "https://example.com", Message: "boom"}, job.Errors[0])
+}
@@ -0,0 +1,207 @@
+package article
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ArticleFilter selects saved articles. Zero fields match every article.
+type ArticleFilter struct {
+	// Tags are tag names, matched ignoring case
+	Tags     []string
+	TagMatch string
+	// States match articles in any of the read states
+	States []string
+	// CollectedAfter and CollectedBefore bound when the article was saved, the first inclusive and the second exclusive
+	CollectedAfter  time.Time
+	CollectedBefore time.Time
+	// MinRating and MaxRating bound the rating, inclusive, and Unrated matches articles without rating only
+	MinRating int16
+	MaxRating int16
+	Unrated   bool
+	// Domain matches articles from the domain and its subdomains
+	Domain string
+	// FetchStatus matches articles whose metadata fetch has the status, by its name
+	FetchStatus string
+}
+
+// Validate normalizes the filter, defaulting to TagMatchAll.
+func (f *ArticleFilter) Validate() error {
+	if f.TagMatch == "" {
+		f.TagMatch = TagMatchAll
+	}
+	if f.TagMatch != TagMatchAll && f.TagMatch != TagMatchAny {
+		return fmt.Errorf("tag_match must be %s or %s", TagMatchAll, TagMatchAny)
+	}
+	for i, name := range f.Tags {
+		normalized, err := NormalizeTagName(name)
+		if err != nil {
+			return err
+		}
+		f.Tags[i] = strings.ToLower(normalized)
+	}
+	for _, state := range f.States {
+		if err := ValidateReadState(state); err != nil {
+			return err
+		}
+	}
+
+	if !f.CollectedAfter.IsZero() && !f.CollectedBefore.IsZero() && !f.CollectedAfter.Before(f.CollectedBefore) {
+		return errors.New("collected_after must be before collected_before")
+	}
+	for _, rating := range []int16{f.MinRating, f.MaxRating} {
+		if rating < 0 || rating > 5 {
+			return errors.New("ratings must be between 1 and 5")
+		}
+	}
+	if f.MinRating > 0 && f.MaxRating > 0 && f.MinRating > f.MaxRating {
+		return errors.New("min_rating must not be above max_rating")
+	}
+	if f.Unrated && (f.MinRating > 0 || f.MaxRating > 0) {
+		return errors.New("unrated articles have no rating to bound")
+	}
+
+	if f.Domain != "" {
+		domain, err := NormalizeDomain(f.Domain)
+		if err != nil {
+			return err
+		}
+		f.Domain = domain
+	}
+	if f.FetchStatus != "" {
+		if _, err := ParseRetryStatus(f.FetchStatus); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NormalizeDomain lowercases a domain and drops its www. prefix, the way the domains of articles are kept.
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+	if domain == "" || strings.ContainsAny(domain, "/:?#@ \t") {
+		return "", errors.New("domain must be a host name such as example.com")
+	}
+	return domain, nil
+}
+
+// Fields saved articles are sorted by
+const (
+	SortByCollectedAt = "collected_at"
+	SortByRating      = "rating"
+	SortByTitle       = "title"
+	SortByDomain      = "domain"
+)
+
+// Orders of sorting
+const (
+	SortOrderAsc  = "asc"
+	SortOrderDesc = "desc"
+)
+
+// ArticleSort orders saved articles by a field. Articles with the same value are ordered by ID the same way.
+type ArticleSort struct {
+	By    string
+	Order string
+}
+
+// Validate defaults the sort to the latest saved first. The order defaults to descending for collected_at and rating,
+// and to ascending for title and domain.
+func (s *ArticleSort) Validate() error {
+	if s.By == "" {
+		s.By = SortByCollectedAt
+	}
+	switch s.By {
+	case SortByCollectedAt, SortByRating:
+		if s.Order == "" {
+			s.Order = SortOrderDesc
+		}
+	case SortByTitle, SortByDomain:
+		if s.Order == "" {
+			s.Order = SortOrderAsc
+		}
+	default:
+		return fmt.Errorf("sort must be %s, %s, %s or %s", SortByCollectedAt, SortByRating, SortByTitle, SortByDomain)
+	}
+	if s.Order != SortOrderAsc && s.Order != SortOrderDesc {
+		return fmt.Errorf("order must be %s or %s", SortOrderAsc, SortOrderDesc)
+	}
+	return nil
+}
+
+// Key returns the value the article is sorted by.
+func (s ArticleSort) Key(art *SavedArticle) string {
+	switch s.By {
+	case SortByRating:
+		return strconv.Itoa(int(art.Rate))
+	case SortByTitle:
+		return art.Title
+	case SortByDomain:
+		return art.Domain
+	default:
+		return art.CollectedAt.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+// validKey checks that key is a value of the sort field
+func (s ArticleSort) validKey(key string) bool {
+	switch s.By {
+	case SortByCollectedAt:
+		_, err := time.Parse(time.RFC3339Nano, key)
+		return err == nil
+	case SortByRating:
+		_, err := strconv.ParseInt(key, 10, 16)
+		return err == nil
+	}
+	return true
+}
+
+// ArticleCursor is where the next page of saved articles starts, right after the last article of a page
+type ArticleCursor struct {
+	Sort ArticleSort
+	Key  string    // the sort key of the last article
+	ID   uuid.UUID // the ID of the last article
+}
+
+type articleCursorJSON struct {
+	By    string    `json:"b"`
+	Order string    `json:"o"`
+	Key   string    `json:"k"`
+	ID    uuid.UUID `json:"i"`
+}
+
+// NewArticleCursor returns the cursor of the page after the article in the sort.
+func NewArticleCursor(sort ArticleSort, last *SavedArticle) *ArticleCursor {
+	return &ArticleCursor{Sort: sort, Key: sort.Key(last), ID: last.ID}
+}
+
+// Encode returns the cursor as an opaque string.
+func (c *ArticleCursor) Encode() string {
+	data, _ := json.Marshal(articleCursorJSON{By: c.Sort.By, Order: c.Sort.Order, Key: c.Key, ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeArticleCursor decodes a cursor returned by Encode.
+func DecodeArticleCursor(s string) (*ArticleCursor, error) {
+	errInvalid := errors.New("invalid cursor")
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalid
+	}
+	var c articleCursorJSON
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == uuid.Nil {
+		return nil, errInvalid
+	}
+	cursor := &ArticleCursor{Sort: ArticleSort{By: c.By, Order: c.Order}, Key: c.Key, ID: c.ID}
+	if c.By == "" || c.Order == "" || cursor.Sort.Validate() != nil || !cursor.Sort.validKey(c.Key) {
+		return nil, errInvalid
+	}
+	return cursor, nil
+}
@@ -0,0 +1,95 @@
+package article
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArticleFilterValidateRanges(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	filter := ArticleFilter{CollectedAfter: now.Add(-time.Hour), CollectedBefore: now, MinRating: 3, MaxRating: 5}
+	assert.NoError(t, filter.Validate())
+
+	for _, filter := range []ArticleFilter{
+		{CollectedAfter: now, CollectedBefore: now},
+		{MinRating: 4, MaxRating: 2},
+		{MaxRating: 6},
+		{Unrated: true, MinRating: 1},
+		{FetchStatus: "done"},
+		{Domain: "example.com/path"},
+	} {
+		assert.Error(t, filter.Validate(), "%+v", filter)
+	}
+
+	filter = ArticleFilter{Domain: " WWW.Example.com ", FetchStatus: "failed"}
+	require.NoError(t, filter.Validate())
+	assert.Equal(t, "example.com", filter.Domain)
+}
+
+func TestRetryStatusNames(t *testing.T) {
+	t.Parallel()
+
+	for _, status := range []RetryStatus{RetryStatusPending, RetryStatusSuccess, RetryStatusFailed} {
+		parsed, err := ParseRetryStatus(status.String())
+		require.NoError(t, err)
+		assert.Equal(t, status, parsed)
+	}
+	_, err := ParseRetryStatus("unknown")
+	assert.Error(t, err)
+}
+
+func TestArticleSortValidate(t *testing.T) {
+	t.Parallel()
+
+	sort := ArticleSort{}
+	require.NoError(t, sort.Validate())
+	assert.Equal(t, ArticleSort{By: SortByCollectedAt, Order: SortOrderDesc}, sort)
+
+	sort = ArticleSort{By: SortByTitle}
+	require.NoError(t, sort.Validate())
+	assert.Equal(t, SortOrderAsc, sort.Order)
+
+	sort = ArticleSort{By: "url"}
+	assert.Error(t, sort.Validate())
+	sort = ArticleSort{By: SortByRating, Order: "up"}
+	assert.Error(t, sort.Validate())
+}
+
+func TestArticleCursor(t *testing.T) {
+	t.Parallel()
+
+	art := &SavedArticle{
+		Article:     Article{ID: uuid.New(), Title: "Go", Domain: "go.dev"},
+		Rate:        4,
+		CollectedAt: time.Date(2024, 5, 1, 8, 30, 0, 123000, time.FixedZone("TW", 8*3600)),
+	}
+	for sort, key := range map[ArticleSort]string{
+		{By: SortByCollectedAt, Order: SortOrderDesc}: "2024-05-01T00:30:00.000123Z",
+		{By: SortByRating, Order: SortOrderAsc}:       "4",
+		{By: SortByTitle, Order: SortOrderAsc}:        "Go",
+		{By: SortByDomain, Order: SortOrderDesc}:      "go.dev",
+	} {
+		cursor := NewArticleCursor(sort, art)
+		assert.Equal(t, key, cursor.Key)
+
+		decoded, err := DecodeArticleCursor(cursor.Encode())
+		require.NoError(t, err)
+		assert.Equal(t, cursor, decoded)
+	}
+
+	for _, s := range []string{
+		"",
+		"not base64!",
+		(&ArticleCursor{Sort: ArticleSort{By: SortByRating, Order: SortOrderAsc}, Key: "high", ID: art.ID}).Encode(),
+		(&ArticleCursor{Sort: ArticleSort{By: SortByTitle, Order: SortOrderAsc}, Key: "Go"}).Encode(),
+	} {
+		_, err := DecodeArticleCursor(s)
+		assert.Error(t, err, s)
+	}
+}
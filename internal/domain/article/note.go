@@ -0,0 +1,88 @@
+package article
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Limits of notes and highlights, in characters
+const (
+	NoteBodyMaxLength         = 20000
+	HighlightTextMaxLength    = 5000
+	HighlightCommentMaxLength = 2000
+
+	// NoteSnippetLength is the number of characters of a note listed with its article
+	NoteSnippetLength = 140
+)
+
+// Note is a private markdown note of a user on one of their saved articles.
+type Note struct {
+	ID        uuid.UUID
+	ArticleID uuid.UUID
+	Body      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// NormalizeNoteBody trims a note body and checks its length.
+func NormalizeNoteBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > NoteBodyMaxLength {
+		return "", fmt.Errorf("note must have 1 to %d characters", NoteBodyMaxLength)
+	}
+	return body, nil
+}
+
+// NoteSnippet returns the start of a note on one line, ending with an ellipsis if the note is longer.
+func NoteSnippet(body string) string {
+	body = strings.Join(strings.Fields(body), " ")
+	if utf8.RuneCountInString(body) <= NoteSnippetLength {
+		return body
+	}
+	runes := []rune(body)
+	return strings.TrimSpace(string(runes[:NoteSnippetLength-1])) + "…"
+}
+
+// HighlightPosition is where the highlighted text is found in the article, as character offsets of its start and end.
+type HighlightPosition struct {
+	Start int
+	End   int
+}
+
+// Highlight is a passage a user quoted from one of their saved articles, with an optional comment.
+type Highlight struct {
+	ID        uuid.UUID
+	ArticleID uuid.UUID
+	Text      string
+	Comment   string
+	Position  *HighlightPosition // nil when the client does not track positions
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Validate checks the text, comment and position of the highlight.
+func (h *Highlight) Validate() error {
+	if strings.TrimSpace(h.Text) == "" || utf8.RuneCountInString(h.Text) > HighlightTextMaxLength {
+		return fmt.Errorf("highlighted text must have 1 to %d characters", HighlightTextMaxLength)
+	}
+	if err := ValidateHighlightComment(h.Comment); err != nil {
+		return err
+	}
+	if h.Position != nil && (h.Position.Start < 0 || h.Position.End <= h.Position.Start) {
+		return errors.New("highlight position must start at 0 or later and end after its start")
+	}
+	return nil
+}
+
+// ValidateHighlightComment checks the length of a highlight comment, which can be empty.
+func ValidateHighlightComment(comment string) error {
+	if utf8.RuneCountInString(comment) > HighlightCommentMaxLength {
+		return fmt.Errorf("highlight comment must have at most %d characters", HighlightCommentMaxLength)
+	}
+	return nil
+}
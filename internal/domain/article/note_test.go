@@ -0,0 +1,54 @@
+package article
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeNoteBody(t *testing.T) {
+	t.Parallel()
+
+	body, err := NormalizeNoteBody("\n# Summary\n\nWorth a read.\n")
+	require.NoError(t, err)
+	assert.Equal(t, "# Summary\n\nWorth a read.", body)
+
+	_, err = NormalizeNoteBody(" \n ")
+	assert.Error(t, err)
+	_, err = NormalizeNoteBody(strings.Repeat("a", NoteBodyMaxLength+1))
+	assert.Error(t, err)
+}
+
+func TestNoteSnippet(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", NoteSnippet(""))
+	assert.Equal(t, "# Summary Worth a read.", NoteSnippet("# Summary\n\n  Worth a read."))
+
+	snippet := NoteSnippet(strings.Repeat("筆記 ", NoteSnippetLength))
+	assert.Equal(t, NoteSnippetLength, utf8.RuneCountInString(snippet))
+	assert.True(t, strings.HasSuffix(snippet, "…"))
+}
+
+func TestHighlightValidate(t *testing.T) {
+	t.Parallel()
+
+	highlight := Highlight{Text: "quoted", Comment: "why it matters"}
+	assert.NoError(t, highlight.Validate())
+
+	highlight.Position = &HighlightPosition{Start: 10, End: 16}
+	assert.NoError(t, highlight.Validate())
+
+	highlight.Position = &HighlightPosition{Start: 16, End: 16}
+	assert.Error(t, highlight.Validate())
+	highlight.Position = &HighlightPosition{Start: -1, End: 5}
+	assert.Error(t, highlight.Validate())
+
+	highlight = Highlight{Text: " "}
+	assert.Error(t, highlight.Validate())
+	highlight = Highlight{Text: "quoted", Comment: strings.Repeat("a", HighlightCommentMaxLength+1)}
+	assert.Error(t, highlight.Validate())
+}
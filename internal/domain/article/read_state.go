@@ -0,0 +1,26 @@
+package article
+
+import (
+	"fmt"
+)
+
+// Read states of a saved article. Saved articles start unread, and users move them on to read and archived,
+// or back. Moving to read stamps when the article was first read, moving to archived also stamps when it was archived.
+// Moving out of archived clears the archive time, and moving back to unread clears both.
+const (
+	ReadStateUnread   = "unread"
+	ReadStateRead     = "read"
+	ReadStateArchived = "archived"
+)
+
+// MaxBulkStateChange is the number of articles whose state can be changed at once
+const MaxBulkStateChange = 100
+
+// ValidateReadState checks that state is one of the read states.
+func ValidateReadState(state string) error {
+	switch state {
+	case ReadStateUnread, ReadStateRead, ReadStateArchived:
+		return nil
+	}
+	return fmt.Errorf("state must be %s, %s or %s", ReadStateUnread, ReadStateRead, ReadStateArchived)
+}
@@ -0,0 +1,27 @@
+package article
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateReadState(t *testing.T) {
+	t.Parallel()
+
+	for _, state := range []string{ReadStateUnread, ReadStateRead, ReadStateArchived} {
+		assert.NoError(t, ValidateReadState(state))
+	}
+	assert.Error(t, ValidateReadState(""))
+	assert.Error(t, ValidateReadState("Read"))
+}
+
+func TestArticleFilterValidateStates(t *testing.T) {
+	t.Parallel()
+
+	filter := ArticleFilter{States: []string{ReadStateUnread, ReadStateArchived}}
+	assert.NoError(t, filter.Validate())
+
+	filter = ArticleFilter{States: []string{"deleted"}}
+	assert.Error(t, filter.Validate())
+}
@@ -0,0 +1,25 @@
+package article
+
+import (
+	"time"
+)
+
+// SavedArticle is an article together with the data of the user who saved it.
+type SavedArticle struct {
+	Article
+	Rate        int16
+	CollectedAt time.Time
+	State       string
+	ReadAt      *time.Time
+	ArchivedAt  *time.Time
+	Tags        []*Tag
+	NoteSnippet string       // the start of the latest note on the article, empty without notes
+	Notes       []*Note      // only set in the personal export
+	Highlights  []*Highlight // only set in the personal export
+}
+
+// ArticleDetail is a saved article with how fetching its metadata went.
+type ArticleDetail struct {
+	SavedArticle
+	Fetch *MetadataFetchRetry // nil when the metadata of the article was never queued for fetching
+}
@@ -0,0 +1,346 @@
+package article
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Saved articles are searched with the full-text search of Postgres. It has no parser for Chinese, so text is split
+// into lexemes here and the tsvector and tsquery are built from them: words of letters and digits are lowercased,
+// and every CJK character is a lexeme of its own, which queries join back into words as phrases.
+
+const (
+	SearchQueryMaxLength = 200 // in characters
+	SearchQueryMaxTerms  = 16
+	SearchSnippetLength  = 160 // in characters
+
+	searchSnippetLead        = 30    // characters kept before the first match of a snippet
+	searchMaxPosition        = 16383 // the largest position of a lexeme in a tsvector
+	searchMaxLexemePositions = 256   // the positions a tsvector keeps for a lexeme
+	searchMaxLexemeLength    = 255   // in bytes, longer words are not searchable
+)
+
+// Modes of searching saved articles
+const (
+	SearchModeKeyword  = "keyword"  // articles containing the words of the query
+	SearchModeSemantic = "semantic" // articles about what the query is about, by the similarity of their embeddings
+	SearchModeHybrid   = "hybrid"   // both, ranked together
+)
+
+// SearchMinSimilarity is how similar the embeddings of an article and a query have to be for a semantic match
+const SearchMinSimilarity = 0.1
+
+// searchFusionK damps the lead of the first places in FuseSearchResults, 60 as proposed with reciprocal rank fusion
+const searchFusionK = 60
+
+// searchMetadataKeys are the Open Graph properties of the article metadata which are searched
+var searchMetadataKeys = []string{"og:site_name", "og:title", "og:description"}
+
+// SearchResult is a saved article found by a search, with how well it matches and the matches highlighted
+type SearchResult struct {
+	SavedArticle
+	Rank           float64
+	TitleHighlight string
+	Snippet        string
+}
+
+// ValidateSearchMode checks that mode is one of the search modes.
+func ValidateSearchMode(mode string) error {
+	switch mode {
+	case SearchModeKeyword, SearchModeSemantic, SearchModeHybrid:
+		return nil
+	}
+	return fmt.Errorf("mode must be %s, %s or %s", SearchModeKeyword, SearchModeSemantic, SearchModeHybrid)
+}
+
+// FuseSearchResults ranks the results of the same search found in different ways, each list best first,
+// by reciprocal rank fusion: a result scores 1/(k+place) for every list it is in. It returns the limit best results
+// with their score as Rank.
+func FuseSearchResults(limit int, lists ...[]*SearchResult) []*SearchResult {
+	var fused []*SearchResult
+	scores := make(map[uuid.UUID]float64)
+	for _, list := range lists {
+		for place, result := range list {
+			if _, ok := scores[result.ID]; !ok {
+				fused = append(fused, result)
+			}
+			scores[result.ID] += 1 / float64(searchFusionK+place+1)
+		}
+	}
+
+	sort.SliceStable(fused, func(i, j int) bool {
+		return scores[fused[i].ID] > scores[fused[j].ID]
+	})
+	if len(fused) > limit {
+		fused = fused[:limit]
+	}
+	for _, result := range fused {
+		result.Rank = scores[result.ID]
+	}
+	return fused
+}
+
+type searchToken struct {
+	text       string
+	start, end int // byte offsets in the text
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// searchTokens splits text into lexemes
+func searchTokens(text string) []searchToken {
+	var tokens []searchToken
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			tokens = append(tokens, searchToken{text: strings.ToLower(text[start:end]), start: start, end: end})
+			start = -1
+		}
+	}
+	for i, r := range text {
+		switch {
+		case isCJK(r):
+			flush(i)
+			tokens = append(tokens, searchToken{text: string(r), start: i, end: i + utf8.RuneLen(r)})
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			if start < 0 {
+				start = i
+			}
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return tokens
+}
+
+// quoteLexeme quotes a lexeme for a tsvector or tsquery literal
+func quoteLexeme(lexeme string) string {
+	lexeme = strings.ReplaceAll(lexeme, `\`, `\\`)
+	return "'" + strings.ReplaceAll(lexeme, "'", "''") + "'"
+}
+
+// metadataSearchText returns the searched properties of the article metadata
+func metadataSearchText(metadata json.RawMessage) []string {
+	var meta struct {
+		Metadata map[string][]string
+	}
+	if len(metadata) == 0 || json.Unmarshal(metadata, &meta) != nil {
+		return nil
+	}
+	var text []string
+	for _, key := range searchMetadataKeys {
+		text = append(text, meta.Metadata[key]...)
+	}
+	return text
+}
+
+// SearchVector returns the tsvector literal the article is searched by.
+// Matches in the title rank above matches in the description, which rank above matches in the metadata.
+func SearchVector(art *Article) string {
+	var lexemes []string
+	positions := make(map[string][]string)
+	pos := 0
+	add := func(text string, weight string) {
+		for _, token := range searchTokens(text) {
+			if pos >= searchMaxPosition {
+				return
+			}
+			if len(token.text) > searchMaxLexemeLength {
+				continue
+			}
+			pos++
+			if _, ok := positions[token.text]; !ok {
+				lexemes = append(lexemes, token.text)
+			}
+			if len(positions[token.text]) < searchMaxLexemePositions {
+				positions[token.text] = append(positions[token.text], fmt.Sprintf("%d%s", pos, weight))
+			}
+		}
+		// Keep phrases from matching across fields
+		pos++
+	}
+	add(art.Title, "A")
+	add(art.Description, "B")
+	for _, text := range metadataSearchText(art.Metadata) {
+		add(text, "C")
+	}
+
+	parts := make([]string, 0, len(lexemes))
+	for _, lexeme := range lexemes {
+		parts = append(parts, quoteLexeme(lexeme)+":"+strings.Join(positions[lexeme], ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// SearchQuery is a parsed search of saved articles. Articles have to contain every word of the query,
+// a word ending in * matches the words starting with it, and words in double quotes have to be next to each other.
+type SearchQuery struct {
+	phrases []searchPhrase
+}
+
+type searchPhrase struct {
+	lexemes []string
+	prefix  bool // the last lexeme matches the lexemes starting with it
+}
+
+// ParseSearchQuery parses the query a user searches their saved articles with.
+func ParseSearchQuery(q string) (*SearchQuery, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return nil, errors.New("search query must not be empty")
+	}
+	if utf8.RuneCountInString(q) > SearchQueryMaxLength {
+		return nil, fmt.Errorf("search query must be at most %d characters", SearchQueryMaxLength)
+	}
+
+	query := &SearchQuery{}
+	for i, part := range strings.Split(q, `"`) {
+		if i%2 == 1 {
+			query.addPhrase(part)
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			query.addPhrase(word)
+		}
+	}
+	if len(query.phrases) == 0 {
+		return nil, errors.New("search query must contain a word")
+	}
+	if len(query.phrases) > SearchQueryMaxTerms {
+		return nil, fmt.Errorf("search query must contain at most %d words or phrases", SearchQueryMaxTerms)
+	}
+	return query, nil
+}
+
+func (q *SearchQuery) addPhrase(text string) {
+	tokens := searchTokens(text)
+	if len(tokens) == 0 {
+		return
+	}
+	phrase := searchPhrase{prefix: strings.HasSuffix(strings.TrimSpace(text), "*")}
+	for _, token := range tokens {
+		phrase.lexemes = append(phrase.lexemes, token.text)
+	}
+	q.phrases = append(q.phrases, phrase)
+}
+
+// TSQuery returns the tsquery literal of the query.
+func (q *SearchQuery) TSQuery() string {
+	terms := make([]string, 0, len(q.phrases))
+	for _, phrase := range q.phrases {
+		lexemes := make([]string, 0, len(phrase.lexemes))
+		for _, lexeme := range phrase.lexemes {
+			lexemes = append(lexemes, quoteLexeme(lexeme))
+		}
+		if phrase.prefix {
+			lexemes[len(lexemes)-1] += ":*"
+		}
+		term := strings.Join(lexemes, " <-> ")
+		if len(lexemes) > 1 {
+			term = "(" + term + ")"
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, " & ")
+}
+
+func (p searchPhrase) matchAt(tokens []searchToken) bool {
+	if len(tokens) < len(p.lexemes) {
+		return false
+	}
+	for i, lexeme := range p.lexemes {
+		if p.prefix && i == len(p.lexemes)-1 {
+			if !strings.HasPrefix(tokens[i].text, lexeme) {
+				return false
+			}
+		} else if tokens[i].text != lexeme {
+			return false
+		}
+	}
+	return true
+}
+
+// matches returns the byte ranges of text which match the query, in order and merged where they touch
+func (q *SearchQuery) matches(text string) [][2]int {
+	tokens := searchTokens(text)
+	var spans [][2]int
+	for i := range tokens {
+		for _, phrase := range q.phrases {
+			if !phrase.matchAt(tokens[i:]) {
+				continue
+			}
+			end := tokens[i+len(phrase.lexemes)-1].end
+			if n := len(spans); n > 0 && spans[n-1][1] >= tokens[i].start {
+				spans[n-1][1] = max(spans[n-1][1], end)
+			} else {
+				spans = append(spans, [2]int{tokens[i].start, end})
+			}
+		}
+	}
+	return spans
+}
+
+// highlight escapes text[start:end] as HTML and wraps the spans in it in <b> and </b>
+func highlight(text string, spans [][2]int, start, end int) string {
+	var b strings.Builder
+	pos := start
+	for _, span := range spans {
+		from, to := max(span[0], start), min(span[1], end)
+		if from >= to {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:from]))
+		b.WriteString("<b>")
+		b.WriteString(html.EscapeString(text[from:to]))
+		b.WriteString("</b>")
+		pos = to
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	return b.String()
+}
+
+// Highlight returns text escaped as HTML, with the matches of the query wrapped in <b> and </b>.
+func (q *SearchQuery) Highlight(text string) string {
+	return highlight(text, q.matches(text), 0, len(text))
+}
+
+// Snippet returns up to SearchSnippetLength characters of text around the first match of the query,
+// highlighted like Highlight. It returns "" when nothing in text matches.
+func (q *SearchQuery) Snippet(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	spans := q.matches(text)
+	if len(spans) == 0 {
+		return ""
+	}
+
+	start := spans[0][0]
+	for i := 0; i < searchSnippetLead && start > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	end := start
+	for i := 0; i < SearchSnippetLength && end < len(text); i++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+
+	snippet := highlight(text, spans, start, end)
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(text) {
+		snippet += "…"
+	}
+	return snippet
+}
@@ -0,0 +1,98 @@
+package article
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchVector(t *testing.T) {
+	t.Parallel()
+
+	art := &Article{
+		Title:       "Go 資料庫",
+		Description: "Using go",
+		Metadata:    []byte(`{"Title":"ignored","Metadata":{"og:site_name":["Blog"],"og:image":["https://example.com/a.png"]}}`),
+	}
+	assert.Equal(t, "'go':1A,7B '資':2A '料':3A '庫':4A 'using':6B 'blog':9C", SearchVector(art))
+	assert.Equal(t, "", SearchVector(&Article{}))
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	t.Parallel()
+
+	query, err := ParseSearchQuery(`Postgres "full text" index* 資料庫`)
+	require.NoError(t, err)
+	assert.Equal(t, "'postgres' & ('full' <-> 'text') & 'index':* & ('資' <-> '料' <-> '庫')", query.TSQuery())
+
+	// Quotes are escaped, a word of punctuation is dropped
+	query, err = ParseSearchQuery(`it's -- ok`)
+	require.NoError(t, err)
+	assert.Equal(t, "('it' <-> 's') & 'ok'", query.TSQuery())
+
+	_, err = ParseSearchQuery("   ")
+	assert.Error(t, err)
+	_, err = ParseSearchQuery(`"" --`)
+	assert.Error(t, err)
+	_, err = ParseSearchQuery(strings.Repeat("a", SearchQueryMaxLength+1))
+	assert.Error(t, err)
+	_, err = ParseSearchQuery(strings.Repeat("a ", SearchQueryMaxTerms+1))
+	assert.Error(t, err)
+}
+
+func TestSearchQueryHighlight(t *testing.T) {
+	t.Parallel()
+
+	query, err := ParseSearchQuery(`data* "go lang" 資料`)
+	require.NoError(t, err)
+
+	assert.Equal(t, "<b>Databases</b> in <b>Go Lang</b> &amp; <b>資料</b>庫", query.Highlight("Databases in Go Lang & 資料庫"))
+	assert.Equal(t, "Go only", query.Highlight("Go only"))
+}
+
+func TestSearchQuerySnippet(t *testing.T) {
+	t.Parallel()
+
+	query, err := ParseSearchQuery("needle")
+	require.NoError(t, err)
+
+	text := strings.Repeat("hay ", 50) + "needle " + strings.Repeat("hay ", 50)
+	snippet := query.Snippet(text)
+	assert.True(t, strings.HasPrefix(snippet, "…"))
+	assert.True(t, strings.HasSuffix(snippet, "…"))
+	assert.Contains(t, snippet, "<b>needle</b>")
+
+	assert.Equal(t, "a <b>needle</b>", query.Snippet("a\n\n needle"))
+	assert.Equal(t, "", query.Snippet("no match"))
+}
+
+func TestFuseSearchResults(t *testing.T) {
+	t.Parallel()
+
+	result := func(id uuid.UUID) *SearchResult {
+		return &SearchResult{SavedArticle: SavedArticle{Article: Article{ID: id}}}
+	}
+	a, b, c, d := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	// b is found both ways, and a leads c by being first
+	fused := FuseSearchResults(3,
+		[]*SearchResult{result(a), result(b)},
+		[]*SearchResult{result(c), result(b), result(d)},
+	)
+	require.Len(t, fused, 3)
+	assert.Equal(t, []uuid.UUID{b, a, c}, []uuid.UUID{fused[0].ID, fused[1].ID, fused[2].ID})
+	assert.InDelta(t, 2.0/62, fused[0].Rank, 1e-9)
+	assert.InDelta(t, 1.0/61, fused[1].Rank, 1e-9)
+
+	assert.Empty(t, FuseSearchResults(3))
+}
+
+func TestValidateSearchMode(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, ValidateSearchMode(SearchModeHybrid))
+	assert.Error(t, ValidateSearchMode("fuzzy"))
+}
@@ -0,0 +1,37 @@
+package article
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// TagNameMaxLength is the number of characters a tag name can have at most
+const TagNameMaxLength = 50
+
+// Tag is a label a user puts on their saved articles. Tag names are unique per user, ignoring case.
+type Tag struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	Name         string
+	ArticleCount int // the number of saved articles carrying the tag, only set when listing tags
+	CreatedAt    time.Time
+}
+
+// NormalizeTagName trims a tag name and checks its length.
+func NormalizeTagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > TagNameMaxLength {
+		return "", fmt.Errorf("tag name must have 1 to %d characters", TagNameMaxLength)
+	}
+	return name, nil
+}
+
+// How the tags of an ArticleFilter are matched
+const (
+	TagMatchAll = "all" // articles carrying every tag
+	TagMatchAny = "any" // articles carrying at least one of the tags
+)
@@ -0,0 +1,43 @@
+package article
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTagName(t *testing.T) {
+	t.Parallel()
+
+	name, err := NormalizeTagName("  Go  ")
+	require.NoError(t, err)
+	assert.Equal(t, "Go", name)
+
+	// Lengths count characters, not bytes
+	_, err = NormalizeTagName(strings.Repeat("讀", TagNameMaxLength))
+	assert.NoError(t, err)
+	_, err = NormalizeTagName(strings.Repeat("a", TagNameMaxLength+1))
+	assert.Error(t, err)
+	_, err = NormalizeTagName("   ")
+	assert.Error(t, err)
+}
+
+func TestArticleFilterValidate(t *testing.T) {
+	t.Parallel()
+
+	filter := ArticleFilter{Tags: []string{" Go ", "DB"}}
+	require.NoError(t, filter.Validate())
+	assert.Equal(t, TagMatchAll, filter.TagMatch)
+	assert.Equal(t, []string{"go", "db"}, filter.Tags)
+
+	filter = ArticleFilter{TagMatch: TagMatchAny}
+	assert.NoError(t, filter.Validate())
+
+	filter = ArticleFilter{TagMatch: "some"}
+	assert.Error(t, filter.Validate())
+
+	filter = ArticleFilter{Tags: []string{""}}
+	assert.Error(t, filter.Validate())
+}
@@ -15,6 +15,9 @@ type UserArticle struct {
 	ArticleID   uuid.UUID
 	Rate        int16
 	CollectedAt time.Time
+	State       string
+	ReadAt      *time.Time // when the article was first read, nil while it is unread
+	ArchivedAt  *time.Time // nil unless the article is archived
 }
 
 // Rate sets the rating for the article, ensuring it's within the valid range.
@@ -0,0 +1,28 @@
+package article
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkspaceArticle is an article saved to a workspace, with the ratings of its members.
+type WorkspaceArticle struct {
+	Article
+	WorkspaceID   uuid.UUID
+	AddedBy       uuid.UUID // uuid.Nil once the member who added it deleted their account
+	CollectedAt   time.Time
+	AverageRating float64 // 0 when no member rated the article
+	RatingCount   int
+	Rate          int16 // the rating of the member the article was listed for, 0 when not rated
+}
+
+// Rating sets the rating of the member for the article, ensuring it's within the valid range.
+func (wa *WorkspaceArticle) Rating(rate int16) error {
+	if rate < 1 || rate > 5 {
+		return fmt.Errorf("rate must be between 1 and 5, but got %d", rate)
+	}
+	wa.Rate = rate
+	return nil
+}
@@ -0,0 +1,25 @@
+package audit
+
+import (
+	"context"
+)
+
+// Request describes the client request an action is taken in.
+type Request struct {
+	ID        string
+	IP        string
+	UserAgent string
+}
+
+type requestContextKey struct{}
+
+// WithRequest returns a copy of ctx carrying the request, which events recorded with it are attributed to.
+func WithRequest(ctx context.Context, r Request) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, r)
+}
+
+// RequestFromContext returns the request ctx carries, or a zero Request for work outside of requests.
+func RequestFromContext(ctx context.Context) Request {
+	r, _ := ctx.Value(requestContextKey{}).(Request)
+	return r
+}
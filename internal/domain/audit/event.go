@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionSignUp           = "user.signup"
+	ActionLogin            = "user.login"
+	ActionPasswordReset    = "user.password_reset"
+	ActionPasswordChange   = "user.password_change"
+	ActionAccountDelete    = "user.delete"
+	ActionAccountExport    = "user.export"
+	ActionTwoFactorEnable  = "user.2fa_enable"
+	ActionTwoFactorDisable = "user.2fa_disable"
+	ActionTokenValidate    = "token.validate"
+	ActionTokenRefresh     = "token.refresh"
+	ActionAPIKeyValidate   = "api_key.validate"
+	ActionAPIKeyDelete     = "api_key.delete"
+	ActionUserRoleChange   = "admin.user_role"
+	ActionUserDisable      = "admin.user_disable"
+	ActionUserEnable       = "admin.user_enable"
+	ActionUserUnlock       = "admin.user_unlock"
+	ActionArticleDelete    = "article.delete"
+	ActionArticleStates    = "article.bulk_state"
+	ActionArticleImport    = "article.import"
+	ActionArticleExport    = "article.export"
+)
+
+// Outcomes of an action
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Kinds of targets an action is taken on
+const (
+	TargetUser    = "user"
+	TargetEmail   = "email" // an account which is addressed by an email that may not be registered
+	TargetAPIKey  = "api_key"
+	TargetArticle = "article"
+	TargetImport  = "import_job"
+)
+
+// Event is an entry of the audit log. Events are only ever appended, never changed.
+type Event struct {
+	ID         int64
+	ActorID    uuid.UUID // uuid.Nil when nobody was authenticated, like for a failed login
+	Action     string
+	TargetType string // empty when the action has no target
+	TargetID   string
+	IP         string
+	UserAgent  string
+	RequestID  string
+	Outcome    string
+	Reason     string // why the action failed, empty on success
+	Count      int    // how many times the action happened alike, more than 1 only for aggregated events
+	CreatedAt  time.Time
+}
+
+// NewEvent returns an event of the action, which succeeded if err is nil and failed with err otherwise.
+func NewEvent(action string, actorID uuid.UUID, targetType string, targetID string, err error) *Event {
+	event := &Event{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Outcome:    OutcomeSuccess,
+		Count:      1,
+	}
+	if err != nil {
+		event.Outcome = OutcomeFailure
+		event.Reason = reasonOf(err)
+	}
+	return event
+}
+
+// IsSuccess reports whether the action succeeded.
+func (e *Event) IsSuccess() bool {
+	return e.Outcome == OutcomeSuccess
+}
+
+// reasonOf describes a failure by its error code and the message clients got, never the internal error
+func reasonOf(err error) string {
+	var domainError common.DomainError
+	if !errors.As(err, &domainError) {
+		return common.ErrorCodeInternalProcess.Name
+	}
+	if msg := domainError.ClientMsg(); msg != "" {
+		return domainError.Name() + ": " + msg
+	}
+	return domainError.Name()
+}
+
+// Filter selects events of the audit log. Zero fields match every event.
+type Filter struct {
+	// UserID matches events the user took, and events taken on the user
+	UserID uuid.UUID
+	Action string
+	Since  time.Time
+	Until  time.Time
+}
@@ -0,0 +1,31 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+func TestNewEvent(t *testing.T) {
+	t.Parallel()
+	actorID := uuid.New()
+
+	succeeded := NewEvent(ActionLogin, actorID, TargetUser, actorID.String(), nil)
+	assert.True(t, succeeded.IsSuccess())
+	assert.Empty(t, succeeded.Reason)
+
+	var cerr common.Error
+	assert.True(t, NewEvent(ActionLogin, actorID, TargetUser, actorID.String(), cerr).IsSuccess(), "a nil common.Error is no failure")
+
+	cerr = common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New("hash mismatch"), common.WithMsg("invalid password"))
+	failed := NewEvent(ActionLogin, uuid.Nil, TargetEmail, "amy@example.com", cerr)
+	assert.Equal(t, OutcomeFailure, failed.Outcome)
+	assert.Equal(t, "AUTH_NOT_AUTHENTICATED: invalid password", failed.Reason, "internal errors stay out of the log")
+
+	failed = NewEvent(ActionTokenValidate, uuid.Nil, "", "", errors.New("boom"))
+	assert.Equal(t, common.ErrorCodeInternalProcess.Name, failed.Reason)
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -74,6 +75,12 @@ func (e DomainError) Detail() map[string]interface{} {
 	return e.detail
 }
 
+// IsErrorCode reports whether err is a DomainError of the given code
+func IsErrorCode(err error, code ErrorCode) bool {
+	var domainError DomainError
+	return errors.As(err, &domainError) && domainError.code.Name == code.Name
+}
+
 type ErrorOption func(*DomainError)
 
 func WithMsg(msg string) ErrorOption {
@@ -30,6 +30,11 @@ var ErrorCodeAuthNotAuthenticated = ErrorCode{
 	StatusCode: http.StatusUnauthorized,
 }
 
+var ErrorCodeAuthTooManyAttempts = ErrorCode{
+	Name:       "AUTH_TOO_MANY_ATTEMPTS",
+	StatusCode: http.StatusTooManyRequests,
+}
+
 /*
 	Resource-related error codes
 */
@@ -39,6 +44,11 @@ var ErrorCodeResourceNotFound = ErrorCode{
 	StatusCode: http.StatusNotFound,
 }
 
+var ErrorCodeResourceConflict = ErrorCode{
+	Name:       "RESOURCE_CONFLICT",
+	StatusCode: http.StatusConflict,
+}
+
 /*
 	Parameter-related error codes
 */
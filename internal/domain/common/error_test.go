@@ -100,6 +100,12 @@ func TestDomainError_ErrorMapping(t *testing.T) {
 			ExpectErrorName:  ErrorCodeAuthNotAuthenticated.Name,
 			ExpectHTTPStatus: http.StatusUnauthorized,
 		},
+		{
+			Name:             "too many attempts",
+			TestError:        NewError(ErrorCodeAuthTooManyAttempts, nil),
+			ExpectErrorName:  ErrorCodeAuthTooManyAttempts.Name,
+			ExpectHTTPStatus: http.StatusTooManyRequests,
+		},
 		{
 			Name:             "invalid parameter",
 			TestError:        NewError(ErrorCodeParameterInvalid, nil),
@@ -112,6 +118,12 @@ func TestDomainError_ErrorMapping(t *testing.T) {
 			ExpectErrorName:  ErrorCodeResourceNotFound.Name,
 			ExpectHTTPStatus: http.StatusNotFound,
 		},
+		{
+			Name:             "resource conflict",
+			TestError:        NewError(ErrorCodeResourceConflict, nil),
+			ExpectErrorName:  ErrorCodeResourceConflict.Name,
+			ExpectHTTPStatus: http.StatusConflict,
+		},
 		{
 			Name:                   "remote process",
 			TestError:              NewError(ErrorCodeRemoteProcess, nil, WithStatus(http.StatusBadRequest)),
@@ -143,3 +155,12 @@ func TestDomainError_ErrorMapping(t *testing.T) {
 		})
 	}
 }
+
+func TestIsErrorCode(t *testing.T) {
+	t.Parallel()
+
+	err := NewError(ErrorCodeResourceNotFound, errors.New("not found"))
+	assert.True(t, IsErrorCode(err, ErrorCodeResourceNotFound))
+	assert.False(t, IsErrorCode(err, ErrorCodeResourceConflict))
+	assert.False(t, IsErrorCode(errors.New("plain error"), ErrorCodeResourceNotFound))
+}
@@ -0,0 +1,49 @@
+package user
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every API key, which tells them apart from JWT access tokens
+const APIKeyPrefix = "dlk_"
+
+// Scopes an API key can be granted
+const (
+	ScopeArticlesRead  = "articles:read"
+	ScopeArticlesWrite = "articles:write"
+)
+
+// Scopes lists every scope an API key can be granted.
+var Scopes = []string{ScopeArticlesRead, ScopeArticlesWrite}
+
+// IsValidScope reports whether scope is one of Scopes.
+func IsValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// IsAPIKey reports whether a bearer token is an API key rather than an access token.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// APIKey is a long-lived credential a user creates for scripts and integrations.
+// Only the hash of the key is stored, the key itself is shown once when it is created.
+type APIKey struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []string
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// HasScope reports whether the key was granted scope.
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
@@ -0,0 +1,29 @@
+package user
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailVerificationToken is the server-side record of an email confirmation link.
+// It remembers the address it was sent to, so that it cannot confirm a different one.
+type EmailVerificationToken struct {
+	ID        int64
+	UserID    uuid.UUID
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// IsUsed reports whether the token has already been consumed.
+func (t *EmailVerificationToken) IsUsed() bool {
+	return t.UsedAt != nil
+}
+
+// IsExpired reports whether the token is expired at the given time.
+func (t *EmailVerificationToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
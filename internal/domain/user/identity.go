@@ -0,0 +1,46 @@
+package user
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Identity links an account of an external identity provider to a user.
+// The pair of Provider and Subject identifies the external account.
+type Identity struct {
+	ID        int64
+	UserID    uuid.UUID
+	Provider  string
+	Subject   string
+	Email     string // the email the provider reported when the identity was linked
+	CreatedAt time.Time
+}
+
+// ExternalIdentity is what an identity provider asserts about the user who signed in.
+type ExternalIdentity struct {
+	Provider          string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// OIDCState is the server-side record of a sign in that was sent to an identity provider.
+// Only the hash of the state parameter is stored, and a state can be used once.
+type OIDCState struct {
+	ID           int64
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string // the PKCE verifier, only ever sent to the token endpoint
+	ExpiresAt    time.Time
+	UsedAt       *time.Time
+	CreatedAt    time.Time
+}
+
+// IsExpired reports whether the state is expired at the given time.
+func (s *OIDCState) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
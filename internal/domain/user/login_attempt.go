@@ -0,0 +1,18 @@
+package user
+
+import (
+	"time"
+)
+
+// LoginAttempt counts the recent failed logins of a key, which identifies an account or a client IP.
+type LoginAttempt struct {
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+// IsLocked reports whether logins for the key are refused at the given time.
+func (a *LoginAttempt) IsLocked(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
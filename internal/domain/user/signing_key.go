@@ -0,0 +1,96 @@
+package user
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Supported token signing algorithms, named after their JWS `alg` values
+const (
+	SigningAlgorithmRS256 = "RS256"
+	SigningAlgorithmEdDSA = "EdDSA"
+)
+
+const rsaKeyBits = 2048
+
+// SigningKey is an asymmetric key pair used to sign access tokens.
+// A key is published from CreatedAt, signs new tokens from ActivatesAt until it is
+// rotated out, and stays available for verification until ExpiresAt so that tokens
+// signed by it keep working.
+type SigningKey struct {
+	ID          string // the JWS `kid`
+	Algorithm   string
+	PrivateKey  crypto.Signer
+	CreatedAt   time.Time
+	ActivatesAt time.Time
+	ExpiresAt   time.Time
+}
+
+// NewSigningKey generates a new key pair for the given algorithm.
+func NewSigningKey(algorithm string, createdAt time.Time, activatesAt time.Time, expiresAt time.Time) (*SigningKey, error) {
+	var privateKey crypto.Signer
+	switch algorithm {
+	case SigningAlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		privateKey = key
+	case SigningAlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		privateKey = key
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	return &SigningKey{
+		ID:          uuid.NewString(),
+		Algorithm:   algorithm,
+		PrivateKey:  privateKey,
+		CreatedAt:   createdAt,
+		ActivatesAt: activatesAt,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// PublicKey returns the public half of the key pair.
+func (k *SigningKey) PublicKey() crypto.PublicKey {
+	return k.PrivateKey.Public()
+}
+
+// IsActive reports whether the key may sign new tokens at the given time.
+func (k *SigningKey) IsActive(now time.Time) bool {
+	return !now.Before(k.ActivatesAt)
+}
+
+// IsExpired reports whether the key can no longer verify tokens at the given time.
+func (k *SigningKey) IsExpired(now time.Time) bool {
+	return !now.Before(k.ExpiresAt)
+}
+
+// PublicKey is the publishable half of a SigningKey.
+type PublicKey struct {
+	ID        string
+	Algorithm string
+	Key       crypto.PublicKey
+	ExpiresAt time.Time
+}
+
+// Public returns the publishable half of the key.
+func (k *SigningKey) Public() PublicKey {
+	return PublicKey{
+		ID:        k.ID,
+		Algorithm: k.Algorithm,
+		Key:       k.PublicKey(),
+		ExpiresAt: k.ExpiresAt,
+	}
+}
@@ -0,0 +1,69 @@
+package user
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Token is the credential pair handed to clients after a successful authentication.
+// The access token is a short-lived JWT, while the refresh token is an opaque value
+// that can be exchanged for a new pair.
+type Token struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// TokenFamily groups every refresh token rotated from the same login.
+// Revoking a family invalidates all access and refresh tokens issued under it.
+// Users see their token families as sessions, one for each login on a device.
+type TokenFamily struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	RevokedAt  *time.Time
+}
+
+// IsRevoked reports whether the family has been revoked.
+func (f *TokenFamily) IsRevoked() bool {
+	return f.RevokedAt != nil
+}
+
+// Device is the client a session was logged in or last refreshed from.
+type Device struct {
+	UserAgent string
+	IP        string
+}
+
+// RefreshToken is the server-side record of an issued refresh token.
+// Only the hash of the token is stored.
+type RefreshToken struct {
+	ID        int64
+	FamilyID  uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	RotatedAt *time.Time
+	CreatedAt time.Time
+}
+
+// IsRotated reports whether the refresh token has already been exchanged.
+func (t *RefreshToken) IsRotated() bool {
+	return t.RotatedAt != nil
+}
+
+// IsExpired reports whether the refresh token is expired at the given time.
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// TokenClaims is what a valid access token tells about its bearer.
+type TokenClaims struct {
+	UserID   uuid.UUID
+	Role     string
+	FamilyID uuid.UUID
+}
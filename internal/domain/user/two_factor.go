@@ -0,0 +1,184 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // TOTP authenticator apps expect HMAC-SHA1 (RFC 6238)
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// TOTPDigits and TOTPPeriod are the defaults every authenticator app supports
+	TOTPDigits = 6
+	TOTPPeriod = 30 * time.Second
+
+	totpSecretBytes = 20
+	// totpSkewSteps is how many periods a code may be off, to tolerate clock drift
+	totpSkewSteps = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTP is the time-based one-time password secret of a user.
+// Two-factor authentication is only enabled once the user confirmed the secret with a code.
+type TOTP struct {
+	UserID      uuid.UUID
+	Secret      string // base32 encoded
+	ConfirmedAt *time.Time
+	// LastUsedStep is the time step of the last accepted code, so that a code cannot be replayed
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// NewTOTP creates an unconfirmed TOTP with a random secret.
+func NewTOTP(userID uuid.UUID) (*TOTP, error) {
+	b := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return &TOTP{
+		UserID: userID,
+		Secret: totpEncoding.EncodeToString(b),
+	}, nil
+}
+
+// IsConfirmed reports whether two-factor authentication is enabled with this secret.
+func (t *TOTP) IsConfirmed() bool {
+	return t.ConfirmedAt != nil
+}
+
+// URI returns the otpauth URI authenticator apps enroll the secret with, usually through a QR code.
+func (t *TOTP) URI(issuer string, accountName string) string {
+	query := url.Values{}
+	query.Set("secret", t.Secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod.Seconds())))
+
+	label := url.PathEscape(issuer + ":" + accountName)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Verify checks a code against the steps around now and returns the step it matched.
+// Codes of steps that are not newer than LastUsedStep are rejected.
+func (t *TOTP) Verify(code string, now time.Time) (int64, bool) {
+	current := TOTPStep(now)
+	for step := current - totpSkewSteps; step <= current+totpSkewSteps; step++ {
+		if step <= t.LastUsedStep {
+			continue
+		}
+		expected, err := GenerateTOTPCode(t.Secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPStep returns the time step a moment falls in.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod.Seconds())
+}
+
+// GenerateTOTPCode returns the code of a base32 secret for a time step, as specified by RFC 6238.
+func GenerateTOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%modulo), nil
+}
+
+// IsTOTPCode reports whether a code has the shape of a TOTP code rather than of a recovery code.
+func IsTOTPCode(code string) bool {
+	if len(code) != TOTPDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// RecoveryCode is a single-use code that replaces a TOTP code when the authenticator is lost.
+// Only the hash of the code is stored.
+type RecoveryCode struct {
+	ID        int64
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// IsUsed reports whether the code has already been consumed.
+func (c *RecoveryCode) IsUsed() bool {
+	return c.UsedAt != nil
+}
+
+// LoginChallenge is the server-side record of a login that passed the password check
+// and still waits for a second factor. Only the hash of the challenge token is stored.
+type LoginChallenge struct {
+	ID        int64
+	UserID    uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// IsUsed reports whether the challenge has already been passed.
+func (c *LoginChallenge) IsUsed() bool {
+	return c.UsedAt != nil
+}
+
+// IsExpired reports whether the challenge is expired at the given time.
+func (c *LoginChallenge) IsExpired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
+
+// TwoFactorEnrollment is what a user needs to add a TOTP secret to an authenticator app.
+type TwoFactorEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// TwoFactorChallenge is returned instead of a token by a login of a user with two-factor authentication.
+type TwoFactorChallenge struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// LoginResult is the outcome of a password login. Exactly one of Token and Challenge is set.
+type LoginResult struct {
+	User      *User
+	Token     *Token
+	Challenge *TwoFactorChallenge
+}
@@ -0,0 +1,63 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret is the base32 form of the SHA1 seed "12345678901234567890" of the RFC 6238 test vectors
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTPCode(t *testing.T) {
+	t.Parallel()
+	// The RFC lists 8 digit codes, we keep their last 6 digits
+	for unix, expected := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		code, err := GenerateTOTPCode(rfc6238Secret, TOTPStep(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, expected, code, unix)
+	}
+}
+
+func TestTOTP_Verify(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1111111109, 0)
+	totp := &TOTP{UserID: uuid.New(), Secret: rfc6238Secret}
+
+	step, ok := totp.Verify("081804", now)
+	require.True(t, ok)
+	assert.Equal(t, TOTPStep(now), step)
+
+	// The previous period is accepted for clock drift, older ones are not
+	previous, err := GenerateTOTPCode(rfc6238Secret, step-1)
+	require.NoError(t, err)
+	_, ok = totp.Verify(previous, now)
+	assert.True(t, ok)
+	stale, err := GenerateTOTPCode(rfc6238Secret, step-2)
+	require.NoError(t, err)
+	_, ok = totp.Verify(stale, now)
+	assert.False(t, ok)
+
+	// A code cannot be replayed
+	totp.LastUsedStep = step
+	_, ok = totp.Verify("081804", now)
+	assert.False(t, ok)
+}
+
+func TestTOTP_URI(t *testing.T) {
+	t.Parallel()
+	totp := &TOTP{Secret: rfc6238Secret}
+
+	assert.Equal(t,
+		"otpauth://totp/DeeliAi:alice@example.com?algorithm=SHA1&digits=6&issuer=DeeliAi&period=30&secret="+rfc6238Secret,
+		totp.URI("DeeliAi", "alice@example.com"),
+	)
+}
@@ -1,12 +1,48 @@
 package user
 
 import (
+	"slices"
+	"time"
+
 	"github.com/google/uuid"
 )
 
+// Roles a user can have
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Roles lists every role a user can have.
+var Roles = []string{RoleUser, RoleAdmin}
+
+// IsValidRole reports whether role is one of Roles.
+func IsValidRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
+
 type User struct {
-	ID           uuid.UUID
-	Email        string
-	Username     string
-	PasswordHash string
+	ID              uuid.UUID
+	Email           string
+	Username        string
+	PasswordHash    string
+	EmailVerifiedAt *time.Time
+	Role            string
+	DisabledAt      *time.Time
+}
+
+// IsEmailVerified reports whether the user confirmed owning Email.
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// HasPassword reports whether the user can log in with a password, accounts created through an
+// identity provider have none until one is set.
+func (u *User) HasPassword() bool {
+	return u.PasswordHash != ""
+}
+
+// IsDisabled reports whether an admin disabled the account.
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
 }
@@ -0,0 +1,39 @@
+package workspace
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Invitation lets someone join a workspace with a role.
+// An invitation with an email is mailed and can only be accepted by the account with that verified email,
+// one without an email can be accepted by whoever the token is shared with.
+// Only the hash of the token is stored, and an invitation can be accepted once.
+type Invitation struct {
+	ID          int64
+	WorkspaceID uuid.UUID
+	Email       string
+	Role        string
+	TokenHash   string
+	InvitedBy   uuid.UUID
+	ExpiresAt   time.Time
+	AcceptedAt  *time.Time
+	CreatedAt   time.Time
+}
+
+// IsExpired reports whether the invitation is expired at the given time.
+func (i *Invitation) IsExpired(now time.Time) bool {
+	return !now.Before(i.ExpiresAt)
+}
+
+// IsAccepted reports whether the invitation was already accepted.
+func (i *Invitation) IsAccepted() bool {
+	return i.AcceptedAt != nil
+}
+
+// IsFor reports whether a user with the email may accept the invitation.
+func (i *Invitation) IsFor(email string) bool {
+	return i.Email == "" || strings.EqualFold(i.Email, email)
+}
@@ -0,0 +1,58 @@
+package workspace
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Roles a workspace member can have, from the most to the least privileged
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Roles lists every role a workspace member can have.
+var Roles = []string{RoleOwner, RoleEditor, RoleViewer}
+
+// IsValidRole reports whether role is one of Roles.
+func IsValidRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
+
+// RoleAllows reports whether a member with role may do what needs the required role.
+// Owners can do everything editors can, and editors everything viewers can.
+func RoleAllows(role string, required string) bool {
+	granted, needed := slices.Index(Roles, role), slices.Index(Roles, required)
+	return granted >= 0 && needed >= 0 && granted <= needed
+}
+
+// Workspace is shared by its members, who curate its articles together.
+type Workspace struct {
+	ID        uuid.UUID
+	Name      string
+	CreatedAt time.Time
+}
+
+// Membership is a workspace together with the role the user it was listed for has in it.
+type Membership struct {
+	Workspace
+	Role string
+}
+
+// Member is a user belonging to a workspace.
+type Member struct {
+	WorkspaceID uuid.UUID
+	UserID      uuid.UUID
+	Username    string
+	Email       string
+	Role        string
+	JoinedAt    time.Time
+}
+
+// HasRole reports whether the member may do what needs the required role.
+func (m *Member) HasRole(required string) bool {
+	return RoleAllows(m.Role, required)
+}
@@ -0,0 +1,34 @@
+package workspace
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleAllows(t *testing.T) {
+	t.Parallel()
+	assert.True(t, RoleAllows(RoleOwner, RoleEditor))
+	assert.True(t, RoleAllows(RoleEditor, RoleEditor))
+	assert.True(t, RoleAllows(RoleEditor, RoleViewer))
+	assert.False(t, RoleAllows(RoleViewer, RoleEditor))
+	assert.False(t, RoleAllows(RoleEditor, RoleOwner))
+	assert.False(t, RoleAllows("", RoleViewer))
+	assert.False(t, RoleAllows(RoleOwner, "admin"))
+}
+
+func TestInvitation(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	invitation := Invitation{Email: "Alice@Example.com", ExpiresAt: now.Add(time.Hour)}
+
+	assert.False(t, invitation.IsExpired(now))
+	assert.True(t, invitation.IsExpired(now.Add(time.Hour)))
+	assert.True(t, invitation.IsFor("alice@example.com"))
+	assert.False(t, invitation.IsFor("bob@example.com"))
+
+	// Invitations without an email are for whoever holds the token
+	invitation.Email = ""
+	assert.True(t, invitation.IsFor("bob@example.com"))
+}
@@ -5,6 +5,7 @@ import (
 
 	// Import postgres
 	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
 	// Import user service
 )
 
@@ -16,6 +17,9 @@ func registerAPIHandlers(router *gin.Engine, app *app.Application) {
 	// Build middlewares
 	BearerToken := NewAuthMiddlewareBearer(app)
 
+	// Publish token verification keys at the well-known location
+	router.GET("/.well-known/jwks.json", GetJSONWebKeySet(app))
+
 	// We mount all handlers under /api path
 	r := router.Group("/api")
 	v1 := r.Group("/v1")
@@ -28,18 +32,146 @@ func registerAPIHandlers(router *gin.Engine, app *app.Application) {
 	{
 		userGroup.POST("/signup", SignUp(app))
 		userGroup.POST("/login", Login(app))
+		userGroup.POST("/login/2fa", CompleteTwoFactorLogin(app))
+		userGroup.GET("/oidc/:provider/login", StartOIDCLogin(app))
+		userGroup.GET("/oidc/:provider/callback", CompleteOIDCLogin(app))
+		userGroup.POST("/token/refresh", RefreshToken(app))
+		userGroup.POST("/logout", Logout(app))
+		userGroup.POST("/password/forgot", ForgotPassword(app))
+		userGroup.POST("/password/reset", ResetPassword(app))
+		userGroup.GET("/verify", VerifyEmail(app))
+		userGroup.POST("/verify/resend", BearerToken.Required(), ResendVerificationEmail(app))
 		userGroup.GET("/me", BearerToken.Required(), GetCurrentUser(app))
+		userGroup.PATCH("/me", BearerToken.Required(), UpdateCurrentUser(app))
+		userGroup.PUT("/me/password", BearerToken.Required(), ChangePassword(app))
+		userGroup.DELETE("/me", BearerToken.Required(), DeleteCurrentUser(app))
+		userGroup.GET("/me/export", BearerToken.Required(), ExportCurrentUser(app))
+	}
+
+	// Add API key management, only a full login can manage API keys
+	apiKeyGroup := userGroup.Group("/api-keys", BearerToken.Required())
+	{
+		apiKeyGroup.POST("", CreateAPIKey(app))
+		apiKeyGroup.GET("", ListAPIKeys(app))
+		apiKeyGroup.DELETE("/:api_key_id", DeleteAPIKey(app))
 	}
 
-	// Add articles namespace
-	articleGroup := v1.Group("/articles", BearerToken.Required()) // Use BearerToken.Required()
+	// Add session management, sessions only exist for a full login
+	sessionGroup := userGroup.Group("/sessions", BearerToken.Required())
+	{
+		sessionGroup.GET("", ListSessions(app))
+		sessionGroup.DELETE("", RevokeOtherSessions(app))
+		sessionGroup.DELETE("/:session_id", RevokeSession(app))
+	}
+
+	// Add two-factor authentication management
+	twoFactorGroup := userGroup.Group("/2fa", BearerToken.Required())
+	{
+		twoFactorGroup.POST("/enroll", EnrollTwoFactor(app))
+		twoFactorGroup.POST("/confirm", ConfirmTwoFactor(app))
+		twoFactorGroup.DELETE("", DisableTwoFactor(app))
+	}
+
+	// Add admin namespace
+	adminGroup := v1.Group("/admin", BearerToken.Required(), BearerToken.RequireRole(user.RoleAdmin))
+	{
+		adminGroup.GET("/users", ListUsers(app))
+		adminGroup.GET("/users/:user_id", GetUser(app))
+		adminGroup.PUT("/users/:user_id/role", SetUserRole(app))
+		adminGroup.POST("/users/:user_id/disable", DisableUser(app))
+		adminGroup.POST("/users/:user_id/enable", EnableUser(app))
+		adminGroup.POST("/users/:user_id/unlock", UnlockUser(app))
+		adminGroup.GET("/users/:user_id/articles", ListUserArticles(app))
+		adminGroup.GET("/audit-events", ListAuditEvents(app))
+	}
+
+	// Add workspace namespace, only a full login can manage workspaces
+	workspaceGroup := v1.Group("/workspaces", BearerToken.Required())
+	{
+		workspaceGroup.POST("", CreateWorkspace(app))
+		workspaceGroup.GET("", ListWorkspaces(app))
+		workspaceGroup.POST("/invitations/accept", AcceptWorkspaceInvitation(app))
+		workspaceGroup.GET("/:workspace_id", GetWorkspace(app))
+		workspaceGroup.PATCH("/:workspace_id", RenameWorkspace(app))
+		workspaceGroup.DELETE("/:workspace_id", DeleteWorkspace(app))
+		workspaceGroup.GET("/:workspace_id/members", ListWorkspaceMembers(app))
+		workspaceGroup.PUT("/:workspace_id/members/:user_id", SetWorkspaceMemberRole(app))
+		workspaceGroup.DELETE("/:workspace_id/members/:user_id", RemoveWorkspaceMember(app))
+		workspaceGroup.POST("/:workspace_id/invitations", CreateWorkspaceInvitation(app))
+		workspaceGroup.GET("/:workspace_id/invitations", ListWorkspaceInvitations(app))
+		workspaceGroup.DELETE("/:workspace_id/invitations/:invitation_id", RevokeWorkspaceInvitation(app))
+	}
+
+	// Add workspace articles, API keys need the same scopes as for the articles of their user
+	workspaceArticleGroup := v1.Group("/workspaces/:workspace_id/articles")
+	workspaceArticleGroup.GET("", BearerToken.Scoped(user.ScopeArticlesRead), ListWorkspaceArticles(app))
+	workspaceArticleWriteGroup := workspaceArticleGroup.Group("", BearerToken.Scoped(user.ScopeArticlesWrite), BearerToken.VerifiedEmail())
+	{
+		workspaceArticleWriteGroup.POST("", CreateWorkspaceArticle(app))
+		workspaceArticleWriteGroup.PUT("/:article_id/rate", RateWorkspaceArticle(app))
+	}
+
+	// Add articles namespace, API keys need the articles:read or articles:write scope
+	articleGroup := v1.Group("/articles")
+	articleReadGroup := articleGroup.Group("", BearerToken.Scoped(user.ScopeArticlesRead))
+	{
+		articleReadGroup.GET("", ListArticles(app))
+		articleReadGroup.GET("/search", SearchArticles(app))
+		articleReadGroup.GET("/export", ExportArticles(app))
+		articleReadGroup.GET("/:article_id", GetArticle(app))
+		articleReadGroup.GET("/imports/:import_id", GetImportJob(app))
+		articleReadGroup.GET("/:article_id/rate", GetArticleRating(app))
+		articleReadGroup.GET("/:article_id/notes", ListNotes(app))
+		articleReadGroup.GET("/:article_id/highlights", ListHighlights(app))
+		articleReadGroup.GET("/recommendations", GetRecommendations(app))
+		articleReadGroup.GET("/counts", CountArticles(app))
+	}
+	articleWriteGroup := articleGroup.Group("", BearerToken.Scoped(user.ScopeArticlesWrite), BearerToken.VerifiedEmail())
+	{
+		articleWriteGroup.POST("", CreateArticle(app))
+		articleWriteGroup.DELETE("/:article_id", DeleteArticle(app))
+		articleWriteGroup.POST("/state", SetArticlesState(app))
+		articleWriteGroup.POST("/import", ImportArticles(app))
+		articleWriteGroup.PUT("/:article_id/state", SetArticleState(app))
+		articleWriteGroup.GET("/:article_id/open", OpenArticle(app))
+		articleWriteGroup.PUT("/:article_id/rate", RateArticle(app))
+		articleWriteGroup.DELETE("/:article_id/rate", DeleteArticleRating(app))
+		articleWriteGroup.POST("/:article_id/tags", AddArticleTags(app))
+		articleWriteGroup.DELETE("/:article_id/tags/:tag_id", RemoveArticleTag(app))
+		articleWriteGroup.POST("/:article_id/notes", CreateNote(app))
+		articleWriteGroup.PATCH("/:article_id/notes/:note_id", UpdateNote(app))
+		articleWriteGroup.DELETE("/:article_id/notes/:note_id", DeleteNote(app))
+		articleWriteGroup.POST("/:article_id/highlights", CreateHighlight(app))
+		articleWriteGroup.PATCH("/:article_id/highlights/:highlight_id", UpdateHighlight(app))
+		articleWriteGroup.DELETE("/:article_id/highlights/:highlight_id", DeleteHighlight(app))
+	}
+
+	// Add tags namespace, tags organize the saved articles so they need the same scopes
+	tagGroup := v1.Group("/tags")
+	tagGroup.GET("", BearerToken.Scoped(user.ScopeArticlesRead), ListTags(app))
+	tagWriteGroup := tagGroup.Group("", BearerToken.Scoped(user.ScopeArticlesWrite), BearerToken.VerifiedEmail())
+	{
+		tagWriteGroup.POST("", CreateTag(app))
+		tagWriteGroup.PATCH("/:tag_id", RenameTag(app))
+		tagWriteGroup.DELETE("/:tag_id", DeleteTag(app))
+		tagWriteGroup.POST("/:tag_id/merge", MergeTag(app))
+	}
+
+	// Add collections namespace, collections group the saved articles so they need the same scopes
+	collectionGroup := v1.Group("/collections")
+	collectionReadGroup := collectionGroup.Group("", BearerToken.Scoped(user.ScopeArticlesRead))
+	{
+		collectionReadGroup.GET("", ListCollections(app))
+		collectionReadGroup.GET("/:collection_id", GetCollection(app))
+		collectionReadGroup.GET("/:collection_id/articles", ListCollectionArticles(app))
+	}
+	collectionWriteGroup := collectionGroup.Group("", BearerToken.Scoped(user.ScopeArticlesWrite), BearerToken.VerifiedEmail())
 	{
-		articleGroup.POST("", CreateArticle(app))
-		articleGroup.GET("", ListArticles(app))
-		articleGroup.DELETE("/:article_id", DeleteArticle(app))
-		articleGroup.PUT("/:article_id/rate", RateArticle(app))
-		articleGroup.GET("/:article_id/rate", GetArticleRating(app))
-		articleGroup.DELETE("/:article_id/rate", DeleteArticleRating(app))
-		articleGroup.GET("/recommendations", GetRecommendations(app))
+		collectionWriteGroup.POST("", CreateCollection(app))
+		collectionWriteGroup.PATCH("/:collection_id", RenameCollection(app))
+		collectionWriteGroup.DELETE("/:collection_id", DeleteCollection(app))
+		collectionWriteGroup.POST("/:collection_id/articles", AddCollectionArticle(app))
+		collectionWriteGroup.PUT("/:collection_id/articles/:article_id/position", MoveCollectionArticle(app))
+		collectionWriteGroup.DELETE("/:collection_id/articles/:article_id", RemoveCollectionArticle(app))
 	}
 }
@@ -0,0 +1,198 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// AdminUserResponse adds what only admins need to know about a user
+type AdminUserResponse struct {
+	UserResponse
+	DisabledAt *time.Time `json:"disabled_at"`
+}
+
+func newAdminUserResponse(u *user.User) AdminUserResponse {
+	return AdminUserResponse{
+		UserResponse: *newUserResponse(u),
+		DisabledAt:   u.DisabledAt,
+	}
+}
+
+func ListUsers(app *app.Application) gin.HandlerFunc {
+	type Query struct {
+		After string `form:"after"`
+		Limit int    `form:"limit"`
+	}
+
+	type Response struct {
+		Users []AdminUserResponse `json:"users"`
+	}
+
+	return func(c *gin.Context) {
+		var query Query
+		if err := c.ShouldBindQuery(&query); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid query parameter")))
+			return
+		}
+
+		var afterID uuid.UUID
+		if query.After != "" {
+			var parseErr error
+			afterID, parseErr = uuid.Parse(query.After)
+			if parseErr != nil {
+				respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, parseErr, common.WithMsg("invalid after id")))
+				return
+			}
+		}
+
+		if query.Limit == 0 {
+			query.Limit = 10 // default limit
+		}
+
+		users, cerr := app.UserService.ListUsers(c.Request.Context(), afterID, query.Limit)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		resp := Response{
+			Users: make([]AdminUserResponse, 0, len(users)),
+		}
+		for _, u := range users {
+			resp.Users = append(resp.Users, newAdminUserResponse(u))
+		}
+
+		respondWithJSON(c, http.StatusOK, resp)
+	}
+}
+
+func GetUser(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetParamUUID(c, "user_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		foundUser, cerr := app.UserService.GetUser(c.Request.Context(), userID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newAdminUserResponse(foundUser))
+	}
+}
+
+type setUserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func SetUserRole(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actorID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		userID, cerr := GetParamUUID(c, "user_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req setUserRoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		if cerr := app.UserService.SetUserRole(c.Request.Context(), actorID, userID, req.Role); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+func DisableUser(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actorID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		userID, cerr := GetParamUUID(c, "user_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.UserService.DisableUser(c.Request.Context(), actorID, userID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+func EnableUser(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actorID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		userID, cerr := GetParamUUID(c, "user_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.UserService.EnableUser(c.Request.Context(), actorID, userID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+// UnlockUser lifts a lockout caused by failed logins
+func UnlockUser(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actorID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		userID, cerr := GetParamUUID(c, "user_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.UserService.UnlockUser(c.Request.Context(), actorID, userID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+// ListUserArticles lists the articles saved by any user
+func ListUserArticles(app *app.Application) gin.HandlerFunc {
+	return listArticles(app, func(c *gin.Context) (uuid.UUID, common.Error) {
+		return GetParamUUID(c, "user_id")
+	})
+}
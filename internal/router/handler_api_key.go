@@ -0,0 +1,116 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+type APIKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newAPIKeyResponse(key *user.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		LastUsedAt: key.LastUsedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required"`
+}
+
+// createAPIKeyResponse is the only response that carries the key itself
+type createAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+func CreateAPIKey(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req createAPIKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		created, key, cerr := app.UserService.CreateAPIKey(c.Request.Context(), userID, req.Name, req.Scopes)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusCreated, createAPIKeyResponse{
+			APIKeyResponse: newAPIKeyResponse(created),
+			Key:            key,
+		})
+	}
+}
+
+func ListAPIKeys(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		keys, cerr := app.UserService.ListAPIKeys(c.Request.Context(), userID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		resp := make([]APIKeyResponse, 0, len(keys))
+		for _, key := range keys {
+			resp = append(resp, newAPIKeyResponse(key))
+		}
+		respondWithJSON(c, http.StatusOK, resp)
+	}
+}
+
+func DeleteAPIKey(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		keyID, cerr := GetParamUUID(c, "api_key_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.UserService.DeleteAPIKey(c.Request.Context(), userID, keyID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
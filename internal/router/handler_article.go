@@ -1,13 +1,19 @@
 package router
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog"
 
 	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
 	"github.com/sappy5678/DeeliAi/internal/domain/common"
 )
 
@@ -65,22 +71,127 @@ func CreateArticle(app *app.Application) gin.HandlerFunc {
 	}
 }
 
+// SavedArticleResponse is an article as listed for the user who saved it
+type SavedArticleResponse struct {
+	ID          uuid.UUID            `json:"id"`
+	URL         string               `json:"url"`
+	Title       string               `json:"title,omitempty"`
+	Description string               `json:"description,omitempty"`
+	ImageURL    string               `json:"image_url,omitempty"`
+	Domain      string               `json:"domain,omitempty"`
+	Rate        int16                `json:"rate"`
+	CollectedAt time.Time            `json:"collected_at"`
+	State       string               `json:"state"`
+	ReadAt      *time.Time           `json:"read_at,omitempty"`
+	ArchivedAt  *time.Time           `json:"archived_at,omitempty"`
+	Tags        []ArticleTagResponse `json:"tags"`
+	NoteSnippet string               `json:"note_snippet,omitempty"`
+}
+
+func newSavedArticleResponse(art *article.SavedArticle) SavedArticleResponse {
+	return SavedArticleResponse{
+		ID:          art.ID,
+		URL:         art.URL,
+		Title:       art.Title,
+		Description: art.Description,
+		ImageURL:    art.ImageURL,
+		Domain:      art.Domain,
+		Rate:        art.Rate,
+		CollectedAt: art.CollectedAt,
+		State:       art.State,
+		ReadAt:      art.ReadAt,
+		ArchivedAt:  art.ArchivedAt,
+		Tags:        newArticleTagResponses(art.Tags),
+		NoteSnippet: art.NoteSnippet,
+	}
+}
+
+func newSavedArticleResponses(articles []*article.SavedArticle) []SavedArticleResponse {
+	resp := make([]SavedArticleResponse, 0, len(articles))
+	for _, art := range articles {
+		resp = append(resp, newSavedArticleResponse(art))
+	}
+	return resp
+}
+
+// articlePage is the query of listing articles a page at a time
+type articlePage struct {
+	After string `form:"after"`
+	Limit int    `form:"limit"`
+}
+
+// afterID returns the article to start listing after, uuid.Nil for the first page
+func (p *articlePage) afterID() (uuid.UUID, common.Error) {
+	if p.After == "" {
+		return uuid.Nil, nil
+	}
+	afterID, err := uuid.Parse(p.After)
+	if err != nil {
+		return uuid.Nil, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid after id"))
+	}
+	return afterID, nil
+}
+
+// maxPageLimit caps the number of articles listed at once
+const maxPageLimit = 100
+
+func (p *articlePage) limit() int {
+	if p.Limit == 0 {
+		return 10 // default limit
+	}
+	if p.Limit > maxPageLimit {
+		return maxPageLimit
+	}
+	return p.Limit
+}
+
 func ListArticles(app *app.Application) gin.HandlerFunc {
-	type Query struct {
-		After string `form:"after"`
-		Limit int    `form:"limit"`
+	return listArticles(app, GetCurrentUserID)
+}
+
+// articleFilterQuery are the query parameters filtering saved articles
+type articleFilterQuery struct {
+	Tags            []string  `form:"tag"`
+	TagMatch        string    `form:"tag_match"`
+	States          []string  `form:"state"`
+	CollectedAfter  time.Time `form:"collected_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CollectedBefore time.Time `form:"collected_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	MinRating       int16     `form:"min_rating"`
+	MaxRating       int16     `form:"max_rating"`
+	Unrated         bool      `form:"unrated"`
+	Domain          string    `form:"domain"`
+	FetchStatus     string    `form:"fetch_status"`
+}
+
+func (q articleFilterQuery) toFilter() article.ArticleFilter {
+	return article.ArticleFilter{
+		Tags:            q.Tags,
+		TagMatch:        q.TagMatch,
+		States:          q.States,
+		CollectedAfter:  q.CollectedAfter,
+		CollectedBefore: q.CollectedBefore,
+		MinRating:       q.MinRating,
+		MaxRating:       q.MaxRating,
+		Unrated:         q.Unrated,
+		Domain:          q.Domain,
+		FetchStatus:     q.FetchStatus,
 	}
+}
 
-	type ArticleResponse struct {
-		ID          uuid.UUID `json:"id"`
-		URL         string    `json:"url"`
-		Title       string    `json:"title,omitempty"`
-		Description string    `json:"description,omitempty"`
-		ImageURL    string    `json:"image_url,omitempty"`
+// listArticles lists the articles saved by the user getUserID picks from the request
+func listArticles(app *app.Application, getUserID func(c *gin.Context) (uuid.UUID, common.Error)) gin.HandlerFunc {
+	type Query struct {
+		articleFilterQuery
+		articlePage
+		Cursor string `form:"cursor"`
+		Sort   string `form:"sort"`
+		Order  string `form:"order"`
 	}
 
 	type Response struct {
-		Articles []ArticleResponse `json:"articles"`
+		Articles    []SavedArticleResponse `json:"articles"`
+		NextCursor  string                 `json:"next_cursor,omitempty"`
+		UnreadCount int                    `json:"unread_count"`
 	}
 
 	return func(c *gin.Context) {
@@ -92,18 +203,147 @@ func ListArticles(app *app.Application) gin.HandlerFunc {
 			return
 		}
 
-		var afterID uuid.UUID
-		if query.After != "" {
-			var parseErr error
-			afterID, parseErr = uuid.Parse(query.After)
-			if parseErr != nil {
-				respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, parseErr, common.WithMsg("invalid after id")))
+		// Pages were once continued after an article ID, which clients may still send instead of a cursor
+		afterID, err := query.afterID()
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+		if afterID != uuid.Nil && query.Cursor != "" {
+			msg := "after cannot be combined with cursor"
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg)))
+			return
+		}
+
+		userID, err := getUserID(c)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		sort := article.ArticleSort{By: query.Sort, Order: query.Order}
+		cursor := query.Cursor
+		if afterID != uuid.Nil {
+			if cursor, err = app.ArticleService.ArticleCursor(ctx, userID, afterID, sort); err != nil {
+				respondWithError(c, err)
 				return
 			}
 		}
 
-		if query.Limit == 0 {
-			query.Limit = 10 // default limit
+		articles, nextCursor, err := app.ArticleService.ListArticles(ctx, userID, query.toFilter(), sort, cursor, query.limit())
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		counts, err := app.ArticleService.CountArticlesByState(ctx, userID)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, Response{
+			Articles:    newSavedArticleResponses(articles),
+			NextCursor:  nextCursor,
+			UnreadCount: counts[article.ReadStateUnread],
+		})
+	}
+}
+
+// ExportArticles streams the articles saved by the user which match the filters of ListArticles as a file
+// in the format, oldest first. Articles are written as they are read, so a failure half way leaves the file truncated.
+func ExportArticles(app *app.Application) gin.HandlerFunc {
+	type Query struct {
+		articleFilterQuery
+		Format string `form:"format"`
+	}
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var query Query
+		if err := c.ShouldBindQuery(&query); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid query parameter")))
+			return
+		}
+		if query.Format == "" {
+			query.Format = article.ExportFormatJSON
+		}
+
+		userID, err := GetCurrentUserID(c)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		now := time.Now().UTC()
+		exporter, exportErr := article.NewExporter(query.Format, c.Writer, now)
+		if exportErr != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, exportErr, common.WithMsg(exportErr.Error())))
+			return
+		}
+
+		// The response starts with the first article, so an invalid filter can still be answered with an error
+		started := false
+		start := func() {
+			if started {
+				return
+			}
+			started = true
+			c.Header("Content-Type", exporter.ContentType())
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="deeliai-articles-%s.%s"`, now.Format("20060102"), exporter.Extension()))
+			c.Status(http.StatusOK)
+		}
+
+		err = app.ArticleService.ExportArticles(ctx, userID, query.toFilter(), func(saved *article.SavedArticle) common.Error {
+			start()
+			if err := exporter.Write(saved); err != nil {
+				return common.NewError(common.ErrorCodeInternalProcess, err)
+			}
+			return nil
+		})
+		if err == nil {
+			start()
+			if closeErr := exporter.Close(); closeErr != nil {
+				err = common.NewError(common.ErrorCodeInternalProcess, closeErr)
+			}
+		}
+		if err != nil {
+			if !started {
+				respondWithError(c, err)
+				return
+			}
+			// The status line is already sent, all we can do is stop writing
+			zerolog.Ctx(ctx).Error().Err(err).Str("component", "handler").Msg("failed to export articles")
+			_ = c.Error(err)
+		}
+	}
+}
+
+// ArticleFetchStatusResponse is how fetching the metadata of an article went
+type ArticleFetchStatusResponse struct {
+	Status        string     `json:"status"`
+	RetryCount    int16      `json:"retry_count"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+// GetArticle returns an article the user saved with its metadata and how fetching it went
+func GetArticle(app *app.Application) gin.HandlerFunc {
+	type Response struct {
+		SavedArticleResponse
+		Metadata    json.RawMessage             `json:"metadata,omitempty"`
+		FetchStatus *ArticleFetchStatusResponse `json:"fetch_status,omitempty"`
+	}
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		articleID, err := GetParamUUID(c, "article_id")
+		if err != nil {
+			respondWithError(c, err)
+			return
 		}
 
 		userID, err := GetCurrentUserID(c)
@@ -112,25 +352,84 @@ func ListArticles(app *app.Application) gin.HandlerFunc {
 			return
 		}
 
-		articles, err := app.ArticleService.ListArticles(ctx, userID, afterID, query.Limit)
+		detail, err := app.ArticleService.GetArticle(ctx, userID, articleID)
 		if err != nil {
 			respondWithError(c, err)
 			return
 		}
 
 		resp := Response{
-			Articles: make([]ArticleResponse, 0, len(articles)),
-		}
-		for _, art := range articles {
-			resp.Articles = append(resp.Articles, ArticleResponse{
-				ID:          art.ID,
-				URL:         art.URL,
-				Title:       art.Title,
-				Description: art.Description,
-				ImageURL:    art.ImageURL,
-			})
+			SavedArticleResponse: newSavedArticleResponse(&detail.SavedArticle),
+			Metadata:             detail.Metadata,
 		}
+		if fetch := detail.Fetch; fetch != nil {
+			resp.FetchStatus = &ArticleFetchStatusResponse{
+				Status:        fetch.Status.String(),
+				RetryCount:    fetch.RetryCount,
+				LastError:     fetch.ErrorMessage,
+				LastAttemptAt: fetch.LastAttemptAt,
+				NextAttemptAt: fetch.NextAttemptAt,
+			}
+		}
+		respondWithJSON(c, http.StatusOK, resp)
+	}
+}
+
+// SearchArticleResponse is a saved article found by a search
+type SearchArticleResponse struct {
+	SavedArticleResponse
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight,omitempty"`
+	Snippet        string  `json:"snippet,omitempty"`
+}
 
+func SearchArticles(app *app.Application) gin.HandlerFunc {
+	type Query struct {
+		Q     string `form:"q" binding:"required"`
+		Mode  string `form:"mode"`
+		Limit int    `form:"limit"`
+	}
+
+	type Response struct {
+		Articles []SearchArticleResponse `json:"articles"`
+	}
+
+	return func(c *gin.Context) {
+		var query Query
+		if err := c.ShouldBindQuery(&query); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid query parameter")))
+			return
+		}
+		if query.Limit == 0 {
+			query.Limit = 20 // default limit
+		}
+		if query.Limit < 1 || query.Limit > 50 {
+			msg := "limit must be between 1 and 50"
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg)))
+			return
+		}
+
+		userID, err := GetCurrentUserID(c)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		results, err := app.ArticleService.SearchArticles(c.Request.Context(), userID, query.Q, query.Mode, query.Limit)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		resp := Response{Articles: make([]SearchArticleResponse, 0, len(results))}
+		for _, result := range results {
+			resp.Articles = append(resp.Articles, SearchArticleResponse{
+				SavedArticleResponse: newSavedArticleResponse(&result.SavedArticle),
+				Rank:                 result.Rank,
+				TitleHighlight:       result.TitleHighlight,
+				Snippet:              result.Snippet,
+			})
+		}
 		respondWithJSON(c, http.StatusOK, resp)
 	}
 }
@@ -290,3 +589,102 @@ func GetRecommendations(app *app.Application) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"articles": recommendationResponses})
 	}
 }
+
+type articleStateRequest struct {
+	State string `json:"state" binding:"required"`
+}
+
+func SetArticleState(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, articleID, err := getArticleParams(c)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		var body articleStateRequest
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		if err := app.ArticleService.SetArticleState(c.Request.Context(), userID, articleID, body.State); err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+// SetArticlesState moves several saved articles to a read state at once.
+func SetArticlesState(app *app.Application) gin.HandlerFunc {
+	type Body struct {
+		articleStateRequest
+		ArticleIDs []uuid.UUID `json:"article_ids" binding:"required"`
+	}
+
+	type Response struct {
+		Updated int `json:"updated"`
+	}
+
+	return func(c *gin.Context) {
+		userID, err := GetCurrentUserID(c)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		var body Body
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		updated, err := app.ArticleService.SetArticlesState(c.Request.Context(), userID, body.ArticleIDs, body.State)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, Response{Updated: updated})
+	}
+}
+
+// CountArticles returns the number of saved articles of the user in every read state.
+func CountArticles(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetCurrentUserID(c)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		counts, err := app.ArticleService.CountArticlesByState(c.Request.Context(), userID)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, counts)
+	}
+}
+
+// OpenArticle redirects to the URL of a saved article and marks it read.
+func OpenArticle(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, articleID, err := getArticleParams(c)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		art, err := app.ArticleService.OpenArticle(c.Request.Context(), userID, articleID)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		c.Redirect(http.StatusFound, art.URL)
+	}
+}
@@ -0,0 +1,108 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+type AuditEventResponse struct {
+	ID         int64      `json:"id"`
+	ActorID    *uuid.UUID `json:"actor_id"`
+	Action     string     `json:"action"`
+	TargetType string     `json:"target_type,omitempty"`
+	TargetID   string     `json:"target_id,omitempty"`
+	IP         string     `json:"ip,omitempty"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	RequestID  string     `json:"request_id,omitempty"`
+	Outcome    string     `json:"outcome"`
+	Reason     string     `json:"reason,omitempty"`
+	Count      int        `json:"count"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newAuditEventResponse(e *audit.Event) AuditEventResponse {
+	var actorID *uuid.UUID
+	if e.ActorID != uuid.Nil {
+		actorID = &e.ActorID
+	}
+
+	return AuditEventResponse{
+		ID:         e.ID,
+		ActorID:    actorID,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IP:         e.IP,
+		UserAgent:  e.UserAgent,
+		RequestID:  e.RequestID,
+		Outcome:    e.Outcome,
+		Reason:     e.Reason,
+		Count:      e.Count,
+		CreatedAt:  e.CreatedAt,
+	}
+}
+
+func ListAuditEvents(app *app.Application) gin.HandlerFunc {
+	type Query struct {
+		UserID string    `form:"user_id"`
+		Action string    `form:"action"`
+		Since  time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+		Until  time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+		Cursor string    `form:"cursor"`
+		Limit  int       `form:"limit"`
+	}
+
+	type Response struct {
+		Events     []AuditEventResponse `json:"events"`
+		NextCursor string               `json:"next_cursor,omitempty"`
+	}
+
+	return func(c *gin.Context) {
+		var query Query
+		if err := c.ShouldBindQuery(&query); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid query parameter")))
+			return
+		}
+
+		filter := audit.Filter{
+			Action: query.Action,
+			Since:  query.Since,
+			Until:  query.Until,
+		}
+		if query.UserID != "" {
+			userID, err := uuid.Parse(query.UserID)
+			if err != nil {
+				respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid user id")))
+				return
+			}
+			filter.UserID = userID
+		}
+
+		if query.Limit == 0 {
+			query.Limit = 50 // default limit
+		}
+
+		events, nextCursor, cerr := app.AuditService.ListEvents(c.Request.Context(), filter, query.Cursor, query.Limit)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		resp := Response{
+			Events:     make([]AuditEventResponse, 0, len(events)),
+			NextCursor: nextCursor,
+		}
+		for _, e := range events {
+			resp.Events = append(resp.Events, newAuditEventResponse(e))
+		}
+
+		respondWithJSON(c, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,267 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+type CollectionResponse struct {
+	ID           uuid.UUID `json:"id"`
+	Name         string    `json:"name"`
+	ArticleCount int       `json:"article_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func newCollectionResponse(collection *article.Collection) CollectionResponse {
+	return CollectionResponse{
+		ID:           collection.ID,
+		Name:         collection.Name,
+		ArticleCount: collection.ArticleCount,
+		CreatedAt:    collection.CreatedAt,
+	}
+}
+
+type collectionRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func CreateCollection(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req collectionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		collection, cerr := app.ArticleService.CreateCollection(c.Request.Context(), userID, req.Name)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusCreated, newCollectionResponse(collection))
+	}
+}
+
+func ListCollections(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		collections, cerr := app.ArticleService.ListCollections(c.Request.Context(), userID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		resp := make([]CollectionResponse, 0, len(collections))
+		for _, collection := range collections {
+			resp = append(resp, newCollectionResponse(collection))
+		}
+		respondWithJSON(c, http.StatusOK, resp)
+	}
+}
+
+func GetCollection(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, collectionID, cerr := getCollectionParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		collection, cerr := app.ArticleService.GetCollection(c.Request.Context(), userID, collectionID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newCollectionResponse(collection))
+	}
+}
+
+func RenameCollection(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, collectionID, cerr := getCollectionParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req collectionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		collection, cerr := app.ArticleService.RenameCollection(c.Request.Context(), userID, collectionID, req.Name)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newCollectionResponse(collection))
+	}
+}
+
+func DeleteCollection(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, collectionID, cerr := getCollectionParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.ArticleService.DeleteCollection(c.Request.Context(), userID, collectionID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+// ListCollectionArticles lists the articles of a collection in its order, a page at a time like ListArticles.
+func ListCollectionArticles(app *app.Application) gin.HandlerFunc {
+	type Response struct {
+		Articles []SavedArticleResponse `json:"articles"`
+	}
+
+	return func(c *gin.Context) {
+		userID, collectionID, cerr := getCollectionParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var query articlePage
+		if err := c.ShouldBindQuery(&query); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid query parameter")))
+			return
+		}
+		afterID, cerr := query.afterID()
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		articles, cerr := app.ArticleService.ListCollectionArticles(c.Request.Context(), userID, collectionID, afterID, query.limit())
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, Response{Articles: newSavedArticleResponses(articles)})
+	}
+}
+
+func AddCollectionArticle(app *app.Application) gin.HandlerFunc {
+	type Body struct {
+		ArticleID uuid.UUID `json:"article_id" binding:"required"`
+	}
+
+	return func(c *gin.Context) {
+		userID, collectionID, cerr := getCollectionParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var body Body
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		if cerr := app.ArticleService.AddCollectionArticle(c.Request.Context(), userID, collectionID, body.ArticleID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+// MoveCollectionArticle moves an article of a collection to the 0-based position given in the body.
+func MoveCollectionArticle(app *app.Application) gin.HandlerFunc {
+	type Body struct {
+		Position *int `json:"position" binding:"required"`
+	}
+
+	return func(c *gin.Context) {
+		userID, collectionID, cerr := getCollectionParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		articleID, cerr := GetParamUUID(c, "article_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var body Body
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		if cerr := app.ArticleService.MoveCollectionArticle(c.Request.Context(), userID, collectionID, articleID, *body.Position); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+func RemoveCollectionArticle(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, collectionID, cerr := getCollectionParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		articleID, cerr := GetParamUUID(c, "article_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.ArticleService.RemoveCollectionArticle(c.Request.Context(), userID, collectionID, articleID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+// getCollectionParams returns the current user and the collection in the path
+func getCollectionParams(c *gin.Context) (uuid.UUID, uuid.UUID, common.Error) {
+	userID, cerr := GetCurrentUserID(c)
+	if cerr != nil {
+		return uuid.Nil, uuid.Nil, cerr
+	}
+	collectionID, cerr := GetParamUUID(c, "collection_id")
+	if cerr != nil {
+		return uuid.Nil, uuid.Nil, cerr
+	}
+	return userID, collectionID, nil
+}
@@ -0,0 +1,157 @@
+package router
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// ImportErrorResponse is an article of an import file which could not be imported
+type ImportErrorResponse struct {
+	Line    int    `json:"line"`
+	URL     string `json:"url,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportJobResponse is the progress of an import job
+type ImportJobResponse struct {
+	ID         uuid.UUID             `json:"id"`
+	Format     string                `json:"format"`
+	Status     string                `json:"status"`
+	Total      int                   `json:"total"`
+	Processed  int                   `json:"processed"`
+	Imported   int                   `json:"imported"`
+	Duplicates int                   `json:"duplicates"`
+	Failed     int                   `json:"failed"`
+	Errors     []ImportErrorResponse `json:"errors"`
+	CreatedAt  time.Time             `json:"created_at"`
+	StartedAt  *time.Time            `json:"started_at,omitempty"`
+	FinishedAt *time.Time            `json:"finished_at,omitempty"`
+}
+
+func newImportJobResponse(job *article.ImportJob) ImportJobResponse {
+	resp := ImportJobResponse{
+		ID:         job.ID,
+		Format:     job.Format,
+		Status:     job.Status,
+		Total:      job.Total,
+		Processed:  job.Processed,
+		Imported:   job.Imported,
+		Duplicates: job.Duplicates,
+		Failed:     job.Failed,
+		Errors:     make([]ImportErrorResponse, 0, len(job.Errors)),
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+	}
+	for _, e := range job.Errors {
+		resp.Errors = append(resp.Errors, ImportErrorResponse{Line: e.Line, URL: e.URL, Message: e.Message})
+	}
+	return resp
+}
+
+// ImportArticles queues a job importing the articles of a file, uploaded as the file field of a multipart form
+// or as the request body
+func ImportArticles(app *app.Application) gin.HandlerFunc {
+	type Query struct {
+		Format string `form:"format"`
+	}
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var query Query
+		if err := c.ShouldBindQuery(&query); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid query parameter")))
+			return
+		}
+
+		userID, err := GetCurrentUserID(c)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		data, err := readImportFile(c)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		job, err := app.ArticleService.ImportArticles(ctx, userID, query.Format, data)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		respondWithJSON(c, http.StatusAccepted, newImportJobResponse(job))
+	}
+}
+
+// readImportFile reads the uploaded file, up to one byte more than can be imported
+func readImportFile(c *gin.Context) ([]byte, common.Error) {
+	// Leave room for the framing of multipart forms
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, article.ImportMaxSize+1<<20)
+
+	var r io.Reader = c.Request.Body
+	var err error
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		var file multipart.File
+		if file, _, err = c.Request.FormFile("file"); err == nil {
+			defer file.Close()
+			r = file
+		}
+	}
+
+	var data []byte
+	if err == nil {
+		data, err = io.ReadAll(io.LimitReader(r, article.ImportMaxSize+1))
+	}
+	var maxBytesError *http.MaxBytesError
+	switch {
+	case err == nil:
+		return data, nil
+	case errors.As(err, &maxBytesError):
+		return nil, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("the file is too large"))
+	case errors.Is(err, http.ErrMissingFile):
+		return nil, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("the file field is required"))
+	}
+	return nil, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("failed to read the file"))
+}
+
+// GetImportJob returns the progress and errors of an import job of the user
+func GetImportJob(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		jobID, err := GetParamUUID(c, "import_id")
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		userID, err := GetCurrentUserID(c)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		job, err := app.ArticleService.GetImportJob(ctx, userID, jobID)
+		if err != nil {
+			respondWithError(c, err)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newImportJobResponse(job))
+	}
+}
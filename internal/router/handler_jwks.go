@@ -0,0 +1,81 @@
+package router
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+
+	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// JSONWebKey is the RFC 7517 representation of a token verification key
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA public key parameters
+	Modulus  string `json:"n,omitempty"`
+	Exponent string `json:"e,omitempty"`
+	// OKP public key parameters
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+type JSONWebKeySetResponse struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+func newJSONWebKey(key user.PublicKey) (JSONWebKey, bool) {
+	jwk := JSONWebKey{
+		KeyID:     key.ID,
+		Use:       "sig",
+		Algorithm: key.Algorithm,
+	}
+
+	switch publicKey := key.Key.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.Modulus = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.Exponent = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	default:
+		return JSONWebKey{}, false
+	}
+	return jwk, true
+}
+
+func GetJSONWebKeySet(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, cerr := app.UserService.PublicKeys(c.Request.Context())
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		resp := JSONWebKeySetResponse{
+			Keys: make([]JSONWebKey, 0, len(keys)),
+		}
+		for _, key := range keys {
+			jwk, ok := newJSONWebKey(key)
+			if !ok {
+				zerolog.Ctx(c.Request.Context()).Warn().Str("kid", key.ID).Msg("skip unsupported public key in JWKS")
+				continue
+			}
+			resp.Keys = append(resp.Keys, jwk)
+		}
+
+		// Verifiers cache the key set and refetch it when they meet an unknown kid
+		c.Header("Cache-Control", "public, max-age=300")
+		respondWithJSON(c, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,298 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+type NoteResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newNoteResponse(note *article.Note) NoteResponse {
+	return NoteResponse{
+		ID:        note.ID,
+		Body:      note.Body,
+		CreatedAt: note.CreatedAt,
+		UpdatedAt: note.UpdatedAt,
+	}
+}
+
+type HighlightPositionResponse struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type HighlightResponse struct {
+	ID        uuid.UUID                  `json:"id"`
+	Text      string                     `json:"text"`
+	Comment   string                     `json:"comment"`
+	Position  *HighlightPositionResponse `json:"position,omitempty"`
+	CreatedAt time.Time                  `json:"created_at"`
+	UpdatedAt time.Time                  `json:"updated_at"`
+}
+
+func newHighlightResponse(highlight *article.Highlight) HighlightResponse {
+	resp := HighlightResponse{
+		ID:        highlight.ID,
+		Text:      highlight.Text,
+		Comment:   highlight.Comment,
+		CreatedAt: highlight.CreatedAt,
+		UpdatedAt: highlight.UpdatedAt,
+	}
+	if highlight.Position != nil {
+		resp.Position = &HighlightPositionResponse{
+			Start: highlight.Position.Start,
+			End:   highlight.Position.End,
+		}
+	}
+	return resp
+}
+
+type noteRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+func CreateNote(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, articleID, cerr := getArticleParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req noteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		note, cerr := app.ArticleService.CreateNote(c.Request.Context(), userID, articleID, req.Body)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusCreated, newNoteResponse(note))
+	}
+}
+
+func ListNotes(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, articleID, cerr := getArticleParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		notes, cerr := app.ArticleService.ListNotes(c.Request.Context(), userID, articleID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		resp := make([]NoteResponse, 0, len(notes))
+		for _, note := range notes {
+			resp = append(resp, newNoteResponse(note))
+		}
+		respondWithJSON(c, http.StatusOK, resp)
+	}
+}
+
+func UpdateNote(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, articleID, cerr := getArticleParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		noteID, cerr := GetParamUUID(c, "note_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req noteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		note, cerr := app.ArticleService.UpdateNote(c.Request.Context(), userID, articleID, noteID, req.Body)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newNoteResponse(note))
+	}
+}
+
+func DeleteNote(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, articleID, cerr := getArticleParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		noteID, cerr := GetParamUUID(c, "note_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.ArticleService.DeleteNote(c.Request.Context(), userID, articleID, noteID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+func CreateHighlight(app *app.Application) gin.HandlerFunc {
+	type Position struct {
+		Start *int `json:"start" binding:"required"`
+		End   *int `json:"end" binding:"required"`
+	}
+
+	type Body struct {
+		Text     string    `json:"text" binding:"required"`
+		Comment  string    `json:"comment"`
+		Position *Position `json:"position"`
+	}
+
+	return func(c *gin.Context) {
+		userID, articleID, cerr := getArticleParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var body Body
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		highlight := &article.Highlight{
+			ArticleID: articleID,
+			Text:      body.Text,
+			Comment:   body.Comment,
+		}
+		if body.Position != nil {
+			highlight.Position = &article.HighlightPosition{
+				Start: *body.Position.Start,
+				End:   *body.Position.End,
+			}
+		}
+
+		created, cerr := app.ArticleService.CreateHighlight(c.Request.Context(), userID, highlight)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusCreated, newHighlightResponse(created))
+	}
+}
+
+func ListHighlights(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, articleID, cerr := getArticleParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		highlights, cerr := app.ArticleService.ListHighlights(c.Request.Context(), userID, articleID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		resp := make([]HighlightResponse, 0, len(highlights))
+		for _, highlight := range highlights {
+			resp = append(resp, newHighlightResponse(highlight))
+		}
+		respondWithJSON(c, http.StatusOK, resp)
+	}
+}
+
+func UpdateHighlight(app *app.Application) gin.HandlerFunc {
+	type Body struct {
+		Comment *string `json:"comment" binding:"required"`
+	}
+
+	return func(c *gin.Context) {
+		userID, articleID, cerr := getArticleParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		highlightID, cerr := GetParamUUID(c, "highlight_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var body Body
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		highlight, cerr := app.ArticleService.UpdateHighlightComment(c.Request.Context(), userID, articleID, highlightID, *body.Comment)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newHighlightResponse(highlight))
+	}
+}
+
+func DeleteHighlight(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, articleID, cerr := getArticleParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		highlightID, cerr := GetParamUUID(c, "highlight_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.ArticleService.DeleteHighlight(c.Request.Context(), userID, articleID, highlightID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+// getArticleParams returns the current user and the article in the path
+func getArticleParams(c *gin.Context) (uuid.UUID, uuid.UUID, common.Error) {
+	userID, cerr := GetCurrentUserID(c)
+	if cerr != nil {
+		return uuid.Nil, uuid.Nil, cerr
+	}
+	articleID, cerr := GetParamUUID(c, "article_id")
+	if cerr != nil {
+		return uuid.Nil, uuid.Nil, cerr
+	}
+	return userID, articleID, nil
+}
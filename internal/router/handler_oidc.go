@@ -0,0 +1,69 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+// StartOIDCLogin redirects the browser to the identity provider to log in
+func StartOIDCLogin(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authURL, cerr := app.UserService.StartOIDCLogin(c.Request.Context(), c.Param("provider"))
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+type oidcCallbackQuery struct {
+	Code             string `form:"code"`
+	State            string `form:"state"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
+
+// CompleteOIDCLogin handles the identity provider redirecting back, and logs the user in like Login does
+func CompleteOIDCLogin(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query oidcCallbackQuery
+		if err := c.ShouldBindQuery(&query); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+		// The user declined or the provider failed the sign in
+		if query.Error != "" {
+			err := fmt.Errorf("identity provider returned %s: %s", query.Error, query.ErrorDescription)
+			respondWithError(c, common.NewError(common.ErrorCodeAuthNotAuthenticated, err, common.WithMsg("the sign in at the identity provider failed")))
+			return
+		}
+		if query.Code == "" || query.State == "" {
+			msg := "code and state are needed"
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg)))
+			return
+		}
+
+		result, cerr := app.UserService.CompleteOIDCLogin(c.Request.Context(), c.Param("provider"), query.State, query.Code, GetClientDevice(c))
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if result.Challenge != nil {
+			respondWithJSON(c, http.StatusOK, newTwoFactorChallengeResponse(result.Challenge))
+			return
+		}
+		respondWithJSON(c, http.StatusOK, loginResponse{
+			User:          newUserResponse(result.User),
+			TokenResponse: newTokenResponse(result.Token),
+		})
+	}
+}
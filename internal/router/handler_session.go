@@ -0,0 +1,106 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+func newSessionResponse(session *user.TokenFamily, currentSessionID uuid.UUID) SessionResponse {
+	return SessionResponse{
+		ID:         session.ID,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IPAddress,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		Current:    session.ID == currentSessionID,
+	}
+}
+
+func ListSessions(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		sessionID, cerr := GetCurrentSessionID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		sessions, cerr := app.UserService.ListSessions(c.Request.Context(), userID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		resp := make([]SessionResponse, 0, len(sessions))
+		for _, session := range sessions {
+			resp = append(resp, newSessionResponse(session, sessionID))
+		}
+		respondWithJSON(c, http.StatusOK, resp)
+	}
+}
+
+// RevokeSession logs out one session of the current user, which may be the current one
+func RevokeSession(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		sessionID, cerr := GetParamUUID(c, "session_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.UserService.RevokeSession(c.Request.Context(), userID, sessionID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+// RevokeOtherSessions logs the current user out everywhere else
+func RevokeOtherSessions(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		sessionID, cerr := GetCurrentSessionID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.UserService.RevokeOtherSessions(c.Request.Context(), userID, sessionID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
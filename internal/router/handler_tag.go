@@ -0,0 +1,240 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+)
+
+type TagResponse struct {
+	ID           uuid.UUID `json:"id"`
+	Name         string    `json:"name"`
+	ArticleCount int       `json:"article_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func newTagResponse(tag *article.Tag) TagResponse {
+	return TagResponse{
+		ID:           tag.ID,
+		Name:         tag.Name,
+		ArticleCount: tag.ArticleCount,
+		CreatedAt:    tag.CreatedAt,
+	}
+}
+
+// ArticleTagResponse is a tag as listed with the articles carrying it
+type ArticleTagResponse struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+func newArticleTagResponses(tags []*article.Tag) []ArticleTagResponse {
+	resp := make([]ArticleTagResponse, 0, len(tags))
+	for _, tag := range tags {
+		resp = append(resp, ArticleTagResponse{
+			ID:   tag.ID,
+			Name: tag.Name,
+		})
+	}
+	return resp
+}
+
+type tagRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func CreateTag(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req tagRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		tag, cerr := app.ArticleService.CreateTag(c.Request.Context(), userID, req.Name)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusCreated, newTagResponse(tag))
+	}
+}
+
+func ListTags(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		tags, cerr := app.ArticleService.ListTags(c.Request.Context(), userID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		resp := make([]TagResponse, 0, len(tags))
+		for _, tag := range tags {
+			resp = append(resp, newTagResponse(tag))
+		}
+		respondWithJSON(c, http.StatusOK, resp)
+	}
+}
+
+func RenameTag(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, tagID, cerr := getTagParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req tagRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		tag, cerr := app.ArticleService.RenameTag(c.Request.Context(), userID, tagID, req.Name)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newTagResponse(tag))
+	}
+}
+
+// MergeTag moves the articles of the tag to the tag given in the body and deletes the tag.
+func MergeTag(app *app.Application) gin.HandlerFunc {
+	type Body struct {
+		Into uuid.UUID `json:"into" binding:"required"`
+	}
+
+	return func(c *gin.Context) {
+		userID, tagID, cerr := getTagParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var body Body
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		target, cerr := app.ArticleService.MergeTags(c.Request.Context(), userID, tagID, body.Into)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newTagResponse(target))
+	}
+}
+
+func DeleteTag(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, tagID, cerr := getTagParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.ArticleService.DeleteTag(c.Request.Context(), userID, tagID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+func AddArticleTags(app *app.Application) gin.HandlerFunc {
+	type Body struct {
+		Names []string `json:"names" binding:"required"`
+	}
+
+	type Response struct {
+		Tags []ArticleTagResponse `json:"tags"`
+	}
+
+	return func(c *gin.Context) {
+		articleID, cerr := GetParamUUID(c, "article_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var body Body
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		tags, cerr := app.ArticleService.AddArticleTags(c.Request.Context(), userID, articleID, body.Names)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, Response{Tags: newArticleTagResponses(tags)})
+	}
+}
+
+func RemoveArticleTag(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		articleID, cerr := GetParamUUID(c, "article_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		userID, tagID, cerr := getTagParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.ArticleService.RemoveArticleTag(c.Request.Context(), userID, articleID, tagID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+// getTagParams returns the current user and the tag in the path
+func getTagParams(c *gin.Context) (uuid.UUID, uuid.UUID, common.Error) {
+	userID, cerr := GetCurrentUserID(c)
+	if cerr != nil {
+		return uuid.Nil, uuid.Nil, cerr
+	}
+	tagID, cerr := GetParamUUID(c, "tag_id")
+	if cerr != nil {
+		return uuid.Nil, uuid.Nil, cerr
+	}
+	return userID, tagID, nil
+}
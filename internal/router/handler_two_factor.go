@@ -0,0 +1,138 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
+)
+
+// twoFactorChallengeResponse is returned by a login that still has to pass the second factor
+type twoFactorChallengeResponse struct {
+	TwoFactorRequired  bool      `json:"two_factor_required"`
+	ChallengeToken     string    `json:"challenge_token"`
+	ChallengeExpiresAt time.Time `json:"challenge_expires_at"`
+}
+
+func newTwoFactorChallengeResponse(challenge *user.TwoFactorChallenge) twoFactorChallengeResponse {
+	return twoFactorChallengeResponse{
+		TwoFactorRequired:  true,
+		ChallengeToken:     challenge.Token,
+		ChallengeExpiresAt: challenge.ExpiresAt,
+	}
+}
+
+type twoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// CompleteTwoFactorLogin exchanges a login challenge and a TOTP or recovery code for a token pair
+func CompleteTwoFactorLogin(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req twoFactorLoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		foundUser, token, cerr := app.UserService.CompleteTwoFactorLogin(c.Request.Context(), req.ChallengeToken, req.Code, GetClientDevice(c))
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, loginResponse{
+			User:          newUserResponse(foundUser),
+			TokenResponse: newTokenResponse(token),
+		})
+	}
+}
+
+type twoFactorEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// EnrollTwoFactor creates a TOTP secret for the current user to confirm
+func EnrollTwoFactor(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		enrollment, cerr := app.UserService.EnrollTwoFactor(c.Request.Context(), userID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, twoFactorEnrollmentResponse{
+			Secret:     enrollment.Secret,
+			OTPAuthURI: enrollment.URI,
+		})
+	}
+}
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type twoFactorConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ConfirmTwoFactor enables two-factor authentication and returns the recovery codes
+func ConfirmTwoFactor(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req twoFactorCodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		codes, cerr := app.UserService.ConfirmTwoFactor(c.Request.Context(), userID, req.Code)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, twoFactorConfirmResponse{RecoveryCodes: codes})
+	}
+}
+
+// DisableTwoFactor turns two-factor authentication off, given a valid code
+func DisableTwoFactor(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req twoFactorCodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		if cerr := app.UserService.DisableTwoFactor(c.Request.Context(), userID, req.Code); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
@@ -1,13 +1,19 @@
 package router
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog"
 
 	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/article"
 	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
 )
 
 type signUpRequest struct {
@@ -17,14 +23,45 @@ type signUpRequest struct {
 }
 
 type signUpResponse struct {
-	User  *UserResponse `json:"user"`
-	Token string        `json:"token"`
+	User *UserResponse `json:"user"`
+	TokenResponse
 }
 
 type UserResponse struct {
-	ID       uuid.UUID `json:"id"`
-	Email    string    `json:"email"`
-	Username string    `json:"username"`
+	ID            uuid.UUID `json:"id"`
+	Email         string    `json:"email"`
+	Username      string    `json:"username"`
+	EmailVerified bool      `json:"email_verified"`
+	HasPassword   bool      `json:"has_password"`
+	Role          string    `json:"role"`
+}
+
+func newUserResponse(u *user.User) *UserResponse {
+	return &UserResponse{
+		ID:            u.ID,
+		Email:         u.Email,
+		Username:      u.Username,
+		EmailVerified: u.IsEmailVerified(),
+		HasPassword:   u.HasPassword(),
+		Role:          u.Role,
+	}
+}
+
+// TokenResponse keeps the access token under `token` for clients built before refresh tokens existed
+type TokenResponse struct {
+	Token                 string    `json:"token"`
+	TokenExpiresAt        time.Time `json:"token_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+}
+
+func newTokenResponse(token *user.Token) TokenResponse {
+	return TokenResponse{
+		Token:                 token.AccessToken,
+		TokenExpiresAt:        token.AccessExpiresAt,
+		RefreshToken:          token.RefreshToken,
+		RefreshTokenExpiresAt: token.RefreshExpiresAt,
+	}
 }
 
 func SignUp(app *app.Application) gin.HandlerFunc {
@@ -35,19 +72,15 @@ func SignUp(app *app.Application) gin.HandlerFunc {
 			return
 		}
 
-		createdUser, token, cerr := app.UserService.SignUp(c.Request.Context(), req.Email, req.Username, req.Password)
+		createdUser, token, cerr := app.UserService.SignUp(c.Request.Context(), req.Email, req.Username, req.Password, GetClientDevice(c))
 		if cerr != nil {
 			respondWithError(c, cerr)
 			return
 		}
 
 		respondWithJSON(c, http.StatusCreated, signUpResponse{
-			User: &UserResponse{
-				ID:       createdUser.ID,
-				Email:    createdUser.Email,
-				Username: createdUser.Username,
-			},
-			Token: token,
+			User:          newUserResponse(createdUser),
+			TokenResponse: newTokenResponse(token),
 		})
 	}
 }
@@ -58,8 +91,8 @@ type loginRequest struct {
 }
 
 type loginResponse struct {
-	User  *UserResponse `json:"user"`
-	Token string        `json:"token"`
+	User *UserResponse `json:"user"`
+	TokenResponse
 }
 
 func Login(app *app.Application) gin.HandlerFunc {
@@ -70,23 +103,105 @@ func Login(app *app.Application) gin.HandlerFunc {
 			return
 		}
 
-		foundUser, token, cerr := app.UserService.Login(c.Request.Context(), req.Email, req.Password)
+		result, cerr := app.UserService.Login(c.Request.Context(), req.Email, req.Password, GetClientDevice(c))
 		if cerr != nil {
 			respondWithError(c, cerr)
 			return
 		}
 
+		if result.Challenge != nil {
+			respondWithJSON(c, http.StatusOK, newTwoFactorChallengeResponse(result.Challenge))
+			return
+		}
 		respondWithJSON(c, http.StatusOK, loginResponse{
-			User: &UserResponse{
-				ID:       foundUser.ID,
-				Email:    foundUser.Email,
-				Username: foundUser.Username,
-			},
-			Token: token,
+			User:          newUserResponse(result.User),
+			TokenResponse: newTokenResponse(result.Token),
 		})
 	}
 }
 
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+func RefreshToken(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req refreshTokenRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		token, cerr := app.UserService.RefreshToken(c.Request.Context(), req.RefreshToken, GetClientDevice(c))
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newTokenResponse(token))
+	}
+}
+
+func Logout(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req refreshTokenRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		if cerr := app.UserService.RevokeToken(c.Request.Context(), req.RefreshToken); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+func ForgotPassword(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req forgotPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		if cerr := app.UserService.ForgotPassword(c.Request.Context(), req.Email); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusAccepted)
+	}
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func ResetPassword(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req resetPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		if cerr := app.UserService.ResetPassword(c.Request.Context(), req.Token, req.Password); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
 func GetCurrentUser(app *app.Application) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, cerr := GetCurrentUserID(c)
@@ -101,10 +216,251 @@ func GetCurrentUser(app *app.Application) gin.HandlerFunc {
 			return
 		}
 
-		respondWithJSON(c, http.StatusOK, UserResponse{
-			ID:       foundUser.ID,
-			Email:    foundUser.Email,
-			Username: foundUser.Username,
+		respondWithJSON(c, http.StatusOK, newUserResponse(foundUser))
+	}
+}
+
+func VerifyEmail(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			msg := "no token"
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg)))
+			return
+		}
+
+		verifiedUser, cerr := app.UserService.VerifyEmail(c.Request.Context(), token)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newUserResponse(verifiedUser))
+	}
+}
+
+func ResendVerificationEmail(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.UserService.ResendVerificationEmail(c.Request.Context(), userID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusAccepted)
+	}
+}
+
+type updateCurrentUserRequest struct {
+	Username *string `json:"username" binding:"omitempty,min=1,max=50"`
+	Email    *string `json:"email" binding:"omitempty,email,max=100"`
+}
+
+func UpdateCurrentUser(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req updateCurrentUserRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+		if req.Username == nil && req.Email == nil {
+			msg := "username or email is needed"
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, errors.New(msg), common.WithMsg(msg)))
+			return
+		}
+
+		updatedUser, cerr := app.UserService.UpdateProfile(c.Request.Context(), userID, req.Username, req.Email)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newUserResponse(updatedUser))
+	}
+}
+
+// changePasswordRequest leaves out the current password of users without one
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+func ChangePassword(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		sessionID, cerr := GetCurrentSessionID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req changePasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		token, cerr := app.UserService.ChangePassword(c.Request.Context(), userID, sessionID, req.CurrentPassword, req.NewPassword, GetClientDevice(c))
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newTokenResponse(token))
+	}
+}
+
+// deleteCurrentUserRequest leaves out the password of users without one
+type deleteCurrentUserRequest struct {
+	Password string `json:"password"`
+}
+
+func DeleteCurrentUser(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		sessionID, cerr := GetCurrentSessionID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req deleteCurrentUserRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		if cerr := app.UserService.DeleteAccount(c.Request.Context(), userID, sessionID, req.Password); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+type exportUserResponse struct {
+	ID              uuid.UUID  `json:"id"`
+	Email           string     `json:"email"`
+	Username        string     `json:"username"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+}
+
+type exportTagResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportCollectionResponse struct {
+	ID         uuid.UUID   `json:"id"`
+	Name       string      `json:"name"`
+	CreatedAt  time.Time   `json:"created_at"`
+	ArticleIDs []uuid.UUID `json:"article_ids"`
+}
+
+// ExportCurrentUser streams the profile, tags and collections of the user and every saved article,
+// with its read state, tags, notes and highlights, as one JSON document.
+// Articles are written as they are read, so a failure half way leaves the document truncated.
+func ExportCurrentUser(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		foundUser, cerr := app.UserService.GetUser(ctx, userID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		tags, cerr := app.ArticleService.ListTags(ctx, userID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		tagsResp := make([]exportTagResponse, 0, len(tags))
+		for _, tag := range tags {
+			tagsResp = append(tagsResp, exportTagResponse{ID: tag.ID, Name: tag.Name, CreatedAt: tag.CreatedAt})
+		}
+
+		collections, cerr := app.ArticleService.ExportCollections(ctx, userID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		collectionsResp := make([]exportCollectionResponse, 0, len(collections))
+		for _, collection := range collections {
+			collectionsResp = append(collectionsResp, exportCollectionResponse{
+				ID:         collection.ID,
+				Name:       collection.Name,
+				CreatedAt:  collection.CreatedAt,
+				ArticleIDs: collection.ArticleIDs,
+			})
+		}
+
+		// The JSON export of GET /articles/export, with the profile, tags and collections of the user on top
+		now := time.Now().UTC()
+		exporter, err := article.NewExporter(article.ExportFormatJSON, c.Writer, now,
+			article.WithExportField("user", exportUserResponse{
+				ID:              foundUser.ID,
+				Email:           foundUser.Email,
+				Username:        foundUser.Username,
+				EmailVerifiedAt: foundUser.EmailVerifiedAt,
+			}),
+			article.WithExportField("tags", tagsResp),
+			article.WithExportField("collections", collectionsResp),
+		)
+		if err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeInternalProcess, err))
+			return
+		}
+
+		c.Header("Content-Type", exporter.ContentType())
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="deeliai-export-%s.%s"`, now.Format("20060102"), exporter.Extension()))
+		c.Status(http.StatusOK)
+
+		cerr = app.ArticleService.ExportUserArticles(ctx, userID, func(saved *article.SavedArticle) common.Error {
+			if err := exporter.Write(saved); err != nil {
+				return common.NewError(common.ErrorCodeInternalProcess, err)
+			}
+			c.Writer.Flush()
+			return nil
 		})
+		if cerr == nil {
+			if err := exporter.Close(); err != nil {
+				cerr = common.NewError(common.ErrorCodeInternalProcess, err)
+			}
+		}
+		if cerr != nil {
+			// The status line is already sent, all we can do is stop writing
+			zerolog.Ctx(ctx).Error().Err(cerr).Str("component", "handler").Msg("failed to export user data")
+			_ = c.Error(cerr)
+		}
 	}
 }
@@ -0,0 +1,514 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/sappy5678/DeeliAi/internal/app"
+	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/workspace"
+)
+
+type WorkspaceResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newWorkspaceResponse(m *workspace.Membership) WorkspaceResponse {
+	return WorkspaceResponse{
+		ID:        m.ID,
+		Name:      m.Name,
+		Role:      m.Role,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+type WorkspaceMemberResponse struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type WorkspaceInvitationResponse struct {
+	ID        int64     `json:"id"`
+	Email     string    `json:"email,omitempty"`
+	Role      string    `json:"role"`
+	InvitedBy uuid.UUID `json:"invited_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newWorkspaceInvitationResponse(invitation *workspace.Invitation) WorkspaceInvitationResponse {
+	return WorkspaceInvitationResponse{
+		ID:        invitation.ID,
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		InvitedBy: invitation.InvitedBy,
+		ExpiresAt: invitation.ExpiresAt,
+		CreatedAt: invitation.CreatedAt,
+	}
+}
+
+type workspaceRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+func CreateWorkspace(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req workspaceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		created, cerr := app.WorkspaceService.CreateWorkspace(c.Request.Context(), userID, req.Name)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusCreated, newWorkspaceResponse(created))
+	}
+}
+
+func ListWorkspaces(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		memberships, cerr := app.WorkspaceService.ListWorkspaces(c.Request.Context(), userID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		resp := make([]WorkspaceResponse, 0, len(memberships))
+		for _, m := range memberships {
+			resp = append(resp, newWorkspaceResponse(m))
+		}
+		respondWithJSON(c, http.StatusOK, resp)
+	}
+}
+
+func GetWorkspace(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, workspaceID, cerr := getWorkspaceParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		membership, cerr := app.WorkspaceService.GetWorkspace(c.Request.Context(), userID, workspaceID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newWorkspaceResponse(membership))
+	}
+}
+
+func RenameWorkspace(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, workspaceID, cerr := getWorkspaceParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req workspaceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		membership, cerr := app.WorkspaceService.RenameWorkspace(c.Request.Context(), userID, workspaceID, req.Name)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newWorkspaceResponse(membership))
+	}
+}
+
+func DeleteWorkspace(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, workspaceID, cerr := getWorkspaceParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.WorkspaceService.DeleteWorkspace(c.Request.Context(), userID, workspaceID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+func ListWorkspaceMembers(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, workspaceID, cerr := getWorkspaceParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		members, cerr := app.WorkspaceService.ListMembers(c.Request.Context(), userID, workspaceID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		resp := make([]WorkspaceMemberResponse, 0, len(members))
+		for _, m := range members {
+			resp = append(resp, WorkspaceMemberResponse{
+				UserID:   m.UserID,
+				Username: m.Username,
+				Email:    m.Email,
+				Role:     m.Role,
+				JoinedAt: m.JoinedAt,
+			})
+		}
+		respondWithJSON(c, http.StatusOK, resp)
+	}
+}
+
+type setWorkspaceMemberRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func SetWorkspaceMemberRole(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actorID, workspaceID, cerr := getWorkspaceParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		memberID, cerr := GetParamUUID(c, "user_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req setWorkspaceMemberRoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		if cerr := app.WorkspaceService.SetMemberRole(c.Request.Context(), actorID, workspaceID, memberID, req.Role); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+func RemoveWorkspaceMember(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actorID, workspaceID, cerr := getWorkspaceParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		memberID, cerr := GetParamUUID(c, "user_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.WorkspaceService.RemoveMember(c.Request.Context(), actorID, workspaceID, memberID); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+type createWorkspaceInvitationRequest struct {
+	Email string `json:"email" binding:"omitempty,email,max=100"`
+	Role  string `json:"role" binding:"required"`
+}
+
+// createWorkspaceInvitationResponse is the only response that carries the invitation token itself
+type createWorkspaceInvitationResponse struct {
+	WorkspaceInvitationResponse
+	Token string `json:"token"`
+}
+
+func CreateWorkspaceInvitation(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actorID, workspaceID, cerr := getWorkspaceParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req createWorkspaceInvitationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		invitation, token, cerr := app.WorkspaceService.InviteMember(c.Request.Context(), actorID, workspaceID, req.Email, req.Role)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusCreated, createWorkspaceInvitationResponse{
+			WorkspaceInvitationResponse: newWorkspaceInvitationResponse(invitation),
+			Token:                       token,
+		})
+	}
+}
+
+func ListWorkspaceInvitations(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actorID, workspaceID, cerr := getWorkspaceParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		invitations, cerr := app.WorkspaceService.ListInvitations(c.Request.Context(), actorID, workspaceID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		resp := make([]WorkspaceInvitationResponse, 0, len(invitations))
+		for _, invitation := range invitations {
+			resp = append(resp, newWorkspaceInvitationResponse(invitation))
+		}
+		respondWithJSON(c, http.StatusOK, resp)
+	}
+}
+
+func RevokeWorkspaceInvitation(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actorID, workspaceID, cerr := getWorkspaceParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		invitationID, cerr := GetParamInt(c, "invitation_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		if cerr := app.WorkspaceService.RevokeInvitation(c.Request.Context(), actorID, workspaceID, int64(invitationID)); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+type acceptWorkspaceInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func AcceptWorkspaceInvitation(app *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var req acceptWorkspaceInvitationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		membership, cerr := app.WorkspaceService.AcceptInvitation(c.Request.Context(), userID, req.Token)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusOK, newWorkspaceResponse(membership))
+	}
+}
+
+func CreateWorkspaceArticle(app *app.Application) gin.HandlerFunc {
+	type Body struct {
+		URL string `json:"url" binding:"required,url"`
+	}
+
+	type Response struct {
+		ID  uuid.UUID `json:"id"`
+		URL string    `json:"url"`
+	}
+
+	return func(c *gin.Context) {
+		userID, workspaceID, cerr := getWorkspaceParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var body Body
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		art, cerr := app.ArticleService.CreateWorkspaceArticle(c.Request.Context(), userID, workspaceID, body.URL)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithJSON(c, http.StatusCreated, Response{ID: art.ID, URL: art.URL})
+	}
+}
+
+func ListWorkspaceArticles(app *app.Application) gin.HandlerFunc {
+	type Query struct {
+		After string `form:"after"`
+		Limit int    `form:"limit"`
+	}
+
+	type ArticleResponse struct {
+		ID            uuid.UUID `json:"id"`
+		URL           string    `json:"url"`
+		Title         string    `json:"title,omitempty"`
+		Description   string    `json:"description,omitempty"`
+		ImageURL      string    `json:"image_url,omitempty"`
+		AddedBy       uuid.UUID `json:"added_by"`
+		CollectedAt   time.Time `json:"collected_at"`
+		AverageRating float64   `json:"average_rating"`
+		RatingCount   int       `json:"rating_count"`
+		Rate          int16     `json:"rate"`
+	}
+
+	type Response struct {
+		Articles []ArticleResponse `json:"articles"`
+	}
+
+	return func(c *gin.Context) {
+		userID, workspaceID, cerr := getWorkspaceParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var query Query
+		if err := c.ShouldBindQuery(&query); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid query parameter")))
+			return
+		}
+
+		var afterID uuid.UUID
+		if query.After != "" {
+			var parseErr error
+			afterID, parseErr = uuid.Parse(query.After)
+			if parseErr != nil {
+				respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, parseErr, common.WithMsg("invalid after id")))
+				return
+			}
+		}
+
+		if query.Limit == 0 {
+			query.Limit = 10 // default limit
+		}
+
+		articles, cerr := app.ArticleService.ListWorkspaceArticles(c.Request.Context(), userID, workspaceID, afterID, query.Limit)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		resp := Response{
+			Articles: make([]ArticleResponse, 0, len(articles)),
+		}
+		for _, art := range articles {
+			resp.Articles = append(resp.Articles, ArticleResponse{
+				ID:            art.ID,
+				URL:           art.URL,
+				Title:         art.Title,
+				Description:   art.Description,
+				ImageURL:      art.ImageURL,
+				AddedBy:       art.AddedBy,
+				CollectedAt:   art.CollectedAt,
+				AverageRating: art.AverageRating,
+				RatingCount:   art.RatingCount,
+				Rate:          art.Rate,
+			})
+		}
+
+		respondWithJSON(c, http.StatusOK, resp)
+	}
+}
+
+func RateWorkspaceArticle(app *app.Application) gin.HandlerFunc {
+	type Body struct {
+		Rate int16 `json:"rate"`
+	}
+
+	return func(c *gin.Context) {
+		userID, workspaceID, cerr := getWorkspaceParams(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		articleID, cerr := GetParamUUID(c, "article_id")
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		var body Body
+		if err := c.ShouldBindJSON(&body); err != nil {
+			respondWithError(c, common.NewError(common.ErrorCodeParameterInvalid, err, common.WithMsg("invalid parameter")))
+			return
+		}
+
+		if cerr := app.ArticleService.RateWorkspaceArticle(c.Request.Context(), userID, workspaceID, articleID, body.Rate); cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+
+		respondWithoutBody(c, http.StatusNoContent)
+	}
+}
+
+// getWorkspaceParams gets the current user and the workspace of the URL
+func getWorkspaceParams(c *gin.Context) (uuid.UUID, uuid.UUID, common.Error) {
+	userID, cerr := GetCurrentUserID(c)
+	if cerr != nil {
+		return uuid.Nil, uuid.Nil, cerr
+	}
+	workspaceID, cerr := GetParamUUID(c, "workspace_id")
+	if cerr != nil {
+		return uuid.Nil, uuid.Nil, cerr
+	}
+	return userID, workspaceID, nil
+}
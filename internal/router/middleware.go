@@ -13,5 +13,16 @@ func SetGeneralMiddlewares(ctx context.Context, ginRouter *gin.Engine) {
 	ginRouter.Use(CORSMiddleware())
 	ginRouter.Use(requestid.New())
 	ginRouter.Use(LoggerMiddleware(ctx))
+	ginRouter.Use(AuditMiddleware())
 
 }
+
+// SetTrustedProxies makes gin take the client IP from the X-Forwarded-For header only when the
+// request comes from one of proxies. Without proxies the client IP is the address of the connection,
+// so that a client cannot choose the IP its failed logins are counted by.
+func SetTrustedProxies(ginRouter *gin.Engine, proxies []string) error {
+	if len(proxies) == 0 {
+		proxies = nil
+	}
+	return ginRouter.SetTrustedProxies(proxies)
+}
@@ -0,0 +1,23 @@
+package router
+
+import (
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+
+	"github.com/sappy5678/DeeliAi/internal/domain/audit"
+)
+
+// AuditMiddleware attributes the audit events recorded while handling a request to the request,
+// by its request ID, client IP and user agent.
+// It has to run after LoggerMiddleware, which replaces the context of the request.
+func AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := audit.WithRequest(c.Request.Context(), audit.Request{
+			ID:        requestid.Get(c),
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
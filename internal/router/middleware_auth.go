@@ -2,12 +2,14 @@ package router
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/sappy5678/DeeliAi/internal/app"
 	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
 )
 
 type AuthMiddlewareBearer struct {
@@ -15,7 +17,11 @@ type AuthMiddlewareBearer struct {
 }
 
 const (
-	ContextKeyUserID = "userID"
+	ContextKeyUserID   = "userID"
+	ContextKeyAPIKeyID = "apiKeyID"
+	ContextKeyUserRole = "userRole"
+	// ContextKeySessionID is the token family of the access token, which users see as a session
+	ContextKeySessionID = "sessionID"
 )
 
 func NewAuthMiddlewareBearer(app *app.Application) *AuthMiddlewareBearer {
@@ -24,7 +30,18 @@ func NewAuthMiddlewareBearer(app *app.Application) *AuthMiddlewareBearer {
 	}
 }
 
+// Required only accepts access tokens, which stand for a full login of the user.
 func (m *AuthMiddlewareBearer) Required() gin.HandlerFunc {
+	return m.authenticate("")
+}
+
+// Scoped accepts access tokens as well as API keys granted the scope.
+func (m *AuthMiddlewareBearer) Scoped(scope string) gin.HandlerFunc {
+	return m.authenticate(scope)
+}
+
+// authenticate validates the bearer token. API keys are only accepted if scope is set and granted to them.
+func (m *AuthMiddlewareBearer) authenticate(scope string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 
@@ -41,14 +58,83 @@ func (m *AuthMiddlewareBearer) Required() gin.HandlerFunc {
 			return
 		}
 
-		userID, cerr := m.app.UserService.ValidateToken(ctx, tokens[1])
+		if user.IsAPIKey(tokens[1]) {
+			if scope == "" {
+				msg := "API keys are not allowed for this endpoint"
+				respondWithError(c, common.NewError(common.ErrorCodeAuthPermissionDenied, errors.New(msg), common.WithMsg(msg)))
+				return
+			}
+
+			key, cerr := m.app.UserService.AuthenticateAPIKey(ctx, tokens[1])
+			if cerr != nil {
+				respondWithError(c, cerr)
+				return
+			}
+			if !key.HasScope(scope) {
+				msg := fmt.Sprintf("API key lacks the %s scope", scope)
+				respondWithError(c, common.NewError(common.ErrorCodeAuthPermissionDenied, errors.New(msg), common.WithMsg(msg)))
+				return
+			}
+
+			c.Set(ContextKeyUserID, key.UserID)
+			c.Set(ContextKeyAPIKeyID, key.ID)
+			c.Next()
+			return
+		}
+
+		claims, cerr := m.app.UserService.ValidateToken(ctx, tokens[1])
 		if cerr != nil {
 			respondWithError(c, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(cerr.Error()), common.WithMsg(cerr.ClientMsg())))
 			return
 		}
 
-		// Set user ID to context
-		c.Set(ContextKeyUserID, userID)
+		// Set user ID, role and session to context
+		c.Set(ContextKeyUserID, claims.UserID)
+		c.Set(ContextKeyUserRole, claims.Role)
+		c.Set(ContextKeySessionID, claims.FamilyID)
+		c.Next()
+	}
+}
+
+// RequireRole rejects users without the role carried by their access token.
+// It must be mounted after Required, API keys never carry a role.
+func (m *AuthMiddlewareBearer) RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(ContextKeyUserRole) != role {
+			msg := fmt.Sprintf("%s role is needed", role)
+			respondWithError(c, common.NewError(common.ErrorCodeAuthPermissionDenied, errors.New(msg), common.WithMsg(msg)))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// VerifiedEmail rejects users who have not confirmed their email yet, when the application requires it.
+// It must be mounted after Required.
+func (m *AuthMiddlewareBearer) VerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.app.Params.RequireVerifiedEmail {
+			c.Next()
+			return
+		}
+
+		userID, cerr := GetCurrentUserID(c)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		foundUser, cerr := m.app.UserService.GetUser(c.Request.Context(), userID)
+		if cerr != nil {
+			respondWithError(c, cerr)
+			return
+		}
+		if !foundUser.IsEmailVerified() {
+			msg := "email address is not verified"
+			respondWithError(c, common.NewError(common.ErrorCodeAuthPermissionDenied, errors.New(msg), common.WithMsg(msg)))
+			return
+		}
+
 		c.Next()
 	}
 }
@@ -13,7 +13,24 @@ import (
 	"github.com/rs/zerolog"
 )
 
-var sensitiveAPIs = map[string]bool{}
+var sensitiveAPIs = map[string]bool{
+	"/api/v1/user/signup":         true,
+	"/api/v1/user/login":          true,
+	"/api/v1/user/token/refresh":  true,
+	"/api/v1/user/logout":         true,
+	"/api/v1/user/password/reset": true,
+	"/api/v1/user/verify":         true,
+	"/api/v1/user/me/password":    true,
+	"/api/v1/user/me":             true,
+	"/api/v1/user/login/2fa":      true,
+	"/api/v1/user/2fa/enroll":     true,
+	"/api/v1/user/2fa/confirm":    true,
+	"/api/v1/user/2fa":            true,
+	// The invitation token lets anyone join the workspace
+	"/api/v1/workspaces/invitations/accept": true,
+	// Matched by route, the provider is part of the path
+	"/api/v1/user/oidc/:provider/callback": true,
+}
 
 // filterSensitiveAPI only returns `email` field for sensitive APIs
 func filterSensitiveAPI(path string, data []byte) []byte {
@@ -71,7 +88,8 @@ func LoggerMiddleware(rootCtx context.Context) gin.HandlerFunc {
 		if err := c.Errors.Last(); err != nil {
 			params.ErrorMessage = err.Error()
 		}
-		if raw != "" {
+		// The query of sensitive APIs may carry a token
+		if raw != "" && !sensitiveAPIs[path] && !sensitiveAPIs[c.FullPath()] {
 			path = path + "?" + raw
 		}
 		params.Path = path
@@ -98,7 +116,7 @@ func LoggerMiddleware(rootCtx context.Context) gin.HandlerFunc {
 			data := buf.Bytes()
 
 			// Try to filter request body if it's a sensitive API
-			data = filterSensitiveAPI(params.Path, data)
+			data = filterSensitiveAPI(c.FullPath(), data)
 
 			var jsonBuf bytes.Buffer
 			if err := json.Compact(&jsonBuf, data); err == nil {
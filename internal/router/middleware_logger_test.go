@@ -0,0 +1,46 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// loggedRequest sends a JSON body to path through LoggerMiddleware and returns what it logged
+func loggedRequest(path string, body string) string {
+	var out bytes.Buffer
+	ctx := zerolog.New(&out).WithContext(context.Background())
+
+	gin.SetMode(gin.TestMode)
+	ginRouter := gin.New()
+	ginRouter.Use(LoggerMiddleware(ctx))
+	ginRouter.POST(path, func(c *gin.Context) {
+		var req map[string]string
+		_ = c.ShouldBindJSON(&req)
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	ginRouter.ServeHTTP(httptest.NewRecorder(), req)
+	return out.String()
+}
+
+func TestLoggerMiddleware_SensitiveBody(t *testing.T) {
+	for _, path := range []string{"/api/v1/user/login", "/api/v1/user/signup"} {
+		logged := loggedRequest(path, `{"email": "user@example.com", "password": "correct horse battery staple"}`)
+		assert.Contains(t, logged, path)
+		assert.NotContains(t, logged, "correct horse battery staple", path)
+	}
+
+	// Bodies of other APIs are logged
+	logged := loggedRequest("/api/v1/articles", `{"url": "https://example.com"}`)
+	assert.Contains(t, logged, "https://example.com")
+}
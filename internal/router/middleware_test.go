@@ -0,0 +1,54 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clientIPOf sends a request from remoteAddr with a X-Forwarded-For header and returns the IP
+// the handlers pass on to login throttling
+func clientIPOf(t *testing.T, ginRouter *gin.Engine, remoteAddr string, forwardedFor string) string {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("X-Forwarded-For", forwardedFor)
+	w := httptest.NewRecorder()
+	ginRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+func newClientIPRouter(t *testing.T, proxies []string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	ginRouter := gin.New()
+	require.NoError(t, SetTrustedProxies(ginRouter, proxies))
+	ginRouter.POST("/login", func(c *gin.Context) {
+		c.String(http.StatusOK, GetClientDevice(c).IP)
+	})
+	return ginRouter
+}
+
+func TestSetTrustedProxies_NoProxies(t *testing.T) {
+	ginRouter := newClientIPRouter(t, nil)
+
+	// A forged header changes nothing, every attempt counts against the address of the connection
+	assert.Equal(t, "203.0.113.7", clientIPOf(t, ginRouter, "203.0.113.7:40000", "198.51.100.1"))
+	assert.Equal(t, "203.0.113.7", clientIPOf(t, ginRouter, "203.0.113.7:40001", "198.51.100.2"))
+}
+
+func TestSetTrustedProxies_KnownProxy(t *testing.T) {
+	ginRouter := newClientIPRouter(t, []string{"10.0.0.0/8"})
+
+	// The proxy reports the client it forwards for
+	assert.Equal(t, "198.51.100.1", clientIPOf(t, ginRouter, "10.0.0.2:40000", "198.51.100.1"))
+	// A client prepending a forged IP is still found behind the proxy
+	assert.Equal(t, "198.51.100.1", clientIPOf(t, ginRouter, "10.0.0.2:40000", "192.0.2.9, 198.51.100.1"))
+	// Other connections are not proxies
+	assert.Equal(t, "203.0.113.7", clientIPOf(t, ginRouter, "203.0.113.7:40000", "198.51.100.1"))
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/sappy5678/DeeliAi/internal/domain/common"
+	"github.com/sappy5678/DeeliAi/internal/domain/user"
 )
 
 const (
@@ -34,6 +35,25 @@ func GetCurrentUserID(c *gin.Context) (uuid.UUID, common.Error) {
 	return userID.(uuid.UUID), nil
 }
 
+// GetCurrentSessionID gets the session of the access token from gin context, API keys have none.
+func GetCurrentSessionID(c *gin.Context) (uuid.UUID, common.Error) {
+	sessionID, ok := c.Get(ContextKeySessionID)
+	if !ok {
+		msg := "session not found in context"
+		return uuid.Nil, common.NewError(common.ErrorCodeAuthNotAuthenticated, errors.New(msg), common.WithMsg(msg))
+	}
+
+	return sessionID.(uuid.UUID), nil
+}
+
+// GetClientDevice describes the client of the request, which is recorded with the session it logs in.
+func GetClientDevice(c *gin.Context) user.Device {
+	return user.Device{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
 // GetParamInt gets a key's value from Gin's URL param and transform it to int.
 func GetParamInt(c *gin.Context, key string) (int, common.Error) {
 	s := c.Param(key)
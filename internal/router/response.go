@@ -2,6 +2,7 @@ package router
 
 import (
 	"errors"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
@@ -31,6 +32,10 @@ func respondWithError(c *gin.Context, err error) {
 	ctx := c.Request.Context()
 	zerolog.Ctx(ctx).Error().Err(err).Str("component", "handler").Msg(errMessage.Message)
 	_ = c.Error(err)
+	// Throttled requests tell the client how many seconds to wait
+	if seconds, ok := errMessage.Detail["retry_after"].(int); ok {
+		c.Header("Retry-After", strconv.Itoa(seconds))
+	}
 	c.AbortWithStatusJSON(errMessage.Code, errMessage)
 }
 
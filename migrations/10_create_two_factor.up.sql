@@ -0,0 +1,31 @@
+-- Table: user_totp
+CREATE TABLE user_totp (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL, -- base32 encoded TOTP secret
+    confirmed_at TIMESTAMP WITH TIME ZONE, -- two-factor authentication is enabled once confirmed
+    last_used_step BIGINT DEFAULT 0 NOT NULL, -- time step of the last accepted code, against replays
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Table: recovery_codes
+CREATE TABLE recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL, -- hex encoded sha256 of the recovery code
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    UNIQUE (user_id, code_hash)
+);
+
+-- Table: login_challenges
+CREATE TABLE login_challenges (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL, -- hex encoded sha256 of the challenge token
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Index for login_challenges
+CREATE INDEX idx_login_challenges_user_id ON login_challenges (user_id);
@@ -0,0 +1,25 @@
+-- Table: user_identities
+CREATE TABLE user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL, -- the name the provider is configured with
+    subject VARCHAR(255) NOT NULL, -- the `sub` claim of the provider
+    email VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    UNIQUE (provider, subject)
+);
+
+-- Index for user_identities
+CREATE INDEX idx_user_identities_user_id ON user_identities (user_id);
+
+-- Table: oidc_states
+CREATE TABLE oidc_states (
+    id BIGSERIAL PRIMARY KEY,
+    state_hash VARCHAR(64) UNIQUE NOT NULL, -- hex encoded sha256 of the state parameter
+    provider VARCHAR(50) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL, -- PKCE code verifier
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
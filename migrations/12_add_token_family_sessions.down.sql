@@ -0,0 +1,3 @@
+ALTER TABLE token_families DROP COLUMN IF EXISTS last_seen_at;
+ALTER TABLE token_families DROP COLUMN IF EXISTS ip_address;
+ALTER TABLE token_families DROP COLUMN IF EXISTS user_agent;
@@ -0,0 +1,4 @@
+-- Existing token families have no known device, they are shown with empty values
+ALTER TABLE token_families ADD COLUMN user_agent VARCHAR(512) DEFAULT '' NOT NULL;
+ALTER TABLE token_families ADD COLUMN ip_address VARCHAR(45) DEFAULT '' NOT NULL;
+ALTER TABLE token_families ADD COLUMN last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS workspace_article_ratings;
+DROP TABLE IF EXISTS workspace_articles;
+DROP TABLE IF EXISTS workspace_invitations;
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
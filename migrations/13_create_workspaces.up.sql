@@ -0,0 +1,58 @@
+-- Table: workspaces
+CREATE TABLE workspaces (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Table: workspace_members
+CREATE TABLE workspace_members (
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    joined_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+-- Index for workspace_members
+CREATE INDEX idx_workspace_members_user_id ON workspace_members (user_id);
+
+-- Table: workspace_invitations
+CREATE TABLE workspace_invitations (
+    id BIGSERIAL PRIMARY KEY,
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    email VARCHAR(100) DEFAULT '' NOT NULL, -- empty for invitations shared as a link
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    token_hash VARCHAR(64) UNIQUE NOT NULL, -- hex encoded sha256 of the token
+    invited_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Index for workspace_invitations
+CREATE INDEX idx_workspace_invitations_workspace_id ON workspace_invitations (workspace_id);
+
+-- Table: workspace_articles
+CREATE TABLE workspace_articles (
+    id BIGSERIAL PRIMARY KEY,
+    workspace_id UUID NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    added_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    collected_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    UNIQUE (workspace_id, article_id)
+);
+
+-- Index for workspace_articles
+CREATE INDEX idx_workspace_articles_article_id ON workspace_articles (article_id);
+
+-- Table: workspace_article_ratings, one rating of every member per workspace article
+CREATE TABLE workspace_article_ratings (
+    workspace_id UUID NOT NULL,
+    article_id UUID NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rate SMALLINT NOT NULL CHECK (rate BETWEEN 1 AND 5),
+    rated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (workspace_id, article_id, user_id),
+    FOREIGN KEY (workspace_id, article_id) REFERENCES workspace_articles (workspace_id, article_id) ON DELETE CASCADE
+);
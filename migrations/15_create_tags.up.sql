@@ -0,0 +1,21 @@
+-- Table: tags
+CREATE TABLE tags (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Index for tags, names are unique per user ignoring case
+CREATE UNIQUE INDEX idx_tags_user_name ON tags (user_id, LOWER(name));
+
+-- Table: user_article_tags
+-- Tags are put on saved articles, so they go away with the saved article
+CREATE TABLE user_article_tags (
+    user_article_id BIGINT NOT NULL REFERENCES user_articles(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_article_id, tag_id)
+);
+
+-- Index for user_article_tags
+CREATE INDEX idx_user_article_tags_tag_id ON user_article_tags (tag_id);
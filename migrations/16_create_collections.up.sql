@@ -0,0 +1,24 @@
+-- Table: collections
+CREATE TABLE collections (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Index for collections, names are unique per user ignoring case
+CREATE UNIQUE INDEX idx_collections_user_name ON collections (user_id, LOWER(name));
+
+-- Table: collection_articles
+-- Deleting a collection only removes its memberships, the saved articles stay
+CREATE TABLE collection_articles (
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    user_article_id BIGINT NOT NULL REFERENCES user_articles(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    added_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (collection_id, user_article_id)
+);
+
+-- Indexes for collection_articles
+CREATE INDEX idx_collection_articles_position ON collection_articles (collection_id, position, user_article_id);
+CREATE INDEX idx_collection_articles_user_article_id ON collection_articles (user_article_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS article_highlights;
+DROP TABLE IF EXISTS article_notes;
@@ -0,0 +1,29 @@
+-- Table: article_notes
+-- Notes and highlights belong to a saved article, so they go away with the saved article
+CREATE TABLE article_notes (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    user_article_id BIGINT NOT NULL REFERENCES user_articles(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Index for article_notes, also finds the latest note of an article
+CREATE INDEX idx_article_notes_user_article_id ON article_notes (user_article_id, updated_at);
+
+-- Table: article_highlights
+-- start_offset and end_offset are both set or both NULL
+CREATE TABLE article_highlights (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    user_article_id BIGINT NOT NULL REFERENCES user_articles(id) ON DELETE CASCADE,
+    text TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    start_offset INTEGER,
+    end_offset INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CHECK ((start_offset IS NULL) = (end_offset IS NULL))
+);
+
+-- Index for article_highlights
+CREATE INDEX idx_article_highlights_user_article_id ON article_highlights (user_article_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_user_articles_user_id_state;
+
+ALTER TABLE user_articles
+    DROP COLUMN IF EXISTS archived_at,
+    DROP COLUMN IF EXISTS read_at,
+    DROP COLUMN IF EXISTS state;
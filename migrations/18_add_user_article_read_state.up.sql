@@ -0,0 +1,8 @@
+-- Read state of saved articles: unread -> read -> archived
+ALTER TABLE user_articles
+    ADD COLUMN state VARCHAR(10) NOT NULL DEFAULT 'unread' CHECK (state IN ('unread', 'read', 'archived')),
+    ADD COLUMN read_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN archived_at TIMESTAMP WITH TIME ZONE;
+
+-- Index for filtering and counting the saved articles of a user by state
+CREATE INDEX idx_user_articles_user_id_state ON user_articles (user_id, state);
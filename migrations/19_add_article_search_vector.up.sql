@@ -0,0 +1,6 @@
+-- Full-text search of articles. The lexemes are split by the application, which also handles Chinese,
+-- so the vector is NULL until the article is indexed by the metadata worker.
+ALTER TABLE articles ADD COLUMN search_vector TSVECTOR;
+
+-- Index for searching articles
+CREATE INDEX idx_articles_search_vector ON articles USING GIN (search_vector);
@@ -0,0 +1,8 @@
+-- Embeddings of articles for semantic search, of the title and description by the model named in model.
+-- Embeddings have unit length, so their dot product is their cosine similarity.
+CREATE TABLE article_embeddings (
+    article_id UUID PRIMARY KEY REFERENCES articles(id) ON DELETE CASCADE,
+    model VARCHAR(50) NOT NULL,
+    embedding REAL[] NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_user_articles_user_id_collected_at;
+DROP INDEX IF EXISTS idx_articles_domain;
+
+ALTER TABLE articles DROP COLUMN IF EXISTS domain;
@@ -0,0 +1,9 @@
+-- The domain articles come from, the host of the URL without www., for filtering and sorting saved articles
+ALTER TABLE articles
+    ADD COLUMN domain TEXT GENERATED ALWAYS AS (LOWER(SUBSTRING(url FROM '^[a-zA-Z][a-zA-Z0-9+.-]*://(?:www\.)?([^/:?#]+)'))) STORED;
+
+-- Index for filtering articles by domain
+CREATE INDEX idx_articles_domain ON articles (domain);
+
+-- Index for the default order of saved articles, the latest saved first
+CREATE INDEX idx_user_articles_user_id_collected_at ON user_articles (user_id, collected_at);
@@ -0,0 +1,25 @@
+-- Table: article_imports
+-- Jobs importing articles from files in the background. items holds the articles of the file until the job is done,
+-- processed is how many of them are handled, so a job resumes where it stopped.
+CREATE TABLE article_imports (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    format VARCHAR(20) NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+    items JSONB NOT NULL,
+    total INTEGER NOT NULL,
+    processed INTEGER NOT NULL DEFAULT 0,
+    imported INTEGER NOT NULL DEFAULT 0,
+    duplicates INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    errors JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    CHECK (status IN ('pending', 'running', 'done'))
+);
+
+-- Index for article_imports, the worker picks the oldest unfinished job
+CREATE INDEX idx_article_imports_unfinished ON article_imports (created_at) WHERE status <> 'done';
+CREATE INDEX idx_article_imports_user_id ON article_imports (user_id);
@@ -0,0 +1,8 @@
+-- Keys are published before they sign tokens, so verifiers can fetch them in time
+ALTER TABLE signing_keys
+    ADD COLUMN activates_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE signing_keys SET activates_at = created_at;
+
+ALTER TABLE signing_keys
+    ALTER COLUMN activates_at SET NOT NULL;
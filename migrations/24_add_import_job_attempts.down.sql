@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_article_imports_unfinished;
+CREATE INDEX idx_article_imports_unfinished ON article_imports (created_at) WHERE status <> 'done';
+
+UPDATE article_imports SET status = 'done', items = '[]' WHERE status = 'failed';
+
+ALTER TABLE article_imports
+    DROP CONSTRAINT article_imports_status_check,
+    ADD CONSTRAINT article_imports_status_check CHECK (status IN ('pending', 'running', 'done'));
+
+ALTER TABLE article_imports
+    DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,10 @@
+-- Import jobs count the runs which claimed them, and fail once they were claimed too often
+ALTER TABLE article_imports
+    ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE article_imports
+    DROP CONSTRAINT article_imports_status_check,
+    ADD CONSTRAINT article_imports_status_check CHECK (status IN ('pending', 'running', 'done', 'failed'));
+
+DROP INDEX IF EXISTS idx_article_imports_unfinished;
+CREATE INDEX idx_article_imports_unfinished ON article_imports (created_at) WHERE status IN ('pending', 'running');
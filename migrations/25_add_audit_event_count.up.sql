@@ -0,0 +1,3 @@
+-- Events which happen a lot, like rejected access tokens, are counted together and written as one
+ALTER TABLE audit_events
+    ADD COLUMN count INTEGER NOT NULL DEFAULT 1 CHECK (count > 0);
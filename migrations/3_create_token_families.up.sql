@@ -0,0 +1,23 @@
+-- Table: token_families
+CREATE TABLE token_families (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Index for token_families
+CREATE INDEX idx_token_families_user_id ON token_families (user_id);
+
+-- Table: refresh_tokens
+CREATE TABLE refresh_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES token_families(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL, -- hex encoded sha256 of the refresh token
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    rotated_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Index for refresh_tokens
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
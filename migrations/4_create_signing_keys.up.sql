@@ -0,0 +1,11 @@
+-- Table: signing_keys
+CREATE TABLE signing_keys (
+    id VARCHAR(64) PRIMARY KEY, -- the JWS kid
+    algorithm VARCHAR(16) NOT NULL, -- RS256 or EdDSA
+    private_key TEXT NOT NULL, -- PKCS #8 PEM
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Index for signing_keys
+CREATE INDEX idx_signing_keys_expires_at ON signing_keys (expires_at);
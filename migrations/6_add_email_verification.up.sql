@@ -0,0 +1,16 @@
+-- Existing users start unverified, they can ask for a new confirmation mail
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+
+-- Table: email_verification_tokens
+CREATE TABLE email_verification_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(100) NOT NULL, -- the address the token was sent to
+    token_hash VARCHAR(64) UNIQUE NOT NULL, -- hex encoded sha256 of the verification token
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Index for email_verification_tokens
+CREATE INDEX idx_email_verification_tokens_user_id ON email_verification_tokens (user_id);
@@ -0,0 +1,14 @@
+-- Table: api_keys
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL, -- the first characters of the key, so users can tell keys apart
+    key_hash VARCHAR(64) UNIQUE NOT NULL, -- hex encoded sha256 of the key
+    scopes TEXT[] NOT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Index for api_keys
+CREATE INDEX idx_api_keys_user_id ON api_keys (user_id);
@@ -0,0 +1,10 @@
+-- Table: login_attempts
+CREATE TABLE login_attempts (
+    key VARCHAR(255) PRIMARY KEY, -- "account:<email>" or "ip:<address>"
+    failures INTEGER DEFAULT 0 NOT NULL,
+    last_failed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE
+);
+
+-- Index for login_attempts
+CREATE INDEX idx_login_attempts_last_failed_at ON login_attempts (last_failed_at);